@@ -1,50 +1,228 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
-	"github.com/getground/tech-tasks/backend/pkg/controller"
+	"github.com/getground/tech-tasks/backend/pkg/config"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/importer"
 	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/server"
 	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
 	"github.com/gin-gonic/gin"
-	_ "github.com/go-sql-driver/mysql"
 )
 
-var (
-	tableRepository repository.TableRepository = repository.NewTableRepository()
-	guestRepository repository.GuestRepository = repository.NewGuestRepository()
+const usage = `Usage:
+  app [serve] [flags]       start the server
+  app config print [flags]  print the effective configuration with secrets redacted
+  app import [import flags] FILE [flags]
+                            add the guest list in a CSV or XLSX file, all of it or none of it
+  app ledger check [-event ID] [-- flags]
+                            compare the guests and tables with the ones the ledger projects
+  app ledger rebuild [-event ID] [-- flags]
+                            rebuild the guests and tables from the ledger
 
-	tableService service.TableService = service.NewTableService(tableRepository)
-	guestService service.GuestService = service.NewGuestService(guestRepository, tableRepository)
-
-	tableController controller.TableController = controller.NewTableController(tableService)
-	guestController controller.GuestController = controller.NewGuestController(guestService)
-)
+Run "app serve -h" to see the flags.`
 
 func main() {
+	args := os.Args[1:]
+
+	command := "serve"
+	if len(args) > 0 && (args[0] == "serve" || args[0] == "config" || args[0] == "import" || args[0] == "ledger") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve(args)
+	case "config":
+		if len(args) == 0 || args[0] != "print" {
+			log.Fatal(usage)
+		}
+		printConfig(args[1:])
+	case "import":
+		importGuests(args)
+	case "ledger":
+		if len(args) == 0 || (args[0] != "check" && args[0] != "rebuild") {
+			log.Fatal(usage)
+		}
+		replayLedger(args[0], args[1:])
+	}
+}
 
-	// Initializes an instance of the gin engine with logger and recovery functions
-	router := gin.Default()
+// Loads the configuration from the arguments and the environment, stopping the program if it is invalid
+func loadConfig(args []string) config.Config {
+	cfg, err := config.Load(args, os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
 
-	// test ping
-	router.GET("/ping", controller.HandlerPing)
+func printConfig(args []string) {
+	fmt.Print(loadConfig(args).Redacted())
+}
 
-	// Specifying routes
-	// Before Party
+func openStore(cfg config.Config) repository.Store {
+	store, err := repository.NewStore(cfg.Database.Driver, cfg.Database.DSN, cfg.GormLogLevel())
+	if err != nil {
+		log.Fatal("Could not open the store: ", err)
+	}
+	return store
+}
 
-	router.GET("/tables", tableController.GetTables)
-	router.GET("/tables/:id", tableController.GetATable)
-	router.POST("/tables", tableController.CreateTable)
+func serve(args []string) {
+	cfg := loadConfig(args)
 
-	router.GET("/guest_list", guestController.GetGuests)
-	router.POST("/guest_list/:name", guestController.CreateGuest)
+	if cfg.LogLevel == config.LogInfo {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
-	//During Party
-	router.GET("/guests", guestController.GetArrivedGuests)
-	router.GET("/seats_empty", tableController.GetSpace)
-	router.PUT("/guests/:name", guestController.Checkin)
-	router.DELETE("/guests/:name", guestController.Checkout)
+	store := openStore(cfg)
+
+	// The server runs until the process ends
+	router, err := server.New(context.Background(), store, cfg)
+	if err != nil {
+		log.Fatal("Could not start the server: ", err)
+	}
 
 	// Specifies what port the server will listen and answer on
-	http.ListenAndServe(":4000", router)
+	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(cfg.Port), router))
+}
+
+// Collects the repeated -column field=header flags
+type columnFlag map[string]string
+
+func (f columnFlag) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f columnFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return errors.New("must be column=header, e.g. name=Full name")
+	}
+	f[parts[0]] = parts[1]
+	return nil
+}
+
+// Imports the guest list in a file the same way POST /guest_list does, then prints the report.
+// The flags after the file are the server's, so the import goes into the same database.
+func importGuests(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	eventId := fs.Int("event", 0, "event the guests are added to, the default event when left out")
+	dryRun := fs.Bool("dry-run", false, "check every row and report the errors without adding any guest")
+	format := fs.String("format", "", "csv or xlsx, worked out from the file when left out")
+	columns := columnFlag{}
+	fs.Var(columns, "column", "the header of a column in the file, e.g. name=Full name, can be repeated")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		log.Fatal(usage)
+	}
+
+	path := fs.Arg(0)
+	cfg := loadConfig(fs.Args()[1:])
+	if *eventId == 0 {
+		*eventId = cfg.Events.DefaultEventId
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format == "" {
+		*format = importer.DetectFormat("", data)
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			*format = importer.FormatCSV
+		}
+	}
+
+	cells, err := importer.Read(*format, bytes.NewReader(data))
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows, err := importer.Guests(cells, columns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store := openStore(cfg)
+	// The server creates the default event when it starts, so the guests can be imported into it before it ever has
+	if *eventId == cfg.Events.DefaultEventId {
+		eventService := service.NewEventService(store.Events, service.EventOptions{OverbookAllowance: cfg.Guests.OverbookAllowance})
+		if err := eventService.EnsureExists(*eventId); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := validation.NewGuestValidator(store.Tables).ValidateRows(*eventId, rows); err != nil {
+		log.Fatal(err)
+	}
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, service.GuestOptions{
+		NamePolicy: cfg.Guests.NamePolicy,
+	})
+
+	// The audit log records the guests as added from the command line, there is no API key to name
+	res, err := guestService.Import(*eventId, rows, *dryRun, service.Actor{Name: "cli"})
+	var failed *service.ImportError
+	if errors.As(err, &failed) {
+		res = failed.Report
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	out, _ := json.MarshalIndent(res, "", "    ")
+	fmt.Println(string(out))
+
+	// Scripts can tell from the exit code that some rows were not valid
+	if len(res.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Checks the guests and tables of an event against the ledger, or rebuilds them from it, then prints what differed.
+// The flags after -- are the server's, so the same database is used.
+func replayLedger(command string, args []string) {
+	fs := flag.NewFlagSet("ledger "+command, flag.ExitOnError)
+	eventId := fs.Int("event", 0, "event to check or rebuild, the default event when left out")
+	fs.Parse(args)
+
+	cfg := loadConfig(fs.Args())
+	if *eventId == 0 {
+		*eventId = cfg.Events.DefaultEventId
+	}
+
+	ledgerService := service.NewLedgerService(openStore(cfg).UnitOfWork)
+	var res dto.LedgerCheckResDto
+	var err error
+	if command == "rebuild" {
+		res, err = ledgerService.Rebuild(*eventId)
+	} else {
+		res, err = ledgerService.Check(*eventId)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, _ := json.MarshalIndent(res, "", "    ")
+	fmt.Println(string(out))
+
+	// Scripts can tell from the exit code that the rows had drifted from the ledger
+	if command == "check" && !res.Consistent {
+		os.Exit(1)
+	}
 }
@@ -5,14 +5,19 @@ go 1.16
 require (
 	github.com/gin-gonic/gin v1.8.2
 	github.com/go-playground/locales v0.14.1 // indirect
-	github.com/go-sql-driver/mysql v1.7.0
+	github.com/go-playground/validator/v10 v10.11.1
 	github.com/goccy/go-json v0.10.0 // indirect
 	github.com/mattn/go-isatty v0.0.17 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/pelletier/go-toml/v2 v2.0.6
 	github.com/stretchr/testify v1.8.1
 	github.com/ugorji/go/codec v1.2.8 // indirect
 	golang.org/x/crypto v0.5.0 // indirect
+	golang.org/x/net v0.5.0
+	golang.org/x/text v0.6.0
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/mysql v1.4.5
+	gorm.io/driver/sqlite v1.4.4
 	gorm.io/gorm v1.24.3
 )
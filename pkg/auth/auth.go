@@ -0,0 +1,201 @@
+// The auth package tells who is calling the server and what they are allowed to do.
+//
+// Staff authenticate with an API key, sent in the X-API-Key header, or with a session token that an API key was exchanged for,
+// sent as "Authorization: Bearer <token>". Session tokens are JWTs signed with HS256, so they can be checked without storing them.
+// Every key and session carries one of the roles, the routes say which roles may call them.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The roles of the staff
+const (
+	// Runs the event, may change the tables and the guest list
+	RoleOrganiser = "organiser"
+	// Checks guests in and out at the door
+	RoleDoorStaff = "door_staff"
+	// Reads the guest list and the exports
+	RoleCaterer = "caterer"
+)
+
+var Roles = []string{RoleOrganiser, RoleDoorStaff, RoleCaterer}
+
+// How a caller authenticated
+const (
+	MethodAPIKey  = "api_key"
+	MethodSession = "session"
+)
+
+// Returned when the request has no credentials, or ones that are not valid or have expired
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Returned when the caller's role may not call the route
+var ErrForbidden = errors.New("the role may not do this")
+
+// A key the staff authenticate with
+type APIKey struct {
+	// Tells the keys apart, it is the subject of the sessions the key opens
+	Name string
+	Role string
+	Key  string
+}
+
+// Who is calling
+type Principal struct {
+	// The name of the API key, or the one the session was opened with
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	Method  string `json:"method"`
+}
+
+type Options struct {
+	Keys []APIKey
+	// Signs the session tokens
+	Secret string
+	// How long a session token is valid for
+	SessionTTL time.Duration
+	// The clock the sessions expire by, left nil it is time.Now
+	Now func() time.Time
+}
+
+type Authenticator struct {
+	keys   []APIKey
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func New(opts Options) *Authenticator {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Authenticator{
+		keys:   opts.Keys,
+		secret: []byte(opts.Secret),
+		ttl:    opts.SessionTTL,
+		now:    opts.Now,
+	}
+}
+
+// Finds out who sent the request, from its API key or its session token
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.apiKey(key)
+	}
+
+	header := r.Header.Get("Authorization")
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return a.session(strings.TrimSpace(header[len("Bearer "):]))
+	}
+
+	return Principal{}, fmt.Errorf("%w: send an X-API-Key header or a bearer token", ErrUnauthenticated)
+}
+
+// Every key is compared, in constant time, so the time taken does not give away how much of a key was right
+func (a *Authenticator) apiKey(key string) (Principal, error) {
+	var found *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(a.keys[i].Key), []byte(key)) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	if found == nil {
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	return Principal{Subject: found.Name, Role: found.Role, Method: MethodAPIKey}, nil
+}
+
+// The claims of a session token
+type claims struct {
+	// The name of the API key the session was opened with
+	Subject string `json:"sub"`
+	// The role when the session was opened, the key's current role is the one that counts
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// The header of every session token
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Opens a session for the caller, the token is valid until the time returned
+func (a *Authenticator) Issue(p Principal) (string, time.Time, error) {
+	issued := a.now().UTC().Truncate(time.Second)
+	expires := issued.Add(a.ttl)
+
+	payload, err := json.Marshal(claims{Subject: p.Subject, Role: p.Role, IssuedAt: issued.Unix(), ExpiresAt: expires.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + a.sign(unsigned), expires, nil
+}
+
+func (a *Authenticator) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Checks the token's signature and expiry. Only the header this package writes is accepted, so a token can not pick another algorithm.
+func (a *Authenticator) session(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return Principal{}, fmt.Errorf("%w: the token is not a session token", ErrUnauthenticated)
+	}
+	if !hmac.Equal([]byte(a.sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return Principal{}, fmt.Errorf("%w: the token's signature is not valid", ErrUnauthenticated)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: the token can not be read", ErrUnauthenticated)
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Principal{}, fmt.Errorf("%w: the token can not be read", ErrUnauthenticated)
+	}
+	if a.now().Unix() >= c.ExpiresAt {
+		return Principal{}, fmt.Errorf("%w: the session has expired", ErrUnauthenticated)
+	}
+
+	// The key is looked up on every request, so a session ends with its key and takes on the key's current role
+	key := a.key(c.Subject)
+	if key == nil {
+		return Principal{}, fmt.Errorf("%w: the key the session was opened with no longer exists", ErrUnauthenticated)
+	}
+
+	return Principal{Subject: key.Name, Role: key.Role, Method: MethodSession}, nil
+}
+
+// The API key with the name, nil when there is none
+func (a *Authenticator) key(name string) *APIKey {
+	for i := range a.keys {
+		if a.keys[i].Name == name {
+			return &a.keys[i]
+		}
+	}
+	return nil
+}
+
+// Reports whether the principal's role is one of the roles
+func (p Principal) Allowed(roles ...string) bool {
+	for _, v := range roles {
+		if p.Role == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,431 @@
+// The config package loads the server's settings.
+//
+// Every setting has a default and can be changed, from lowest to highest precedence, by:
+//   - the config file, YAML (.yaml/.yml) or TOML (.toml), given with -config or CONFIG_FILE
+//   - an environment variable
+//   - a command-line flag
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/auth"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm/logger"
+)
+
+// The effective configuration of the server
+type Config struct {
+	Port     int            `yaml:"port" toml:"port"`
+	LogLevel string         `yaml:"log_level" toml:"log_level"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Events   EventConfig    `yaml:"events" toml:"events"`
+	Guests   GuestConfig    `yaml:"guests" toml:"guests"`
+	Features FeatureConfig  `yaml:"features" toml:"features"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Webhooks WebhookConfig  `yaml:"webhooks" toml:"webhooks"`
+}
+
+type DatabaseConfig struct {
+	// mysql, sqlite or memory
+	Driver string `yaml:"driver" toml:"driver"`
+	// Left empty the default for the driver is used
+	DSN string `yaml:"dsn" toml:"dsn"`
+}
+
+type EventConfig struct {
+	// The event that the routes outside /events/:eventId work against, it is created when it does not exist
+	DefaultEventId int `yaml:"default_event_id" toml:"default_event_id"`
+}
+
+type GuestConfig struct {
+	// The percentage of extra seats that can be booked on each table for guests who may not show up, new events start with this
+	OverbookAllowance int `yaml:"overbook_allowance" toml:"overbook_allowance"`
+	// Whether two guests of an event can have the same name: unique, unique_ignore_case or allow_duplicates
+	NamePolicy string `yaml:"name_policy" toml:"name_policy"`
+	// Whether guests who are not on the guest list are given a table at the door: allow or deny
+	WalkIns string `yaml:"walk_ins" toml:"walk_ins"`
+	// The most walk-ins each event takes, 0 for no limit
+	MaxWalkIns int `yaml:"max_walk_ins" toml:"max_walk_ins"`
+}
+
+// Optional subsystems that can be switched on or off
+type FeatureConfig struct {
+	// Logs a line for every HTTP request
+	RequestLogging bool `yaml:"request_logging" toml:"request_logging"`
+}
+
+// Who may call the server. Left off every route is open to anyone who can reach it.
+type AuthConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Signs the session tokens, at least 32 characters long
+	SigningKey string `yaml:"signing_key" toml:"signing_key"`
+	// How long a session token is valid for
+	SessionMinutes int            `yaml:"session_minutes" toml:"session_minutes"`
+	APIKeys        []APIKeyConfig `yaml:"api_keys" toml:"api_keys"`
+}
+
+type APIKeyConfig struct {
+	Name string `yaml:"name" toml:"name"`
+	// organiser, door_staff or caterer
+	Role string `yaml:"role" toml:"role"`
+	// At least 16 characters long
+	Key string `yaml:"key" toml:"key"`
+}
+
+// How the webhook deliveries are sent
+type WebhookConfig struct {
+	// Sends the queued deliveries, left off they are still queued and sent once it is on
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// The attempts a delivery gets before it is given up on
+	MaxAttempts int `yaml:"max_attempts" toml:"max_attempts"`
+	// How long the first retry waits, every retry after it waits twice as long up to an hour
+	RetrySeconds int `yaml:"retry_seconds" toml:"retry_seconds"`
+	// How long a receiver has to answer
+	TimeoutSeconds int `yaml:"timeout_seconds" toml:"timeout_seconds"`
+}
+
+// The log levels, they are passed on to GORM
+const (
+	LogSilent = "silent"
+	LogError  = "error"
+	LogWarn   = "warn"
+	LogInfo   = "info"
+)
+
+var logLevels = map[string]logger.LogLevel{
+	LogSilent: logger.Silent,
+	LogError:  logger.Error,
+	LogWarn:   logger.Warn,
+	LogInfo:   logger.Info,
+}
+
+// The drivers the repository package can open
+var drivers = []string{repository.DriverMySQL, repository.DriverSQLite, repository.DriverMemory}
+
+// The settings used when nothing else is given
+func Default() Config {
+	return Config{
+		Port:     4000,
+		LogLevel: LogInfo,
+		Database: DatabaseConfig{
+			Driver: repository.DriverMySQL,
+		},
+		Events: EventConfig{
+			DefaultEventId: 1,
+		},
+		Guests: GuestConfig{
+			NamePolicy: service.NamePolicyUnique,
+			WalkIns:    service.WalkInsAllow,
+		},
+		Features: FeatureConfig{
+			RequestLogging: true,
+		},
+		Auth: AuthConfig{
+			SessionMinutes: 720,
+		},
+		Webhooks: WebhookConfig{
+			Enabled:        true,
+			MaxAttempts:    8,
+			RetrySeconds:   30,
+			TimeoutSeconds: 10,
+		},
+	}
+}
+
+// A setting that can be changed by an environment variable and a flag
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"PORT", "port", "port the server listens on", setInt(func(c *Config) *int { return &c.Port })},
+	{"LOG_LEVEL", "log-level", "silent, error, warn or info", setString(func(c *Config) *string { return &c.LogLevel })},
+	{"DB_DRIVER", "db-driver", "storage backend: mysql, sqlite or memory", setString(func(c *Config) *string { return &c.Database.Driver })},
+	{"DB_DSN", "db-dsn", "data source name of the database", setString(func(c *Config) *string { return &c.Database.DSN })},
+	{"DEFAULT_EVENT_ID", "default-event-id", "event used by the routes outside /events/:eventId", setInt(func(c *Config) *int { return &c.Events.DefaultEventId })},
+	{"OVERBOOK_ALLOWANCE", "overbook-allowance", "percentage of extra seats that can be booked on each table", setInt(func(c *Config) *int { return &c.Guests.OverbookAllowance })},
+	{"GUEST_NAME_POLICY", "guest-name-policy", "unique, unique_ignore_case or allow_duplicates", setString(func(c *Config) *string { return &c.Guests.NamePolicy })},
+	{"WALK_INS", "walk-ins", "allow or deny guests who are not on the guest list at the door", setString(func(c *Config) *string { return &c.Guests.WalkIns })},
+	{"MAX_WALK_INS", "max-walk-ins", "most walk-ins each event takes, 0 for no limit", setInt(func(c *Config) *int { return &c.Guests.MaxWalkIns })},
+	{"REQUEST_LOGGING", "request-logging", "log every HTTP request", setBool(func(c *Config) *bool { return &c.Features.RequestLogging })},
+	{"AUTH_ENABLED", "auth", "require an API key or session token on every route", setBool(func(c *Config) *bool { return &c.Auth.Enabled })},
+	{"SESSION_SIGNING_KEY", "session-signing-key", "key the session tokens are signed with", setString(func(c *Config) *string { return &c.Auth.SigningKey })},
+	{"SESSION_MINUTES", "session-minutes", "minutes a session token is valid for", setInt(func(c *Config) *int { return &c.Auth.SessionMinutes })},
+	{"API_KEYS", "api-keys", "comma separated name:role:key API keys, replacing those of the config file", setAPIKeys},
+	{"WEBHOOKS_ENABLED", "webhooks", "send the queued webhook deliveries", setBool(func(c *Config) *bool { return &c.Webhooks.Enabled })},
+	{"WEBHOOK_MAX_ATTEMPTS", "webhook-max-attempts", "attempts a webhook delivery gets before it is given up on", setInt(func(c *Config) *int { return &c.Webhooks.MaxAttempts })},
+	{"WEBHOOK_RETRY_SECONDS", "webhook-retry-seconds", "seconds before the first retry of a webhook delivery, doubled on every retry", setInt(func(c *Config) *int { return &c.Webhooks.RetrySeconds })},
+	{"WEBHOOK_TIMEOUT_SECONDS", "webhook-timeout-seconds", "seconds a webhook receiver has to answer", setInt(func(c *Config) *int { return &c.Webhooks.TimeoutSeconds })},
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// Reads name:role:key,name:role:key, the key is everything after the second colon
+func setAPIKeys(c *Config, value string) error {
+	var keys []APIKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("%q is not name:role:key", entry)
+		}
+		keys = append(keys, APIKeyConfig{Name: parts[0], Role: parts[1], Key: parts[2]})
+	}
+	c.Auth.APIKeys = keys
+	return nil
+}
+
+// Builds the configuration from the defaults, the config file, the environment and the command-line arguments, then validates it
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	file := fs.String("config", "", "path to a YAML or TOML config file")
+	values := make(map[string]*string)
+	for _, s := range settings {
+		values[s.flag] = fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *file == "" {
+		*file = getenv("CONFIG_FILE")
+	}
+	if *file != "" {
+		if err := loadFile(&cfg, *file); err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(&cfg, value); err != nil {
+				return cfg, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Only the flags that were actually given override the other sources
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && err == nil {
+				if setErr := s.set(&cfg, *values[s.flag]); setErr != nil {
+					err = fmt.Errorf("-%s: %w", s.flag, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	if cfg.Database.DSN == "" && cfg.Database.Driver != repository.DriverMemory {
+		cfg.Database.DSN = repository.DefaultDSN(cfg.Database.Driver)
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Reads the config file on top of cfg, the format is picked from the file extension
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Checks every setting and reports all of the problems at once
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port must be between 1 and 65535, got %d", c.Port))
+	}
+	if _, ok := logLevels[c.LogLevel]; !ok {
+		problems = append(problems, fmt.Sprintf("log_level must be silent, error, warn or info, got %q", c.LogLevel))
+	}
+	if !contains(drivers, c.Database.Driver) {
+		problems = append(problems, fmt.Sprintf("database.driver must be one of %s, got %q", strings.Join(drivers, ", "), c.Database.Driver))
+	}
+	if c.Events.DefaultEventId < 1 {
+		problems = append(problems, fmt.Sprintf("events.default_event_id must be at least 1, got %d", c.Events.DefaultEventId))
+	}
+	if c.Guests.OverbookAllowance < 0 || c.Guests.OverbookAllowance > 100 {
+		problems = append(problems, fmt.Sprintf("guests.overbook_allowance must be between 0 and 100, got %d", c.Guests.OverbookAllowance))
+	}
+	if !contains(service.NamePolicies, c.Guests.NamePolicy) {
+		problems = append(problems, fmt.Sprintf("guests.name_policy must be one of %s, got %q", strings.Join(service.NamePolicies, ", "), c.Guests.NamePolicy))
+	}
+	if !contains(service.WalkInPolicies, c.Guests.WalkIns) {
+		problems = append(problems, fmt.Sprintf("guests.walk_ins must be one of %s, got %q", strings.Join(service.WalkInPolicies, ", "), c.Guests.WalkIns))
+	}
+	if c.Guests.MaxWalkIns < 0 {
+		problems = append(problems, fmt.Sprintf("guests.max_walk_ins must be at least 0, got %d", c.Guests.MaxWalkIns))
+	}
+	if c.Auth.SessionMinutes < 1 {
+		problems = append(problems, fmt.Sprintf("auth.session_minutes must be at least 1, got %d", c.Auth.SessionMinutes))
+	}
+	if c.Auth.Enabled {
+		problems = append(problems, c.Auth.problems()...)
+	}
+	if c.Webhooks.MaxAttempts < 1 || c.Webhooks.MaxAttempts > 20 {
+		problems = append(problems, fmt.Sprintf("webhooks.max_attempts must be between 1 and 20, got %d", c.Webhooks.MaxAttempts))
+	}
+	if c.Webhooks.RetrySeconds < 1 {
+		problems = append(problems, fmt.Sprintf("webhooks.retry_seconds must be at least 1, got %d", c.Webhooks.RetrySeconds))
+	}
+	if c.Webhooks.TimeoutSeconds < 1 || c.Webhooks.TimeoutSeconds > 60 {
+		problems = append(problems, fmt.Sprintf("webhooks.timeout_seconds must be between 1 and 60, got %d", c.Webhooks.TimeoutSeconds))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// The secret and the keys only have to be right when they are used
+func (a AuthConfig) problems() []string {
+	var problems []string
+
+	if len(a.SigningKey) < 32 {
+		problems = append(problems, "auth.signing_key must be at least 32 characters long")
+	}
+	if len(a.APIKeys) == 0 {
+		problems = append(problems, "auth.api_keys must have at least one key")
+	}
+
+	names, keys := make(map[string]bool), make(map[string]bool)
+	for i, k := range a.APIKeys {
+		if k.Name == "" || names[k.Name] {
+			problems = append(problems, fmt.Sprintf("auth.api_keys[%d].name must be set and unique, got %q", i, k.Name))
+		}
+		if !contains(auth.Roles, k.Role) {
+			problems = append(problems, fmt.Sprintf("auth.api_keys[%d].role must be one of %s, got %q", i, strings.Join(auth.Roles, ", "), k.Role))
+		}
+		// The key itself is never repeated in a message
+		if len(k.Key) < 16 || keys[k.Key] {
+			problems = append(problems, fmt.Sprintf("auth.api_keys[%d].key must be unique and at least 16 characters long", i))
+		}
+		names[k.Name], keys[k.Key] = true, true
+	}
+
+	return problems
+}
+
+// The API keys as the auth package takes them
+func (a AuthConfig) Keys() []auth.APIKey {
+	var keys []auth.APIKey
+	for _, k := range a.APIKeys {
+		keys = append(keys, auth.APIKey{Name: k.Name, Role: k.Role, Key: k.Key})
+	}
+	return keys
+}
+
+// The webhook settings as the webhook service takes them
+func (w WebhookConfig) Options() service.WebhookOptions {
+	return service.WebhookOptions{
+		MaxAttempts: w.MaxAttempts,
+		RetryDelay:  time.Duration(w.RetrySeconds) * time.Second,
+		Timeout:     time.Duration(w.TimeoutSeconds) * time.Second,
+	}
+}
+
+// The GORM logger level for the configured log level
+func (c Config) GormLogLevel() logger.LogLevel {
+	return logLevels[c.LogLevel]
+}
+
+// Matches the password in a DSN such as user:password@tcp(host:3306)/db
+var dsnPassword = regexp.MustCompile(`^([^:@/]*):([^@]*)@`)
+
+// Returns a copy of the configuration with the secrets replaced, so it can be printed or logged
+func (c Config) Redacted() Config {
+	c.Database.DSN = dsnPassword.ReplaceAllString(c.Database.DSN, "$1:*****@")
+	if c.Auth.SigningKey != "" {
+		c.Auth.SigningKey = "*****"
+	}
+	// The keys are copied, the copy of the config shares them with the original
+	var keys []APIKeyConfig
+	for _, k := range c.Auth.APIKeys {
+		k.Key = "*****"
+		keys = append(keys, k)
+	}
+	c.Auth.APIKeys = keys
+	return c
+}
+
+// The configuration as YAML, in the same layout as the config file
+func (c Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/export"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// The key the id of the request is stored under in the gin context
+const requestIdKey = "request_id"
+
+// The request ids a client may send, anything else is replaced so the audit log only holds ids that are safe to show
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Middleware that gives every request an id, the one the client sent in X-Request-ID or else a new one.
+// The id is sent back in the same header and recorded with every change the request makes.
+func RequestID(ctx *gin.Context) {
+	id := ctx.GetHeader("X-Request-ID")
+	if !requestIdPattern.MatchString(id) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		id = hex.EncodeToString(b)
+	}
+
+	ctx.Set(requestIdKey, id)
+	ctx.Header("X-Request-ID", id)
+	ctx.Next()
+}
+
+// Who is making the changes of the request, for the audit log
+func actor(ctx *gin.Context) service.Actor {
+	a := service.Actor{Name: "anonymous", Request_ID: ctx.GetString(requestIdKey)}
+	if p, ok := principal(ctx); ok {
+		a.Name = p.Subject
+		a.Role = p.Role
+	}
+	return a
+}
+
+type AuditController interface {
+	GetAuditLog(ctx *gin.Context)
+	ExportAuditLog(ctx *gin.Context)
+}
+
+type auditController struct {
+	auditService service.AuditService
+}
+
+func NewAuditController(auditS service.AuditService) AuditController {
+	return &auditController{
+		auditService: auditS,
+	}
+}
+
+func readAuditQuery(ctx *gin.Context) (dto.AuditListReqDto, error) {
+	var req dto.AuditListReqDto
+
+	err := readQuery(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	return req, err
+}
+
+// Lists the changes made to the guests and tables of the event, the latest first
+func (c *auditController) GetAuditLog(ctx *gin.Context) {
+	req, err := readAuditQuery(ctx)
+	if err != nil {
+		log.Println("Get Audit Log Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, page, err := c.auditService.FindAll(eventId(ctx), req)
+	if err != nil {
+		log.Println("Get Audit Log Controller - Could not retrieve the audit log")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Audit Log Controller - Successfully retrieved the audit log")
+	writePage(ctx, res, page)
+}
+
+// The audit log as a JSON lines file to download, oldest first, with the same filters as the list
+func (c *auditController) ExportAuditLog(ctx *gin.Context) {
+	req, err := readAuditQuery(ctx)
+	if err != nil {
+		log.Println("Export Audit Log Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.auditService.ListAll(eventId(ctx), req)
+	if err != nil {
+		log.Println("Export Audit Log Controller - Could not retrieve the audit log")
+		ctx.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteAuditJSONLines(&buf, res); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Export Audit Log Controller - Successfully exported the audit log")
+	ctx.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	ctx.Data(http.StatusOK, "application/x-ndjson", buf.Bytes())
+}
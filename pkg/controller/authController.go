@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/auth"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// The key the caller of the request is stored under in the gin context
+const principalKey = "principal"
+
+type AuthController interface {
+	Authenticate(ctx *gin.Context)
+	TokenFromQuery(ctx *gin.Context)
+	Allow(roles ...string) gin.HandlerFunc
+	CreateSession(ctx *gin.Context)
+	GetSession(ctx *gin.Context)
+}
+
+type authController struct {
+	authenticator *auth.Authenticator
+}
+
+// A nil authenticator switches auth off, every request is let through
+func NewAuthController(authenticator *auth.Authenticator) AuthController {
+	return &authController{
+		authenticator: authenticator,
+	}
+}
+
+// The caller of the request, not set when auth is off
+func principal(ctx *gin.Context) (auth.Principal, bool) {
+	p, ok := ctx.Get(principalKey)
+	if !ok {
+		return auth.Principal{}, false
+	}
+	return p.(auth.Principal), true
+}
+
+// Middleware that works out who is calling from their API key or session token, and stops the request when it can not
+func (c *authController) Authenticate(ctx *gin.Context) {
+	if c.authenticator == nil {
+		return
+	}
+
+	p, err := c.authenticator.Authenticate(ctx.Request)
+	if err != nil {
+		log.Println("Authenticate - Could not authenticate the request")
+		ctx.Header("WWW-Authenticate", `Bearer realm="party-server"`)
+		ctx.Error(err)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set(principalKey, p)
+	ctx.Next()
+}
+
+// Middleware that reads a session token from the access_token query parameter, for browsers that can not set headers on an
+// EventSource or a WebSocket. It runs before Authenticate and only when the request has no credentials in its headers.
+// API keys are not read from the query string, a URL ends up in logs and a key does not expire. RequestLogger leaves the token
+// out of the server's own log.
+func (c *authController) TokenFromQuery(ctx *gin.Context) {
+	token := ctx.Query("access_token")
+	if c.authenticator == nil || token == "" {
+		return
+	}
+	if ctx.GetHeader("X-API-Key") != "" || ctx.GetHeader("Authorization") != "" {
+		return
+	}
+	// Only session tokens are accepted as bearer tokens, so an API key sent this way is turned away
+	ctx.Request.Header.Set("Authorization", "Bearer "+token)
+}
+
+// Matches the value of the access_token query parameter
+var accessTokenParam = regexp.MustCompile(`([?&]access_token=)[^&]*`)
+
+// Logs a line for every request like gin.Logger, with the session token of an access_token query parameter left out
+// so that the log does not hold tokens that can still be used
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			accessTokenParam.ReplaceAllString(param.Path, "${1}*****"),
+			param.ErrorMessage,
+		)
+	})
+}
+
+// Middleware that only lets the given roles through, it runs after Authenticate
+func (c *authController) Allow(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if c.authenticator == nil {
+			return
+		}
+
+		p, _ := principal(ctx)
+		if !p.Allowed(roles...) {
+			log.Println("Allow - The role may not call the route")
+			ctx.Error(fmt.Errorf("%w: %s may not %s %s", auth.ErrForbidden, p.Role, ctx.Request.Method, ctx.FullPath()))
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Exchanges an API key for a session token, so the key itself does not have to be kept on the devices at the door
+func (c *authController) CreateSession(ctx *gin.Context) {
+	p, _ := principal(ctx)
+	// A session could otherwise be kept open for ever by renewing it
+	if p.Method != auth.MethodAPIKey {
+		log.Println("Create Session Controller - Sessions are opened with an API key")
+		ctx.Error(fmt.Errorf("%w: sessions are opened with an API key", auth.ErrForbidden))
+		return
+	}
+
+	token, expires, err := c.authenticator.Issue(p)
+	if err != nil {
+		log.Println("Create Session Controller - Could not open the session")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Create Session Controller - Successfully opened a session")
+	ctx.IndentedJSON(http.StatusCreated, dto.SessionResDto{
+		Token:   token,
+		Subject: p.Subject,
+		Role:    p.Role,
+		Method:  auth.MethodSession,
+		Expires: expires.Format(time.RFC3339),
+	})
+}
+
+// Tells the caller who they are authenticated as
+func (c *authController) GetSession(ctx *gin.Context) {
+	p, _ := principal(ctx)
+
+	log.Println("Get Session Controller - Successfully retrieved the session")
+	ctx.IndentedJSON(http.StatusOK, dto.SessionResDto{Subject: p.Subject, Role: p.Role, Method: p.Method})
+}
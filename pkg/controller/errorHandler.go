@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/getground/tech-tasks/backend/pkg/auth"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// Returned when the request body or query string can not be read into the request DTO
+var ErrMalformedRequest = errors.New("the request could not be read")
+
+// How an error is reported to the client
+type problem struct {
+	err    error
+	status int
+	// Identifies the kind of problem, the type of the response is /problems/<slug>
+	slug  string
+	title string
+}
+
+// The first problem the error matches is used, errors that match none are internal server errors
+var problems = []problem{
+	{ErrMalformedRequest, http.StatusBadRequest, "malformed-request", "Malformed request"},
+	{validation.ErrInvalid, http.StatusUnprocessableEntity, "validation-failed", "Request is not valid"},
+	{service.ErrInvalidCursor, http.StatusBadRequest, "invalid-cursor", "Invalid cursor"},
+	{auth.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated", "Authentication required"},
+	{auth.ErrForbidden, http.StatusForbidden, "forbidden", "Not allowed for this role"},
+	{service.ErrWalkInsNotAllowed, http.StatusForbidden, "walk-ins-not-allowed", "Walk-ins are not allowed"},
+	{service.ErrEventNotFound, http.StatusNotFound, "event-not-found", "Event not found"},
+	{service.ErrTableNotFound, http.StatusNotFound, "table-not-found", "Table not found"},
+	{service.ErrGuestNotFound, http.StatusNotFound, "guest-not-found", "Guest not found"},
+	{service.ErrConstraintNotFound, http.StatusNotFound, "constraint-not-found", "Seating constraint not found"},
+	{service.ErrWaitlistEntryNotFound, http.StatusNotFound, "waitlist-entry-not-found", "Waitlist entry not found"},
+	{service.ErrWebhookNotFound, http.StatusNotFound, "webhook-not-found", "Webhook not found"},
+	{service.ErrOverbooked, http.StatusConflict, "overbooked", "Table is fully booked"},
+	{service.ErrOverCapacity, http.StatusConflict, "over-capacity", "Not enough free seats"},
+	{service.ErrCapacityBelowReservations, http.StatusConflict, "capacity-below-reservations", "Capacity is below the seats already reserved"},
+	{service.ErrTableHasGuests, http.StatusConflict, "table-has-guests", "Table still has guests"},
+	{service.ErrAlreadyCheckedIn, http.StatusConflict, "already-checked-in", "Guest is already at the party"},
+	{service.ErrGuestNotPresent, http.StatusConflict, "guest-not-present", "Guest is not at the party"},
+	{service.ErrDuplicateName, http.StatusConflict, "duplicate-name", "Name is already on the guest list"},
+	{service.ErrAmbiguousGuest, http.StatusConflict, "ambiguous-guest", "More than one guest has this name"},
+	{service.ErrPartiesUnseated, http.StatusConflict, "parties-unseated", "Some parties do not fit at any table"},
+	{service.ErrConstraintViolated, http.StatusConflict, "constraint-violated", "The move breaks a seating constraint"},
+	{service.ErrWalkInLimitReached, http.StatusConflict, "walk-in-limit-reached", "The event takes no more walk-ins"},
+	{service.ErrNoFreeTable, http.StatusConflict, "no-free-table", "No table has enough free seats"},
+	{service.ErrInvalidEvent, http.StatusUnprocessableEntity, "invalid-event", "Invalid event"},
+	{service.ErrImportFailed, http.StatusUnprocessableEntity, "import-failed", "Guest list could not be imported"},
+	{service.ErrInvalidConstraint, http.StatusUnprocessableEntity, "invalid-constraint", "Invalid seating constraint"},
+}
+
+// Middleware that turns the last error a handler added with ctx.Error into a problem+json response,
+// unless the handler has already written a response
+func ErrorHandler(ctx *gin.Context) {
+	ctx.Next()
+
+	if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+		return
+	}
+	err := ctx.Errors.Last().Err
+
+	res := dto.ProblemResDto{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusInternalServerError),
+		Status:   http.StatusInternalServerError,
+		Instance: ctx.Request.URL.Path,
+	}
+
+	found := false
+	for _, p := range problems {
+		if errors.Is(err, p.err) {
+			res.Type = "/problems/" + p.slug
+			res.Title = p.title
+			res.Status = p.status
+			res.Detail = err.Error()
+			found = true
+			break
+		}
+	}
+	// The details of unexpected errors are only logged, they may give away how the server works
+	if !found {
+		log.Println("Error Handler - Unexpected error: " + err.Error())
+	}
+
+	var ambiguous *service.AmbiguousGuestError
+	if errors.As(err, &ambiguous) {
+		res.Candidates = ambiguous.Candidates
+	}
+	var invalid *validation.Error
+	if errors.As(err, &invalid) {
+		res.Errors = invalid.Fields
+	}
+	var failed *service.ImportError
+	if errors.As(err, &failed) {
+		res.Rows = failed.Report.Errors
+	}
+
+	ctx.Header("Content-Type", "application/problem+json")
+	ctx.IndentedJSON(res.Status, res)
+}
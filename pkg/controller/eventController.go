@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// The key the event id of the request is stored under in the gin context
+const eventIdKey = "eventId"
+
+type EventController interface {
+	GetEvents(ctx *gin.Context)
+	GetAnEvent(ctx *gin.Context)
+	CreateEvent(ctx *gin.Context)
+	Scope(ctx *gin.Context)
+}
+
+type eventController struct {
+	eventService   service.EventService
+	defaultEventId int
+}
+
+// Requests on routes without an :eventId are for the default event
+func NewEventController(eventS service.EventService, defaultEventId int) EventController {
+	return &eventController{
+		eventService:   eventS,
+		defaultEventId: defaultEventId,
+	}
+}
+
+// The id of the event the request is for, set by Scope
+func eventId(ctx *gin.Context) int {
+	return ctx.GetInt(eventIdKey)
+}
+
+func (c *eventController) GetEvents(ctx *gin.Context) {
+	res, err := c.eventService.FindAll()
+	if err != nil {
+		log.Println("Get Events Controller - Could not retrieve events")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Events Controller - Successfully retrieved all events")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *eventController) GetAnEvent(ctx *gin.Context) {
+	res, err := c.eventService.FindById(eventId(ctx))
+	if err != nil {
+		log.Println("Get Event By Id Controller - Could not get event")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Event By Id Controller - Successfully retrieved event")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *eventController) CreateEvent(ctx *gin.Context) {
+	var req dto.EventReqDto
+
+	err := readJSON(ctx, &req)
+	if err != nil {
+		log.Println("Create Event Controller - Could not retrieve event data")
+		ctx.Error(err)
+		return
+	}
+
+	err = validation.Struct(req)
+	if err != nil {
+		log.Println("Create Event Controller - The event is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.eventService.Save(req)
+	if err != nil {
+		log.Println("Create Event Controller - Could not create new event")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Create Event Controller - Successfully added event")
+	ctx.IndentedJSON(http.StatusCreated, res)
+}
+
+// Middleware that works out which event the request is for, from the :eventId in the route or else the default event,
+// and stops the request when that event does not exist
+func (c *eventController) Scope(ctx *gin.Context) {
+	id := c.defaultEventId
+
+	if param, ok := ctx.Params.Get("eventId"); ok {
+		parsed, err := strconv.Atoi(param)
+		if err != nil {
+			log.Println("Event Scope - The event id is not a number")
+			ctx.Error(fmt.Errorf("%w: %q", service.ErrEventNotFound, param))
+			ctx.Abort()
+			return
+		}
+		id = parsed
+	}
+
+	_, err := c.eventService.FindById(id)
+	if err != nil {
+		log.Println("Event Scope - Could not get event")
+		ctx.Error(err)
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set(eventIdKey, id)
+	ctx.Next()
+}
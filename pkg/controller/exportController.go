@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/export"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+type ExportController interface {
+	ExportGuests(ctx *gin.Context)
+	ExportSeatingChart(ctx *gin.Context)
+}
+
+type exportController struct {
+	eventService service.EventService
+	tableService service.TableService
+	guestService service.GuestService
+}
+
+func NewExportController(eventS service.EventService, tableS service.TableService, guestS service.GuestService) ExportController {
+	return &exportController{
+		eventService: eventS,
+		tableService: tableS,
+		guestService: guestS,
+	}
+}
+
+// The guest list as a CSV or JSON file to download, with each guest's table, party size and arrival and departure times
+func (c *exportController) ExportGuests(ctx *gin.Context) {
+	var req dto.GuestExportReqDto
+
+	err := readQuery(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Export Guests Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	guests, err := c.guestService.ListAll(eventId(ctx))
+	if err != nil {
+		log.Println("Export Guests Controller - Could not retrieve guests")
+		ctx.Error(err)
+		return
+	}
+	res := export.Guests(guests)
+
+	log.Println("Export Guests Controller - Successfully exported guests")
+	if req.Format == "csv" {
+		var buf bytes.Buffer
+		if err := export.WriteGuestsCSV(&buf, res); err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.Header("Content-Disposition", `attachment; filename="guests.csv"`)
+		ctx.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="guests.json"`)
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// The seating chart of the event as a PDF to print, the guests are listed under their tables
+func (c *exportController) ExportSeatingChart(ctx *gin.Context) {
+	event, err := c.eventService.FindById(eventId(ctx))
+	if err != nil {
+		log.Println("Export Seating Chart Controller - Could not retrieve event")
+		ctx.Error(err)
+		return
+	}
+
+	tables, err := c.tableService.ListAll(eventId(ctx))
+	if err != nil {
+		log.Println("Export Seating Chart Controller - Could not retrieve tables")
+		ctx.Error(err)
+		return
+	}
+
+	guests, err := c.guestService.ListAll(eventId(ctx))
+	if err != nil {
+		log.Println("Export Seating Chart Controller - Could not retrieve guests")
+		ctx.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := export.WriteSeatingChart(&buf, event, tables, export.Guests(guests)); err != nil {
+		log.Println("Export Seating Chart Controller - Could not write the seating chart")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Export Seating Chart Controller - Successfully exported the seating chart")
+	ctx.Header("Content-Disposition", `attachment; filename="seating-chart.pdf"`)
+	ctx.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
@@ -1,119 +1,406 @@
 package controller
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/importer"
 	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
 	"github.com/gin-gonic/gin"
 )
 
 type GuestController interface {
 	GetGuests(ctx *gin.Context)
 	CreateGuest(ctx *gin.Context)
+	GetAGuest(ctx *gin.Context)
+	UpdateGuest(ctx *gin.Context)
+	PatchGuest(ctx *gin.Context)
+	DeleteGuest(ctx *gin.Context)
 	Checkin(ctx *gin.Context)
 	Checkout(ctx *gin.Context)
+	WalkIn(ctx *gin.Context)
 	GetArrivedGuests(ctx *gin.Context)
+	GetDepartedGuests(ctx *gin.Context)
+	GetVisits(ctx *gin.Context)
+	SearchGuests(ctx *gin.Context)
+	ImportGuests(ctx *gin.Context)
 }
 
 type guestController struct {
-	guestService service.GuestService
+	guestService   service.GuestService
+	guestValidator validation.GuestValidator
 }
 
-func NewGuestController(guestS service.GuestService) GuestController {
+func NewGuestController(guestS service.GuestService, guestV validation.GuestValidator) GuestController {
 	return &guestController{
-		guestService: guestS,
+		guestService:   guestS,
+		guestValidator: guestV,
 	}
 }
 
+// Reads the filters, order and page of a guest list from the query string
+func readGuestList(ctx *gin.Context) (dto.GuestListReqDto, error) {
+	var req dto.GuestListReqDto
+
+	if err := readQuery(ctx, &req); err != nil {
+		return req, err
+	}
+	return req, validation.Struct(req)
+}
+
 func (c *guestController) GetGuests(ctx *gin.Context) {
-	res, err := c.guestService.FindAll()
+	req, err := readGuestList(ctx)
+	if err != nil {
+		log.Println("Get Guests Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, page, err := c.guestService.FindAll(eventId(ctx), req)
 	if err != nil {
 		log.Println("Could not retrieve guests")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
 	log.Println("Get Guests Controller - Successfully retrieved guests")
-	ctx.IndentedJSON(http.StatusFound, res)
+	writePage(ctx, res, page)
 }
 
 func (c *guestController) CreateGuest(ctx *gin.Context) {
 	var req dto.GuestReqDto
-	var emptyRes dto.GuestResDto
 
-	err := ctx.BindJSON(&req)
+	err := readJSON(ctx, &req)
 	if err != nil {
 		log.Println("Could not retrieve guest data")
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
 	name := ctx.Param("name")
 
 	req.Name = name
 
-	res, err := c.guestService.Save(req)
+	err = c.guestValidator.Validate(eventId(ctx), req)
+	if err != nil {
+		log.Println("Create Guest Controller - The guest is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.guestService.Save(eventId(ctx), req, actor(ctx))
 	if err != nil {
 		log.Println("Create Guest Controller - Could not create new guest")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
-	if res == emptyRes {
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": "too many guests"})
-	} else {
-		log.Println("Create Guest Controller - Successfully added to guest list")
-		ctx.IndentedJSON(http.StatusCreated, res)
+	// The party did not fit and waits for seats, it is not on the guest list yet
+	if res.Waitlist != nil {
+		log.Println("Create Guest Controller - Successfully added to the waitlist")
+		ctx.IndentedJSON(http.StatusAccepted, res)
+		return
 	}
+
+	log.Println("Create Guest Controller - Successfully added to guest list")
+	ctx.IndentedJSON(http.StatusCreated, res)
 }
 
-func (c *guestController) Checkin(ctx *gin.Context) {
+// The guest is given by public id or, when the name is unique, by name
+func (c *guestController) GetAGuest(ctx *gin.Context) {
+	res, err := c.guestService.FindOne(eventId(ctx), ctx.Param("guest"))
+	if err != nil {
+		log.Println("Get Guest Controller - Could not get guest")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Guest Controller - Successfully retrieved guest")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// Replaces the guest-list entry, the name, table and party size all have to be given
+func (c *guestController) UpdateGuest(ctx *gin.Context) {
 	var req dto.GuestReqDto
-	var emptyRes dto.GuestResDto
 
-	err := ctx.BindJSON(&req)
+	err := readJSON(ctx, &req)
 	if err != nil {
-		log.Println("Checkin Controller - Could not retrieve guest data")
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		log.Println("Update Guest Controller - Could not retrieve guest data")
+		ctx.Error(err)
+		return
 	}
 
-	name := ctx.Param("name")
+	err = c.guestValidator.Validate(eventId(ctx), req)
+	if err != nil {
+		log.Println("Update Guest Controller - The guest is not valid")
+		ctx.Error(err)
+		return
+	}
 
-	req.Name = name
+	// Aliases left out of the request are removed, like every other field it replaces the old one
+	aliases := req.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+	patch := dto.GuestPatchReqDto{Name: req.Name, Aliases: aliases, Table_ID: req.Table_ID, Acompanying_Guests: &req.Acompanying_Guests}
+	res, err := c.guestService.Update(eventId(ctx), ctx.Param("guest"), patch, actor(ctx))
+	if err != nil {
+		log.Println("Update Guest Controller - Could not update guest")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Update Guest Controller - Successfully updated guest")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// Changes the fields of the guest-list entry that are in the request
+func (c *guestController) PatchGuest(ctx *gin.Context) {
+	var req dto.GuestPatchReqDto
 
-	res, err := c.guestService.Checkin(req)
+	err := readJSON(ctx, &req)
+	if err != nil {
+		log.Println("Patch Guest Controller - Could not retrieve guest data")
+		ctx.Error(err)
+		return
+	}
+
+	err = c.guestValidator.ValidatePatch(eventId(ctx), req)
+	if err != nil {
+		log.Println("Patch Guest Controller - The guest is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.guestService.Update(eventId(ctx), ctx.Param("guest"), req, actor(ctx))
+	if err != nil {
+		log.Println("Patch Guest Controller - Could not update guest")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Patch Guest Controller - Successfully updated guest")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *guestController) DeleteGuest(ctx *gin.Context) {
+	err := c.guestService.Delete(eventId(ctx), ctx.Param("guest"), actor(ctx))
+	if err != nil {
+		log.Println("Delete Guest Controller - Could not delete guest")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Delete Guest Controller - Successfully removed guest from the guest list")
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *guestController) Checkin(ctx *gin.Context) {
+	var req dto.CheckinReqDto
+
+	err := readJSON(ctx, &req)
+	if err != nil {
+		log.Println("Checkin Controller - Could not retrieve guest data")
+		ctx.Error(err)
+		return
+	}
+
+	err = validation.Struct(req)
+	if err != nil {
+		log.Println("Checkin Controller - The check-in is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.guestService.Checkin(eventId(ctx), ctx.Param("guest"), req, actor(ctx))
 	if err != nil {
 		log.Println("Checkin Controller - Could not update guest")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
-	if res == emptyRes {
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": "too many guests"})
-	} else {
-		log.Println("Checkin Controller - Successfully checked in guest")
-		ctx.IndentedJSON(http.StatusCreated, res)
+	// The party did not fit and waits at the door for seats
+	if res.Waitlist != nil {
+		log.Println("Checkin Controller - Successfully added to the waitlist")
+		ctx.IndentedJSON(http.StatusAccepted, res)
+		return
 	}
+
+	log.Println("Checkin Controller - Successfully checked in guest")
+	ctx.IndentedJSON(http.StatusCreated, res)
 }
 
 func (c *guestController) Checkout(ctx *gin.Context) {
-	name := ctx.Param("name")
+	err := c.guestService.Checkout(eventId(ctx), ctx.Param("guest"), actor(ctx))
+	if err != nil {
+		log.Println("Checkout Controller - Could not check out guest")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Checkout Controller - Successfully checked out guest")
+	ctx.Status(http.StatusNoContent)
+}
+
+// Seats a guest who is not on the guest list at a table with enough free seats and checks them in
+func (c *guestController) WalkIn(ctx *gin.Context) {
+	var req dto.WalkInReqDto
+
+	err := readJSON(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Walk In Controller - The walk-in is not valid")
+		ctx.Error(err)
+		return
+	}
 
-	err := c.guestService.Checkout(name)
+	res, err := c.guestService.WalkIn(eventId(ctx), req, actor(ctx))
 	if err != nil {
-		log.Println("Checkout Controller - Could not delete guest")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Println("Walk In Controller - Could not seat the walk-in")
+		ctx.Error(err)
+		return
 	}
 
-	log.Println("Checkout Controller - Successfully deleted guest")
-	ctx.IndentedJSON(http.StatusNoContent, nil)
+	log.Println("Walk In Controller - Successfully seated the walk-in")
+	ctx.IndentedJSON(http.StatusCreated, res)
 }
 
 func (c *guestController) GetArrivedGuests(ctx *gin.Context) {
-	res, err := c.guestService.GetArrivedGuests()
+	req, err := readGuestList(ctx)
+	if err != nil {
+		log.Println("Get Arrived Guests Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, page, err := c.guestService.GetArrivedGuests(eventId(ctx), req)
 	if err != nil {
 		log.Println("Could not retrieve guests")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
 	log.Println("Get Guests Controller - Successfully retrieved guests")
-	ctx.IndentedJSON(http.StatusFound, res)
+	writePage(ctx, res, page)
+}
+
+func (c *guestController) GetDepartedGuests(ctx *gin.Context) {
+	res, err := c.guestService.GetDepartedGuests(eventId(ctx))
+	if err != nil {
+		log.Println("Get Departed Guests Controller - Could not retrieve guests")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Departed Guests Controller - Successfully retrieved guests")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *guestController) GetVisits(ctx *gin.Context) {
+	res, err := c.guestService.FindVisits(eventId(ctx), ctx.Param("guest"))
+	if err != nil {
+		log.Println("Get Visits Controller - Could not retrieve visits")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Visits Controller - Successfully retrieved visits")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// Finds guests by a name that may be misspelt or written without its accents, for the door staff
+func (c *guestController) SearchGuests(ctx *gin.Context) {
+	var req dto.GuestSearchReqDto
+
+	err := readQuery(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Search Guests Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.guestService.Search(eventId(ctx), req)
+	if err != nil {
+		log.Println("Search Guests Controller - Could not search guests")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Search Guests Controller - Successfully searched guests")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// The largest file that can be imported
+const maxImportSize = 10 << 20
+
+// Adds a guest list from the CSV or XLSX file in the body.
+// The columns are found by their header, ?column[name]=Full%20name maps a column to a header with another name.
+// With ?dry_run=true every row is checked and reported on without adding any guest.
+func (c *guestController) ImportGuests(ctx *gin.Context) {
+	var req dto.GuestImportReqDto
+
+	err := readQuery(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Import Guests Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	data, err := readBody(ctx, maxImportSize)
+	if err != nil {
+		log.Println("Import Guests Controller - Could not read the file")
+		ctx.Error(err)
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = importer.DetectFormat(ctx.ContentType(), data)
+	}
+
+	cells, err := importer.Read(format, bytes.NewReader(data))
+	var rows []dto.GuestImportRowDto
+	if err == nil {
+		rows, err = importer.Guests(cells, ctx.QueryMap("column"))
+	}
+	if errors.Is(err, importer.ErrUnreadable) {
+		err = fmt.Errorf("%w: %v", ErrMalformedRequest, err)
+	}
+	if err == nil {
+		err = c.guestValidator.ValidateRows(eventId(ctx), rows)
+	}
+	if err != nil {
+		log.Println("Import Guests Controller - Could not read the guest list")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.guestService.Import(eventId(ctx), rows, req.DryRun, actor(ctx))
+	if err != nil {
+		log.Println("Import Guests Controller - Could not import the guest list")
+		ctx.Error(err)
+		return
+	}
+
+	if req.DryRun {
+		log.Println("Import Guests Controller - Successfully checked the guest list")
+		ctx.IndentedJSON(http.StatusOK, res)
+		return
+	}
+
+	log.Println("Import Guests Controller - Successfully imported the guest list")
+	ctx.IndentedJSON(http.StatusCreated, res)
 }
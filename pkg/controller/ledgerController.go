@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerController interface {
+	CheckLedger(ctx *gin.Context)
+}
+
+type ledgerController struct {
+	ledgerService service.LedgerService
+}
+
+func NewLedgerController(ledgerS service.LedgerService) LedgerController {
+	return &ledgerController{
+		ledgerService: ledgerS,
+	}
+}
+
+// Compares the stored guests and tables with the ones the ledger projects, the differences are listed field by field
+func (c *ledgerController) CheckLedger(ctx *gin.Context) {
+	res, err := c.ledgerService.Check(eventId(ctx))
+	if err != nil {
+		log.Println("Check Ledger Controller - Could not check the ledger")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Check Ledger Controller - Successfully checked the ledger")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
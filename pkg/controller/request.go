@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Reads the JSON body of the request into req without checking its validation rules,
+// so that values from the route can be filled in before the whole request is validated
+func readJSON(ctx *gin.Context, req interface{}) error {
+	if ctx.Request.Body == nil {
+		return fmt.Errorf("%w: the body is empty", ErrMalformedRequest)
+	}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(req); err != nil {
+		return fmt.Errorf("%w: the body is not valid JSON: %v", ErrMalformedRequest, err)
+	}
+	return nil
+}
+
+// Reads the query string into the form fields of req, like readJSON the validation rules are checked afterwards
+func readQuery(ctx *gin.Context, req interface{}) error {
+	if err := binding.MapFormWithTag(req, ctx.Request.URL.Query(), "form"); err != nil {
+		return fmt.Errorf("%w: the query string is not valid: %v", ErrMalformedRequest, err)
+	}
+	return nil
+}
+
+// Reads the whole body of the request, a body larger than limit is not read
+func readBody(ctx *gin.Context, limit int64) ([]byte, error) {
+	if ctx.Request.Body == nil {
+		return nil, fmt.Errorf("%w: the body is empty", ErrMalformedRequest)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(ctx.Request.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedRequest, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: the body is larger than %d bytes", ErrMalformedRequest, limit)
+	}
+	return data, nil
+}
+
+// Answers with a page of a list. The total is sent in X-Total-Count and the links to the first and next pages in Link,
+// they keep the filters of the request.
+func writePage(ctx *gin.Context, res interface{}, page dto.PageDto) {
+	query := ctx.Request.URL.Query()
+	query.Del("cursor")
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(ctx, query))}
+	if page.Next != "" {
+		query.Set("cursor", page.Next)
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(ctx, query)))
+	}
+
+	ctx.Header("X-Total-Count", strconv.FormatInt(page.Total, 10))
+	ctx.Header("Link", strings.Join(links, ", "))
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// The path of the request with another query string
+func pageURL(ctx *gin.Context, query url.Values) string {
+	u := *ctx.Request.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+type SeatingController interface {
+	PlanSeating(ctx *gin.Context)
+	GetConstraints(ctx *gin.Context)
+	CreateConstraint(ctx *gin.Context)
+	DeleteConstraint(ctx *gin.Context)
+	GetViolations(ctx *gin.Context)
+}
+
+type seatingController struct {
+	seatingService    service.SeatingService
+	constraintService service.ConstraintService
+}
+
+func NewSeatingController(seatingS service.SeatingService, constraintS service.ConstraintService) SeatingController {
+	return &seatingController{
+		seatingService:    seatingS,
+		constraintService: constraintS,
+	}
+}
+
+// Finds tables for parties that are not on the guest list yet and for re-seated guests,
+// and adds or moves them to those tables when the mode is apply
+func (c *seatingController) PlanSeating(ctx *gin.Context) {
+	var req dto.SeatingPlanReqDto
+
+	err := readJSON(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Plan Seating Controller - The request is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.seatingService.Plan(eventId(ctx), req, actor(ctx))
+	if err != nil {
+		log.Println("Plan Seating Controller - Could not plan the seating")
+		ctx.Error(err)
+		return
+	}
+
+	if res.Mode == service.SeatingApply {
+		log.Println("Plan Seating Controller - Successfully seated the parties")
+		ctx.IndentedJSON(http.StatusCreated, res)
+		return
+	}
+
+	log.Println("Plan Seating Controller - Successfully planned the seating")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *seatingController) GetConstraints(ctx *gin.Context) {
+	res, err := c.constraintService.FindAll(eventId(ctx))
+	if err != nil {
+		log.Println("Get Constraints Controller - Could not retrieve constraints")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Constraints Controller - Successfully retrieved all constraints")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *seatingController) CreateConstraint(ctx *gin.Context) {
+	var req dto.ConstraintReqDto
+
+	err := readJSON(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Create Constraint Controller - The constraint is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.constraintService.Save(eventId(ctx), req)
+	if err != nil {
+		log.Println("Create Constraint Controller - Could not create constraint")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Create Constraint Controller - Successfully added constraint")
+	ctx.IndentedJSON(http.StatusCreated, res)
+}
+
+func (c *seatingController) DeleteConstraint(ctx *gin.Context) {
+	// An id that is not a number is a constraint that does not exist
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		log.Println("Delete Constraint Controller - The id is not a number")
+		ctx.Error(fmt.Errorf("%w: %q", service.ErrConstraintNotFound, ctx.Param("id")))
+		return
+	}
+
+	err = c.constraintService.Delete(eventId(ctx), id)
+	if err != nil {
+		log.Println("Delete Constraint Controller - Could not delete constraint")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Delete Constraint Controller - Successfully deleted constraint")
+	ctx.Status(http.StatusNoContent)
+}
+
+// Lists every seating constraint the guest list breaks
+func (c *seatingController) GetViolations(ctx *gin.Context) {
+	res, err := c.constraintService.Violations(eventId(ctx))
+	if err != nil {
+		log.Println("Get Violations Controller - Could not check the constraints")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Violations Controller - Successfully checked the constraints")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
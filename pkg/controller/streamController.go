@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/hub"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// How often an idle Server-Sent Events stream sends a comment, so proxies do not close it
+const heartbeat = 15 * time.Second
+
+// How long a message may take to reach a WebSocket client before it is dropped
+const socketWriteTimeout = 10 * time.Second
+
+type StreamController interface {
+	GetStream(ctx *gin.Context)
+	GetSocket(ctx *gin.Context)
+}
+
+type streamController struct {
+	streamService service.StreamService
+}
+
+func NewStreamController(streamS service.StreamService) StreamController {
+	return &streamController{
+		streamService: streamS,
+	}
+}
+
+// Reads the filters and the id of the last message the client got, the Last-Event-ID header is preferred over the query string
+func readStreamQuery(ctx *gin.Context) (dto.StreamReqDto, *int64, error) {
+	var req dto.StreamReqDto
+
+	err := readQuery(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		return req, nil, err
+	}
+
+	lastId := req.Last_Event_ID
+	if header := ctx.GetHeader("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			return req, nil, fmt.Errorf("%w: Last-Event-ID %q is not a message id", ErrMalformedRequest, header)
+		}
+		lastId = &id
+	}
+	return req, lastId, nil
+}
+
+// Streams the changes to the guests and tables as Server-Sent Events, until the client goes away
+func (c *streamController) GetStream(ctx *gin.Context) {
+	req, lastId, err := readStreamQuery(ctx)
+	if err != nil {
+		log.Println("Get Stream Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	stream, err := c.streamService.Subscribe(req, lastId)
+	if err != nil {
+		log.Println("Get Stream Controller - Could not subscribe")
+		ctx.Error(err)
+		return
+	}
+	defer stream.Cancel()
+
+	log.Println("Get Stream Controller - Successfully subscribed")
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	// Stops nginx from holding the events back
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.WriteHeaderNow()
+	ctx.Writer.Flush()
+
+	for _, msg := range stream.Replay {
+		if err := writeEvent(ctx, msg); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case msg, ok := <-stream.Messages:
+			if !ok {
+				return
+			}
+			if err := writeEvent(ctx, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(ctx.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// Writes the message as an event named after its type, the id is the one the client resumes from
+func writeEvent(ctx *gin.Context, msg hub.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(ctx.Writer, "id: %d\nevent: %s\ndata: %s\n\n", msg.Id, msg.Type, data); err != nil {
+		return err
+	}
+	ctx.Writer.Flush()
+	return nil
+}
+
+// Streams the changes to the guests and tables over a WebSocket, one JSON message per frame.
+// The filters are the same as GetStream's, a client resumes with last_event_id as browsers can not set headers on a WebSocket.
+func (c *streamController) GetSocket(ctx *gin.Context) {
+	req, lastId, err := readStreamQuery(ctx)
+	if err != nil {
+		log.Println("Get Socket Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	stream, err := c.streamService.Subscribe(req, lastId)
+	if err != nil {
+		log.Println("Get Socket Controller - Could not subscribe")
+		ctx.Error(err)
+		return
+	}
+	defer stream.Cancel()
+
+	// The Origin is not checked, the caller has been authenticated by their key or token rather than a cookie
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		// The client sends nothing, reading only finds out when it has gone
+		gone := make(chan struct{})
+		go func() {
+			defer close(gone)
+			var discard string
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+		}()
+
+		send := func(msg hub.Message) error {
+			ws.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			return websocket.JSON.Send(ws, msg)
+		}
+		for _, msg := range stream.Replay {
+			if err := send(msg); err != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case <-gone:
+				return
+			case msg, ok := <-stream.Messages:
+				if !ok {
+					return
+				}
+				if err := send(msg); err != nil {
+					return
+				}
+			}
+		}
+	}}
+
+	log.Println("Get Socket Controller - Successfully subscribed")
+	server.ServeHTTP(ctx.Writer, ctx.Request)
+}
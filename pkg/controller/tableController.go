@@ -9,6 +9,7 @@ import (
 
 	"github.com/getground/tech-tasks/backend/pkg/dto"
 	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,6 +17,9 @@ type TableController interface {
 	GetTables(ctx *gin.Context)
 	GetATable(ctx *gin.Context)
 	CreateTable(ctx *gin.Context)
+	UpdateTable(ctx *gin.Context)
+	PatchTable(ctx *gin.Context)
+	DeleteTable(ctx *gin.Context)
 	GetSpace(ctx *gin.Context)
 }
 
@@ -34,55 +38,195 @@ func HandlerPing(ctx *gin.Context) {
 }
 
 func (c *tableController) GetTables(ctx *gin.Context) {
-	res, err := c.tableService.FindAll()
+	var req dto.TableListReqDto
+
+	err := readQuery(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
 	if err != nil {
-		log.Println("Create Table Controller - Could not create new table")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Println("Get Tables Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, page, err := c.tableService.FindAll(eventId(ctx), req)
+	if err != nil {
+		log.Println("Get Tables Controller - Could not retrieve tables")
+		ctx.Error(err)
+		return
 	}
 
 	log.Println("Create Table Controller - Successfully retrieved all tables")
-	ctx.IndentedJSON(http.StatusOK, res)
+	writePage(ctx, res, page)
+}
+
+// The id of the table in the route, an id that is not a number is a table that does not exist
+func tableId(ctx *gin.Context) (int, error) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", service.ErrTableNotFound, ctx.Param("id"))
+	}
+	return id, nil
 }
 
 func (c *tableController) GetATable(ctx *gin.Context) {
-	id, _ := strconv.Atoi(ctx.Param("id"))
+	id, err := tableId(ctx)
+	if err != nil {
+		log.Println("Get Table By Id Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
 
-	res, err := c.tableService.FindById(id)
+	res, err := c.tableService.FindById(eventId(ctx), id)
 	if err != nil {
 		log.Println("Get Table By Id Controller - Could not get table")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
-	log.Println("Create Table Controller - Successfully retrieved table")
-	ctx.IndentedJSON(http.StatusFound, res)
+	log.Println("Get Table By Id Controller - Successfully retrieved table")
+	ctx.IndentedJSON(http.StatusOK, res)
 }
 
 func (c *tableController) CreateTable(ctx *gin.Context) {
 	var req dto.TableReqDto
 
-	err := ctx.BindJSON(&req)
+	err := readJSON(ctx, &req)
 	if err != nil {
 		log.Println("Could not retrieve table data")
-		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
-	res, err := c.tableService.Save(req)
+	err = validation.Struct(req)
+	if err != nil {
+		log.Println("Create Table Controller - The table is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.tableService.Save(eventId(ctx), req, actor(ctx))
 	if err != nil {
 		log.Println("Create Table Controller - Could not create new table")
-		ctx.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.Error(err)
+		return
 	}
 
 	log.Println("Create Table Controller - Successfully added table")
 	ctx.IndentedJSON(http.StatusCreated, res)
 }
 
+// Replaces the table's details, every field has to be given
+func (c *tableController) UpdateTable(ctx *gin.Context) {
+	var req dto.TableReqDto
+
+	id, err := tableId(ctx)
+	if err != nil {
+		log.Println("Update Table Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	err = readJSON(ctx, &req)
+	if err != nil {
+		log.Println("Update Table Controller - Could not retrieve table data")
+		ctx.Error(err)
+		return
+	}
+
+	err = validation.Struct(req)
+	if err != nil {
+		log.Println("Update Table Controller - The table is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.tableService.Update(eventId(ctx), id, dto.TablePatchReqDto{Capacity: &req.Capacity, NearStage: &req.NearStage}, actor(ctx))
+	if err != nil {
+		log.Println("Update Table Controller - Could not update table")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Update Table Controller - Successfully updated table")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// Changes the fields of the table that are in the request
+func (c *tableController) PatchTable(ctx *gin.Context) {
+	var req dto.TablePatchReqDto
+
+	id, err := tableId(ctx)
+	if err != nil {
+		log.Println("Patch Table Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	err = readJSON(ctx, &req)
+	if err != nil {
+		log.Println("Patch Table Controller - Could not retrieve table data")
+		ctx.Error(err)
+		return
+	}
+
+	err = validation.Struct(req)
+	if err != nil {
+		log.Println("Patch Table Controller - The table is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.tableService.Update(eventId(ctx), id, req, actor(ctx))
+	if err != nil {
+		log.Println("Patch Table Controller - Could not update table")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Patch Table Controller - Successfully updated table")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// Deletes the table, ?reassign_to=<table id> moves its guests to another table first
+func (c *tableController) DeleteTable(ctx *gin.Context) {
+	id, err := tableId(ctx)
+	if err != nil {
+		log.Println("Delete Table Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	reassignTo := 0
+	if value, ok := ctx.GetQuery("reassign_to"); ok {
+		reassignTo, err = strconv.Atoi(value)
+		if err != nil || reassignTo < 1 || reassignTo == id {
+			log.Println("Delete Table Controller - The table to move the guests to is not valid")
+			ctx.Error(&validation.Error{Fields: []dto.FieldErrorResDto{{Field: "reassign_to", Message: "must be the id of another table"}}})
+			return
+		}
+	}
+
+	err = c.tableService.Delete(eventId(ctx), id, reassignTo, actor(ctx))
+	if err != nil {
+		log.Println("Delete Table Controller - Could not delete table")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Delete Table Controller - Successfully deleted table")
+	ctx.Status(http.StatusNoContent)
+}
+
 func (c *tableController) GetSpace(ctx *gin.Context) {
-	space, ok := c.tableService.CheckSpace()
+	// Having no empty seats is not an error, the response says there are none
+	res, ok := c.tableService.CheckSpace(eventId(ctx))
 	if !ok {
 		log.Println("Available Space Controller - There are no empty seats")
-		ctx.IndentedJSON(http.StatusNoContent, gin.H{"seats_empty": 0})
+	} else {
+		log.Println("Available Space Controller - There are empty seats")
 	}
 
-	log.Println("Available Space Controller - There are empty seats")
-	ctx.IndentedJSON(http.StatusOK, gin.H{"seats_empty": space})
+	ctx.IndentedJSON(http.StatusOK, res)
 }
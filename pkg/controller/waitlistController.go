@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/gin-gonic/gin"
+)
+
+type WaitlistController interface {
+	GetWaitlist(ctx *gin.Context)
+	GetAnEntry(ctx *gin.Context)
+	DeleteEntry(ctx *gin.Context)
+}
+
+type waitlistController struct {
+	waitlistService service.WaitlistService
+}
+
+func NewWaitlistController(waitlistS service.WaitlistService) WaitlistController {
+	return &waitlistController{
+		waitlistService: waitlistS,
+	}
+}
+
+// Lists the parties still waiting, with their place in the line for their table
+func (c *waitlistController) GetWaitlist(ctx *gin.Context) {
+	res, err := c.waitlistService.FindAll(eventId(ctx))
+	if err != nil {
+		log.Println("Get Waitlist Controller - Could not retrieve the waitlist")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Waitlist Controller - Successfully retrieved the waitlist")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *waitlistController) GetAnEntry(ctx *gin.Context) {
+	id, err := entryId(ctx)
+	if err != nil {
+		log.Println("Get Waitlist Entry Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.waitlistService.FindById(eventId(ctx), id)
+	if err != nil {
+		log.Println("Get Waitlist Entry Controller - Could not retrieve entry")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Waitlist Entry Controller - Successfully retrieved entry")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// Takes a party off the waitlist
+func (c *waitlistController) DeleteEntry(ctx *gin.Context) {
+	id, err := entryId(ctx)
+	if err != nil {
+		log.Println("Delete Waitlist Entry Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	err = c.waitlistService.Delete(eventId(ctx), id)
+	if err != nil {
+		log.Println("Delete Waitlist Entry Controller - Could not delete entry")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Delete Waitlist Entry Controller - Successfully deleted entry")
+	ctx.Status(http.StatusNoContent)
+}
+
+// An id that is not a number is an entry that does not exist
+func entryId(ctx *gin.Context) (int, error) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", service.ErrWaitlistEntryNotFound, ctx.Param("id"))
+	}
+	return id, nil
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController interface {
+	GetWebhooks(ctx *gin.Context)
+	GetAWebhook(ctx *gin.Context)
+	CreateWebhook(ctx *gin.Context)
+	DeleteWebhook(ctx *gin.Context)
+	GetDeliveries(ctx *gin.Context)
+}
+
+type webhookController struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookController(webhookS service.WebhookService) WebhookController {
+	return &webhookController{
+		webhookService: webhookS,
+	}
+}
+
+func (c *webhookController) GetWebhooks(ctx *gin.Context) {
+	res, err := c.webhookService.FindAll(eventId(ctx))
+	if err != nil {
+		log.Println("Get Webhooks Controller - Could not retrieve webhooks")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Webhooks Controller - Successfully retrieved webhooks")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+func (c *webhookController) GetAWebhook(ctx *gin.Context) {
+	id, err := webhookId(ctx)
+	if err != nil {
+		log.Println("Get Webhook Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.webhookService.FindById(eventId(ctx), id)
+	if err != nil {
+		log.Println("Get Webhook Controller - Could not retrieve webhook")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Webhook Controller - Successfully retrieved webhook")
+	ctx.IndentedJSON(http.StatusOK, res)
+}
+
+// Subscribes a URL to some of the event's changes, the secret signs every payload sent to it and is not sent back
+func (c *webhookController) CreateWebhook(ctx *gin.Context) {
+	var req dto.WebhookReqDto
+
+	err := readJSON(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Create Webhook Controller - The webhook is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, err := c.webhookService.Save(eventId(ctx), req)
+	if err != nil {
+		log.Println("Create Webhook Controller - Could not create webhook")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Create Webhook Controller - Successfully added webhook")
+	ctx.IndentedJSON(http.StatusCreated, res)
+}
+
+// Removes the webhook, the deliveries it has not been sent yet are dropped
+func (c *webhookController) DeleteWebhook(ctx *gin.Context) {
+	id, err := webhookId(ctx)
+	if err != nil {
+		log.Println("Delete Webhook Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	err = c.webhookService.Delete(eventId(ctx), id)
+	if err != nil {
+		log.Println("Delete Webhook Controller - Could not delete webhook")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Delete Webhook Controller - Successfully deleted webhook")
+	ctx.Status(http.StatusNoContent)
+}
+
+// Lists what was sent to the webhook and how the receiver answered, the latest first
+func (c *webhookController) GetDeliveries(ctx *gin.Context) {
+	id, err := webhookId(ctx)
+	if err != nil {
+		log.Println("Get Deliveries Controller - The id is not a number")
+		ctx.Error(err)
+		return
+	}
+
+	var req dto.DeliveryListReqDto
+	err = readQuery(ctx, &req)
+	if err == nil {
+		err = validation.Struct(req)
+	}
+	if err != nil {
+		log.Println("Get Deliveries Controller - The query is not valid")
+		ctx.Error(err)
+		return
+	}
+
+	res, page, err := c.webhookService.FindDeliveries(eventId(ctx), id, req)
+	if err != nil {
+		log.Println("Get Deliveries Controller - Could not retrieve the deliveries")
+		ctx.Error(err)
+		return
+	}
+
+	log.Println("Get Deliveries Controller - Successfully retrieved the deliveries")
+	writePage(ctx, res, page)
+}
+
+// An id that is not a number is a webhook that does not exist
+func webhookId(ctx *gin.Context) (int, error) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", service.ErrWebhookNotFound, ctx.Param("id"))
+	}
+	return id, nil
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//This is the request DTO for listing the audit log, it is read from the query string.
+type AuditListReqDto struct {
+	Actor      string `form:"actor" binding:"max=100"`
+	Action     string `form:"action" binding:"omitempty,oneof=guest.created guest.updated guest.checked_in guest.checked_out guest.deleted table.created table.updated table.deleted"`
+	Entity     string `form:"entity" binding:"omitempty,oneof=guest table"`
+	Entity_ID  string `form:"entity_id" binding:"max=32"`
+	Request_ID string `form:"request_id" binding:"max=64"`
+	// RFC 3339 times, the entries recorded from since up to but not including until
+	Since time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Newest first unless sorted by id
+	Sort   string `form:"sort" binding:"omitempty,oneof=id -id"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+//This is the response DTO for an entry of the audit log.
+type AuditResDto struct {
+	Id     int    `json:"id"`
+	Time   string `json:"time"`
+	Actor  string `json:"actor"`
+	Role   string `json:"role,omitempty"`
+	Action string `json:"action"`
+	Entity string `json:"entity"`
+	// The guest's public id or the table's id
+	Entity_ID string `json:"entity_id"`
+	// The guest or table as it was before and after the change, left out before it was created and after it was deleted
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Request_ID string          `json:"request_id,omitempty"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+// This is the response DTO for a session, the token is only given when the session is opened.
+type SessionResDto struct {
+	Token   string `json:"token,omitempty"`
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	// api_key or session, how the request was authenticated
+	Method  string `json:"method,omitempty"`
+	Expires string `json:"expires,omitempty"`
+}
@@ -0,0 +1,33 @@
+package dto
+
+//This is the request DTO for a seating rule, the guests are given by their id or name.
+type ConstraintReqDto struct {
+	// together, apart, pinned or near_stage
+	Type   string   `json:"type" binding:"required,oneof=together apart pinned near_stage"`
+	Guests []string `json:"guests" binding:"required,min=1,max=50,dive,required,max=100"`
+	// The table a pinned guest sits at
+	Table_ID int `json:"table_id" binding:"required_if=Type pinned,min=0"`
+	// How much a near stage wish counts, from 1 to 10, 1 when it is left out
+	Priority int `json:"priority" binding:"omitempty,min=1,max=10"`
+}
+
+//This is the response DTO for a seating rule.
+type ConstraintResDto struct {
+	Id   int    `json:"id"`
+	Type string `json:"type"`
+	// The ids of the guests
+	Guests   []string `json:"guests"`
+	Table_ID int      `json:"table_id,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+}
+
+//This is the response DTO for a seating rule the guest list breaks.
+type ViolationResDto struct {
+	Constraint_ID int    `json:"constraint_id"`
+	Type          string `json:"type"`
+	// Whether guests are kept from moving in a way that breaks the rule, only near_stage wishes are not
+	Hard bool `json:"hard"`
+	// The ids of the guests that break the rule
+	Guests  []string `json:"guests"`
+	Message string   `json:"message"`
+}
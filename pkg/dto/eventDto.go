@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+//This is the request DTO for the event model.
+type EventReqDto struct {
+	Name      string    `json:"name" binding:"required,max=200"`
+	Venue     string    `json:"venue" binding:"max=200"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time" binding:"gtefield=StartTime"`
+	Timezone  string    `json:"timezone" binding:"max=64"`
+	// Left out the server's default is used
+	OverbookAllowance *int `json:"overbook_allowance,omitempty" binding:"omitempty,min=0,max=100"`
+}
+
+//This is the response DTO for the event model.
+type EventResDto struct {
+	Id                int       `json:"id"`
+	Name              string    `json:"name"`
+	Venue             string    `json:"venue"`
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	Timezone          string    `json:"timezone"`
+	OverbookAllowance int       `json:"overbook_allowance"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+//This is the request DTO for an export of the guest list, it is read from the query string.
+type GuestExportReqDto struct {
+	// Left out the guests are exported as JSON
+	Format string `form:"format" binding:"omitempty,oneof=csv json"`
+}
+
+//This is the response DTO for a guest in an export, every field is always there so each record has the same columns.
+type GuestExportResDto struct {
+	Id                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Aliases            []string `json:"aliases"`
+	Table_ID           int      `json:"table_id"`
+	Acompanying_Guests int      `json:"accompanying_guests"`
+	// The guest and the people they bring
+	PartySize   int    `json:"party_size"`
+	TimeArrived string `json:"time_arrived"`
+	TimeLeft    string `json:"time_left"`
+	// Whether the guest was given a table at the door
+	WalkIn bool `json:"walk_in"`
+}
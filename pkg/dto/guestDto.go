@@ -2,18 +2,83 @@
 package dto
 
 //This is the request DTO for the guest model.
+//The binding tags are the rules the request is validated against, when a guest is added the name is taken from the route.
 type GuestReqDto struct {
-	Name               string `json:"name,omitempty"`
-	Table_ID           int    `json:"table_id,omitempty"`
-	Acompanying_Guests int    `json:"accompanying_guests"`
-	TimeArrived        string `json:"time_arrived,omitempty"`
+	Name               string   `json:"name,omitempty" binding:"required,max=100,personname"`
+	Aliases            []string `json:"aliases,omitempty" binding:"max=10,dive,required,max=100,personname"`
+	Table_ID           int      `json:"table_id,omitempty" binding:"required,min=1"`
+	Acompanying_Guests int      `json:"accompanying_guests" binding:"min=0,max=100"`
+	// Puts the party on the waitlist for the table when it is fully booked, instead of turning it away
+	Waitlist bool `json:"waitlist,omitempty"`
+	// The priority on the waitlist, higher goes first
+	Priority int `json:"priority,omitempty" binding:"min=0,max=10"`
+}
+
+//This is the request DTO for changing a guest-list entry, the fields that are left out are not changed.
+type GuestPatchReqDto struct {
+	Name string `json:"name,omitempty" binding:"omitempty,max=100,personname"`
+	// An empty list removes every alias
+	Aliases            []string `json:"aliases" binding:"max=10,dive,required,max=100,personname"`
+	Table_ID           int      `json:"table_id,omitempty" binding:"omitempty,min=1"`
+	Acompanying_Guests *int     `json:"accompanying_guests,omitempty" binding:"omitempty,min=0,max=100"`
+}
+
+//This is the request DTO for a guest who is not on the guest list and is given a table at the door.
+type WalkInReqDto struct {
+	Name               string   `json:"name" binding:"required,max=100,personname"`
+	Aliases            []string `json:"aliases,omitempty" binding:"max=10,dive,required,max=100,personname"`
+	Acompanying_Guests int      `json:"accompanying_guests" binding:"min=0,max=100"`
+}
+
+//This is the request DTO for checking in a guest.
+type CheckinReqDto struct {
+	Acompanying_Guests int `json:"accompanying_guests" binding:"min=0,max=100"`
+	// Puts the guest on the waitlist for their table when it has too few free seats, instead of turning them away
+	Waitlist bool `json:"waitlist,omitempty"`
+	// The priority on the waitlist, higher goes first
+	Priority int `json:"priority,omitempty" binding:"min=0,max=10"`
 }
 
 //This is the response DTO for the guest model.
 type GuestResDto struct {
-	Id                 int    `json:"id,omitempty"`
-	Name               string `json:"name,omitempty"`
-	Table_ID           int    `json:"table_id,omitempty"`
-	Acompanying_Guests int    `json:"accompanying_guests"`
-	TimeArrived        string `json:"time_arrived,omitempty"`
+	Id                 string   `json:"id,omitempty"`
+	Name               string   `json:"name,omitempty"`
+	Aliases            []string `json:"aliases,omitempty"`
+	Table_ID           int      `json:"table_id,omitempty"`
+	Acompanying_Guests int      `json:"accompanying_guests"`
+	TimeArrived        string   `json:"time_arrived,omitempty"`
+	TimeLeft           string   `json:"time_left,omitempty"`
+	WalkIn             bool     `json:"walk_in,omitempty"`
+	// Set when the party was put on the waitlist rather than given its seats
+	Waitlist *WaitlistResDto `json:"waitlist,omitempty"`
+}
+
+//This is the request DTO for the filters, order and page of a list of guests, it is read from the query string.
+type GuestListReqDto struct {
+	Table_ID int `form:"table_id" binding:"omitempty,min=1"`
+	// Whether the guests have arrived at some point, left out lists both
+	Arrived *bool `form:"arrived"`
+	// The party size counts the guest
+	MinPartySize int    `form:"min_party_size" binding:"omitempty,min=1"`
+	MaxPartySize int    `form:"max_party_size" binding:"omitempty,min=1,gtefield=MinPartySize"`
+	NamePrefix   string `form:"name_prefix" binding:"max=100"`
+	// A minus in front sorts the other way round
+	Sort   string `form:"sort" binding:"omitempty,oneof=id -id name -name time_arrived -time_arrived table_id -table_id"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+//This is the request DTO for searching the guest list, it is read from the query string.
+type GuestSearchReqDto struct {
+	Q     string `form:"q" binding:"required,max=100"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+//This is the response DTO for a guest found by a search, the best match first.
+type GuestMatchResDto struct {
+	GuestResDto
+	// How well the guest matched, from 0 to 1
+	Score float64 `json:"score"`
+	// The name or alias that matched best
+	Matched string `json:"matched"`
 }
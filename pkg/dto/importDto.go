@@ -0,0 +1,35 @@
+package dto
+
+//This is the request DTO for importing a guest list, it is read from the query string and the file is the body.
+type GuestImportReqDto struct {
+	// Checks every row and reports the errors without adding any guest
+	DryRun bool `form:"dry_run"`
+	// Left out it is worked out from the Content-Type of the request, or else from the file itself
+	Format string `form:"format" binding:"omitempty,oneof=csv xlsx"`
+}
+
+//This is a row of an imported guest list read into the request DTO for adding a guest.
+type GuestImportRowDto struct {
+	// The row of the spreadsheet, the header is row 1
+	Row   int
+	Guest GuestReqDto
+	// The rules the row breaks, a row with errors is not added
+	Errors []FieldErrorResDto
+}
+
+//This is the response DTO for an import, it is the report of a dry run too.
+type GuestImportResDto struct {
+	DryRun bool `json:"dry_run"`
+	// The rows read from the file, not counting the header or empty rows
+	Rows int `json:"rows"`
+	// The guests that were added, or would be added on a dry run
+	Imported int                    `json:"imported"`
+	Errors   []ImportRowErrorResDto `json:"errors,omitempty"`
+}
+
+//This is the response DTO for a row of an import that can not be added.
+type ImportRowErrorResDto struct {
+	Row    int                `json:"row"`
+	Name   string             `json:"name,omitempty"`
+	Errors []FieldErrorResDto `json:"errors"`
+}
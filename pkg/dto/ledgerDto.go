@@ -0,0 +1,22 @@
+package dto
+
+//This is the response DTO for a check of the guests and tables against the ledger, or a rebuild of them from it.
+type LedgerCheckResDto struct {
+	// Whether the stored guests and tables are the ones the ledger projects, for a rebuild whether they were before it
+	Consistent bool `json:"consistent"`
+	Entries    int  `json:"entries"`
+	// The guests and tables the ledger projects
+	Guests      int                      `json:"guests"`
+	Tables      int                      `json:"tables"`
+	Differences []LedgerDifferenceResDto `json:"differences"`
+}
+
+//This is the response DTO for a field whose stored value is not the one the ledger has.
+type LedgerDifferenceResDto struct {
+	Entity    string `json:"entity"`
+	Entity_ID int    `json:"entity_id"`
+	// Left out when the whole guest or table is missing on one side
+	Field  string `json:"field,omitempty"`
+	Ledger string `json:"ledger"`
+	Stored string `json:"stored"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+//This describes the page of a list that was returned, it is sent in the response headers rather than the body.
+type PageDto struct {
+	// The rows matching the filters on every page
+	Total int64
+	// Passed as the cursor to get the next page, empty on the last page
+	Next string
+}
@@ -0,0 +1,23 @@
+package dto
+
+//This is the response DTO for an error, in the RFC 7807 problem details format.
+type ProblemResDto struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// The guests a name could mean, when it is ambiguous
+	Candidates []GuestResDto `json:"candidates,omitempty"`
+	// The fields of the request that broke a validation rule
+	Errors []FieldErrorResDto `json:"errors,omitempty"`
+	// The rows of an import that can not be added
+	Rows []ImportRowErrorResDto `json:"rows,omitempty"`
+}
+
+//This is the response DTO for a field of the request that is not valid.
+type FieldErrorResDto struct {
+	// The name of the field in the request body, or of the route parameter
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,43 @@
+package dto
+
+//This is the request DTO for planning the tables of parties that are not on the guest list yet, and of guests on it who are re-seated.
+type SeatingPlanReqDto struct {
+	Parties []SeatingPartyReqDto `json:"parties" binding:"required_without=Guests,max=500,dive"`
+	// The ids or names of guests on the guest list to find new tables for, by their seating constraints
+	Guests []string `json:"guests" binding:"max=500,dive,required,max=100"`
+	// preview, the default, only shows the plan, apply adds the parties to the guest list at their tables
+	Mode string `json:"mode" binding:"omitempty,oneof=preview apply"`
+}
+
+//This is the request DTO for a party to seat, the guest and the people they bring sit at the same table.
+type SeatingPartyReqDto struct {
+	Name               string `json:"name" binding:"required,max=100,personname"`
+	Acompanying_Guests int    `json:"accompanying_guests" binding:"min=0,max=100"`
+}
+
+//This is the response DTO for a seating plan.
+type SeatingPlanResDto struct {
+	Mode string `json:"mode"`
+	// exact when no other plan is better, heuristic when the plan was found by a rule of thumb
+	Solver string `json:"solver"`
+	// The people that have a seat in the plan
+	Seated int `json:"seated"`
+	// The priorities of the near stage wishes the plan meets
+	NearStage int `json:"near_stage"`
+	// The seats left empty at the tables that have someone at them
+	WastedSeats int                    `json:"wasted_seats"`
+	Assignments []SeatAssignmentResDto `json:"assignments"`
+	// The parties that no table has room for or that no table keeps to their seating constraints, a plan with any is not applied
+	Unseated []SeatAssignmentResDto `json:"unseated"`
+	// The tables as they are with the plan
+	Tables []TableResDto `json:"tables"`
+}
+
+//This is the response DTO for the table a party is given.
+type SeatAssignmentResDto struct {
+	// The id of the guest, once the plan is applied or when the guest is re-seated
+	Id                 string `json:"id,omitempty"`
+	Name               string `json:"name"`
+	Acompanying_Guests int    `json:"accompanying_guests"`
+	Table_ID           int    `json:"table_id,omitempty"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+//This is the request DTO for the filters of the live stream, it is read from the query string.
+type StreamReqDto struct {
+	// Left out follows every event
+	Event_ID int `form:"event_id" binding:"omitempty,min=1"`
+	// Given more than once follows each of the tables, left out follows every table
+	Table_ID []int `form:"table_id" binding:"omitempty,max=100,dive,min=1"`
+	// The id of the last message the client got, for clients that can not send the Last-Event-ID header
+	Last_Event_ID *int64 `form:"last_event_id" binding:"omitempty,min=0"`
+}
@@ -2,11 +2,36 @@ package dto
 
 //This is the request DTO for the table model.
 type TableReqDto struct {
-	Capacity int `json:"capacity"`
+	Capacity  int  `json:"capacity" binding:"min=1,max=1000"`
+	NearStage bool `json:"near_stage"`
+}
+
+//This is the request DTO for changing a table, the fields that are left out are not changed.
+type TablePatchReqDto struct {
+	Capacity  *int  `json:"capacity,omitempty" binding:"omitempty,min=1,max=1000"`
+	NearStage *bool `json:"near_stage,omitempty"`
 }
 
 //This is the response DTO for the table model.
 type TableResDto struct {
-	Id       int `json:"id,omitempty"`
-	Capacity int `json:"capacity"`
+	Id        int  `json:"id,omitempty"`
+	Capacity  int  `json:"capacity"`
+	NearStage bool `json:"near_stage"`
+	Reserved  int  `json:"reserved"`
+	Arrived   int  `json:"arrived"`
+	Free      int  `json:"free"`
+}
+
+//This is the response DTO for the empty seats across every table.
+type SeatsResDto struct {
+	SeatsEmpty int           `json:"seats_empty"`
+	Tables     []TableResDto `json:"tables"`
+}
+
+//This is the request DTO for the order and page of a list of tables, it is read from the query string.
+type TableListReqDto struct {
+	// A minus in front sorts the other way round
+	Sort   string `form:"sort" binding:"omitempty,oneof=id -id capacity -capacity"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=1000"`
 }
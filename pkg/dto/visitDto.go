@@ -0,0 +1,8 @@
+package dto
+
+//This is the response DTO for the visit model.
+type VisitResDto struct {
+	Acompanying_Guests int    `json:"accompanying_guests"`
+	TimeArrived        string `json:"time_arrived"`
+	TimeLeft           string `json:"time_left,omitempty"`
+}
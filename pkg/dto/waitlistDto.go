@@ -0,0 +1,20 @@
+package dto
+
+//This is the response DTO for a party on the waitlist.
+type WaitlistResDto struct {
+	Id int `json:"id"`
+	// booking when the party waits for seats to book, arrival when a guest on the guest list waits at the door
+	Kind string `json:"kind"`
+	// The id of the guest who arrived, or the id a booking was added to the guest list with once it was promoted
+	Guest              string `json:"guest,omitempty"`
+	Name               string `json:"name"`
+	Table_ID           int    `json:"table_id"`
+	Acompanying_Guests int    `json:"accompanying_guests"`
+	Priority           int    `json:"priority"`
+	// waiting or promoted
+	Status string `json:"status"`
+	// The place in the line for the table, 1 is next, 0 once the party is no longer waiting
+	Position int    `json:"position"`
+	Queued   string `json:"queued"`
+	Promoted string `json:"promoted,omitempty"`
+}
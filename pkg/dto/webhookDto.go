@@ -0,0 +1,46 @@
+package dto
+
+import "encoding/json"
+
+//This is the request DTO for the webhook model.
+type WebhookReqDto struct {
+	URL string `json:"url" binding:"required,max=2048,webhookurl"`
+	// Signs the payloads, the receiver checks the signature with it
+	Secret string   `json:"secret" binding:"required,min=16,max=256"`
+	Events []string `json:"events" binding:"required,min=1,max=4,dive,oneof=guest.created guest.checked_in guest.checked_out table.full"`
+}
+
+//This is the response DTO for the webhook model, the secret is never sent back.
+type WebhookResDto struct {
+	Id      int      `json:"id"`
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Created string   `json:"created"`
+}
+
+//This is the request DTO for the filters and page of a webhook's delivery log, it is read from the query string.
+type DeliveryListReqDto struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered failed"`
+	// A minus in front sorts the other way round
+	Sort   string `form:"sort" binding:"omitempty,oneof=id -id"`
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+//This is the response DTO for a delivery of a webhook.
+type DeliveryResDto struct {
+	Id         int    `json:"id"`
+	Webhook_ID int    `json:"webhook_id"`
+	Type       string `json:"type"`
+	// pending, delivered or failed
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// When a pending delivery is tried next
+	NextAttempt    string          `json:"next_attempt,omitempty"`
+	LastAttempt    string          `json:"last_attempt,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Delivered      string          `json:"delivered,omitempty"`
+	Created        string          `json:"created"`
+	Payload        json.RawMessage `json:"payload"`
+}
@@ -0,0 +1,184 @@
+// The export package turns the guest list and the tables of an event into documents to hand to caterers and the venue:
+// the guest list as CSV or JSON and a seating chart as a PDF to print, and the audit log as JSON lines.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+)
+
+// The guests as they are exported, in the order of their tables and then their names
+func Guests(guests []dto.GuestResDto) []dto.GuestExportResDto {
+	res := []dto.GuestExportResDto{}
+	for _, v := range guests {
+		aliases := v.Aliases
+		if aliases == nil {
+			aliases = []string{}
+		}
+		res = append(res, dto.GuestExportResDto{
+			Id:                 v.Id,
+			Name:               v.Name,
+			Aliases:            aliases,
+			Table_ID:           v.Table_ID,
+			Acompanying_Guests: v.Acompanying_Guests,
+			PartySize:          v.Acompanying_Guests + 1,
+			TimeArrived:        v.TimeArrived,
+			TimeLeft:           v.TimeLeft,
+			WalkIn:             v.WalkIn,
+		})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Table_ID != res[j].Table_ID {
+			return res[i].Table_ID < res[j].Table_ID
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// The header of the CSV export, the columns are the JSON names of the fields
+var csvHeader = []string{"id", "name", "aliases", "table_id", "accompanying_guests", "party_size", "time_arrived", "time_left", "walk_in"}
+
+// Writes the guests as CSV with a header row, the aliases are separated by semicolons like in an import
+func WriteGuestsCSV(w io.Writer, guests []dto.GuestExportResDto) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, v := range guests {
+		record := []string{
+			v.Id,
+			csvText(v.Name),
+			csvText(strings.Join(v.Aliases, ";")),
+			strconv.Itoa(v.Table_ID),
+			strconv.Itoa(v.Acompanying_Guests),
+			strconv.Itoa(v.PartySize),
+			v.TimeArrived,
+			v.TimeLeft,
+			strconv.FormatBool(v.WalkIn),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the entries of the audit log one JSON object to a line, so the file can be read a line at a time however long it is
+func WriteAuditJSONLines(w io.Writer, entries []dto.AuditResDto) error {
+	encoder := json.NewEncoder(w)
+	for _, v := range entries {
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Spreadsheet programs run a cell starting with one of these as a formula, a leading apostrophe keeps it text
+func csvText(s string) string {
+	if s != "" && strings.ContainsAny(s[:1], "=+-@") {
+		return "'" + s
+	}
+	return s
+}
+
+// Writes the seating chart of the event: every table with the guests on its list, the people they bring
+// and whether they have arrived or left
+func WriteSeatingChart(w io.Writer, event dto.EventResDto, tables []dto.TableResDto, guests []dto.GuestExportResDto) error {
+	loc, err := time.LoadLocation(event.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	byTable := make(map[int][]dto.GuestExportResDto)
+	for _, v := range guests {
+		byTable[v.Table_ID] = append(byTable[v.Table_ID], v)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Id < tables[j].Id })
+
+	doc := newPDF()
+
+	title := "Seating chart"
+	if event.Name != "" {
+		title += ": " + event.Name
+	}
+	doc.line(fontBold, 18, 0, 0, title)
+
+	var details []string
+	if event.Venue != "" {
+		details = append(details, event.Venue)
+	}
+	if !event.StartTime.IsZero() {
+		details = append(details, event.StartTime.In(loc).Format("Monday 2 January 2006, 15:04"))
+	}
+	if len(details) > 0 {
+		doc.line(fontRegular, 11, 0, 6, strings.Join(details, " · "))
+	}
+
+	seats, reserved, people := 0, 0, 0
+	for _, t := range tables {
+		seats += t.Capacity
+		reserved += t.Reserved
+	}
+	for _, v := range guests {
+		people += v.PartySize
+	}
+	doc.line(fontRegular, 11, 0, 4, fmt.Sprintf("%d tables, %d seats, %d reserved, %d guests on the list", len(tables), seats, reserved, people))
+
+	for _, t := range tables {
+		// A table's heading is not left alone at the bottom of a page
+		if !doc.fits(13+4+11, 18) {
+			doc.newPage()
+		}
+		doc.line(fontBold, 13, 0, 18, fmt.Sprintf("Table %d: %d seats, %d reserved", t.Id, t.Capacity, t.Reserved))
+
+		if len(byTable[t.Id]) == 0 {
+			doc.line(fontRegular, 11, 15, 4, "No guests")
+		}
+		for _, v := range byTable[t.Id] {
+			doc.line(fontRegular, 11, 15, 4, guestLine(v))
+		}
+	}
+
+	_, err = doc.WriteTo(w)
+	return err
+}
+
+// Hannah Smith, party of 3, walk-in, arrived 20:15
+func guestLine(guest dto.GuestExportResDto) string {
+	text := guest.Name
+	if guest.PartySize > 1 {
+		text += fmt.Sprintf(", party of %d", guest.PartySize)
+	}
+	if guest.WalkIn {
+		text += ", walk-in"
+	}
+	if guest.TimeLeft != "" {
+		text += ", left " + clock(guest.TimeLeft)
+	} else if guest.TimeArrived != "" {
+		text += ", arrived " + clock(guest.TimeArrived)
+	}
+	return text
+}
+
+// The time of day of an RFC 3339 time, it is already in the event's timezone
+func clock(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.Format("15:04")
+}
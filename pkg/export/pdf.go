@@ -0,0 +1,113 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// A PDF document of plain text pages, written with the fonts every PDF reader has so nothing has to be embedded.
+// Only what the seating chart needs is supported: lines of text in a regular or bold font.
+
+// A4 in points, the unit of PDF
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 50
+)
+
+// The standard fonts, the name is the resource name used in the page content
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	// The top of the next line on the current page
+	y float64
+}
+
+func newPDF() *pdfDocument {
+	doc := &pdfDocument{}
+	doc.newPage()
+	return doc
+}
+
+func (doc *pdfDocument) newPage() {
+	doc.pages = append(doc.pages, &bytes.Buffer{})
+	doc.y = pageHeight - margin
+}
+
+// Writes a line of text at the indent from the left margin, starting a new page when the line does not fit.
+// gap is the space left above the line.
+func (doc *pdfDocument) line(font string, size float64, indent float64, gap float64, text string) {
+	if doc.y-gap-size < margin {
+		doc.newPage()
+		gap = 0
+	}
+	doc.y -= gap + size
+
+	page := doc.pages[len(doc.pages)-1]
+	fmt.Fprintf(page, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, size, margin+indent, doc.y, pdfString(text))
+}
+
+// Whether a line of the size still fits on the current page after the gap
+func (doc *pdfDocument) fits(size float64, gap float64) bool {
+	return doc.y-gap-size >= margin
+}
+
+// The standard fonts only have the Windows-1252 characters, the others are printed as ?
+var pdfEncoder = encoding.ReplaceUnsupported(charmap.Windows1252.NewEncoder())
+
+// The text as the inside of a PDF string literal
+func pdfString(text string) string {
+	encoded, err := pdfEncoder.String(text)
+	if err != nil {
+		encoded = text
+	}
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", "", "\n", " ").Replace(encoded)
+}
+
+// Writes the document: the catalog, the page tree, the two fonts, then a page and its content for every page,
+// followed by the table of where each object starts
+func (doc *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 4 come first, the pages are numbered from 5 with their content right after them
+	var kids []string
+	for i := range doc.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(doc.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range doc.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(out.Bytes())
+	return int64(n), err
+}
@@ -0,0 +1,126 @@
+// The hub package passes what happens at a party on to the parts of the server that follow it.
+//
+// The services publish a message once a change is committed, and every subscriber whose filter matches gets a copy.
+// Publishing never waits for a subscriber, one that does not keep up misses messages rather than holding up a check-in.
+// The last messages are kept, so a subscriber that lost its connection can pick up from the last message it got.
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+// Something that happened to an event's guest list or tables
+type Message struct {
+	// Counts up from 1 across every event, in the order the messages were published
+	Id       int64  `json:"id"`
+	Type     string `json:"type"`
+	Event_ID int    `json:"event_id"`
+	// The table it happened at, 0 when it is not about one table
+	Table_ID int         `json:"table_id,omitempty"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data"`
+}
+
+type subscriber struct {
+	ch     chan Message
+	filter func(Message) bool
+}
+
+// How many of the last messages are kept to be replayed
+const History = 1000
+
+type Hub struct {
+	mu          sync.Mutex
+	lastId      int64
+	subscribers map[*subscriber]bool
+	// The last messages, oldest first
+	history []Message
+}
+
+func New() *Hub {
+	return &Hub{
+		subscribers: make(map[*subscriber]bool),
+	}
+}
+
+// Sends the message to every subscriber that wants it
+func (h *Hub) Publish(kind string, eventId int, tableId int, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastId++
+	msg := Message{Id: h.lastId, Type: kind, Event_ID: eventId, Table_ID: tableId, Time: time.Now().UTC(), Data: data}
+	if len(h.history) == History {
+		h.history = append(h.history[:0], h.history[1:]...)
+	}
+	h.history = append(h.history, msg)
+
+	for s := range h.subscribers {
+		if s.filter != nil && !s.filter(msg) {
+			continue
+		}
+		select {
+		case s.ch <- msg:
+		default:
+		}
+	}
+}
+
+// Returns the messages the filter lets through, a nil filter lets every message through.
+// The channel holds up to buffer messages that have not been read, cancel stops the messages and closes it.
+func (h *Hub) Subscribe(filter func(Message) bool, buffer int) (<-chan Message, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribe(filter, buffer)
+}
+
+// The messages a resumed subscriber missed
+type Replay struct {
+	// The kept messages the filter lets through that were published after the one resumed from, oldest first
+	Messages []Message
+	// False when some of the messages after the one resumed from are no longer kept, or it is not one the hub published,
+	// which is the case when the server was restarted since
+	Complete bool
+	// The id of the last message published before the subscription started
+	LastId int64
+}
+
+// Subscribes like Subscribe, and hands back the messages published after the one with the id after.
+// Both happen at once, so no message is missed or received twice in between.
+func (h *Hub) Resume(after int64, filter func(Message) bool, buffer int) (Replay, <-chan Message, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	replay := Replay{LastId: h.lastId}
+	// The ids of the kept messages follow each other, the oldest is the one after the last that was dropped
+	replay.Complete = after >= h.lastId-int64(len(h.history)) && after <= h.lastId
+	if replay.Complete {
+		for _, msg := range h.history {
+			if msg.Id > after && (filter == nil || filter(msg)) {
+				replay.Messages = append(replay.Messages, msg)
+			}
+		}
+	}
+
+	ch, cancel := h.subscribe(filter, buffer)
+	return replay, ch, cancel
+}
+
+// Adds the subscriber, the caller holds the lock
+func (h *Hub) subscribe(filter func(Message) bool, buffer int) (<-chan Message, func()) {
+	s := &subscriber{ch: make(chan Message, buffer), filter: filter}
+	h.subscribers[s] = true
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, s)
+			h.mu.Unlock()
+			close(s.ch)
+		})
+	}
+	return s.ch, cancel
+}
@@ -0,0 +1,200 @@
+// The importer package reads a guest list from a CSV or XLSX spreadsheet into the requests for adding the guests.
+//
+// The first row is the header, the columns are found by their header so they can be in any order,
+// and the header of each column can be mapped for spreadsheets that name them differently.
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+)
+
+// The spreadsheet formats that can be read
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// The columns of a guest list, they are the JSON names of the fields of a guest
+const (
+	ColumnName      = "name"
+	ColumnTable     = "table_id"
+	ColumnPartySize = "accompanying_guests"
+	// The aliases of a guest are in one cell, separated by semicolons
+	ColumnAliases = "aliases"
+)
+
+var columns = []string{ColumnName, ColumnTable, ColumnPartySize, ColumnAliases}
+
+// The most rows an import can have, besides the header
+const MaxRows = 5000
+
+// Returned when the file is not a spreadsheet of the format or has no header
+var ErrUnreadable = errors.New("the file could not be read")
+
+// The format of the file from its Content-Type or, failing that, its first bytes; XLSX files are zip archives
+func DetectFormat(contentType string, data []byte) string {
+	switch {
+	case strings.Contains(contentType, "spreadsheetml"):
+		return FormatXLSX
+	case strings.HasPrefix(contentType, "text/csv"):
+		return FormatCSV
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// Reads every row of the spreadsheet, the cells of an XLSX file are read from its first sheet
+func Read(format string, r io.Reader) ([][]string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	switch format {
+	case FormatXLSX:
+		rows, err = readXLSX(data)
+	default:
+		rows, err = readCSV(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	return rows, nil
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	// Spreadsheet programs start the CSV files they save with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+// Reads the guests from the rows of a spreadsheet.
+// mapping gives the header of a column when it is not the column's own name, e.g. "name" -> "Full name".
+// A cell that can not be read is reported as an error of its row, the other rows are still read.
+func Guests(rows [][]string, mapping map[string]string) ([]dto.GuestImportRowDto, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrUnreadable)
+	}
+
+	index, err := headerIndex(rows[0], mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var guests []dto.GuestImportRowDto
+	for i, row := range rows[1:] {
+		if blank(row) {
+			continue
+		}
+		if len(guests) == MaxRows {
+			return nil, &validation.Error{Fields: []dto.FieldErrorResDto{{Field: "file", Message: fmt.Sprintf("must have at most %d rows", MaxRows)}}}
+		}
+		guests = append(guests, guestRow(i+2, row, index))
+	}
+	return guests, nil
+}
+
+// The position of every column in the header, columns that are not in the header are left out.
+// The headers are compared without case or the spaces around them.
+func headerIndex(header []string, mapping map[string]string) (map[string]int, error) {
+	invalid := &validation.Error{}
+
+	for column := range mapping {
+		if !contains(columns, column) {
+			invalid.Fields = append(invalid.Fields, dto.FieldErrorResDto{Field: "column[" + column + "]", Message: "must be one of " + strings.Join(columns, ", ")})
+		}
+	}
+
+	index := make(map[string]int)
+	for _, column := range columns {
+		title := column
+		if mapped, ok := mapping[column]; ok {
+			title = mapped
+		}
+		for i, cell := range header {
+			if strings.EqualFold(strings.TrimSpace(cell), strings.TrimSpace(title)) {
+				index[column] = i
+				break
+			}
+		}
+
+		_, found := index[column]
+		if !found && (column == ColumnName || column == ColumnTable) {
+			invalid.Fields = append(invalid.Fields, dto.FieldErrorResDto{Field: "column[" + column + "]", Message: fmt.Sprintf("the header has no column %q", title)})
+		}
+	}
+
+	if len(invalid.Fields) > 0 {
+		return nil, invalid
+	}
+	return index, nil
+}
+
+func guestRow(line int, row []string, index map[string]int) dto.GuestImportRowDto {
+	res := dto.GuestImportRowDto{Row: line}
+
+	cell := func(column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	number := func(column string) int {
+		value := cell(column)
+		if value == "" {
+			return 0
+		}
+		// Spreadsheets can store whole numbers as 4.0
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || n != float64(int(n)) {
+			res.Errors = append(res.Errors, dto.FieldErrorResDto{Field: column, Message: "must be a whole number"})
+			return 0
+		}
+		return int(n)
+	}
+
+	res.Guest.Name = cell(ColumnName)
+	res.Guest.Table_ID = number(ColumnTable)
+	res.Guest.Acompanying_Guests = number(ColumnPartySize)
+	for _, alias := range strings.Split(cell(ColumnAliases), ";") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			res.Guest.Aliases = append(res.Guest.Aliases, alias)
+		}
+	}
+
+	return res
+}
+
+func blank(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
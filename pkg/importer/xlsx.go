@@ -0,0 +1,200 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The parts of an XLSX file that are needed to read the cells of its first sheet.
+// An XLSX file is a zip archive of XML files, only the text and numbers of the cells are read, not their formatting.
+
+// Excel's last column is XFD, a cell further right than that is not from a spreadsheet program
+const maxColumns = 16384
+
+// The most an XML part of the file may hold once it is decompressed, far more than the sheet of the largest import needs.
+// A zip archive can decompress to many times its size, so the size of the upload alone does not limit this.
+const maxPartSize = 32 << 20
+
+// The most cells the rows may have once the empty cells are filled in, so a few cells far to the right can not make rows of thousands
+const maxCells = 1 << 20
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelId string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// Text is read from rich text runs too, a cell with bold and plain words has one run for each
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			// The column and row of the cell, e.g. B3
+			Ref string `xml:"r,attr"`
+			// s is a shared string, inlineStr a string in the cell and b a boolean, anything else is stored as it is
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXML(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	sheetPath, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+	var sheet xlsxSheet
+	if err := readXML(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	cells := 0
+	for _, r := range sheet.Rows {
+		var row []string
+		for i, c := range r.Cells {
+			// Empty cells are left out of the file, the reference says which column a cell is in
+			col := columnIndex(c.Ref)
+			if col < 0 {
+				col = i
+			}
+			if col >= maxColumns {
+				return nil, fmt.Errorf("the cell %.20s is beyond the last column", c.Ref)
+			}
+			if col >= len(row) {
+				cells += col + 1 - len(row)
+				if cells > maxCells {
+					return nil, fmt.Errorf("the sheet has more than %d cells", maxCells)
+				}
+				row = append(row, make([]string, col+1-len(row))...)
+			}
+
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, errors.New("a cell refers to a shared string that does not exist")
+				}
+				row[col] = shared.Items[n].String()
+			case "inlineStr":
+				row[col] = c.Inline.String()
+			default:
+				row[col] = c.Value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// The path of the first sheet of the workbook, sheet1.xml when the workbook does not say
+func firstSheet(files map[string]*zip.File) (string, error) {
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	if err := readXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("the workbook has no sheets")
+	}
+	if err := readXML(files, "xl/_rels/workbook.xml.rels", &rels); err == nil {
+		for _, r := range rels.Relationships {
+			if r.Id == workbook.Sheets[0].RelId {
+				// The target is relative to the workbook unless it starts with /
+				if strings.HasPrefix(r.Target, "/") {
+					return strings.TrimPrefix(r.Target, "/"), nil
+				}
+				return path.Join("xl", r.Target), nil
+			}
+		}
+	}
+	return "xl/worksheets/sheet1.xml", nil
+}
+
+func readXML(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return errors.New(name + " is missing")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// One byte more than the limit is read to tell a part that is too large from one that is just the size of it
+	data, err := ioutil.ReadAll(io.LimitReader(rc, maxPartSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxPartSize {
+		return fmt.Errorf("%s is larger than %d MB once decompressed", name, maxPartSize>>20)
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// B3 -> 1, AA10 -> 26. A reference beyond the last column is maxColumns, however far beyond it is.
+func columnIndex(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+		if n > maxColumns {
+			return maxColumns
+		}
+	}
+	return n - 1
+}
@@ -0,0 +1,198 @@
+// The ledger package projects the guests and tables of an event from its ledger, the entries every change to them appends,
+// and compares the projection with the rows that are stored.
+//
+// Every entry carries the guest or table as it was once the change was made, so replaying the entries in order gives the
+// guest list and the tables, and the seats reserved and taken on each table are added up from the guests.
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/model"
+)
+
+// The guests and tables of an event as the ledger has them
+type State struct {
+	Guests map[int]model.Guest
+	Tables map[int]model.Table
+}
+
+// Replays the entries in the order they are given
+func Project(entries []model.LedgerEntry) (State, error) {
+	state := State{Guests: make(map[int]model.Guest), Tables: make(map[int]model.Table)}
+
+	for _, entry := range entries {
+		switch entry.Stream {
+		case model.LedgerStreamGuest:
+			if entry.Data == "" {
+				delete(state.Guests, entry.Stream_ID)
+				continue
+			}
+			var guest model.Guest
+			if err := json.Unmarshal([]byte(entry.Data), &guest); err != nil {
+				return state, fmt.Errorf("ledger entry %d can not be read: %w", entry.Id, err)
+			}
+			state.Guests[entry.Stream_ID] = guest
+
+		case model.LedgerStreamTable:
+			if entry.Data == "" {
+				delete(state.Tables, entry.Stream_ID)
+				continue
+			}
+			var table model.Table
+			if err := json.Unmarshal([]byte(entry.Data), &table); err != nil {
+				return state, fmt.Errorf("ledger entry %d can not be read: %w", entry.Id, err)
+			}
+			state.Tables[entry.Stream_ID] = table
+
+		default:
+			return state, fmt.Errorf("ledger entry %d is about an unknown stream %q", entry.Id, entry.Stream)
+		}
+	}
+
+	// The seats are never taken from the entries, they are only ever added up from the guests
+	for id, table := range state.Tables {
+		table.Reserved, table.Occupied = 0, 0
+		for _, guest := range state.Guests {
+			if guest.Table_ID != id || guest.LeftAt != nil {
+				continue
+			}
+			table.Reserved += guest.Acompanying_Guests + 1
+			if guest.Present() {
+				table.Occupied += guest.Acompanying_Guests + 1
+			}
+		}
+		state.Tables[id] = table
+	}
+
+	return state, nil
+}
+
+// The guests of the state by id
+func (s State) GuestList() []model.Guest {
+	guests := make([]model.Guest, 0, len(s.Guests))
+	for _, v := range s.Guests {
+		guests = append(guests, v)
+	}
+	sort.Slice(guests, func(i, j int) bool { return guests[i].Id < guests[j].Id })
+	return guests
+}
+
+// The tables of the state by id
+func (s State) TableList() []model.Table {
+	tables := make([]model.Table, 0, len(s.Tables))
+	for _, v := range s.Tables {
+		tables = append(tables, v)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Id < tables[j].Id })
+	return tables
+}
+
+// A field of a guest or table whose stored value is not the one the ledger has
+type Difference struct {
+	// guest or table
+	Entity    string
+	Entity_ID int
+	// The JSON name of the field, empty when the whole row is missing on one side
+	Field  string
+	Ledger string
+	Stored string
+}
+
+// Shown for a row that is missing on one side
+const missing = "missing"
+
+// Compares the stored guests and tables with the state the ledger projects, the tables are read with their seats.
+// The differences come back by entity, id and field.
+func Compare(state State, guests []model.Guest, tables []model.Table) []Difference {
+	var diffs []Difference
+
+	stored := make(map[int]model.Guest, len(guests))
+	for _, v := range guests {
+		stored[v.Id] = v
+		if _, ok := state.Guests[v.Id]; !ok {
+			diffs = append(diffs, Difference{Entity: model.LedgerStreamGuest, Entity_ID: v.Id, Ledger: missing, Stored: v.Name})
+		}
+	}
+	for id, projected := range state.Guests {
+		guest, ok := stored[id]
+		if !ok {
+			diffs = append(diffs, Difference{Entity: model.LedgerStreamGuest, Entity_ID: id, Ledger: projected.Name, Stored: missing})
+			continue
+		}
+		fields := []struct {
+			name           string
+			ledger, stored string
+		}{
+			{"public_id", projected.PublicId, guest.PublicId},
+			{"name", projected.Name, guest.Name},
+			{"aliases", strings.Join(projected.Aliases, ", "), strings.Join(guest.Aliases, ", ")},
+			{"table_id", strconv.Itoa(projected.Table_ID), strconv.Itoa(guest.Table_ID)},
+			{"accompanying_guests", strconv.Itoa(projected.Acompanying_Guests), strconv.Itoa(guest.Acompanying_Guests)},
+			{"arrived_at", timeText(projected.ArrivedAt), timeText(guest.ArrivedAt)},
+			{"left_at", timeText(projected.LeftAt), timeText(guest.LeftAt)},
+			{"walk_in", strconv.FormatBool(projected.WalkIn), strconv.FormatBool(guest.WalkIn)},
+		}
+		for _, f := range fields {
+			if f.ledger != f.stored {
+				diffs = append(diffs, Difference{Entity: model.LedgerStreamGuest, Entity_ID: id, Field: f.name, Ledger: f.ledger, Stored: f.stored})
+			}
+		}
+	}
+
+	storedTables := make(map[int]model.Table, len(tables))
+	for _, v := range tables {
+		storedTables[v.Id] = v
+		if _, ok := state.Tables[v.Id]; !ok {
+			diffs = append(diffs, Difference{Entity: model.LedgerStreamTable, Entity_ID: v.Id, Ledger: missing, Stored: "capacity " + strconv.Itoa(v.Capacity)})
+		}
+	}
+	for id, projected := range state.Tables {
+		table, ok := storedTables[id]
+		if !ok {
+			diffs = append(diffs, Difference{Entity: model.LedgerStreamTable, Entity_ID: id, Ledger: "capacity " + strconv.Itoa(projected.Capacity), Stored: missing})
+			continue
+		}
+		fields := []struct {
+			name           string
+			ledger, stored int
+		}{
+			{"capacity", projected.Capacity, table.Capacity},
+			{"reserved", projected.Reserved, table.Reserved},
+			{"occupied", projected.Occupied, table.Occupied},
+		}
+		for _, f := range fields {
+			if f.ledger != f.stored {
+				diffs = append(diffs, Difference{Entity: model.LedgerStreamTable, Entity_ID: id, Field: f.name, Ledger: strconv.Itoa(f.ledger), Stored: strconv.Itoa(f.stored)})
+			}
+		}
+		if projected.NearStage != table.NearStage {
+			diffs = append(diffs, Difference{Entity: model.LedgerStreamTable, Entity_ID: id, Field: "near_stage",
+				Ledger: strconv.FormatBool(projected.NearStage), Stored: strconv.FormatBool(table.NearStage)})
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if diffs[i].Entity != diffs[j].Entity {
+			return diffs[i].Entity < diffs[j].Entity
+		}
+		if diffs[i].Entity_ID != diffs[j].Entity_ID {
+			return diffs[i].Entity_ID < diffs[j].Entity_ID
+		}
+		return diffs[i].Field < diffs[j].Field
+	})
+	return diffs
+}
+
+// Times are compared as the instant they stand for, a database may hand them back in another zone
+func timeText(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
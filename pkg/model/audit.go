@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// The changes the audit log records
+const (
+	AuditGuestCreated    = "guest.created"
+	AuditGuestUpdated    = "guest.updated"
+	AuditGuestCheckedIn  = "guest.checked_in"
+	AuditGuestCheckedOut = "guest.checked_out"
+	AuditGuestDeleted    = "guest.deleted"
+	AuditTableCreated    = "table.created"
+	AuditTableUpdated    = "table.updated"
+	AuditTableDeleted    = "table.deleted"
+)
+
+// What an audit entry is about
+const (
+	AuditEntityGuest = "guest"
+	AuditEntityTable = "table"
+)
+
+// Creating audit entry model, one change to a guest or a table. Entries are only ever added, never changed or removed.
+type AuditEntry struct {
+	Id       int `json:"id" gorm:"primaryKey"`
+	Event_ID int `json:"event_id" gorm:"index"`
+	// The name of the API key or session that made the change, anonymous when auth is off
+	Actor  string `json:"actor" gorm:"size:100;index"`
+	Role   string `json:"role" gorm:"size:16"`
+	Action string `json:"action" gorm:"size:32;index"`
+	Entity string `json:"entity" gorm:"size:16"`
+	// The guest's public id or the table's id
+	Entity_ID string `json:"entity_id" gorm:"size:32;index"`
+	// The guest or table as JSON before and after the change, empty before it was created and after it was deleted
+	Before     string    `json:"before" gorm:"type:text"`
+	After      string    `json:"after" gorm:"type:text"`
+	Request_ID string    `json:"request_id" gorm:"size:64;index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+func (u *AuditEntry) TableName() string {
+	// custom table name, this is default
+	return "audit_log"
+}
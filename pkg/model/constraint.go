@@ -0,0 +1,46 @@
+package model
+
+// The kinds of seating rule
+const (
+	// The guests sit at the same table
+	ConstraintTogether = "together"
+	// No two of the guests sit at the same table
+	ConstraintApart = "apart"
+	// The guest sits at the table
+	ConstraintPinned = "pinned"
+	// The guests would rather sit at a table near the stage, the only rule that is a wish rather than a must
+	ConstraintNearStage = "near_stage"
+)
+
+// Creating constraint model, a seating rule the host sets for some of the guests of an event
+type Constraint struct {
+	Id       int    `json:"id" gorm:"primaryKey"`
+	Event_ID int    `json:"event_id" gorm:"index"`
+	Type     string `json:"type" gorm:"size:16"`
+	// The ids of the guests the rule is about
+	Guest_IDs []int `json:"guest_ids" gorm:"type:text;serializer:json"`
+	// The table of a pinned guest
+	Table_ID int `json:"table_id"`
+	// How much a near stage wish counts against the other guests', from 1 to 10
+	Priority int `json:"priority"`
+}
+
+// Whether the rule is kept when guests are moved, a near stage wish is only taken into account when tables are planned
+func (u *Constraint) Hard() bool {
+	return u.Type != ConstraintNearStage
+}
+
+// Whether the rule is about the guest
+func (u *Constraint) Has(guestId int) bool {
+	for _, id := range u.Guest_IDs {
+		if id == guestId {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *Constraint) TableName() string {
+	// constraint is a reserved word in SQL
+	return "seating_constraint"
+}
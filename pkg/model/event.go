@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Creating event model, an event owns its tables and guest list
+type Event struct {
+	Id        int       `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	Venue     string    `json:"venue"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// IANA name such as Europe/London
+	Timezone string `json:"timezone"`
+	// The percentage of extra seats that can be booked on each table for guests who may not show up
+	OverbookAllowance int `json:"overbook_allowance"`
+}
+
+func (u *Event) TableName() string {
+	// custom table name, this is default
+	return "event"
+}
@@ -1,17 +1,62 @@
 //The model shows the structure of the data that will be interacted with through the repository.
 package model
 
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+)
+
 // Creating guest model
 type Guest struct {
-	Id                 int    `json:"id" gorm:"primaryKey"`
-	Name               string `json:"name"`
-	Table_ID           int    `json:"table_id"`
-	Table              Table  `gorm:"foreignKey:Table_ID;references:Id"`
-	Acompanying_Guests int    `json:"accompanying_guests"`
-	TimeArrived        string `json:"time_arrived"`
+	Id       int `json:"id" gorm:"primaryKey"`
+	Event_ID int `json:"event_id" gorm:"index"`
+	// The id the guest is known by outside the server, it never changes and tells guests with the same name apart
+	PublicId string `json:"public_id" gorm:"size:32;index"`
+	Name     string `json:"name"`
+	// Other names the guest may give at the door, such as a nickname or maiden name, they are searched along with the name
+	Aliases            []string `json:"aliases" gorm:"type:text;serializer:json"`
+	Table_ID           int      `json:"table_id"`
+	Table              Table    `json:"-" gorm:"foreignKey:Table_ID;references:Id"`
+	Acompanying_Guests int      `json:"accompanying_guests"`
+	// When the guest arrived on their latest visit, nil until they first check in
+	ArrivedAt *time.Time `json:"arrived_at"`
+	// Set when the guest checks out, the row is kept so the guest list still shows who was invited
+	LeftAt *time.Time `json:"left_at"`
+	// Whether the guest was not on the guest list and was given a table at the door
+	WalkIn bool `json:"walk_in"`
+}
+
+// A guest is at the party from when they check in until they check out
+func (u *Guest) Present() bool {
+	return u.ArrivedAt != nil && u.LeftAt == nil
 }
 
 func (u *Guest) TableName() string {
 	// custom table name, this is default
 	return "guest"
 }
+
+// Public ids start with this so the routes can tell them apart from guest names
+const GuestIdPrefix = "g_"
+
+var publicIdEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Returns a new random public id for a guest, such as g_mfrggzdfmztwq2lk
+func NewGuestPublicId() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return GuestIdPrefix + strings.ToLower(publicIdEncoding.EncodeToString(b))
+}
+
+// Reports whether s has the shape of a guest public id
+func IsGuestPublicId(s string) bool {
+	if !strings.HasPrefix(s, GuestIdPrefix) || len(s) != len(GuestIdPrefix)+16 {
+		return false
+	}
+	_, err := publicIdEncoding.DecodeString(strings.ToUpper(strings.TrimPrefix(s, GuestIdPrefix)))
+	return err == nil
+}
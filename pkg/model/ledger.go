@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// The kinds of change the ledger records
+const (
+	// A party was added to the guest list
+	LedgerGuestReserved = "guest.reserved"
+	// A guest's name, table or party changed
+	LedgerGuestChanged = "guest.changed"
+	// A guest checked in, or walked in without a reservation
+	LedgerGuestArrived  = "guest.arrived"
+	LedgerGuestDeparted = "guest.departed"
+	// A guest was taken off the guest list
+	LedgerGuestCancelled = "guest.cancelled"
+	LedgerTableAdded     = "table.added"
+	LedgerTableChanged   = "table.changed"
+	LedgerTableRemoved   = "table.removed"
+)
+
+// What a ledger entry changed
+const (
+	LedgerStreamGuest = "guest"
+	LedgerStreamTable = "table"
+)
+
+// Creating ledger entry model. The ledger is the record the guest and table rows are projected from, entries are only ever added.
+type LedgerEntry struct {
+	// The position of the entry in the ledger, the entries are replayed in this order
+	Id       int    `json:"id" gorm:"primaryKey"`
+	Event_ID int    `json:"event_id" gorm:"index"`
+	Type     string `json:"type" gorm:"size:32"`
+	Stream   string `json:"stream" gorm:"size:16"`
+	// The id of the guest or table row
+	Stream_ID int `json:"stream_id" gorm:"index"`
+	// The guest or table as JSON once the change was made, empty when it was removed
+	Data      string    `json:"data" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (u *LedgerEntry) TableName() string {
+	// custom table name, this is default
+	return "ledger"
+}
@@ -3,7 +3,23 @@ package model
 // Creating table model
 type Table struct {
 	Id       int `json:"id" gorm:"primaryKey"`
+	Event_ID int `json:"event_id" gorm:"index"`
 	Capacity int `json:"capacity"`
+	// Whether the table is one of those near the stage, which guests with a near stage wish are seated at first
+	NearStage bool `json:"near_stage"`
+	// Reserved and Occupied are worked out from the guest rows when the table is read, they are never stored
+	Reserved int `json:"reserved" gorm:"->;-:migration"`
+	Occupied int `json:"occupied" gorm:"->;-:migration"`
+}
+
+// The seats that are not taken by guests who have arrived
+func (u *Table) Free() int {
+	return u.Capacity - u.Occupied
+}
+
+// The seats that can be reserved, which is more than the capacity when the event allows overbooking
+func (u *Table) Bookable(overbookAllowance int) int {
+	return u.Capacity + u.Capacity*overbookAllowance/100
 }
 
 func (u *Table) TableName() string {
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Creating visit model, a guest has one visit for every time they arrive at the party
+type Visit struct {
+	Id       int `json:"id" gorm:"primaryKey"`
+	Event_ID int `json:"event_id" gorm:"index"`
+	Guest_ID int `json:"guest_id" gorm:"index"`
+	// The size of the party that came in on this visit, including the guest
+	Acompanying_Guests int       `json:"accompanying_guests"`
+	ArrivedAt          time.Time `json:"arrived_at"`
+	// Nil while the guest is still at the party
+	LeftAt *time.Time `json:"left_at"`
+}
+
+func (u *Visit) TableName() string {
+	// custom table name, this is default
+	return "visit"
+}
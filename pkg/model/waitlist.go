@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// The reasons a party waits
+const (
+	// The party is not on the guest list and waits for seats to book at the table
+	WaitlistBooking = "booking"
+	// The guest is on the guest list and waits at the door for seats to be free at their table
+	WaitlistArrival = "arrival"
+)
+
+// Creating waitlist entry model, a party waiting for seats at a table that is full
+type WaitlistEntry struct {
+	Id       int    `json:"id" gorm:"primaryKey"`
+	Event_ID int    `json:"event_id" gorm:"index"`
+	Kind     string `json:"kind" gorm:"size:16"`
+	// The guest who waits to arrive, or the guest a booking was added as once it was promoted
+	Guest_ID           int      `json:"guest_id"`
+	Name               string   `json:"name"`
+	Aliases            []string `json:"aliases" gorm:"type:text;serializer:json"`
+	Table_ID           int      `json:"table_id" gorm:"index"`
+	Acompanying_Guests int      `json:"accompanying_guests"`
+	// Parties with a higher priority are promoted first, then the ones that have waited longest
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+	// Set when the party got its seats, the entry is kept so its outcome can still be looked up
+	PromotedAt *time.Time `json:"promoted_at"`
+}
+
+func (u *WaitlistEntry) Waiting() bool {
+	return u.PromotedAt == nil
+}
+
+func (u *WaitlistEntry) TableName() string {
+	// custom table name, this is default
+	return "waitlist"
+}
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// Where a delivery is in the queue
+const (
+	// Waiting for its next attempt
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// Given up on after the last attempt
+	DeliveryFailed = "failed"
+)
+
+// Creating webhook model, a URL of the organisers' own systems that is sent the event's changes it subscribes to
+type Webhook struct {
+	Id       int    `json:"id" gorm:"primaryKey"`
+	Event_ID int    `json:"event_id" gorm:"index"`
+	URL      string `json:"url" gorm:"size:2048"`
+	// Signs the payloads, it is never sent back
+	Secret string `json:"-" gorm:"size:256"`
+	// The kinds of change the webhook is sent
+	Events    []string  `json:"events" gorm:"type:text;serializer:json"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (u *Webhook) TableName() string {
+	// custom table name, this is default
+	return "webhook"
+}
+
+// Reports whether the webhook subscribes to the kind of change
+func (u *Webhook) Wants(kind string) bool {
+	for _, v := range u.Events {
+		if v == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Creating webhook delivery model, one payload for one webhook. The pending deliveries are the queue that is worked through,
+// the others are kept as the delivery log.
+type WebhookDelivery struct {
+	Id         int    `json:"id" gorm:"primaryKey"`
+	Event_ID   int    `json:"event_id" gorm:"index"`
+	Webhook_ID int    `json:"webhook_id" gorm:"index"`
+	Type       string `json:"type" gorm:"size:32"`
+	// The JSON body that is signed and sent
+	Payload  string `json:"payload" gorm:"type:text"`
+	Status   string `json:"status" gorm:"size:16;index"`
+	Attempts int    `json:"attempts"`
+	// When the delivery is tried next, while it is pending
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	// The status code the receiver answered the last attempt with, 0 when it could not be reached
+	ResponseStatus int        `json:"response_status"`
+	Error          string     `json:"error" gorm:"size:512"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (u *WebhookDelivery) TableName() string {
+	// custom table name, this is default
+	return "webhook_delivery"
+}
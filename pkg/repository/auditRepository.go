@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+// The audit log can only be added to, there is no way to change or remove an entry through it
+type AuditRepository interface {
+	Save(entry model.AuditEntry) (model.AuditEntry, error)
+	Query(eventId int, q AuditQuery) (AuditPage, error)
+}
+
+// Which entries of an event's audit log to list, and which page of them. Empty filters are left out.
+type AuditQuery struct {
+	Actor      string
+	Action     string
+	Entity     string
+	Entity_ID  string
+	Request_ID string
+	// Only the entries recorded at or after Since and before Until, a zero time leaves the bound out
+	Since time.Time
+	Until time.Time
+	// The newest entries first
+	Desc  bool
+	After *Cursor
+	Limit int
+}
+
+// A page of the audit log
+type AuditPage struct {
+	Entries []model.AuditEntry
+	Total   int64
+	Next    *Cursor
+}
+
+type auditDatabase struct {
+	connection *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	db.AutoMigrate(&model.AuditEntry{})
+
+	return &auditDatabase{
+		connection: db,
+	}
+}
+
+// This query runs -> INSERT INTO `audit_log` (`event_id`,`actor`,`role`,`action`,...,`created_at`) VALUES (1,'front-door','door_staff','guest.checked_in',...)
+func (db *auditDatabase) Save(entry model.AuditEntry) (model.AuditEntry, error) {
+	if err := db.connection.Create(&entry).Error; err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// This query runs -> SELECT * FROM `audit_log` WHERE audit_log.event_id = 1 AND audit_log.action = 'guest.deleted' ORDER BY audit_log.id DESC LIMIT 101
+func (db *auditDatabase) Query(eventId int, q AuditQuery) (AuditPage, error) {
+	var page AuditPage
+	q.Limit = pageLimit(q.Limit)
+
+	if err := checkCursor(q.After, AuditSortId, q.Desc); err != nil {
+		return page, err
+	}
+
+	tx := db.connection.Model(&model.AuditEntry{}).Where("audit_log.event_id = ?", eventId)
+	filters := []struct{ column, value string }{
+		{"audit_log.actor", q.Actor},
+		{"audit_log.action", q.Action},
+		{"audit_log.entity", q.Entity},
+		{"audit_log.entity_id", q.Entity_ID},
+		{"audit_log.request_id", q.Request_ID},
+	}
+	for _, f := range filters {
+		if f.value != "" {
+			tx = tx.Where(f.column+" = ?", f.value)
+		}
+	}
+	if !q.Since.IsZero() {
+		tx = tx.Where("audit_log.created_at >= ?", q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		tx = tx.Where("audit_log.created_at < ?", q.Until.UTC())
+	}
+
+	if err := tx.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	tx, err := keyset(tx, "audit_log.id", "audit_log.id", false, q.Desc, q.After)
+	if err != nil {
+		return page, err
+	}
+
+	if err := tx.Limit(q.Limit + 1).Find(&page.Entries).Error; err != nil {
+		return page, err
+	}
+	if len(page.Entries) > q.Limit {
+		page.Entries = page.Entries[:q.Limit]
+		page.Next = &Cursor{Sort: AuditSortId, Desc: q.Desc, Id: page.Entries[q.Limit-1].Id}
+	}
+
+	return page, nil
+}
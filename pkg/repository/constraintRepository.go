@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+type ConstraintRepository interface {
+	FindAll(eventId int) ([]model.Constraint, error)
+	FindById(eventId int, id int) (model.Constraint, error)
+	Save(constraint model.Constraint) (model.Constraint, error)
+	Update(constraint model.Constraint) error
+	Delete(constraint model.Constraint) error
+}
+
+type constraintDatabase struct {
+	connection *gorm.DB
+}
+
+func NewConstraintRepository(db *gorm.DB) ConstraintRepository {
+	db.AutoMigrate(&model.Constraint{})
+
+	return &constraintDatabase{
+		connection: db,
+	}
+}
+
+// Every seating rule of the event, in the order they were added
+// This query runs -> SELECT * FROM `seating_constraint` WHERE event_id = 1 ORDER BY id
+func (db *constraintDatabase) FindAll(eventId int) ([]model.Constraint, error) {
+	var constraints []model.Constraint
+	if err := db.connection.Where("event_id = ?", eventId).Order("id").Find(&constraints).Error; err != nil {
+		return constraints, err
+	}
+	return constraints, nil
+}
+
+// Returns gorm.ErrRecordNotFound when the event has no rule with the id
+// This query runs -> SELECT * FROM `seating_constraint` WHERE event_id = 1 AND `seating_constraint`.`id` = 3 LIMIT 1
+func (db *constraintDatabase) FindById(eventId int, id int) (model.Constraint, error) {
+	var constraint model.Constraint
+	if err := db.connection.Where("event_id = ?", eventId).First(&constraint, id).Error; err != nil {
+		return constraint, err
+	}
+	return constraint, nil
+}
+
+func (db *constraintDatabase) Save(constraint model.Constraint) (model.Constraint, error) {
+	if err := db.connection.Create(&constraint).Error; err != nil {
+		return constraint, err
+	}
+	return constraint, nil
+}
+
+func (db *constraintDatabase) Update(constraint model.Constraint) error {
+	if err := db.connection.Save(&constraint).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *constraintDatabase) Delete(constraint model.Constraint) error {
+	if err := db.connection.Delete(&constraint).Error; err != nil {
+		return err
+	}
+	return nil
+}
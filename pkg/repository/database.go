@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The storage backends the repositories can be built on
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+	DriverMemory = "memory"
+)
+
+// The database started by docker-compose
+const DefaultMySQLDSN = "user:password@tcp(host.docker.internal:3306)/getground?charset=utf8&parseTime=True&loc=Local"
+
+// A database file in the working directory
+const DefaultSQLiteDSN = "party.db"
+
+// The dsn used for the driver when none is configured
+func DefaultDSN(driver string) string {
+	if driver == DriverSQLite {
+		return DefaultSQLiteDSN
+	}
+	return DefaultMySQLDSN
+}
+
+// Everything the services need from a storage backend
+type Store struct {
+	Events      EventRepository
+	Guests      GuestRepository
+	Tables      TableRepository
+	Visits      VisitRepository
+	Constraints ConstraintRepository
+	Waitlist    WaitlistRepository
+	Audit       AuditRepository
+	Ledger      LedgerRepository
+	Webhooks    WebhookRepository
+	Deliveries  DeliveryRepository
+	UnitOfWork  UnitOfWork
+}
+
+// Opens the storage backend for the driver, the dsn and log level are ignored by the memory backend
+func NewStore(driver string, dsn string, logLevel logger.LogLevel) (Store, error) {
+	if driver == DriverMemory {
+		log.Println("Using the in memory store, nothing will be saved when the server stops")
+		return NewMemoryStore(), nil
+	}
+
+	db, err := NewDatabase(driver, dsn, logLevel)
+	if err != nil {
+		return Store{}, err
+	}
+
+	// The tables are created in order of the references between them
+	store := Store{
+		Events:      NewEventRepository(db),
+		Tables:      NewTableRepository(db),
+		Guests:      NewGuestRepository(db),
+		Visits:      NewVisitRepository(db),
+		Constraints: NewConstraintRepository(db),
+		Waitlist:    NewWaitlistRepository(db),
+		Audit:       NewAuditRepository(db),
+		Ledger:      NewLedgerRepository(db),
+		Webhooks:    NewWebhookRepository(db),
+		Deliveries:  NewDeliveryRepository(db),
+		UnitOfWork:  NewUnitOfWork(db),
+	}
+
+	// Brings the data of an existing database up to date with the models
+	if err := Migrate(db); err != nil {
+		return Store{}, err
+	}
+
+	return store, nil
+}
+
+// Opens the connection that is shared by every repository, so that they can all take part in the same transaction
+func NewDatabase(driver string, dsn string, logLevel logger.LogLevel) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+
+	switch driver {
+	case DriverMySQL:
+		dialector = mysql.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	})
+	if err != nil {
+		//If connection fails then throw error
+		log.Println("Could not connect to db")
+		return nil, err
+	}
+
+	// SQLite has no row locks, so a single connection is used to apply transactions one after the other
+	if driver == DriverSQLite {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	log.Println("Database connection is successful")
+
+	return db, nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EventRepository interface {
+	FindAll() ([]model.Event, error)
+	FindById(id int) (model.Event, error)
+	FindByIdForUpdate(id int) (model.Event, error)
+	Save(event model.Event) (model.Event, error)
+	Update(event model.Event) error
+}
+
+type eventDatabase struct {
+	connection *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) EventRepository {
+	db.AutoMigrate(&model.Event{})
+
+	return &eventDatabase{
+		connection: db,
+	}
+}
+
+func (db *eventDatabase) FindAll() ([]model.Event, error) {
+	var events []model.Event
+	if err := db.connection.Find(&events).Error; err != nil {
+		return events, err
+	}
+
+	return events, nil
+}
+
+// This query runs -> SELECT * FROM `event` WHERE `event`.`id` = 1 ORDER BY `event`.`id` LIMIT 1
+func (db *eventDatabase) FindById(id int) (model.Event, error) {
+	var event model.Event
+	if err := db.connection.First(&event, id).Error; err != nil {
+		return event, err
+	}
+
+	return event, nil
+}
+
+// Same as FindById but the row stays locked until the surrounding transaction ends
+func (db *eventDatabase) FindByIdForUpdate(id int) (model.Event, error) {
+	var event model.Event
+	if err := db.connection.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+		return event, err
+	}
+
+	return event, nil
+}
+
+func (db *eventDatabase) Save(event model.Event) (model.Event, error) {
+	if err := db.connection.Create(&event).Error; err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+func (db *eventDatabase) Update(event model.Event) error {
+	if err := db.connection.Save(&event).Error; err != nil {
+		return err
+	}
+	return nil
+}
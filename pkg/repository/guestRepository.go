@@ -4,17 +4,21 @@ import (
 	"log"
 
 	"github.com/getground/tech-tasks/backend/pkg/model"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	"gorm.io/gorm/clause"
 )
 
 type GuestRepository interface {
-	FindAll() ([]model.Guest, error)
-	FindByName(name string) (model.Guest, error)
+	FindAll(eventId int) ([]model.Guest, error)
+	Query(eventId int, q GuestQuery) (GuestPage, error)
+	FindByPublicId(eventId int, publicId string) (model.Guest, error)
+	FindByPublicIdForUpdate(eventId int, publicId string) (model.Guest, error)
+	FindAllByName(eventId int, name string) ([]model.Guest, error)
+	FindAllByNameForUpdate(eventId int, name string) ([]model.Guest, error)
 	Save(guest model.Guest) (model.Guest, error)
 	Update(guest model.Guest) error
-	GetArrivedGuests() ([]model.Guest, error)
+	GetArrivedGuests(eventId int) ([]model.Guest, error)
+	GetDepartedGuests(eventId int) ([]model.Guest, error)
 	Delete(guest model.Guest) error
 }
 
@@ -22,18 +26,7 @@ type guestDatabase struct {
 	connection *gorm.DB
 }
 
-func NewGuestRepository() GuestRepository {
-	dsn := "user:password@tcp(host.docker.internal:3306)/getground?charset=utf8&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		//If connection fails then throw error
-		panic("Could not connect to db")
-	}
-
-	log.Println("Database connection is successful")
-
+func NewGuestRepository(db *gorm.DB) GuestRepository {
 	db.AutoMigrate(&model.Guest{})
 
 	return &guestDatabase{
@@ -42,23 +35,113 @@ func NewGuestRepository() GuestRepository {
 
 }
 
-func (db *guestDatabase) FindAll() ([]model.Guest, error) {
+func (db *guestDatabase) FindAll(eventId int) ([]model.Guest, error) {
 	var guests []model.Guest
-	if err := db.connection.Set("gorm:auto_preload", true).Find(&guests).Error; err != nil {
+	if err := db.connection.Set("gorm:auto_preload", true).Where("event_id = ?", eventId).Find(&guests).Error; err != nil {
 		return guests, err
 	}
 	return guests, nil
 }
 
-func (db *guestDatabase) FindByName(name string) (model.Guest, error) {
+// A page of the guests matching the query's filters, together with how many match on every page
+// This query runs -> SELECT * FROM `guest` WHERE event_id = 1 AND table_id = 2 AND (guest.name > 'sara' OR ...) ORDER BY guest.name, guest.id LIMIT 51
+func (db *guestDatabase) Query(eventId int, q GuestQuery) (GuestPage, error) {
+	var page GuestPage
+	q.Limit = pageLimit(q.Limit)
+
+	column, ok := guestSortColumns[q.Sort]
+	if !ok {
+		column, q.Sort = guestSortColumns[GuestSortId], GuestSortId
+	}
+	if err := checkCursor(q.After, q.Sort, q.Desc); err != nil {
+		return page, err
+	}
+
+	tx := db.connection.Model(&model.Guest{}).Where("guest.event_id = ?", eventId)
+	if q.Table_ID != 0 {
+		tx = tx.Where("guest.table_id = ?", q.Table_ID)
+	}
+	if q.Arrived != nil && *q.Arrived {
+		tx = tx.Where("guest.arrived_at IS NOT NULL")
+	}
+	if q.Arrived != nil && !*q.Arrived {
+		tx = tx.Where("guest.arrived_at IS NULL")
+	}
+	if q.Present {
+		tx = tx.Where("guest.arrived_at IS NOT NULL AND guest.left_at IS NULL")
+	}
+	if q.MinPartySize != 0 {
+		tx = tx.Where("guest.acompanying_guests + 1 >= ?", q.MinPartySize)
+	}
+	if q.MaxPartySize != 0 {
+		tx = tx.Where("guest.acompanying_guests + 1 <= ?", q.MaxPartySize)
+	}
+	if q.NamePrefix != "" {
+		tx = tx.Where("LOWER(guest.name) LIKE ? ESCAPE '!'", likePrefix(q.NamePrefix))
+	}
+
+	// This query runs -> SELECT count(*) FROM `guest` WHERE event_id = 1 AND ...
+	if err := tx.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	tx, err := keyset(tx, column, guestSortColumns[GuestSortId], q.Sort == GuestSortArrival, q.Desc, q.After)
+	if err != nil {
+		return page, err
+	}
+
+	// One row more than the page is read to find out whether there is a next page
+	if err := tx.Limit(q.Limit + 1).Find(&page.Guests).Error; err != nil {
+		return page, err
+	}
+	if len(page.Guests) > q.Limit {
+		page.Guests = page.Guests[:q.Limit]
+		page.Next = guestCursor(q.Sort, q.Desc, page.Guests[q.Limit-1])
+	}
+
+	return page, nil
+}
+
+// This query runs -> SELECT * FROM `guest` WHERE `guest`.`event_id` = 1 AND `guest`.`public_id` = 'g_...' ORDER BY `guest`.`id` LIMIT 1
+func (db *guestDatabase) FindByPublicId(eventId int, publicId string) (model.Guest, error) {
 	var guest model.Guest
-	if err := db.connection.Where(&model.Guest{Name: name}).First(&guest).Error; err != nil {
+	if err := db.connection.Where(&model.Guest{Event_ID: eventId, PublicId: publicId}).First(&guest).Error; err != nil {
 		return guest, err
 	}
 	return guest, nil
 }
 
+// Same as FindByPublicId but the row stays locked until the surrounding transaction ends
+func (db *guestDatabase) FindByPublicIdForUpdate(eventId int, publicId string) (model.Guest, error) {
+	var guest model.Guest
+	if err := db.connection.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&model.Guest{Event_ID: eventId, PublicId: publicId}).First(&guest).Error; err != nil {
+		return guest, err
+	}
+	return guest, nil
+}
+
+// Names are not unique, so every guest of the event with the name is returned
+func (db *guestDatabase) FindAllByName(eventId int, name string) ([]model.Guest, error) {
+	var guests []model.Guest
+	if err := db.connection.Where(&model.Guest{Event_ID: eventId, Name: name}).Order("id").Find(&guests).Error; err != nil {
+		return guests, err
+	}
+	return guests, nil
+}
+
+// Same as FindAllByName but the rows stay locked until the surrounding transaction ends
+func (db *guestDatabase) FindAllByNameForUpdate(eventId int, name string) ([]model.Guest, error) {
+	var guests []model.Guest
+	if err := db.connection.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&model.Guest{Event_ID: eventId, Name: name}).Order("id").Find(&guests).Error; err != nil {
+		return guests, err
+	}
+	return guests, nil
+}
+
 func (db *guestDatabase) Save(guest model.Guest) (model.Guest, error) {
+	if guest.PublicId == "" {
+		guest.PublicId = model.NewGuestPublicId()
+	}
 
 	if err := db.connection.Create(&guest).Error; err != nil {
 		return guest, err
@@ -74,15 +157,26 @@ func (db *guestDatabase) Update(guest model.Guest) error {
 	return nil
 }
 
-func (db *guestDatabase) GetArrivedGuests() ([]model.Guest, error) {
+// The guests that are at the party in the order they arrived, guests who have checked out are left out
+func (db *guestDatabase) GetArrivedGuests(eventId int) ([]model.Guest, error) {
 	var guests []model.Guest
-	if err := db.connection.Not("time_arrived = ?", "").Find(&guests).Error; err != nil {
+	if err := db.connection.Where("event_id = ? AND arrived_at IS NOT NULL AND left_at IS NULL", eventId).Order("arrived_at, id").Find(&guests).Error; err != nil {
 		log.Println("Get Arrived Guests Service - Could not retrieve guests")
 		return guests, err
 	}
 	return guests, nil
 }
 
+// The guests that have checked out and not come back, in the order they left
+func (db *guestDatabase) GetDepartedGuests(eventId int) ([]model.Guest, error) {
+	var guests []model.Guest
+	if err := db.connection.Where("event_id = ? AND left_at IS NOT NULL", eventId).Order("left_at, id").Find(&guests).Error; err != nil {
+		log.Println("Get Departed Guests Service - Could not retrieve guests")
+		return guests, err
+	}
+	return guests, nil
+}
+
 func (db *guestDatabase) Delete(guest model.Guest) error {
 	if err := db.connection.Delete(&guest).Error; err != nil {
 		log.Println("Checkout Service - Could not delete guest")
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+// Entries can only be appended to the ledger, there is no way to change or remove one through it
+type LedgerRepository interface {
+	Append(entry model.LedgerEntry) (model.LedgerEntry, error)
+	// Every entry of the event, in the order they were appended
+	FindAll(eventId int) ([]model.LedgerEntry, error)
+}
+
+type ledgerDatabase struct {
+	connection *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	db.AutoMigrate(&model.LedgerEntry{})
+
+	return &ledgerDatabase{
+		connection: db,
+	}
+}
+
+// This query runs -> INSERT INTO `ledger` (`event_id`,`type`,`stream`,`stream_id`,`data`,`created_at`) VALUES (1,'guest.arrived','guest',2,'{...}',...)
+func (db *ledgerDatabase) Append(entry model.LedgerEntry) (model.LedgerEntry, error) {
+	if err := db.connection.Create(&entry).Error; err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// This query runs -> SELECT * FROM `ledger` WHERE event_id = 1 ORDER BY id
+func (db *ledgerDatabase) FindAll(eventId int) ([]model.LedgerEntry, error) {
+	var entries []model.LedgerEntry
+	if err := db.connection.Where("event_id = ?", eventId).Order("id").Find(&entries).Error; err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,979 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+// An in memory storage backend, the data is lost when the server stops.
+// It behaves like the database backends so the server can run, and be tested, without a database.
+type memoryStore struct {
+	mu   sync.Mutex
+	data memoryData
+	// What the unit of work being run needs to put back, nil outside of one
+	undo *memoryUndo
+}
+
+// Everything held by the memory store. The start of a unit of work copies it so it can be put back,
+// apart from the history which grows with every write and is undone by memoryUndo instead.
+type memoryData struct {
+	events      map[int]model.Event
+	guests      map[int]model.Guest
+	tables      map[int]model.Table
+	visits      map[int]model.Visit
+	constraints map[int]model.Constraint
+	waitlist    map[int]model.WaitlistEntry
+	// Append only, in the order of their ids
+	audit      []model.AuditEntry
+	ledger     []model.LedgerEntry
+	webhooks   map[int]model.Webhook
+	deliveries map[int]model.WebhookDelivery
+	// The last id handed out for each kind of row
+	lastIds map[string]int
+}
+
+func (d memoryData) clone() memoryData {
+	c := memoryData{
+		events:      make(map[int]model.Event, len(d.events)),
+		guests:      make(map[int]model.Guest, len(d.guests)),
+		tables:      make(map[int]model.Table, len(d.tables)),
+		visits:      make(map[int]model.Visit, len(d.visits)),
+		constraints: make(map[int]model.Constraint, len(d.constraints)),
+		waitlist:    make(map[int]model.WaitlistEntry, len(d.waitlist)),
+		audit:       d.audit,
+		ledger:      d.ledger,
+		webhooks:    make(map[int]model.Webhook, len(d.webhooks)),
+		deliveries:  d.deliveries,
+		lastIds:     make(map[string]int, len(d.lastIds)),
+	}
+	if c.deliveries == nil {
+		c.deliveries = make(map[int]model.WebhookDelivery)
+	}
+	for k, v := range d.events {
+		c.events[k] = v
+	}
+	for k, v := range d.guests {
+		c.guests[k] = v
+	}
+	for k, v := range d.tables {
+		c.tables[k] = v
+	}
+	for k, v := range d.visits {
+		c.visits[k] = v
+	}
+	for k, v := range d.constraints {
+		c.constraints[k] = v
+	}
+	for k, v := range d.waitlist {
+		c.waitlist[k] = v
+	}
+	for k, v := range d.webhooks {
+		c.webhooks[k] = v
+	}
+	for k, v := range d.lastIds {
+		c.lastIds[k] = v
+	}
+	return c
+}
+
+// The history as it was before a unit of work, the audit log and the ledger are only appended to
+// so they are cut back to their length, and the deliveries changed are put back one by one
+type memoryUndo struct {
+	audit  int
+	ledger int
+	// The deliveries as they were before they were first changed, nil for the ones that were added
+	deliveries map[int]*model.WebhookDelivery
+}
+
+func (u *memoryUndo) apply(d *memoryData) {
+	d.audit = d.audit[:u.audit]
+	d.ledger = d.ledger[:u.ledger]
+	for k, v := range u.deliveries {
+		if v == nil {
+			delete(d.deliveries, k)
+		} else {
+			d.deliveries[k] = *v
+		}
+	}
+}
+
+// Returns the id for a new row, or keeps the one it was given like an auto increment column does
+func (d memoryData) nextId(kind string, id int) int {
+	if id == 0 {
+		d.lastIds[kind]++
+		return d.lastIds[kind]
+	}
+	if id > d.lastIds[kind] {
+		d.lastIds[kind] = id
+	}
+	return id
+}
+
+// The repositories built inside a unit of work already hold the store's lock
+type memoryRepository struct {
+	store         *memoryStore
+	inTransaction bool
+}
+
+type memoryEventRepository struct{ memoryRepository }
+type memoryGuestRepository struct{ memoryRepository }
+type memoryTableRepository struct{ memoryRepository }
+type memoryVisitRepository struct{ memoryRepository }
+type memoryConstraintRepository struct{ memoryRepository }
+type memoryWaitlistRepository struct{ memoryRepository }
+type memoryAuditRepository struct{ memoryRepository }
+type memoryLedgerRepository struct{ memoryRepository }
+type memoryWebhookRepository struct{ memoryRepository }
+type memoryDeliveryRepository struct{ memoryRepository }
+
+type memoryUnitOfWork struct {
+	store *memoryStore
+}
+
+func NewMemoryStore() Store {
+	store := &memoryStore{
+		data: memoryData{}.clone(),
+	}
+	repos := store.repositories(false)
+
+	return Store{
+		Events:      repos.Events,
+		Guests:      repos.Guests,
+		Tables:      repos.Tables,
+		Visits:      repos.Visits,
+		Constraints: repos.Constraints,
+		Waitlist:    repos.Waitlist,
+		Audit:       repos.Audit,
+		Ledger:      repos.Ledger,
+		Webhooks:    repos.Webhooks,
+		Deliveries:  repos.Deliveries,
+		UnitOfWork:  &memoryUnitOfWork{store: store},
+	}
+}
+
+func (s *memoryStore) repositories(inTransaction bool) Repositories {
+	base := memoryRepository{store: s, inTransaction: inTransaction}
+
+	return Repositories{
+		Events:      &memoryEventRepository{base},
+		Guests:      &memoryGuestRepository{base},
+		Tables:      &memoryTableRepository{base},
+		Visits:      &memoryVisitRepository{base},
+		Constraints: &memoryConstraintRepository{base},
+		Waitlist:    &memoryWaitlistRepository{base},
+		Audit:       &memoryAuditRepository{base},
+		Ledger:      &memoryLedgerRepository{base},
+		Webhooks:    &memoryWebhookRepository{base},
+		Deliveries:  &memoryDeliveryRepository{base},
+	}
+}
+
+// Takes the store's lock unless the caller is inside a unit of work, returns the function that releases it
+func (r memoryRepository) lock() func() {
+	if r.inTransaction {
+		return func() {}
+	}
+	r.store.mu.Lock()
+	return r.store.mu.Unlock
+}
+
+func (r memoryRepository) data() memoryData {
+	return r.store.data
+}
+
+// Keeps the delivery as it is now, the first time a unit of work changes it, so it can be put back
+func (r memoryRepository) changingDelivery(id int) {
+	undo := r.store.undo
+	if undo == nil {
+		return
+	}
+	if _, ok := undo.deliveries[id]; ok {
+		return
+	}
+	var before *model.WebhookDelivery
+	if v, ok := r.store.data.deliveries[id]; ok {
+		before = &v
+	}
+	undo.deliveries[id] = before
+}
+
+// Runs fn while holding the lock, so units of work are applied one after the other,
+// and puts the old data back if fn fails
+func (uow *memoryUnitOfWork) Do(fn func(repos Repositories) error) error {
+	store := uow.store
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	before := store.data.clone()
+	store.undo = &memoryUndo{
+		audit:      len(store.data.audit),
+		ledger:     len(store.data.ledger),
+		deliveries: map[int]*model.WebhookDelivery{},
+	}
+	defer func() { store.undo = nil }()
+
+	err := fn(store.repositories(true))
+	if err != nil {
+		// The copy shares the history with the store, so it is undone in place
+		store.undo.apply(&before)
+		store.data = before
+	}
+
+	return err
+}
+
+func (r *memoryEventRepository) FindAll() ([]model.Event, error) {
+	defer r.lock()()
+
+	var events []model.Event
+	for _, v := range r.data().events {
+		events = append(events, v)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+
+	return events, nil
+}
+
+func (r *memoryEventRepository) FindById(id int) (model.Event, error) {
+	defer r.lock()()
+
+	event, ok := r.data().events[id]
+	if !ok {
+		return model.Event{}, gorm.ErrRecordNotFound
+	}
+
+	return event, nil
+}
+
+// The whole store is locked during a unit of work, so this is the same as FindById
+func (r *memoryEventRepository) FindByIdForUpdate(id int) (model.Event, error) {
+	return r.FindById(id)
+}
+
+func (r *memoryEventRepository) Save(event model.Event) (model.Event, error) {
+	defer r.lock()()
+
+	event.Id = r.data().nextId("event", event.Id)
+	r.data().events[event.Id] = event
+
+	return event, nil
+}
+
+func (r *memoryEventRepository) Update(event model.Event) error {
+	defer r.lock()()
+
+	r.data().events[event.Id] = event
+
+	return nil
+}
+
+// Returns the guests of the event matching keep in the order of their ids, like the database does
+func (r *memoryGuestRepository) filter(eventId int, keep func(guest model.Guest) bool) []model.Guest {
+	var guests []model.Guest
+	for _, v := range r.data().guests {
+		if v.Event_ID == eventId && keep(v) {
+			guests = append(guests, v)
+		}
+	}
+
+	sort.Slice(guests, func(i, j int) bool { return guests[i].Id < guests[j].Id })
+
+	return guests
+}
+
+func (r *memoryGuestRepository) FindAll(eventId int) ([]model.Guest, error) {
+	defer r.lock()()
+
+	return r.filter(eventId, func(guest model.Guest) bool { return true }), nil
+}
+
+// Orders guests like the database does for the sort key: by the key, with guests who have not arrived last, then by id
+func guestLess(sortKey string, desc bool) func(a model.Guest, b model.Guest) bool {
+	return func(a model.Guest, b model.Guest) bool {
+		c := 0
+		switch sortKey {
+		case GuestSortName:
+			c = strings.Compare(a.Name, b.Name)
+		case GuestSortTable:
+			c = a.Table_ID - b.Table_ID
+		case GuestSortArrival:
+			switch {
+			case a.ArrivedAt == nil && b.ArrivedAt == nil:
+			case a.ArrivedAt == nil:
+				return false
+			case b.ArrivedAt == nil:
+				return true
+			case a.ArrivedAt.Before(*b.ArrivedAt):
+				c = -1
+			case a.ArrivedAt.After(*b.ArrivedAt):
+				c = 1
+			}
+		}
+		if c == 0 {
+			c = a.Id - b.Id
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	}
+}
+
+func (r *memoryGuestRepository) Query(eventId int, q GuestQuery) (GuestPage, error) {
+	defer r.lock()()
+
+	var page GuestPage
+	q.Limit = pageLimit(q.Limit)
+
+	if _, ok := guestSortColumns[q.Sort]; !ok {
+		q.Sort = GuestSortId
+	}
+	if err := checkCursor(q.After, q.Sort, q.Desc); err != nil {
+		return page, err
+	}
+
+	prefix := strings.ToLower(q.NamePrefix)
+	guests := r.filter(eventId, func(guest model.Guest) bool {
+		size := guest.Acompanying_Guests + 1
+		return (q.Table_ID == 0 || guest.Table_ID == q.Table_ID) &&
+			(q.Arrived == nil || *q.Arrived == (guest.ArrivedAt != nil)) &&
+			(!q.Present || guest.Present()) &&
+			(q.MinPartySize == 0 || size >= q.MinPartySize) &&
+			(q.MaxPartySize == 0 || size <= q.MaxPartySize) &&
+			strings.HasPrefix(strings.ToLower(guest.Name), prefix)
+	})
+	page.Total = int64(len(guests))
+
+	less := guestLess(q.Sort, q.Desc)
+	sort.SliceStable(guests, func(i, j int) bool { return less(guests[i], guests[j]) })
+
+	// The guest the cursor was made from, only the guests ordered after it are on the page
+	if q.After != nil {
+		last := model.Guest{Id: q.After.Id}
+		if !q.After.Null && q.Sort != GuestSortId {
+			value, err := cursorValue(q.After)
+			if err != nil {
+				return page, err
+			}
+			switch v := value.(type) {
+			case string:
+				last.Name = v
+			case int:
+				last.Table_ID = v
+			case time.Time:
+				last.ArrivedAt = &v
+			}
+		}
+		start := sort.Search(len(guests), func(i int) bool { return less(last, guests[i]) })
+		guests = guests[start:]
+	}
+
+	if len(guests) > q.Limit {
+		guests = guests[:q.Limit]
+		page.Next = guestCursor(q.Sort, q.Desc, guests[q.Limit-1])
+	}
+	page.Guests = guests
+
+	return page, nil
+}
+
+func (r *memoryGuestRepository) FindByPublicId(eventId int, publicId string) (model.Guest, error) {
+	defer r.lock()()
+
+	guests := r.filter(eventId, func(guest model.Guest) bool { return guest.PublicId == publicId })
+	if len(guests) == 0 {
+		return model.Guest{}, gorm.ErrRecordNotFound
+	}
+
+	return guests[0], nil
+}
+
+// The whole store is locked during a unit of work, so this is the same as FindByPublicId
+func (r *memoryGuestRepository) FindByPublicIdForUpdate(eventId int, publicId string) (model.Guest, error) {
+	return r.FindByPublicId(eventId, publicId)
+}
+
+func (r *memoryGuestRepository) FindAllByName(eventId int, name string) ([]model.Guest, error) {
+	defer r.lock()()
+
+	return r.filter(eventId, func(guest model.Guest) bool { return guest.Name == name }), nil
+}
+
+// The whole store is locked during a unit of work, so this is the same as FindAllByName
+func (r *memoryGuestRepository) FindAllByNameForUpdate(eventId int, name string) ([]model.Guest, error) {
+	return r.FindAllByName(eventId, name)
+}
+
+func (r *memoryGuestRepository) Save(guest model.Guest) (model.Guest, error) {
+	defer r.lock()()
+
+	guest.Id = r.data().nextId("guest", guest.Id)
+	if guest.PublicId == "" {
+		guest.PublicId = model.NewGuestPublicId()
+	}
+	r.data().guests[guest.Id] = guest
+
+	return guest, nil
+}
+
+func (r *memoryGuestRepository) Update(guest model.Guest) error {
+	defer r.lock()()
+
+	r.data().guests[guest.Id] = guest
+
+	return nil
+}
+
+func (r *memoryGuestRepository) GetArrivedGuests(eventId int) ([]model.Guest, error) {
+	defer r.lock()()
+
+	guests := r.filter(eventId, func(guest model.Guest) bool { return guest.Present() })
+	sort.SliceStable(guests, func(i, j int) bool { return guests[i].ArrivedAt.Before(*guests[j].ArrivedAt) })
+
+	return guests, nil
+}
+
+func (r *memoryGuestRepository) GetDepartedGuests(eventId int) ([]model.Guest, error) {
+	defer r.lock()()
+
+	guests := r.filter(eventId, func(guest model.Guest) bool { return guest.LeftAt != nil })
+	sort.SliceStable(guests, func(i, j int) bool { return guests[i].LeftAt.Before(*guests[j].LeftAt) })
+
+	return guests, nil
+}
+
+func (r *memoryGuestRepository) Delete(guest model.Guest) error {
+	defer r.lock()()
+
+	delete(r.data().guests, guest.Id)
+
+	return nil
+}
+
+// Works out the reserved and occupied seats from the guests, like the occupancy query does
+func (r *memoryTableRepository) withOccupancy(table model.Table) model.Table {
+	table.Reserved, table.Occupied = 0, 0
+
+	for _, v := range r.data().guests {
+		if v.Table_ID != table.Id || v.LeftAt != nil {
+			continue
+		}
+		table.Reserved += v.Acompanying_Guests + 1
+		if v.ArrivedAt != nil {
+			table.Occupied += v.Acompanying_Guests + 1
+		}
+	}
+
+	return table
+}
+
+func (r *memoryTableRepository) FindAll(eventId int) ([]model.Table, error) {
+	defer r.lock()()
+
+	var tables []model.Table
+	for _, v := range r.data().tables {
+		if v.Event_ID == eventId {
+			tables = append(tables, r.withOccupancy(v))
+		}
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Id < tables[j].Id })
+
+	return tables, nil
+}
+
+func (r *memoryTableRepository) Query(eventId int, q TableQuery) (TablePage, error) {
+	defer r.lock()()
+
+	var page TablePage
+	q.Limit = pageLimit(q.Limit)
+
+	if _, ok := tableSortColumns[q.Sort]; !ok {
+		q.Sort = TableSortId
+	}
+	if err := checkCursor(q.After, q.Sort, q.Desc); err != nil {
+		return page, err
+	}
+
+	var tables []model.Table
+	for _, v := range r.data().tables {
+		if v.Event_ID == eventId {
+			tables = append(tables, r.withOccupancy(v))
+		}
+	}
+	page.Total = int64(len(tables))
+
+	less := func(a model.Table, b model.Table) bool {
+		c := 0
+		if q.Sort == TableSortCapacity {
+			c = a.Capacity - b.Capacity
+		}
+		if c == 0 {
+			c = a.Id - b.Id
+		}
+		if q.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.Slice(tables, func(i, j int) bool { return less(tables[i], tables[j]) })
+
+	if q.After != nil {
+		last := model.Table{Id: q.After.Id}
+		if q.Sort == TableSortCapacity {
+			value, err := cursorValue(q.After)
+			if err != nil {
+				return page, err
+			}
+			last.Capacity = value.(int)
+		}
+		start := sort.Search(len(tables), func(i int) bool { return less(last, tables[i]) })
+		tables = tables[start:]
+	}
+
+	if len(tables) > q.Limit {
+		tables = tables[:q.Limit]
+		page.Next = tableCursor(q.Sort, q.Desc, tables[q.Limit-1])
+	}
+	page.Tables = tables
+
+	return page, nil
+}
+
+// Like the database backends a missing table is returned empty rather than as an error
+func (r *memoryTableRepository) FindById(eventId int, id int) (model.Table, error) {
+	defer r.lock()()
+
+	table, ok := r.data().tables[id]
+	if !ok || table.Event_ID != eventId {
+		return model.Table{}, nil
+	}
+
+	return r.withOccupancy(table), nil
+}
+
+// The whole store is locked during a unit of work, so there is no row to lock
+func (r *memoryTableRepository) FindByIdForUpdate(eventId int, id int) (model.Table, error) {
+	defer r.lock()()
+
+	table, ok := r.data().tables[id]
+	if !ok || table.Event_ID != eventId {
+		return model.Table{}, gorm.ErrRecordNotFound
+	}
+
+	return r.withOccupancy(table), nil
+}
+
+func (r *memoryTableRepository) Save(table model.Table) (model.Table, error) {
+	defer r.lock()()
+
+	table.Id = r.data().nextId("table", table.Id)
+	r.data().tables[table.Id] = table
+
+	return table, nil
+}
+
+func (r *memoryTableRepository) Update(table model.Table) error {
+	defer r.lock()()
+
+	r.data().tables[table.Id] = table
+
+	return nil
+}
+
+func (r *memoryTableRepository) Delete(table model.Table) error {
+	defer r.lock()()
+
+	delete(r.data().tables, table.Id)
+
+	return nil
+}
+
+// Returns the visits of the guest matching keep, the first one first
+func (r *memoryVisitRepository) filter(guestId int, keep func(visit model.Visit) bool) []model.Visit {
+	var visits []model.Visit
+	for _, v := range r.data().visits {
+		if v.Guest_ID == guestId && keep(v) {
+			visits = append(visits, v)
+		}
+	}
+
+	sort.Slice(visits, func(i, j int) bool { return visits[i].Id < visits[j].Id })
+
+	return visits
+}
+
+func (r *memoryVisitRepository) FindByGuest(guestId int) ([]model.Visit, error) {
+	defer r.lock()()
+
+	return r.filter(guestId, func(visit model.Visit) bool { return true }), nil
+}
+
+func (r *memoryVisitRepository) FindOpen(guestId int) (model.Visit, error) {
+	defer r.lock()()
+
+	visits := r.filter(guestId, func(visit model.Visit) bool { return visit.LeftAt == nil })
+	if len(visits) == 0 {
+		return model.Visit{}, gorm.ErrRecordNotFound
+	}
+
+	return visits[len(visits)-1], nil
+}
+
+func (r *memoryVisitRepository) Save(visit model.Visit) (model.Visit, error) {
+	defer r.lock()()
+
+	visit.Id = r.data().nextId("visit", visit.Id)
+	r.data().visits[visit.Id] = visit
+
+	return visit, nil
+}
+
+func (r *memoryVisitRepository) Update(visit model.Visit) error {
+	defer r.lock()()
+
+	r.data().visits[visit.Id] = visit
+
+	return nil
+}
+
+func (r *memoryVisitRepository) DeleteByGuest(guestId int) error {
+	defer r.lock()()
+
+	for id, v := range r.data().visits {
+		if v.Guest_ID == guestId {
+			delete(r.data().visits, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryConstraintRepository) FindAll(eventId int) ([]model.Constraint, error) {
+	defer r.lock()()
+
+	var constraints []model.Constraint
+	for _, v := range r.data().constraints {
+		if v.Event_ID == eventId {
+			constraints = append(constraints, v)
+		}
+	}
+
+	sort.Slice(constraints, func(i, j int) bool { return constraints[i].Id < constraints[j].Id })
+
+	return constraints, nil
+}
+
+func (r *memoryConstraintRepository) FindById(eventId int, id int) (model.Constraint, error) {
+	defer r.lock()()
+
+	constraint, ok := r.data().constraints[id]
+	if !ok || constraint.Event_ID != eventId {
+		return model.Constraint{}, gorm.ErrRecordNotFound
+	}
+
+	return constraint, nil
+}
+
+func (r *memoryConstraintRepository) Save(constraint model.Constraint) (model.Constraint, error) {
+	defer r.lock()()
+
+	constraint.Id = r.data().nextId("constraint", constraint.Id)
+	r.data().constraints[constraint.Id] = constraint
+
+	return constraint, nil
+}
+
+func (r *memoryConstraintRepository) Update(constraint model.Constraint) error {
+	defer r.lock()()
+
+	r.data().constraints[constraint.Id] = constraint
+
+	return nil
+}
+
+func (r *memoryConstraintRepository) Delete(constraint model.Constraint) error {
+	defer r.lock()()
+
+	delete(r.data().constraints, constraint.Id)
+
+	return nil
+}
+
+func (r *memoryWaitlistRepository) FindAll(eventId int) ([]model.WaitlistEntry, error) {
+	defer r.lock()()
+
+	var entries []model.WaitlistEntry
+	for _, v := range r.data().waitlist {
+		if v.Event_ID == eventId {
+			entries = append(entries, v)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Id < entries[j].Id })
+
+	return entries, nil
+}
+
+func (r *memoryWaitlistRepository) FindById(eventId int, id int) (model.WaitlistEntry, error) {
+	defer r.lock()()
+
+	entry, ok := r.data().waitlist[id]
+	if !ok || entry.Event_ID != eventId {
+		return model.WaitlistEntry{}, gorm.ErrRecordNotFound
+	}
+
+	return entry, nil
+}
+
+func (r *memoryWaitlistRepository) Save(entry model.WaitlistEntry) (model.WaitlistEntry, error) {
+	defer r.lock()()
+
+	entry.Id = r.data().nextId("waitlist", entry.Id)
+	r.data().waitlist[entry.Id] = entry
+
+	return entry, nil
+}
+
+func (r *memoryWaitlistRepository) Update(entry model.WaitlistEntry) error {
+	defer r.lock()()
+
+	r.data().waitlist[entry.Id] = entry
+
+	return nil
+}
+
+func (r *memoryWaitlistRepository) Delete(entry model.WaitlistEntry) error {
+	defer r.lock()()
+
+	delete(r.data().waitlist, entry.Id)
+
+	return nil
+}
+
+func (r *memoryAuditRepository) Save(entry model.AuditEntry) (model.AuditEntry, error) {
+	defer r.lock()()
+
+	entry.Id = r.data().nextId("audit", entry.Id)
+	r.store.data.audit = append(r.store.data.audit, entry)
+
+	return entry, nil
+}
+
+func (r *memoryAuditRepository) Query(eventId int, q AuditQuery) (AuditPage, error) {
+	defer r.lock()()
+
+	var page AuditPage
+	q.Limit = pageLimit(q.Limit)
+
+	if err := checkCursor(q.After, AuditSortId, q.Desc); err != nil {
+		return page, err
+	}
+
+	matches := func(filter string, value string) bool { return filter == "" || filter == value }
+	var entries []model.AuditEntry
+	for _, v := range r.data().audit {
+		if v.Event_ID == eventId && matches(q.Actor, v.Actor) && matches(q.Action, v.Action) && matches(q.Entity, v.Entity) &&
+			matches(q.Entity_ID, v.Entity_ID) && matches(q.Request_ID, v.Request_ID) &&
+			(q.Since.IsZero() || !v.CreatedAt.Before(q.Since)) && (q.Until.IsZero() || v.CreatedAt.Before(q.Until)) {
+			entries = append(entries, v)
+		}
+	}
+	page.Total = int64(len(entries))
+
+	sort.Slice(entries, func(i, j int) bool {
+		if q.Desc {
+			return entries[i].Id > entries[j].Id
+		}
+		return entries[i].Id < entries[j].Id
+	})
+
+	if q.After != nil {
+		start := sort.Search(len(entries), func(i int) bool {
+			if q.Desc {
+				return entries[i].Id < q.After.Id
+			}
+			return entries[i].Id > q.After.Id
+		})
+		entries = entries[start:]
+	}
+
+	if len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		page.Next = &Cursor{Sort: AuditSortId, Desc: q.Desc, Id: entries[q.Limit-1].Id}
+	}
+	page.Entries = entries
+
+	return page, nil
+}
+
+func (r *memoryLedgerRepository) Append(entry model.LedgerEntry) (model.LedgerEntry, error) {
+	defer r.lock()()
+
+	entry.Id = r.data().nextId("ledger", entry.Id)
+	r.store.data.ledger = append(r.store.data.ledger, entry)
+
+	return entry, nil
+}
+
+func (r *memoryLedgerRepository) FindAll(eventId int) ([]model.LedgerEntry, error) {
+	defer r.lock()()
+
+	var entries []model.LedgerEntry
+	for _, v := range r.data().ledger {
+		if v.Event_ID == eventId {
+			entries = append(entries, v)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Id < entries[j].Id })
+
+	return entries, nil
+}
+
+func (r *memoryWebhookRepository) FindAll(eventId int) ([]model.Webhook, error) {
+	defer r.lock()()
+
+	var webhooks []model.Webhook
+	for _, v := range r.data().webhooks {
+		if v.Event_ID == eventId {
+			webhooks = append(webhooks, v)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].Id < webhooks[j].Id })
+
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) FindById(eventId int, id int) (model.Webhook, error) {
+	defer r.lock()()
+
+	webhook, ok := r.data().webhooks[id]
+	if !ok || webhook.Event_ID != eventId {
+		return model.Webhook{}, gorm.ErrRecordNotFound
+	}
+
+	return webhook, nil
+}
+
+func (r *memoryWebhookRepository) Save(webhook model.Webhook) (model.Webhook, error) {
+	defer r.lock()()
+
+	webhook.Id = r.data().nextId("webhook", webhook.Id)
+	r.data().webhooks[webhook.Id] = webhook
+
+	return webhook, nil
+}
+
+func (r *memoryWebhookRepository) Delete(webhook model.Webhook) error {
+	defer r.lock()()
+
+	delete(r.data().webhooks, webhook.Id)
+
+	return nil
+}
+
+func (r *memoryDeliveryRepository) Save(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	defer r.lock()()
+
+	delivery.Id = r.data().nextId("delivery", delivery.Id)
+	r.changingDelivery(delivery.Id)
+	r.data().deliveries[delivery.Id] = delivery
+
+	return delivery, nil
+}
+
+func (r *memoryDeliveryRepository) Update(delivery model.WebhookDelivery) error {
+	defer r.lock()()
+
+	r.changingDelivery(delivery.Id)
+	r.data().deliveries[delivery.Id] = delivery
+
+	return nil
+}
+
+// The memory store runs one unit of work at a time, so the deliveries do not need locking
+func (r *memoryDeliveryRepository) FindDueForUpdate(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	defer r.lock()()
+
+	var deliveries []model.WebhookDelivery
+	for _, v := range r.data().deliveries {
+		if v.Status == model.DeliveryPending && !v.NextAttemptAt.After(now) {
+			deliveries = append(deliveries, v)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttemptAt.Equal(deliveries[j].NextAttemptAt) {
+			return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+		}
+		return deliveries[i].Id < deliveries[j].Id
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+func (r *memoryDeliveryRepository) Query(eventId int, q DeliveryQuery) (DeliveryPage, error) {
+	defer r.lock()()
+
+	var page DeliveryPage
+	q.Limit = pageLimit(q.Limit)
+
+	if err := checkCursor(q.After, DeliverySortId, q.Desc); err != nil {
+		return page, err
+	}
+
+	var deliveries []model.WebhookDelivery
+	for _, v := range r.data().deliveries {
+		if v.Event_ID == eventId && (q.Webhook_ID == 0 || v.Webhook_ID == q.Webhook_ID) && (q.Status == "" || v.Status == q.Status) {
+			deliveries = append(deliveries, v)
+		}
+	}
+	page.Total = int64(len(deliveries))
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		if q.Desc {
+			return deliveries[i].Id > deliveries[j].Id
+		}
+		return deliveries[i].Id < deliveries[j].Id
+	})
+
+	if q.After != nil {
+		start := sort.Search(len(deliveries), func(i int) bool {
+			if q.Desc {
+				return deliveries[i].Id < q.After.Id
+			}
+			return deliveries[i].Id > q.After.Id
+		})
+		deliveries = deliveries[start:]
+	}
+
+	if len(deliveries) > q.Limit {
+		deliveries = deliveries[:q.Limit]
+		page.Next = &Cursor{Sort: DeliverySortId, Desc: q.Desc, Id: deliveries[q.Limit-1].Id}
+	}
+	page.Deliveries = deliveries
+
+	return page, nil
+}
+
+func (r *memoryDeliveryRepository) DeleteByWebhook(webhookId int) error {
+	defer r.lock()()
+
+	for k, v := range r.data().deliveries {
+		if v.Webhook_ID == webhookId {
+			r.changingDelivery(k)
+			delete(r.data().deliveries, k)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+// A one-off change to the data of an existing database, AutoMigrate only ever changes the schema
+type migration struct {
+	name string
+	run  func(tx *gorm.DB) error
+}
+
+// Records the migrations that have already been applied to the database
+type schemaMigration struct {
+	Name      string `gorm:"primaryKey;size:191"`
+	AppliedAt time.Time
+}
+
+func (u *schemaMigration) TableName() string {
+	return "schema_migration"
+}
+
+// Migrations are applied in this order and each one only runs once per database
+var migrations = []migration{
+	{
+		// Check-in used to take the arrived party off the table's capacity and checkout used to add it back,
+		// so adding the parties that are still at the table gives back the size the table was created with
+		name: "0001_restore_table_capacity",
+		run: func(tx *gorm.DB) error {
+			// Databases created after arrivals became timestamps never took seats off the capacity
+			if !tx.Migrator().HasColumn(&model.Guest{}, "time_arrived") {
+				return nil
+			}
+			// This query runs -> UPDATE `table` SET capacity = capacity + (SELECT ... FROM guest WHERE guest.table_id = `table`.id AND guest.time_arrived <> '')
+			return tx.Exec("UPDATE `table` SET capacity = capacity + " +
+				"(SELECT COALESCE(SUM(guest.acompanying_guests + 1), 0) FROM guest WHERE guest.table_id = `table`.id AND guest.time_arrived <> '')").Error
+		},
+	},
+	{
+		// Tables and guests created before there were events are moved into event 1, which is created for them
+		name: "0002_default_event",
+		run: func(tx *gorm.DB) error {
+			var orphans int64
+			if err := tx.Model(&model.Table{}).Where("event_id = 0 OR event_id IS NULL").Count(&orphans).Error; err != nil {
+				return err
+			}
+			if orphans == 0 {
+				return nil
+			}
+
+			now := time.Now().UTC()
+			event := model.Event{Id: 1, Name: "Party", StartTime: now, EndTime: now, Timezone: "UTC"}
+			if err := tx.FirstOrCreate(&event, model.Event{Id: 1}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE `table` SET event_id = 1 WHERE event_id = 0 OR event_id IS NULL").Error; err != nil {
+				return err
+			}
+			return tx.Exec("UPDATE guest SET event_id = 1 WHERE event_id = 0 OR event_id IS NULL").Error
+		},
+	},
+	{
+		// Guests created before there were public ids are given one
+		name: "0003_guest_public_ids",
+		run: func(tx *gorm.DB) error {
+			var guests []model.Guest
+			if err := tx.Where("public_id = '' OR public_id IS NULL").Find(&guests).Error; err != nil {
+				return err
+			}
+			for _, guest := range guests {
+				if err := tx.Model(&model.Guest{}).Where("id = ?", guest.Id).Update("public_id", model.NewGuestPublicId()).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Checkout used to delete the guest, so every guest that has arrived is still at the party and is given an open visit,
+		// the time they arrived is filled in by 0005
+		name: "0004_visit_history",
+		run: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&model.Guest{}, "time_arrived") {
+				return nil
+			}
+			return tx.Exec("INSERT INTO visit (event_id, guest_id, acompanying_guests) SELECT event_id, id, acompanying_guests FROM guest WHERE time_arrived <> ''").Error
+		},
+	},
+	{
+		// Arrivals and departures used to be stored as "15:04" strings, they are turned into timestamps on the day of the event
+		name: "0005_timestamps",
+		run: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&model.Guest{}, "time_arrived") {
+				return nil
+			}
+
+			events := make(map[int]model.Event)
+			for _, table := range []interface{}{&model.Guest{}, &model.Visit{}} {
+				if !tx.Migrator().HasColumn(table, "time_arrived") {
+					continue
+				}
+				if err := convertClockTimes(tx, table, events); err != nil {
+					return err
+				}
+			}
+
+			// The visits opened by 0004 start when their guest arrived
+			if err := tx.Exec("UPDATE visit SET arrived_at = (SELECT guest.arrived_at FROM guest WHERE guest.id = visit.guest_id) WHERE arrived_at IS NULL").Error; err != nil {
+				return err
+			}
+
+			for _, table := range []interface{}{&model.Guest{}, &model.Visit{}} {
+				for _, column := range []string{"time_arrived", "time_left"} {
+					if !tx.Migrator().HasColumn(table, column) {
+						continue
+					}
+					if err := tx.Migrator().DropColumn(table, column); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// The guests and tables from before there was a ledger are appended to it as they are now, so it can be replayed from the start
+		name: "0006_ledger_baseline",
+		run: func(tx *gorm.DB) error {
+			var tables []model.Table
+			if err := tx.Order("id").Find(&tables).Error; err != nil {
+				return err
+			}
+			var guests []model.Guest
+			if err := tx.Order("id").Find(&guests).Error; err != nil {
+				return err
+			}
+
+			now := time.Now().UTC()
+			for _, table := range tables {
+				if err := appendBaseline(tx, model.LedgerTableAdded, table.Event_ID, model.LedgerStreamTable, table.Id, table, now); err != nil {
+					return err
+				}
+			}
+			for _, guest := range guests {
+				kind := model.LedgerGuestReserved
+				if guest.Present() {
+					kind = model.LedgerGuestArrived
+				}
+				if err := appendBaseline(tx, kind, guest.Event_ID, model.LedgerStreamGuest, guest.Id, guest, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+func appendBaseline(tx *gorm.DB, kind string, eventId int, stream string, id int, row interface{}, at time.Time) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&model.LedgerEntry{Event_ID: eventId, Type: kind, Stream: stream, Stream_ID: id, Data: string(data), CreatedAt: at}).Error
+}
+
+// The "15:04" times of a guest or visit row before 0005
+type clockTimes struct {
+	Id          int
+	Event_ID    int
+	TimeArrived string
+	TimeLeft    string
+}
+
+// Fills in arrived_at and left_at of every row of the table from its time_arrived and time_left columns
+func convertClockTimes(tx *gorm.DB, table interface{}, events map[int]model.Event) error {
+	timeLeft := "''"
+	if tx.Migrator().HasColumn(table, "time_left") {
+		timeLeft = "COALESCE(time_left, '')"
+	}
+
+	var rows []clockTimes
+	if err := tx.Model(table).Select("id, event_id, COALESCE(time_arrived, '') AS time_arrived, " + timeLeft + " AS time_left").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if row.TimeArrived == "" {
+			continue
+		}
+
+		event, ok := events[row.Event_ID]
+		if !ok {
+			if err := tx.First(&event, row.Event_ID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			events[row.Event_ID] = event
+		}
+
+		loc, err := time.LoadLocation(event.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		start := event.StartTime.In(loc)
+
+		// A time more than 12 hours before the event starts was after midnight, on the next day
+		arrived := onDayOf(start, row.TimeArrived)
+		if arrived.Before(start.Add(-12 * time.Hour)) {
+			arrived = arrived.AddDate(0, 0, 1)
+		}
+		updates := map[string]interface{}{"arrived_at": arrived.UTC()}
+
+		if row.TimeLeft != "" {
+			left := onDayOf(arrived, row.TimeLeft)
+			if left.Before(arrived) {
+				left = left.AddDate(0, 0, 1)
+			}
+			updates["left_at"] = left.UTC()
+		}
+
+		if err := tx.Model(table).Where("id = ?", row.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The "15:04" clock time on the same day as day, in its location. A clock time that can not be read is taken to be day itself.
+func onDayOf(day time.Time, clock string) time.Time {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		log.Println("Migration - Could not read the time " + clock)
+		return day
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), parsed.Hour(), parsed.Minute(), 0, 0, day.Location())
+}
+
+// Applies every migration that has not been applied to this database yet
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&schemaMigration{}).Where("name = ?", m.name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := m.run(tx); err != nil {
+				return err
+			}
+
+			log.Println("Migration " + m.name + " was applied")
+
+			return tx.Create(&schemaMigration{Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			log.Println("Migration " + m.name + " failed")
+			return err
+		}
+	}
+
+	return nil
+}
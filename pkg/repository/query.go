@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+// The keys a list of guests can be sorted by, every sort ends with the id so the order is always the same
+const (
+	// The order the guests were added in
+	GuestSortId      = "id"
+	GuestSortName    = "name"
+	GuestSortArrival = "time_arrived"
+	GuestSortTable   = "table_id"
+)
+
+// The keys a list of tables can be sorted by
+const (
+	TableSortId       = "id"
+	TableSortCapacity = "capacity"
+)
+
+// The audit log is only sorted by id, the order the entries were recorded in
+const AuditSortId = "id"
+
+// The delivery log is only sorted by id, the order the deliveries were queued in
+const DeliverySortId = "id"
+
+// The rows on a page when the query does not say, and the most it can ask for
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Keeps the page size of a query between 1 and MaxLimit
+func pageLimit(limit int) int {
+	if limit < 1 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// Returned when a cursor can not be read or was made for another order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Marks the last row of a page, the next page starts after it.
+// Paging by the last row rather than by an offset means rows added or removed meanwhile do not shift the pages.
+type Cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	// The sort value of the row, empty when the rows are sorted by id
+	Value string `json:"v,omitempty"`
+	// Set when the sort value of the row is NULL, such as the arrival of a guest who has not arrived
+	Null bool `json:"n,omitempty"`
+	Id   int  `json:"i"`
+}
+
+// The cursor as an opaque string that can be put in a URL
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// Which guests of an event to list, in which order, and which page of them
+type GuestQuery struct {
+	// 0 lists the guests of every table
+	Table_ID int
+	// Whether the guests have ever arrived, nil lists both
+	Arrived *bool
+	// Only the guests who are at the party
+	Present bool
+	// The party size counts the guest, 0 leaves the bound out
+	MinPartySize int
+	MaxPartySize int
+	// Matched against the start of the name, ignoring case
+	NamePrefix string
+	// One of the GuestSort constants
+	Sort string
+	Desc bool
+	// The page starts after this row, nil starts at the first row
+	After *Cursor
+	// 0 is DefaultLimit
+	Limit int
+}
+
+// A page of guests
+type GuestPage struct {
+	Guests []model.Guest
+	// Every guest matching the filters, on any page
+	Total int64
+	// The cursor of the next page, nil on the last page
+	Next *Cursor
+}
+
+// Which tables of an event to list, in which order, and which page of them
+type TableQuery struct {
+	// One of the TableSort constants
+	Sort  string
+	Desc  bool
+	After *Cursor
+	Limit int
+}
+
+// A page of tables
+type TablePage struct {
+	Tables []model.Table
+	Total  int64
+	Next   *Cursor
+}
+
+// The columns the guest sort keys are stored in
+var guestSortColumns = map[string]string{
+	GuestSortId:      "guest.id",
+	GuestSortName:    "guest.name",
+	GuestSortArrival: "guest.arrived_at",
+	GuestSortTable:   "guest.table_id",
+}
+
+var tableSortColumns = map[string]string{
+	TableSortId:       "`table`.id",
+	TableSortCapacity: "`table`.capacity",
+}
+
+// Checks that the cursor belongs to the query's order
+func checkCursor(after *Cursor, sortKey string, desc bool) error {
+	if after != nil && (after.Sort != sortKey || after.Desc != desc) {
+		return fmt.Errorf("%w: the cursor is for another order", ErrInvalidCursor)
+	}
+	return nil
+}
+
+// The cursor that marks the guest as the last row of a page
+func guestCursor(sortKey string, desc bool, guest model.Guest) *Cursor {
+	c := &Cursor{Sort: sortKey, Desc: desc, Id: guest.Id}
+	switch sortKey {
+	case GuestSortName:
+		c.Value = guest.Name
+	case GuestSortTable:
+		c.Value = strconv.Itoa(guest.Table_ID)
+	case GuestSortArrival:
+		if guest.ArrivedAt == nil {
+			c.Null = true
+		} else {
+			c.Value = guest.ArrivedAt.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return c
+}
+
+func tableCursor(sortKey string, desc bool, table model.Table) *Cursor {
+	c := &Cursor{Sort: sortKey, Desc: desc, Id: table.Id}
+	if sortKey == TableSortCapacity {
+		c.Value = strconv.Itoa(table.Capacity)
+	}
+	return c
+}
+
+// The sort value of the cursor with the type of its column
+func cursorValue(c *Cursor) (interface{}, error) {
+	switch c.Sort {
+	case GuestSortName:
+		return c.Value, nil
+	case GuestSortArrival:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		return t, nil
+	default:
+		n, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		return n, nil
+	}
+}
+
+// Orders the rows by the column then the id, rows without a value in a nullable column come last either way round,
+// and when there is a cursor only the rows after it are kept
+// This query runs -> ... WHERE (guest.name > 'sara' OR (guest.name = 'sara' AND guest.id > 5)) ORDER BY guest.name, guest.id
+func keyset(tx *gorm.DB, column string, idColumn string, nullable bool, desc bool, after *Cursor) (*gorm.DB, error) {
+	dir, cmp := "", ">"
+	if desc {
+		dir, cmp = " DESC", "<"
+	}
+
+	if after != nil {
+		switch {
+		case after.Null:
+			tx = tx.Where(column+" IS NULL AND "+idColumn+" "+cmp+" ?", after.Id)
+		case column == idColumn:
+			tx = tx.Where(idColumn+" "+cmp+" ?", after.Id)
+		default:
+			value, err := cursorValue(after)
+			if err != nil {
+				return nil, err
+			}
+			condition := column + " " + cmp + " ? OR (" + column + " = ? AND " + idColumn + " " + cmp + " ?)"
+			if nullable {
+				condition = column + " IS NULL OR " + condition
+			}
+			tx = tx.Where("("+condition+")", value, value, after.Id)
+		}
+	}
+
+	if nullable {
+		tx = tx.Order(column + " IS NULL")
+	}
+	if column != idColumn {
+		tx = tx.Order(column + dir)
+	}
+	return tx.Order(idColumn + dir), nil
+}
+
+// The LIKE pattern for names starting with the prefix, in lower case.
+// Its wildcards are escaped with !, which means the same in MySQL and SQLite, so the prefix is matched as it is written.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(prefix)) + "%"
+}
@@ -3,38 +3,35 @@
 package repository
 
 import (
-	"log"
-
 	"github.com/getground/tech-tasks/backend/pkg/model"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	"gorm.io/gorm/clause"
 )
 
 type TableRepository interface {
-	FindAll() ([]model.Table, error)
-	FindById(id int) (model.Table, error)
-	Save(table model.Table) error
+	FindAll(eventId int) ([]model.Table, error)
+	Query(eventId int, q TableQuery) (TablePage, error)
+	FindById(eventId int, id int) (model.Table, error)
+	FindByIdForUpdate(eventId int, id int) (model.Table, error)
+	Save(table model.Table) (model.Table, error)
 	Update(table model.Table) error
 	Delete(table model.Table) error
 }
 
+// The reserved seats are every party on the guest list for the table that has not left, the occupied seats are the parties that are at the party
+const occupancyColumns = "COALESCE(SUM(CASE WHEN guest.left_at IS NULL THEN guest.acompanying_guests + 1 ELSE 0 END), 0) AS reserved, " +
+	"COALESCE(SUM(CASE WHEN guest.arrived_at IS NOT NULL AND guest.left_at IS NULL THEN guest.acompanying_guests + 1 ELSE 0 END), 0) AS occupied"
+
+type occupancy struct {
+	Reserved int
+	Occupied int
+}
+
 type tableDatabase struct {
 	connection *gorm.DB
 }
 
-func NewTableRepository() TableRepository {
-	dsn := "user:password@tcp(host.docker.internal:3306)/getground?charset=utf8&parseTime=True&loc=Local"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		//If connection fails then throw error
-		panic("Could not connect to db")
-	}
-
-	log.Println("Database connection is successful")
-
+func NewTableRepository(db *gorm.DB) TableRepository {
 	db.AutoMigrate(&model.Table{})
 
 	return &tableDatabase{
@@ -43,29 +40,95 @@ func NewTableRepository() TableRepository {
 
 }
 
-func (db *tableDatabase) FindAll() ([]model.Table, error) {
+// Selects the tables together with their reserved and occupied seats
+// This query runs -> SELECT `table`.*, COALESCE(SUM(...)) AS reserved, ... FROM `table` LEFT JOIN guest ON guest.table_id = `table`.id GROUP BY `table`.id
+func (db *tableDatabase) withOccupancy() *gorm.DB {
+	return db.connection.Model(&model.Table{}).
+		Select("`table`.*, " + occupancyColumns).
+		Joins("LEFT JOIN guest ON guest.table_id = `table`.id").
+		Group("`table`.id")
+}
+
+func (db *tableDatabase) FindAll(eventId int) ([]model.Table, error) {
 	var tables []model.Table
-	if err := db.connection.Find(&tables).Error; err != nil {
+	if err := db.withOccupancy().Where("`table`.event_id = ?", eventId).Find(&tables).Error; err != nil {
 		return tables, err
 	}
 
 	return tables, nil
 }
 
-func (db *tableDatabase) FindById(id int) (model.Table, error) {
+// A page of the tables of the event together with how many there are
+// This query runs -> SELECT `table`.*, COALESCE(SUM(...)) AS reserved, ... WHERE `table`.event_id = 1 AND `table`.id > 5 GROUP BY `table`.id ORDER BY `table`.id LIMIT 51
+func (db *tableDatabase) Query(eventId int, q TableQuery) (TablePage, error) {
+	var page TablePage
+	q.Limit = pageLimit(q.Limit)
+
+	column, ok := tableSortColumns[q.Sort]
+	if !ok {
+		column, q.Sort = tableSortColumns[TableSortId], TableSortId
+	}
+	if err := checkCursor(q.After, q.Sort, q.Desc); err != nil {
+		return page, err
+	}
+
+	// This query runs -> SELECT count(*) FROM `table` WHERE event_id = 1
+	if err := db.connection.Model(&model.Table{}).Where("event_id = ?", eventId).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	tx, err := keyset(db.withOccupancy().Where("`table`.event_id = ?", eventId), column, tableSortColumns[TableSortId], false, q.Desc, q.After)
+	if err != nil {
+		return page, err
+	}
+
+	if err := tx.Limit(q.Limit + 1).Find(&page.Tables).Error; err != nil {
+		return page, err
+	}
+	if len(page.Tables) > q.Limit {
+		page.Tables = page.Tables[:q.Limit]
+		page.Next = tableCursor(q.Sort, q.Desc, page.Tables[q.Limit-1])
+	}
+
+	return page, nil
+}
+
+func (db *tableDatabase) FindById(eventId int, id int) (model.Table, error) {
+	var table model.Table
+	if err := db.withOccupancy().Where("`table`.id = ? AND `table`.event_id = ?", id, eventId).Find(&table).Error; err != nil {
+		return table, err
+	}
+
+	return table, nil
+}
+
+// Same as FindById but the row stays locked until the surrounding transaction ends
+// This query runs -> SELECT * FROM `table` WHERE event_id = 1 AND `table`.`id` = 5 ORDER BY `table`.`id` LIMIT 1 FOR UPDATE
+func (db *tableDatabase) FindByIdForUpdate(eventId int, id int) (model.Table, error) {
 	var table model.Table
-	if err := db.connection.Find(&table, id).Error; err != nil {
+	var seats occupancy
+
+	if err := db.connection.Clauses(clause.Locking{Strength: "UPDATE"}).Where("event_id = ?", eventId).First(&table, id).Error; err != nil {
+		return table, err
+	}
+
+	// The guests are read with a locking read too, so the counts include every guest committed before the lock was taken
+	// This query runs -> SELECT COALESCE(SUM(...)) AS reserved, ... FROM `guest` WHERE table_id = 5 FOR UPDATE
+	if err := db.connection.Model(&model.Guest{}).Clauses(clause.Locking{Strength: "UPDATE"}).Select(occupancyColumns).Where("table_id = ?", id).Scan(&seats).Error; err != nil {
 		return table, err
 	}
 
+	table.Reserved = seats.Reserved
+	table.Occupied = seats.Occupied
+
 	return table, nil
 }
 
-func (db *tableDatabase) Save(table model.Table) error {
+func (db *tableDatabase) Save(table model.Table) (model.Table, error) {
 	if err := db.connection.Create(&table).Error; err != nil {
-		return err
+		return table, err
 	}
-	return nil
+	return table, nil
 }
 
 func (db *tableDatabase) Update(table model.Table) error {
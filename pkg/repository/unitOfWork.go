@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+// The repositories that can take part in a unit of work
+type Repositories struct {
+	Events      EventRepository
+	Guests      GuestRepository
+	Tables      TableRepository
+	Visits      VisitRepository
+	Constraints ConstraintRepository
+	Waitlist    WaitlistRepository
+	Audit       AuditRepository
+	Ledger      LedgerRepository
+	Webhooks    WebhookRepository
+	Deliveries  DeliveryRepository
+}
+
+// A unit of work runs several repository calls as one all-or-nothing operation.
+// If fn returns an error every change made through the repositories it was given is rolled back.
+type UnitOfWork interface {
+	Do(fn func(repos Repositories) error) error
+}
+
+type unitOfWork struct {
+	connection *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) UnitOfWork {
+	return &unitOfWork{
+		connection: db,
+	}
+}
+
+func (uow *unitOfWork) Do(fn func(repos Repositories) error) error {
+	// This runs -> BEGIN ... COMMIT, or ROLLBACK when fn fails
+	return uow.connection.Transaction(func(tx *gorm.DB) error {
+		return fn(Repositories{
+			Events:      &eventDatabase{connection: tx},
+			Guests:      &guestDatabase{connection: tx},
+			Tables:      &tableDatabase{connection: tx},
+			Visits:      &visitDatabase{connection: tx},
+			Constraints: &constraintDatabase{connection: tx},
+			Waitlist:    &waitlistDatabase{connection: tx},
+			Audit:       &auditDatabase{connection: tx},
+			Ledger:      &ledgerDatabase{connection: tx},
+			Webhooks:    &webhookDatabase{connection: tx},
+			Deliveries:  &deliveryDatabase{connection: tx},
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+type VisitRepository interface {
+	FindByGuest(guestId int) ([]model.Visit, error)
+	FindOpen(guestId int) (model.Visit, error)
+	Save(visit model.Visit) (model.Visit, error)
+	Update(visit model.Visit) error
+	DeleteByGuest(guestId int) error
+}
+
+type visitDatabase struct {
+	connection *gorm.DB
+}
+
+func NewVisitRepository(db *gorm.DB) VisitRepository {
+	db.AutoMigrate(&model.Visit{})
+
+	return &visitDatabase{
+		connection: db,
+	}
+}
+
+// Every visit of the guest, the first one first
+// This query runs -> SELECT * FROM `visit` WHERE guest_id = 2 ORDER BY id
+func (db *visitDatabase) FindByGuest(guestId int) ([]model.Visit, error) {
+	var visits []model.Visit
+	if err := db.connection.Where("guest_id = ?", guestId).Order("id").Find(&visits).Error; err != nil {
+		return visits, err
+	}
+	return visits, nil
+}
+
+// The visit the guest has not left yet
+func (db *visitDatabase) FindOpen(guestId int) (model.Visit, error) {
+	var visit model.Visit
+	// This query runs -> SELECT * FROM `visit` WHERE guest_id = 2 AND left_at IS NULL ORDER BY `visit`.`id` DESC LIMIT 1
+	if err := db.connection.Where("guest_id = ? AND left_at IS NULL", guestId).Order("id DESC").First(&visit).Error; err != nil {
+		return visit, err
+	}
+	return visit, nil
+}
+
+func (db *visitDatabase) Save(visit model.Visit) (model.Visit, error) {
+	if err := db.connection.Create(&visit).Error; err != nil {
+		return visit, err
+	}
+	return visit, nil
+}
+
+func (db *visitDatabase) Update(visit model.Visit) error {
+	if err := db.connection.Save(&visit).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Removes the history of a guest who is taken off the guest list
+// This query runs -> DELETE FROM `visit` WHERE guest_id = 2
+func (db *visitDatabase) DeleteByGuest(guestId int) error {
+	if err := db.connection.Where("guest_id = ?", guestId).Delete(&model.Visit{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+)
+
+type WaitlistRepository interface {
+	FindAll(eventId int) ([]model.WaitlistEntry, error)
+	FindById(eventId int, id int) (model.WaitlistEntry, error)
+	Save(entry model.WaitlistEntry) (model.WaitlistEntry, error)
+	Update(entry model.WaitlistEntry) error
+	Delete(entry model.WaitlistEntry) error
+}
+
+type waitlistDatabase struct {
+	connection *gorm.DB
+}
+
+func NewWaitlistRepository(db *gorm.DB) WaitlistRepository {
+	db.AutoMigrate(&model.WaitlistEntry{})
+
+	return &waitlistDatabase{
+		connection: db,
+	}
+}
+
+// Every entry of the event, waiting or promoted, in the order they joined
+// This query runs -> SELECT * FROM `waitlist` WHERE event_id = 1 ORDER BY id
+func (db *waitlistDatabase) FindAll(eventId int) ([]model.WaitlistEntry, error) {
+	var entries []model.WaitlistEntry
+	if err := db.connection.Where("event_id = ?", eventId).Order("id").Find(&entries).Error; err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
+
+// Returns gorm.ErrRecordNotFound when the event has no entry with the id
+// This query runs -> SELECT * FROM `waitlist` WHERE event_id = 1 AND `waitlist`.`id` = 3 LIMIT 1
+func (db *waitlistDatabase) FindById(eventId int, id int) (model.WaitlistEntry, error) {
+	var entry model.WaitlistEntry
+	if err := db.connection.Where("event_id = ?", eventId).First(&entry, id).Error; err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+func (db *waitlistDatabase) Save(entry model.WaitlistEntry) (model.WaitlistEntry, error) {
+	if err := db.connection.Create(&entry).Error; err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+func (db *waitlistDatabase) Update(entry model.WaitlistEntry) error {
+	if err := db.connection.Save(&entry).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (db *waitlistDatabase) Delete(entry model.WaitlistEntry) error {
+	if err := db.connection.Delete(&entry).Error; err != nil {
+		return err
+	}
+	return nil
+}
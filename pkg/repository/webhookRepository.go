@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	FindAll(eventId int) ([]model.Webhook, error)
+	FindById(eventId int, id int) (model.Webhook, error)
+	Save(webhook model.Webhook) (model.Webhook, error)
+	Delete(webhook model.Webhook) error
+}
+
+// The deliveries of the webhooks, the pending ones are the queue they are sent from
+type DeliveryRepository interface {
+	Save(delivery model.WebhookDelivery) (model.WebhookDelivery, error)
+	Update(delivery model.WebhookDelivery) error
+	// The pending deliveries of every event that are due by now, oldest first, locked until the surrounding transaction ends
+	FindDueForUpdate(now time.Time, limit int) ([]model.WebhookDelivery, error)
+	Query(eventId int, q DeliveryQuery) (DeliveryPage, error)
+	DeleteByWebhook(webhookId int) error
+}
+
+// Which deliveries of an event to list, and which page of them. Empty filters are left out.
+type DeliveryQuery struct {
+	Webhook_ID int
+	Status     string
+	// The newest deliveries first
+	Desc  bool
+	After *Cursor
+	Limit int
+}
+
+// A page of the delivery log
+type DeliveryPage struct {
+	Deliveries []model.WebhookDelivery
+	Total      int64
+	Next       *Cursor
+}
+
+type webhookDatabase struct {
+	connection *gorm.DB
+}
+
+type deliveryDatabase struct {
+	connection *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	db.AutoMigrate(&model.Webhook{})
+
+	return &webhookDatabase{
+		connection: db,
+	}
+}
+
+func NewDeliveryRepository(db *gorm.DB) DeliveryRepository {
+	db.AutoMigrate(&model.WebhookDelivery{})
+
+	return &deliveryDatabase{
+		connection: db,
+	}
+}
+
+// This query runs -> SELECT * FROM `webhook` WHERE event_id = 1 ORDER BY id
+func (db *webhookDatabase) FindAll(eventId int) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	if err := db.connection.Where("event_id = ?", eventId).Order("id").Find(&webhooks).Error; err != nil {
+		return webhooks, err
+	}
+	return webhooks, nil
+}
+
+// Returns gorm.ErrRecordNotFound when the event has no webhook with the id
+// This query runs -> SELECT * FROM `webhook` WHERE event_id = 1 AND `webhook`.`id` = 2 ORDER BY `webhook`.`id` LIMIT 1
+func (db *webhookDatabase) FindById(eventId int, id int) (model.Webhook, error) {
+	var webhook model.Webhook
+	if err := db.connection.Where("event_id = ?", eventId).First(&webhook, id).Error; err != nil {
+		return webhook, err
+	}
+	return webhook, nil
+}
+
+func (db *webhookDatabase) Save(webhook model.Webhook) (model.Webhook, error) {
+	if err := db.connection.Create(&webhook).Error; err != nil {
+		return webhook, err
+	}
+	return webhook, nil
+}
+
+func (db *webhookDatabase) Delete(webhook model.Webhook) error {
+	if err := db.connection.Delete(&webhook).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// This query runs -> INSERT INTO `webhook_delivery` (`event_id`,`webhook_id`,`type`,`payload`,`status`,...) VALUES (1,2,'guest.checked_in','{...}','pending',...)
+func (db *deliveryDatabase) Save(delivery model.WebhookDelivery) (model.WebhookDelivery, error) {
+	if err := db.connection.Create(&delivery).Error; err != nil {
+		return delivery, err
+	}
+	return delivery, nil
+}
+
+func (db *deliveryDatabase) Update(delivery model.WebhookDelivery) error {
+	if err := db.connection.Save(&delivery).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// This query runs -> SELECT * FROM `webhook_delivery` WHERE status = 'pending' AND next_attempt_at <= '2026-12-31 20:00:00' ORDER BY next_attempt_at,id LIMIT 10 FOR UPDATE
+func (db *deliveryDatabase) FindDueForUpdate(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := db.connection.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now.UTC()).
+		Order("next_attempt_at").Order("id").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return deliveries, err
+	}
+	return deliveries, nil
+}
+
+// This query runs -> SELECT * FROM `webhook_delivery` WHERE webhook_delivery.event_id = 1 AND webhook_delivery.webhook_id = 2 ORDER BY webhook_delivery.id DESC LIMIT 101
+func (db *deliveryDatabase) Query(eventId int, q DeliveryQuery) (DeliveryPage, error) {
+	var page DeliveryPage
+	q.Limit = pageLimit(q.Limit)
+
+	if err := checkCursor(q.After, DeliverySortId, q.Desc); err != nil {
+		return page, err
+	}
+
+	tx := db.connection.Model(&model.WebhookDelivery{}).Where("webhook_delivery.event_id = ?", eventId)
+	if q.Webhook_ID != 0 {
+		tx = tx.Where("webhook_delivery.webhook_id = ?", q.Webhook_ID)
+	}
+	if q.Status != "" {
+		tx = tx.Where("webhook_delivery.status = ?", q.Status)
+	}
+
+	if err := tx.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return page, err
+	}
+
+	tx, err := keyset(tx, "webhook_delivery.id", "webhook_delivery.id", false, q.Desc, q.After)
+	if err != nil {
+		return page, err
+	}
+
+	if err := tx.Limit(q.Limit + 1).Find(&page.Deliveries).Error; err != nil {
+		return page, err
+	}
+	if len(page.Deliveries) > q.Limit {
+		page.Deliveries = page.Deliveries[:q.Limit]
+		page.Next = &Cursor{Sort: DeliverySortId, Desc: q.Desc, Id: page.Deliveries[q.Limit-1].Id}
+	}
+
+	return page, nil
+}
+
+// This query runs -> DELETE FROM `webhook_delivery` WHERE webhook_id = 2
+func (db *deliveryDatabase) DeleteByWebhook(webhookId int) error {
+	if err := db.connection.Where("webhook_id = ?", webhookId).Delete(&model.WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
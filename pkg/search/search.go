@@ -0,0 +1,137 @@
+// The search package scores how well a typed name matches a name on the guest list.
+//
+// Names are compared without case and accents, and a typo or a missing letter still matches:
+// "hanna" finds "Hannah" and "Zoe" finds "Zoë".
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Names scoring below this are not a match
+const MinScore = 0.5
+
+// Lower case without accents or punctuation, with single spaces between the words
+func Normalize(s string) string {
+	// Splitting the letters from their accents and dropping the accents turns "é" into "e"
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		stripped = s
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(stripped), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// The number of letters that have to be inserted, deleted or changed to turn a into b
+func Levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Only the previous row of the table is kept
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = smallest(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func smallest(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// The share of three letter sequences the two strings have in common, from 0 to 1.
+// Every word is padded with spaces, the way PostgreSQL's pg_trgm does it, so the starts of words count for more.
+func TrigramSimilarity(a string, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// How well the query matches the name, from 0 to 1. Both are normalized first.
+// The query is compared with the whole name and with each of its words, so "hanna" matches "Hannah Smith".
+func Score(query string, name string) float64 {
+	q, n := Normalize(query), Normalize(name)
+	if q == "" || n == "" {
+		return 0
+	}
+	if q == n {
+		return 1
+	}
+
+	best := similarity(q, n)
+	if strings.HasPrefix(n, q) {
+		best = maxScore(best, 0.9)
+	}
+	for _, word := range strings.Fields(n) {
+		// A whole word matching is not quite as good as the whole name matching
+		best = maxScore(best, 0.95*similarity(q, word))
+	}
+	return best
+}
+
+// The better of the edit distance, scaled by the length of the longer string, and the trigram similarity
+func similarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+	longest := len([]rune(a))
+	if l := len([]rune(b)); l > longest {
+		longest = l
+	}
+	edit := 1 - float64(Levenshtein(a, b))/float64(longest)
+	return maxScore(edit, TrigramSimilarity(a, b))
+}
+
+func maxScore(a float64, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,338 @@
+// The seating package works out which table each party sits at.
+//
+// A party is never split across tables and a table never gets more people than it has room for. Parties can be limited
+// to some tables, kept away from others and kept apart from each other. The best plan seats the most people, then seats
+// the parties that wish to be near the stage there, then wastes the fewest seats, counting the empty seats at every table
+// that has someone at it. Small events are solved exactly, larger ones with a best-fit-decreasing heuristic.
+package seating
+
+import "sort"
+
+// The solvers a plan can come from
+const (
+	SolverExact     = "exact"
+	SolverHeuristic = "heuristic"
+)
+
+// Events with at most this many parties to seat are solved exactly
+const MaxExactParties = 10
+
+// The exact solver gives up after looking at this many partial plans and keeps the best it has found,
+// which is never worse than the heuristic's
+const maxExactSteps = 200000
+
+// A table and the seats that are left on it
+type Table struct {
+	Id int
+	// The seats that can still be booked
+	Room int
+	// Whether someone already has a seat at the table, its empty seats are wasted whether or not a party is added
+	InUse     bool
+	NearStage bool
+}
+
+// A party to seat, it takes Size seats at one table
+type Party struct {
+	Size int
+	// The ids of the only tables the party can sit at, nil lets it sit at any table and an empty list at none
+	Tables []int
+	// The ids of the tables the party can not sit at
+	NotTables []int
+	// The positions of the parties it can not share a table with
+	Apart []int
+	// How much seating the party near the stage counts, 0 when it has no wish to be there
+	Priority int
+}
+
+// Where each party sits
+type Plan struct {
+	// The table id of each party, in the order of the parties, 0 when the party could not be seated
+	Tables []int
+	// The people that have a seat
+	Seated int
+	// The priorities of the parties seated near the stage
+	Stage int
+	// The empty seats at the tables that have someone at them
+	Wasted int
+	Solver string
+}
+
+// Plans the seats of the parties at the tables
+func Solve(tables []Table, parties []Party) Plan {
+	plan := heuristic(tables, parties)
+	if len(parties) <= MaxExactParties {
+		exact, complete := solveExact(tables, parties, plan)
+		if complete {
+			exact.Solver = SolverExact
+		}
+		return exact
+	}
+	return plan
+}
+
+// Whether plan a is better than plan b
+func better(a Plan, b Plan) bool {
+	if a.Seated != b.Seated {
+		return a.Seated > b.Seated
+	}
+	if a.Stage != b.Stage {
+		return a.Stage > b.Stage
+	}
+	return a.Wasted < b.Wasted
+}
+
+// The parties kept to some tables go first so the others do not take their seats, then the biggest parties,
+// each to the table it leaves the fewest seats empty on.
+// Tables already in use are filled before an empty one is started, since their empty seats are wasted anyway.
+// A party that wishes to be near the stage takes a table there first, the others leave those tables for them.
+func heuristic(tables []Table, parties []Party) Plan {
+	s := newState(tables, parties)
+
+	order := bySize(parties)
+	sort.SliceStable(order, func(i, j int) bool { return parties[order[i]].Tables != nil && parties[order[j]].Tables == nil })
+
+	plan := Plan{Tables: make([]int, len(parties)), Solver: SolverHeuristic}
+	for _, p := range order {
+		stage := parties[p].Priority > 0
+		best := -1
+		for i := range tables {
+			if !s.fits(p, i) {
+				continue
+			}
+			if best == -1 {
+				best = i
+				continue
+			}
+			if tables[i].NearStage != tables[best].NearStage {
+				if tables[i].NearStage == stage {
+					best = i
+				}
+				continue
+			}
+			if (s.inUse[i] && !s.inUse[best]) || (s.inUse[i] == s.inUse[best] && s.room[i] < s.room[best]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		s.seat(p, best)
+		plan.Tables[p] = tables[best].Id
+		plan.Seated += parties[p].Size
+		if tables[best].NearStage {
+			plan.Stage += parties[p].Priority
+		}
+	}
+
+	plan.Wasted = wasted(s.room, s.inUse)
+	return plan
+}
+
+// The seats left on the tables and the parties at them while a plan is made
+type state struct {
+	parties []Party
+	room    []int
+	inUse   []bool
+	// The position of the table each party sits at, -1 when it has none
+	at []int
+	// The positions of the tables each party can sit at, by the rules of the party alone
+	allowed [][]bool
+	// Whether the party is kept apart from another, either way round
+	apart []bool
+}
+
+func newState(tables []Table, parties []Party) *state {
+	s := &state{
+		parties: parties,
+		room:    make([]int, len(tables)),
+		inUse:   make([]bool, len(tables)),
+		at:      make([]int, len(parties)),
+		allowed: make([][]bool, len(parties)),
+		apart:   make([]bool, len(parties)),
+	}
+	for i, t := range tables {
+		s.room[i], s.inUse[i] = t.Room, t.InUse
+	}
+	for p, party := range parties {
+		s.at[p] = -1
+		s.allowed[p] = make([]bool, len(tables))
+		for i, t := range tables {
+			s.allowed[p][i] = (party.Tables == nil || contains(party.Tables, t.Id)) && !contains(party.NotTables, t.Id)
+		}
+		for _, q := range party.Apart {
+			s.apart[p], s.apart[q] = true, true
+		}
+	}
+	return s
+}
+
+// Whether the party can sit at the table with the parties already seated
+func (s *state) fits(p int, i int) bool {
+	if s.room[i] < s.parties[p].Size || !s.allowed[p][i] {
+		return false
+	}
+	for _, q := range s.parties[p].Apart {
+		if s.at[q] == i {
+			return false
+		}
+	}
+	// Being kept apart goes both ways
+	for q, party := range s.parties {
+		if s.at[q] == i && contains(party.Apart, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *state) seat(p int, i int) {
+	s.room[i] -= s.parties[p].Size
+	s.inUse[i] = true
+	s.at[p] = i
+}
+
+func contains(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Tries every way of seating the parties, the biggest first, skipping the ones that can not beat the best plan so far.
+// complete is false when the search ran out of steps, the plan is then the best it found, which may not be the best there is.
+func solveExact(tables []Table, parties []Party, start Plan) (Plan, bool) {
+	order := bySize(parties)
+	s := newState(tables, parties)
+	room, inUse := s.room, s.inUse
+
+	// The people and the near stage priorities in the parties after each position of the order,
+	// to bound what the rest of a plan can still seat
+	remaining := make([]int, len(order)+1)
+	priorities := make([]int, len(order)+1)
+	for i := len(order) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + parties[order[i]].Size
+		priorities[i] = priorities[i+1] + parties[order[i]].Priority
+	}
+
+	// Tables a party is kept to or away from are told apart from the others, and so are tables with a party
+	// that others are kept apart from
+	named := make(map[int]bool)
+	for _, party := range parties {
+		for _, id := range append(append([]int(nil), party.Tables...), party.NotTables...) {
+			named[id] = true
+		}
+	}
+
+	best := start
+	assigned := make([]int, len(parties))
+	steps := 0
+
+	var search func(k int, seated int, stage int) bool
+	search = func(k int, seated int, stage int) bool {
+		steps++
+		if steps > maxExactSteps {
+			return false
+		}
+		if seated+remaining[k] < best.Seated {
+			return true
+		}
+		// To tie on the people seated every party left has to be seated, then the same goes for the stage
+		if seated+remaining[k] == best.Seated {
+			if stage+priorities[k] < best.Stage {
+				return true
+			}
+			// Each party left can at best fill seats that would be wasted
+			if stage+priorities[k] == best.Stage && wasted(room, inUse)-remaining[k] >= best.Wasted {
+				return true
+			}
+		}
+		if k == len(order) {
+			candidate := Plan{Seated: seated, Stage: stage, Wasted: wasted(room, inUse)}
+			if better(candidate, best) {
+				best.Tables = append([]int(nil), assigned...)
+				best.Seated, best.Stage, best.Wasted = candidate.Seated, candidate.Stage, candidate.Wasted
+			}
+			return true
+		}
+
+		p := order[k]
+		size := parties[p].Size
+		// Tables that look the same to the rest of the search are only tried once
+		tried := make(map[[4]int]bool)
+		for i := range tables {
+			if !s.fits(p, i) {
+				continue
+			}
+			key := [4]int{room[i], boolInt(inUse[i]), boolInt(tables[i].NearStage), 0}
+			if named[tables[i].Id] || s.keptApart(i) {
+				key[3] = tables[i].Id
+			}
+			if tried[key] {
+				continue
+			}
+			tried[key] = true
+
+			wasInUse := inUse[i]
+			s.seat(p, i)
+			assigned[p] = tables[i].Id
+			gain := 0
+			if tables[i].NearStage {
+				gain = parties[p].Priority
+			}
+			ok := search(k+1, seated+size, stage+gain)
+			room[i] += size
+			inUse[i] = wasInUse
+			s.at[p] = -1
+			assigned[p] = 0
+			if !ok {
+				return false
+			}
+		}
+
+		// Leaving the party out is only worth trying when it might not fit with the others
+		return search(k+1, seated, stage)
+	}
+
+	complete := search(0, 0, 0)
+	return best, complete
+}
+
+// Whether a party seated at the table is kept apart from another party
+func (s *state) keptApart(i int) bool {
+	for p := range s.parties {
+		if s.at[p] == i && s.apart[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// The empty seats at the tables that have someone at them
+func wasted(room []int, inUse []bool) int {
+	n := 0
+	for i := range room {
+		if inUse[i] {
+			n += room[i]
+		}
+	}
+	return n
+}
+
+// The positions of the parties, the biggest first and otherwise in the order they were given
+func bySize(parties []Party) []int {
+	order := make([]int, len(parties))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return parties[order[i]].Size > parties[order[j]].Size })
+	return order
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
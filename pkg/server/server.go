@@ -0,0 +1,222 @@
+// The server wires the storage backend, services and controllers together and registers the routes
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/auth"
+	"github.com/getground/tech-tasks/backend/pkg/config"
+	"github.com/getground/tech-tasks/backend/pkg/controller"
+	"github.com/getground/tech-tasks/backend/pkg/hub"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// Builds the router for the party server on top of the given store. The work the server does in the background, sending
+// the webhook deliveries, stops when the context is done.
+func New(ctx context.Context, store repository.Store, cfg config.Config) (*gin.Engine, error) {
+	eventService := service.NewEventService(store.Events, service.EventOptions{
+		OverbookAllowance: cfg.Guests.OverbookAllowance,
+	})
+	// The services publish the changes they make, the live stream passes them on
+	changes := hub.New()
+	guestOptions := service.GuestOptions{
+		NamePolicy: cfg.Guests.NamePolicy,
+		WalkIns:    cfg.Guests.WalkIns,
+		MaxWalkIns: cfg.Guests.MaxWalkIns,
+		Publisher:  changes,
+	}
+	tableService := service.NewTableService(store.Events, store.Tables, store.UnitOfWork, guestOptions)
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, guestOptions)
+	seatingService := service.NewSeatingService(store.UnitOfWork, guestOptions)
+	constraintService := service.NewConstraintService(store.Guests, store.Tables, store.Constraints, store.UnitOfWork)
+	waitlistService := service.NewWaitlistService(store.Events, store.Guests, store.Waitlist, store.UnitOfWork, guestOptions)
+	auditService := service.NewAuditService(store.Audit)
+	ledgerService := service.NewLedgerService(store.UnitOfWork)
+	streamService := service.NewStreamService(store.Events, changes)
+	webhookService := service.NewWebhookService(store.Webhooks, store.Deliveries, store.UnitOfWork, cfg.Webhooks.Options())
+
+	// The routes outside /events/:eventId need the default event to exist
+	if err := eventService.EnsureExists(cfg.Events.DefaultEventId); err != nil {
+		return nil, err
+	}
+
+	eventController := controller.NewEventController(eventService, cfg.Events.DefaultEventId)
+	tableController := controller.NewTableController(tableService)
+	guestController := controller.NewGuestController(guestService, validation.NewGuestValidator(store.Tables))
+	exportController := controller.NewExportController(eventService, tableService, guestService)
+	seatingController := controller.NewSeatingController(seatingService, constraintService)
+	waitlistController := controller.NewWaitlistController(waitlistService)
+	auditController := controller.NewAuditController(auditService)
+	ledgerController := controller.NewLedgerController(ledgerService)
+	streamController := controller.NewStreamController(streamService)
+	webhookController := controller.NewWebhookController(webhookService)
+
+	if cfg.Webhooks.Enabled {
+		go deliverWebhooks(ctx, webhookService, changes)
+	}
+
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authenticator = auth.New(auth.Options{
+			Keys:       cfg.Auth.Keys(),
+			Secret:     cfg.Auth.SigningKey,
+			SessionTTL: time.Duration(cfg.Auth.SessionMinutes) * time.Minute,
+		})
+	}
+	authController := controller.NewAuthController(authenticator)
+	// Who may call each route, when auth is off every route is open
+	access := access{
+		read:   authController.Allow(auth.Roles...),
+		door:   authController.Allow(auth.RoleOrganiser, auth.RoleDoorStaff),
+		manage: authController.Allow(auth.RoleOrganiser),
+	}
+
+	// Initializes an instance of the gin engine with the recovery function, and the logger when it is switched on
+	router := gin.New()
+	router.Use(gin.Recovery())
+	if cfg.Features.RequestLogging {
+		router.Use(controller.RequestLogger())
+	}
+
+	// Every request gets an id, the audit log records it with the changes the request made
+	router.Use(controller.RequestID)
+	// Errors the handlers add to the context are answered with problem+json
+	router.Use(controller.ErrorHandler)
+
+	// test ping
+	router.GET("/ping", controller.HandlerPing)
+
+	// Staff exchange their API key for a session token, and can check who they are signed in as
+	if cfg.Auth.Enabled {
+		router.POST("/auth/sessions", authController.Authenticate, authController.CreateSession)
+		router.GET("/auth/session", authController.Authenticate, authController.GetSession)
+	}
+
+	router.GET("/events", authController.Authenticate, access.read, eventController.GetEvents)
+	router.POST("/events", authController.Authenticate, access.manage, eventController.CreateEvent)
+	// The caller is authenticated before the event is looked up, so nobody can find out which events exist
+	// The live stream of every event's changes, event_id and table_id narrow it down
+	router.GET("/events/stream", authController.TokenFromQuery, authController.Authenticate, access.read, streamController.GetStream)
+	router.GET("/events/socket", authController.TokenFromQuery, authController.Authenticate, access.read, streamController.GetSocket)
+	router.GET("/events/:eventId", authController.Authenticate, access.read, eventController.Scope, eventController.GetAnEvent)
+
+	// The party routes are served for every event, and at the top level for the default event
+	partyRoutes(router.Group("/events/:eventId", authController.Authenticate, eventController.Scope), access, tableController, guestController, exportController, seatingController, waitlistController, auditController, ledgerController, webhookController)
+	partyRoutes(router.Group("/", authController.Authenticate, eventController.Scope), access, tableController, guestController, exportController, seatingController, waitlistController, auditController, ledgerController, webhookController)
+
+	return router, nil
+}
+
+// The middleware of the roles that may call a route
+type access struct {
+	// Every role, the caterers only read
+	read gin.HandlerFunc
+	// Checking guests in and out at the door
+	door gin.HandlerFunc
+	// Changing the tables, the guest list and the seating
+	manage gin.HandlerFunc
+}
+
+func partyRoutes(router gin.IRoutes, access access, tableController controller.TableController, guestController controller.GuestController, exportController controller.ExportController, seatingController controller.SeatingController, waitlistController controller.WaitlistController, auditController controller.AuditController, ledgerController controller.LedgerController, webhookController controller.WebhookController) {
+	// Specifying routes
+	// Before Party
+
+	router.GET("/tables", access.read, tableController.GetTables)
+	router.GET("/tables/:id", access.read, tableController.GetATable)
+	router.POST("/tables", access.manage, tableController.CreateTable)
+	// Finds tables for parties that are not on the guest list yet and for re-seated guests, with "mode": "apply" it seats them
+	router.POST("/seating/plan", access.manage, seatingController.PlanSeating)
+	// Rules that keep guests together, apart, at a table or near the stage
+	router.GET("/seating/constraints", access.read, seatingController.GetConstraints)
+	router.POST("/seating/constraints", access.manage, seatingController.CreateConstraint)
+	router.DELETE("/seating/constraints/:id", access.manage, seatingController.DeleteConstraint)
+	router.GET("/seating/violations", access.read, seatingController.GetViolations)
+	router.PUT("/tables/:id", access.manage, tableController.UpdateTable)
+	router.PATCH("/tables/:id", access.manage, tableController.PatchTable)
+	router.DELETE("/tables/:id", access.manage, tableController.DeleteTable)
+
+	router.GET("/guest_list", access.read, guestController.GetGuests)
+	// Imports a whole guest list from a CSV or XLSX file
+	router.POST("/guest_list", access.manage, guestController.ImportGuests)
+	router.POST("/guest_list/:name", access.manage, guestController.CreateGuest)
+	// :guest is the guest's id, or their name when no other guest of the event has it
+	router.PUT("/guest_list/:guest", access.manage, guestController.UpdateGuest)
+	router.PATCH("/guest_list/:guest", access.manage, guestController.PatchGuest)
+	router.DELETE("/guest_list/:guest", access.manage, guestController.DeleteGuest)
+
+	//During Party
+	router.GET("/guests", access.read, guestController.GetArrivedGuests)
+	router.GET("/guests/departed", access.read, guestController.GetDepartedGuests)
+	router.GET("/guests/search", access.read, guestController.SearchGuests)
+	router.GET("/seats_empty", access.read, tableController.GetSpace)
+	// :guest is the guest's id, or their name when no other guest of the event has it
+	router.GET("/guests/:guest", access.read, guestController.GetAGuest)
+	router.PUT("/guests/:guest", access.door, guestController.Checkin)
+	router.DELETE("/guests/:guest", access.door, guestController.Checkout)
+	router.GET("/guests/:guest/visits", access.read, guestController.GetVisits)
+	// Guests who are not on the guest list are given a table at the door and checked in
+	router.POST("/walk_ins", access.door, guestController.WalkIn)
+	// Parties that did not fit, in the order they get seats when some are freed
+	router.GET("/waitlist", access.read, waitlistController.GetWaitlist)
+	router.GET("/waitlist/:id", access.read, waitlistController.GetAnEntry)
+	router.DELETE("/waitlist/:id", access.door, waitlistController.DeleteEntry)
+
+	// Hand-off documents for caterers and the venue
+	router.GET("/export/guests", access.read, exportController.ExportGuests)
+	router.GET("/export/seating_chart", access.read, exportController.ExportSeatingChart)
+
+	// Who changed which guest or table and how, only the organisers read it
+	router.GET("/audit", access.manage, auditController.GetAuditLog)
+	router.GET("/audit/export", access.manage, auditController.ExportAuditLog)
+	// Whether the guests and tables are still the ones the ledger of arrivals, departures, reservations and table changes projects
+	router.GET("/ledger/check", access.manage, ledgerController.CheckLedger)
+
+	// The URLs that are sent the guests' arrivals and departures and the tables that fill up, and what was sent to them
+	router.GET("/webhooks", access.manage, webhookController.GetWebhooks)
+	router.POST("/webhooks", access.manage, webhookController.CreateWebhook)
+	router.GET("/webhooks/:id", access.manage, webhookController.GetAWebhook)
+	router.DELETE("/webhooks/:id", access.manage, webhookController.DeleteWebhook)
+	router.GET("/webhooks/:id/deliveries", access.manage, webhookController.GetDeliveries)
+}
+
+// How often the delivery queue is looked at when nothing was published, for the retries and the deliveries queued before a restart
+const deliveryInterval = time.Second
+
+// Sends the webhook deliveries until the context is done. A change that queued a delivery wakes it up straight away.
+func deliverWebhooks(ctx context.Context, webhookService service.WebhookService, changes *hub.Hub) {
+	queued, cancel := changes.Subscribe(func(msg hub.Message) bool {
+		for _, v := range service.WebhookEvents {
+			if v == msg.Type {
+				return true
+			}
+		}
+		return false
+	}, 1)
+	defer cancel()
+	ticker := time.NewTicker(deliveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-queued:
+		case <-ticker.C:
+		}
+		// A full batch may have left more deliveries that are due
+		for {
+			n, err := webhookService.DeliverDue()
+			if err != nil {
+				log.Println("Webhooks - Could not send the due deliveries: " + err.Error())
+			}
+			if err != nil || n == 0 || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+)
+
+// Who asked for a change, it is recorded in the audit log with every change the request makes
+type Actor struct {
+	// The name of the API key or session, anonymous when auth is off
+	Name string
+	Role string
+	// The id of the request the change was made by
+	Request_ID string
+}
+
+// Lists the audit log of an event
+type AuditService interface {
+	FindAll(eventId int, req dto.AuditListReqDto) ([]dto.AuditResDto, dto.PageDto, error)
+	ListAll(eventId int, req dto.AuditListReqDto) ([]dto.AuditResDto, error)
+}
+
+type auditService struct {
+	auditRepository repository.AuditRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository) AuditService {
+	return &auditService{
+		auditRepository: auditRepo,
+	}
+}
+
+func auditQuery(req dto.AuditListReqDto) (repository.AuditQuery, error) {
+	q := repository.AuditQuery{
+		Actor:      req.Actor,
+		Action:     req.Action,
+		Entity:     req.Entity,
+		Entity_ID:  req.Entity_ID,
+		Request_ID: req.Request_ID,
+		Since:      req.Since,
+		Until:      req.Until,
+		Limit:      req.Limit,
+	}
+	// The latest changes are the ones looked for most, so they come first unless the request asks otherwise
+	sort := req.Sort
+	if sort == "" {
+		sort = "-" + repository.AuditSortId
+	}
+	_, q.Desc = sortKey(sort, repository.AuditSortId)
+
+	if req.Cursor != "" {
+		after, err := repository.DecodeCursor(req.Cursor)
+		if err != nil {
+			return q, err
+		}
+		q.After = &after
+	}
+	return q, nil
+}
+
+func (service *auditService) FindAll(eventId int, req dto.AuditListReqDto) ([]dto.AuditResDto, dto.PageDto, error) {
+	var resArr []dto.AuditResDto
+
+	q, err := auditQuery(req)
+	if err != nil {
+		log.Println("Get Audit Log Service - Could not read the cursor")
+		return resArr, dto.PageDto{}, err
+	}
+
+	// This query runs -> SELECT * FROM `audit_log` WHERE audit_log.event_id = 1 ORDER BY audit_log.id DESC LIMIT 101
+	page, err := service.auditRepository.Query(eventId, q)
+	if err != nil {
+		log.Println("Get Audit Log Service - Could not retrieve the audit log")
+		return resArr, dto.PageDto{}, err
+	}
+
+	for _, v := range page.Entries {
+		resArr = append(resArr, toAuditResDto(v))
+	}
+
+	return resArr, toPageDto(page.Total, page.Next), nil
+}
+
+// Every entry matching the filters, oldest first, for the export
+func (service *auditService) ListAll(eventId int, req dto.AuditListReqDto) ([]dto.AuditResDto, error) {
+	var resArr []dto.AuditResDto
+
+	q, err := auditQuery(req)
+	if err != nil {
+		return resArr, err
+	}
+	q.Desc = req.Sort == "-"+repository.AuditSortId
+	q.After = nil
+	q.Limit = repository.MaxLimit
+
+	for {
+		page, err := service.auditRepository.Query(eventId, q)
+		if err != nil {
+			log.Println("Export Audit Log Service - Could not retrieve the audit log")
+			return resArr, err
+		}
+		for _, v := range page.Entries {
+			resArr = append(resArr, toAuditResDto(v))
+		}
+		if page.Next == nil {
+			return resArr, nil
+		}
+		q.After = page.Next
+	}
+}
+
+func toAuditResDto(entry model.AuditEntry) dto.AuditResDto {
+	res := dto.AuditResDto{
+		Id:         entry.Id,
+		Time:       entry.CreatedAt.UTC().Format(time.RFC3339),
+		Actor:      entry.Actor,
+		Role:       entry.Role,
+		Action:     entry.Action,
+		Entity:     entry.Entity,
+		Entity_ID:  entry.Entity_ID,
+		Request_ID: entry.Request_ID,
+	}
+	if entry.Before != "" {
+		res.Before = json.RawMessage(entry.Before)
+	}
+	if entry.After != "" {
+		res.After = json.RawMessage(entry.After)
+	}
+	return res
+}
+
+// Records a change to a guest in the audit log and the ledger, in the caller's unit of work so the entries are only kept when the change is.
+// before is nil when the guest was created and after when they were deleted.
+func recordGuest(repos repository.Repositories, actor Actor, action string, before *model.Guest, after *model.Guest) error {
+	if err := ledgerGuest(repos, action, before, after); err != nil {
+		return err
+	}
+
+	entry := model.AuditEntry{Action: action, Entity: model.AuditEntityGuest}
+	var err error
+	if before != nil {
+		entry.Event_ID, entry.Entity_ID = before.Event_ID, before.PublicId
+		if entry.Before, err = snapshot(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		entry.Event_ID, entry.Entity_ID = after.Event_ID, after.PublicId
+		if entry.After, err = snapshot(after); err != nil {
+			return err
+		}
+	}
+	return record(repos, actor, entry)
+}
+
+// Records a change to a table, like recordGuest
+func recordTable(repos repository.Repositories, actor Actor, action string, before *model.Table, after *model.Table) error {
+	if err := ledgerTable(repos, action, before, after); err != nil {
+		return err
+	}
+
+	entry := model.AuditEntry{Action: action, Entity: model.AuditEntityTable}
+	var err error
+	if before != nil {
+		entry.Event_ID, entry.Entity_ID = before.Event_ID, strconv.Itoa(before.Id)
+		if entry.Before, err = snapshot(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		entry.Event_ID, entry.Entity_ID = after.Event_ID, strconv.Itoa(after.Id)
+		if entry.After, err = snapshot(after); err != nil {
+			return err
+		}
+	}
+	return record(repos, actor, entry)
+}
+
+func snapshot(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func record(repos repository.Repositories, actor Actor, entry model.AuditEntry) error {
+	entry.Actor = actor.Name
+	entry.Role = actor.Role
+	entry.Request_ID = actor.Request_ID
+	entry.CreatedAt = now()
+
+	// This query runs -> INSERT INTO `audit_log` (`event_id`,`actor`,`role`,`action`,...,`created_at`) VALUES (1,'front-door','door_staff','guest.checked_in',...)
+	if _, err := repos.Audit.Save(entry); err != nil {
+		log.Println("Audit Service - Could not record the change")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// The priority of a near stage wish that does not give one
+const DefaultStagePriority = 1
+
+// Keeps the seating rules of an event and lists the ones the guest list breaks
+type ConstraintService interface {
+	FindAll(eventId int) ([]dto.ConstraintResDto, error)
+	Save(eventId int, req dto.ConstraintReqDto) (dto.ConstraintResDto, error)
+	Delete(eventId int, id int) error
+	Violations(eventId int) ([]dto.ViolationResDto, error)
+}
+
+type constraintService struct {
+	guestRepository      repository.GuestRepository
+	tableRepository      repository.TableRepository
+	constraintRepository repository.ConstraintRepository
+	unitOfWork           repository.UnitOfWork
+}
+
+func NewConstraintService(guestRepo repository.GuestRepository, tableRepo repository.TableRepository, constraintRepo repository.ConstraintRepository, uow repository.UnitOfWork) ConstraintService {
+	return &constraintService{
+		guestRepository:      guestRepo,
+		tableRepository:      tableRepo,
+		constraintRepository: constraintRepo,
+		unitOfWork:           uow,
+	}
+}
+
+func (service *constraintService) FindAll(eventId int) ([]dto.ConstraintResDto, error) {
+	resArr := []dto.ConstraintResDto{}
+
+	// This query runs -> SELECT * FROM `seating_constraint` WHERE event_id = 1 ORDER BY id
+	constraints, err := service.constraintRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Constraints Service - Could not find constraints")
+		return nil, err
+	}
+
+	// This query runs -> SELECT * FROM `guest` WHERE event_id = 1
+	guests, err := service.guestRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Constraints Service - Could not find guests")
+		return nil, err
+	}
+	byId := guestsById(guests)
+
+	for _, v := range constraints {
+		resArr = append(resArr, toConstraintResDto(v, byId))
+	}
+
+	return resArr, nil
+}
+
+// Adds a seating rule. A rule the guest list already breaks can be added, it is then listed with the violations
+// and moves that keep breaking it are still allowed.
+func (service *constraintService) Save(eventId int, req dto.ConstraintReqDto) (dto.ConstraintResDto, error) {
+	var res dto.ConstraintResDto
+
+	err := service.unitOfWork.Do(func(repos repository.Repositories) error {
+		// The event row is locked so that no guest is moved while the rule is added
+		if _, err := repos.Events.FindByIdForUpdate(eventId); err != nil {
+			log.Println("Create Constraint Service - Could not find event")
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventNotFound
+			}
+			return err
+		}
+
+		constraint := model.Constraint{Event_ID: eventId, Type: req.Type}
+		byId := make(map[int]model.Guest)
+		loc := eventLocation(repos.Events, eventId)
+		for _, ref := range req.Guests {
+			guest, err := findGuest(repos.Guests, eventId, ref, false, loc)
+			if err != nil {
+				log.Println("Create Constraint Service - Could not find guest")
+				return err
+			}
+			// A guest given twice is only counted once
+			if _, ok := byId[guest.Id]; !ok {
+				constraint.Guest_IDs = append(constraint.Guest_IDs, guest.Id)
+			}
+			byId[guest.Id] = guest
+		}
+
+		switch req.Type {
+		case model.ConstraintTogether, model.ConstraintApart:
+			if len(constraint.Guest_IDs) < 2 {
+				log.Println("Create Constraint Service - The rule needs two guests")
+				return fmt.Errorf("%w: a %s rule needs at least two different guests", ErrInvalidConstraint, req.Type)
+			}
+		case model.ConstraintPinned:
+			if len(constraint.Guest_IDs) != 1 {
+				log.Println("Create Constraint Service - A pin is for one guest")
+				return fmt.Errorf("%w: guests are pinned to a table one at a time", ErrInvalidConstraint)
+			}
+			// This query runs -> SELECT `table`.*, ... FROM `table` ... WHERE `table`.id = 5 AND `table`.event_id = 1
+			table, err := repos.Tables.FindById(eventId, req.Table_ID)
+			if err != nil {
+				log.Println("Create Constraint Service - Could not find table")
+				return err
+			}
+			if table.Id == 0 {
+				log.Println("Create Constraint Service - Could not find table")
+				return fmt.Errorf("%w: %d", ErrTableNotFound, req.Table_ID)
+			}
+			constraint.Table_ID = table.Id
+		case model.ConstraintNearStage:
+			constraint.Priority = req.Priority
+			if constraint.Priority == 0 {
+				constraint.Priority = DefaultStagePriority
+			}
+		}
+
+		// This query runs -> INSERT INTO `seating_constraint` (`event_id`,`type`,`guest_ids`,`table_id`,`priority`) VALUES (1,'together','[2,3]',0,0)
+		constraint, err := repos.Constraints.Save(constraint)
+		if err != nil {
+			log.Println("Create Constraint Service - Could not create constraint")
+			return err
+		}
+
+		res = toConstraintResDto(constraint, byId)
+
+		return nil
+	})
+	if err != nil {
+		return dto.ConstraintResDto{}, err
+	}
+
+	return res, nil
+}
+
+func (service *constraintService) Delete(eventId int, id int) error {
+	constraint, err := service.constraintRepository.FindById(eventId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Delete Constraint Service - Could not find constraint")
+		return fmt.Errorf("%w: %d", ErrConstraintNotFound, id)
+	}
+	if err != nil {
+		log.Println("Delete Constraint Service - Could not find constraint")
+		return err
+	}
+
+	// This query runs -> DELETE FROM `seating_constraint` WHERE `seating_constraint`.`id` = 3
+	if err := service.constraintRepository.Delete(constraint); err != nil {
+		log.Println("Delete Constraint Service - Could not delete constraint")
+		return err
+	}
+
+	return nil
+}
+
+// Every seating rule the guest list breaks now, in the order the rules were added
+func (service *constraintService) Violations(eventId int) ([]dto.ViolationResDto, error) {
+	resArr := []dto.ViolationResDto{}
+
+	constraints, err := service.constraintRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Violations Service - Could not find constraints")
+		return nil, err
+	}
+	guests, err := service.guestRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Violations Service - Could not find guests")
+		return nil, err
+	}
+	tables, err := service.tableRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Violations Service - Could not find tables")
+		return nil, err
+	}
+
+	for _, v := range brokenConstraints(constraints, guestsById(guests), tablesById(tables)) {
+		res := dto.ViolationResDto{Constraint_ID: v.constraint.Id, Type: v.constraint.Type, Hard: v.constraint.Hard(), Guests: []string{}, Message: v.message}
+		for _, guest := range v.guests {
+			res.Guests = append(res.Guests, guest.PublicId)
+		}
+		resArr = append(resArr, res)
+	}
+
+	return resArr, nil
+}
+
+func toConstraintResDto(constraint model.Constraint, guests map[int]model.Guest) dto.ConstraintResDto {
+	res := dto.ConstraintResDto{Id: constraint.Id, Type: constraint.Type, Guests: []string{}, Table_ID: constraint.Table_ID, Priority: constraint.Priority}
+	for _, id := range constraint.Guest_IDs {
+		res.Guests = append(res.Guests, guests[id].PublicId)
+	}
+	return res
+}
+
+func guestsById(guests []model.Guest) map[int]model.Guest {
+	res := make(map[int]model.Guest, len(guests))
+	for _, v := range guests {
+		res[v.Id] = v
+	}
+	return res
+}
+
+func tablesById(tables []model.Table) map[int]model.Table {
+	res := make(map[int]model.Table, len(tables))
+	for _, v := range tables {
+		res[v.Id] = v
+	}
+	return res
+}
+
+// A seating rule the seats of some of its guests break
+type violation struct {
+	constraint model.Constraint
+	guests     []model.Guest
+	message    string
+}
+
+// The rules the seats of the guests break. Guests who have left hold no seat, so they can not break a rule.
+// Without tables the near stage wishes are not checked.
+func brokenConstraints(constraints []model.Constraint, guests map[int]model.Guest, tables map[int]model.Table) []violation {
+	var res []violation
+	for _, c := range constraints {
+		// The guests of the rule by the table they sit at
+		byTable := make(map[int][]model.Guest)
+		var seated []model.Guest
+		for _, id := range c.Guest_IDs {
+			guest, ok := guests[id]
+			if !ok || guest.LeftAt != nil {
+				continue
+			}
+			byTable[guest.Table_ID] = append(byTable[guest.Table_ID], guest)
+			seated = append(seated, guest)
+		}
+
+		switch c.Type {
+		case model.ConstraintTogether:
+			if len(byTable) > 1 {
+				res = append(res, violation{c, seated, fmt.Sprintf("%s are split across tables %s", guestNames(seated), tableList(byTable))})
+			}
+		case model.ConstraintApart:
+			for _, table := range sortedTables(byTable) {
+				if len(byTable[table]) > 1 {
+					res = append(res, violation{c, byTable[table], fmt.Sprintf("%s share table %d", guestNames(byTable[table]), table)})
+				}
+			}
+		case model.ConstraintPinned:
+			for _, guest := range seated {
+				if guest.Table_ID != c.Table_ID {
+					res = append(res, violation{c, []model.Guest{guest}, fmt.Sprintf("%s is at table %d instead of table %d", guest.Name, guest.Table_ID, c.Table_ID)})
+				}
+			}
+		case model.ConstraintNearStage:
+			if tables == nil {
+				continue
+			}
+			var away []model.Guest
+			for _, guest := range seated {
+				if table := tables[guest.Table_ID]; !table.NearStage {
+					away = append(away, guest)
+				}
+			}
+			if len(away) > 0 {
+				res = append(res, violation{c, away, fmt.Sprintf("%s not near the stage", isAre(away))})
+			}
+		}
+	}
+	return res
+}
+
+// Refuses to move guests to other tables when that breaks a seating rule the guests keep now,
+// moves is the table each guest moves to by the guest's id
+func checkMoves(repos repository.Repositories, eventId int, moves map[int]int) error {
+	// This query runs -> SELECT * FROM `seating_constraint` WHERE event_id = 1 ORDER BY id
+	constraints, err := repos.Constraints.FindAll(eventId)
+	if err != nil || len(constraints) == 0 {
+		return err
+	}
+
+	// This query runs -> SELECT * FROM `guest` WHERE event_id = 1
+	guests, err := repos.Guests.FindAll(eventId)
+	if err != nil {
+		return err
+	}
+	before := guestsById(guests)
+	after := make(map[int]model.Guest, len(before))
+	for id, guest := range before {
+		if table, ok := moves[id]; ok {
+			guest.Table_ID = table
+		}
+		after[id] = guest
+	}
+
+	broken := make(map[int]bool)
+	for _, v := range brokenConstraints(constraints, before, nil) {
+		broken[v.constraint.Id] = true
+	}
+	for _, v := range brokenConstraints(constraints, after, nil) {
+		if !broken[v.constraint.Id] {
+			return fmt.Errorf("%w: %d (%s), %s", ErrConstraintViolated, v.constraint.Id, v.constraint.Type, v.message)
+		}
+	}
+	return nil
+}
+
+// Takes a guest who is removed from the guest list out of the seating rules, a rule left with too few guests goes with them
+func forgetGuest(repos repository.Repositories, eventId int, guestId int) error {
+	constraints, err := repos.Constraints.FindAll(eventId)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range constraints {
+		if !c.Has(guestId) {
+			continue
+		}
+
+		var rest []int
+		for _, id := range c.Guest_IDs {
+			if id != guestId {
+				rest = append(rest, id)
+			}
+		}
+		c.Guest_IDs = rest
+
+		if len(rest) == 0 || (len(rest) == 1 && (c.Type == model.ConstraintTogether || c.Type == model.ConstraintApart)) {
+			// This query runs -> DELETE FROM `seating_constraint` WHERE `seating_constraint`.`id` = 3
+			err = repos.Constraints.Delete(c)
+		} else {
+			// This query runs -> UPDATE `seating_constraint` SET `guest_ids`='[3,4]',... WHERE `id` = 3
+			err = repos.Constraints.Update(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes the pins to a table that is deleted
+func unpinTable(repos repository.Repositories, eventId int, tableId int) error {
+	constraints, err := repos.Constraints.FindAll(eventId)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range constraints {
+		if c.Type == model.ConstraintPinned && c.Table_ID == tableId {
+			if err := repos.Constraints.Delete(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// "Ann, Bob and Cat"
+func guestNames(guests []model.Guest) string {
+	var names []string
+	for _, v := range guests {
+		names = append(names, v.Name)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// "Ann is" or "Ann and Bob are"
+func isAre(guests []model.Guest) string {
+	if len(guests) == 1 {
+		return guests[0].Name + " is"
+	}
+	return guestNames(guests) + " are"
+}
+
+func sortedTables(byTable map[int][]model.Guest) []int {
+	var ids []int
+	for id := range byTable {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// "1, 2 and 5"
+func tableList(byTable map[int][]model.Guest) string {
+	var ids []string
+	for _, id := range sortedTables(byTable) {
+		ids = append(ids, strconv.Itoa(id))
+	}
+	return strings.Join(ids[:len(ids)-1], ", ") + " and " + ids[len(ids)-1]
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+)
+
+// The errors the services return when a request can not be carried out.
+// They are wrapped with the details of the request, so they are checked with errors.Is.
+
+// Returned when the event does not exist
+var ErrEventNotFound = errors.New("event not found")
+
+// Returned when the details of a new event do not make sense
+var ErrInvalidEvent = errors.New("invalid event")
+
+// Returned when the event has no table with the id
+var ErrTableNotFound = errors.New("table not found")
+
+// Returned when no guest of the event has the public id or name
+var ErrGuestNotFound = errors.New("guest not found")
+
+// Returned when the cursor of a list can not be read or was made for another order
+var ErrInvalidCursor = repository.ErrInvalidCursor
+
+// Returned when a party does not fit in the seats that are left to book on a table
+var ErrOverbooked = errors.New("table is fully booked")
+
+// Returned when an arriving party does not fit in the seats that are free at their table
+var ErrOverCapacity = errors.New("not enough free seats at the table")
+
+// Returned when a table would have fewer seats than are already reserved or taken on it
+var ErrCapacityBelowReservations = errors.New("the capacity is below the seats already reserved")
+
+// Returned when a table that is on the guest list of a guest is deleted without moving its guests to another table
+var ErrTableHasGuests = errors.New("the table still has guests")
+
+// Returned when a guest who is already at the party checks in
+var ErrAlreadyCheckedIn = errors.New("guest is already at the party")
+
+// Returned when a guest who is not at the party checks out
+var ErrGuestNotPresent = errors.New("guest is not at the party")
+
+// Returned when the name policy does not allow another guest with the name
+var ErrDuplicateName = errors.New("a guest with this name is already on the guest list")
+
+// Returned, wrapped in an AmbiguousGuestError, when a name belongs to more than one guest
+var ErrAmbiguousGuest = errors.New("more than one guest has this name, use the guest's id instead")
+
+// Lists the guests sharing a name so the caller can pick one by id
+type AmbiguousGuestError struct {
+	Name       string
+	Candidates []dto.GuestResDto
+}
+
+func (e *AmbiguousGuestError) Error() string {
+	return fmt.Sprintf("%s: %d guests are called %q", ErrAmbiguousGuest, len(e.Candidates), e.Name)
+}
+
+func (e *AmbiguousGuestError) Unwrap() error {
+	return ErrAmbiguousGuest
+}
+
+// Returned, wrapped in an ImportError, when a row of an import can not be added, then none of the rows are added
+var ErrImportFailed = errors.New("the guest list could not be imported")
+
+// Reports the rows of an import that can not be added
+type ImportError struct {
+	Report dto.GuestImportResDto
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%s: %d of %d rows have errors", ErrImportFailed, len(e.Report.Errors), e.Report.Rows)
+}
+
+func (e *ImportError) Unwrap() error {
+	return ErrImportFailed
+}
+
+// Returned when a seating plan is applied while some of its parties have no table
+var ErrPartiesUnseated = errors.New("some parties do not fit at any table")
+
+// Returned when the event has no seating rule with the id
+var ErrConstraintNotFound = errors.New("seating constraint not found")
+
+// Returned when the guests of a seating rule can not be kept to it, such as a guest pinned to a table they are not on
+var ErrInvalidConstraint = errors.New("invalid seating constraint")
+
+// Returned when moving guests would break a seating rule that they keep
+var ErrConstraintViolated = errors.New("the move breaks a seating constraint")
+
+// Returned when a guest who is not on the guest list arrives and walk-ins are not allowed
+var ErrWalkInsNotAllowed = errors.New("walk-ins are not allowed")
+
+// Returned when the event has taken as many walk-ins as it allows
+var ErrWalkInLimitReached = errors.New("the event takes no more walk-ins")
+
+// Returned when no table has enough free seats for a walk-in party
+var ErrNoFreeTable = errors.New("no table has enough free seats")
+
+// Returned when the event has no waitlist entry with the id
+var ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+
+// Returned when the projections of an event are rebuilt from a ledger that has no entries while the event has guests or tables,
+// which would remove all of them
+var ErrLedgerEmpty = errors.New("the ledger has no entries")
+
+// Returned when the event has no webhook with the id
+var ErrWebhookNotFound = errors.New("webhook not found")
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm"
+)
+
+type EventService interface {
+	FindAll() ([]dto.EventResDto, error)
+	FindById(id int) (dto.EventResDto, error)
+	Save(req dto.EventReqDto) (dto.EventResDto, error)
+	EnsureExists(id int) error
+}
+
+// The settings new events start with
+type EventOptions struct {
+	// How many seats, as a percentage of a table's capacity, may be booked on top of the capacity to make up for guests who do not show up
+	OverbookAllowance int
+}
+
+type eventService struct {
+	eventRepository repository.EventRepository
+	options         EventOptions
+}
+
+func NewEventService(eventRepo repository.EventRepository, opts EventOptions) EventService {
+	return &eventService{
+		eventRepository: eventRepo,
+		options:         opts,
+	}
+}
+
+func (service *eventService) FindAll() ([]dto.EventResDto, error) {
+	var resArr []dto.EventResDto
+
+	events, err := service.eventRepository.FindAll()
+	if err != nil {
+		log.Println("Get Events Service - Could not find events")
+		return resArr, err
+	}
+
+	for _, v := range events {
+		resArr = append(resArr, toEventResDto(v))
+	}
+
+	return resArr, nil
+}
+
+func (service *eventService) FindById(id int) (dto.EventResDto, error) {
+	event, err := service.eventRepository.FindById(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dto.EventResDto{}, ErrEventNotFound
+	}
+	if err != nil {
+		log.Println("Get Event By Id Service - Could not find event")
+		return dto.EventResDto{}, err
+	}
+
+	return toEventResDto(event), nil
+}
+
+func (service *eventService) Save(req dto.EventReqDto) (dto.EventResDto, error) {
+	var event model.Event
+
+	if req.Name == "" {
+		return dto.EventResDto{}, fmt.Errorf("%w: name is required", ErrInvalidEvent)
+	}
+
+	// Times are rendered in the event's timezone, so it has to be one the server knows
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		return dto.EventResDto{}, fmt.Errorf("%w: unknown timezone %q", ErrInvalidEvent, req.Timezone)
+	}
+
+	if req.EndTime.Before(req.StartTime) {
+		return dto.EventResDto{}, fmt.Errorf("%w: end_time is before start_time", ErrInvalidEvent)
+	}
+
+	event.Name = req.Name
+	event.Venue = req.Venue
+	event.StartTime = req.StartTime
+	event.EndTime = req.EndTime
+	event.Timezone = req.Timezone
+	event.OverbookAllowance = service.options.OverbookAllowance
+	if req.OverbookAllowance != nil {
+		event.OverbookAllowance = *req.OverbookAllowance
+	}
+	if event.OverbookAllowance < 0 {
+		return dto.EventResDto{}, fmt.Errorf("%w: overbook_allowance can not be negative", ErrInvalidEvent)
+	}
+
+	event, err := service.eventRepository.Save(event)
+	if err != nil {
+		log.Println("Create Event Service - Could not create event")
+		return dto.EventResDto{}, err
+	}
+
+	return toEventResDto(event), nil
+}
+
+// Creates the event when it does not exist yet, used for the default event that the routes outside /events/:eventId work against
+func (service *eventService) EnsureExists(id int) error {
+	_, err := service.eventRepository.FindById(id)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = service.eventRepository.Save(model.Event{
+		Id:                id,
+		Name:              "Party",
+		StartTime:         now,
+		EndTime:           now,
+		Timezone:          "UTC",
+		OverbookAllowance: service.options.OverbookAllowance,
+	})
+	if err != nil {
+		log.Println("Ensure Event Service - Could not create event")
+		return err
+	}
+
+	log.Printf("Ensure Event Service - Created event %d", id)
+
+	return nil
+}
+
+func toEventResDto(event model.Event) dto.EventResDto {
+	return dto.EventResDto{
+		Id:                event.Id,
+		Name:              event.Name,
+		Venue:             event.Venue,
+		StartTime:         event.StartTime,
+		EndTime:           event.EndTime,
+		Timezone:          event.Timezone,
+		OverbookAllowance: event.OverbookAllowance,
+	}
+}
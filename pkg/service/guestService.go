@@ -2,219 +2,1180 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/getground/tech-tasks/backend/pkg/dto"
 	"github.com/getground/tech-tasks/backend/pkg/model"
 	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/search"
+	"gorm.io/gorm"
 )
 
 //The guest service
 type GuestService interface {
-	FindAll() ([]dto.GuestResDto, error)
-	Save(req dto.GuestReqDto) (dto.GuestResDto, error)
-	Checkin(req dto.GuestReqDto) (dto.GuestResDto, error)
-	Checkout(name string) error
-	GetArrivedGuests() ([]dto.GuestResDto, error)
+	FindAll(eventId int, req dto.GuestListReqDto) ([]dto.GuestResDto, dto.PageDto, error)
+	ListAll(eventId int) ([]dto.GuestResDto, error)
+	Save(eventId int, req dto.GuestReqDto, actor Actor) (dto.GuestResDto, error)
+	FindOne(eventId int, ref string) (dto.GuestResDto, error)
+	Update(eventId int, ref string, req dto.GuestPatchReqDto, actor Actor) (dto.GuestResDto, error)
+	Delete(eventId int, ref string, actor Actor) error
+	Checkin(eventId int, ref string, req dto.CheckinReqDto, actor Actor) (dto.GuestResDto, error)
+	Checkout(eventId int, ref string, actor Actor) error
+	GetArrivedGuests(eventId int, req dto.GuestListReqDto) ([]dto.GuestResDto, dto.PageDto, error)
+	GetDepartedGuests(eventId int) ([]dto.GuestResDto, error)
+	FindVisits(eventId int, ref string) ([]dto.VisitResDto, error)
+	Search(eventId int, req dto.GuestSearchReqDto) ([]dto.GuestMatchResDto, error)
+	Import(eventId int, rows []dto.GuestImportRowDto, dryRun bool, actor Actor) (dto.GuestImportResDto, error)
+	WalkIn(eventId int, req dto.WalkInReqDto, actor Actor) (dto.GuestResDto, error)
+}
+
+// The rules for names on the guest list of an event
+const (
+	// Two guests can not have exactly the same name
+	NamePolicyUnique = "unique"
+	// Two guests can not have the same name, ignoring upper and lower case
+	NamePolicyUniqueIgnoreCase = "unique_ignore_case"
+	// Any number of guests can have the same name, they are told apart by their ids
+	NamePolicyAllowDuplicates = "allow_duplicates"
+)
+
+var NamePolicies = []string{NamePolicyUnique, NamePolicyUniqueIgnoreCase, NamePolicyAllowDuplicates}
+
+// Whether guests who are not on the guest list are given a table at the door
+const (
+	WalkInsAllow = "allow"
+	WalkInsDeny  = "deny"
+)
+
+var WalkInPolicies = []string{WalkInsAllow, WalkInsDeny}
+
+// The settings of the guest service
+type GuestOptions struct {
+	// One of the NamePolicy constants, left empty names must be unique
+	NamePolicy string
+	// One of the WalkIns constants, left empty walk-ins are allowed
+	WalkIns string
+	// The most walk-ins each event takes, 0 for no limit
+	MaxWalkIns int
+	// Told when parties join, move up on or leave the waitlist, nil tells nobody
+	Publisher Publisher
 }
 
 type guestService struct {
+	eventRepository repository.EventRepository
 	guestRepository repository.GuestRepository
 	tableRepository repository.TableRepository
+	visitRepository repository.VisitRepository
+	unitOfWork      repository.UnitOfWork
+	options         GuestOptions
 }
 
-func NewGuestService(guestRepo repository.GuestRepository, tableRepo repository.TableRepository) GuestService {
+func NewGuestService(eventRepo repository.EventRepository, guestRepo repository.GuestRepository, tableRepo repository.TableRepository, visitRepo repository.VisitRepository, uow repository.UnitOfWork, opts GuestOptions) GuestService {
+	if opts.NamePolicy == "" {
+		opts.NamePolicy = NamePolicyUnique
+	}
+	if opts.WalkIns == "" {
+		opts.WalkIns = WalkInsAllow
+	}
+
 	return &guestService{
+		eventRepository: eventRepo,
 		guestRepository: guestRepo,
 		tableRepository: tableRepo,
+		visitRepository: visitRepo,
+		unitOfWork:      uow,
+		options:         opts,
 	}
 }
 
-//This function will call the table repository to retrieve all of the tables, then it maps the table entity to the response data transfer object
-func (service *guestService) FindAll() ([]dto.GuestResDto, error) {
+// Reports whether the name policy counts the two names as the same
+func sameName(policy string, a string, b string) bool {
+	switch policy {
+	case NamePolicyAllowDuplicates:
+		return false
+	case NamePolicyUniqueIgnoreCase:
+		return strings.EqualFold(a, b)
+	default:
+		return a == b
+	}
+}
 
-	var res dto.GuestResDto
-	var resArr []dto.GuestResDto
+// Checks the name against the guest list of the event by the name policy, the guest with the id exceptId is not compared with
+func checkName(guests repository.GuestRepository, eventId int, name string, exceptId int, policy string) error {
+	if policy == NamePolicyAllowDuplicates {
+		return nil
+	}
 
-	//This query runs -> SELECT * FROM `guest`
-	guests, err := service.guestRepository.FindAll()
+	list, err := guests.FindAll(eventId)
 	if err != nil {
-		log.Println("Get Tables Service - Could not find tables")
-		return resArr, err
+		return err
+	}
+	for _, v := range list {
+		if v.Id != exceptId && sameName(policy, v.Name, name) {
+			return fmt.Errorf("%w: %q", ErrDuplicateName, v.Name)
+		}
+	}
+	return nil
+}
+
+// The timezone the times of the event are shown in, UTC when the event has none
+func eventLocation(events repository.EventRepository, eventId int) *time.Location {
+	event, err := events.FindById(eventId)
+	if err != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(event.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Formats t as RFC 3339 in the event's timezone, nil is left empty
+func formatTime(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// The current time as it is stored, in UTC to the second
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// Finds the guest a route refers to, by public id or else by name.
+// When forUpdate is set the guest's row stays locked until the surrounding transaction ends.
+func findGuest(guests repository.GuestRepository, eventId int, ref string, forUpdate bool, loc *time.Location) (model.Guest, error) {
+	// A guest whose name looks like a public id can still be found by name
+	if model.IsGuestPublicId(ref) {
+		var guest model.Guest
+		var err error
+		if forUpdate {
+			guest, err = guests.FindByPublicIdForUpdate(eventId, ref)
+		} else {
+			guest, err = guests.FindByPublicId(eventId, ref)
+		}
+		if err == nil {
+			return guest, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Guest{}, err
+		}
+	}
+
+	var found []model.Guest
+	var err error
+	if forUpdate {
+		found, err = guests.FindAllByNameForUpdate(eventId, ref)
+	} else {
+		found, err = guests.FindAllByName(eventId, ref)
+	}
+	if err != nil {
+		return model.Guest{}, err
+	}
+
+	switch len(found) {
+	case 0:
+		return model.Guest{}, ErrGuestNotFound
+	case 1:
+		return found[0], nil
+	}
+
+	ambiguous := &AmbiguousGuestError{Name: ref}
+	for _, v := range found {
+		ambiguous.Candidates = append(ambiguous.Candidates, toGuestResDto(v, loc))
+	}
+	return model.Guest{}, ambiguous
+}
+
+func toGuestResDto(guest model.Guest, loc *time.Location) dto.GuestResDto {
+	return dto.GuestResDto{
+		Id:                 guest.PublicId,
+		Name:               guest.Name,
+		Aliases:            guest.Aliases,
+		Table_ID:           guest.Table_ID,
+		Acompanying_Guests: guest.Acompanying_Guests,
+		TimeArrived:        formatTime(guest.ArrivedAt, loc),
+		TimeLeft:           formatTime(guest.LeftAt, loc),
+		WalkIn:             guest.WalkIn,
+	}
+}
+
+// The repository query for a list request, sorted by defaultSort when the request does not say
+func guestQuery(req dto.GuestListReqDto, defaultSort string) (repository.GuestQuery, error) {
+	q := repository.GuestQuery{
+		Table_ID:     req.Table_ID,
+		Arrived:      req.Arrived,
+		MinPartySize: req.MinPartySize,
+		MaxPartySize: req.MaxPartySize,
+		NamePrefix:   req.NamePrefix,
+		Limit:        req.Limit,
+	}
+	q.Sort, q.Desc = sortKey(req.Sort, defaultSort)
+
+	if req.Cursor != "" {
+		after, err := repository.DecodeCursor(req.Cursor)
+		if err != nil {
+			return q, err
+		}
+		q.After = &after
+	}
+	return q, nil
+}
+
+// Splits "-name" into the key and whether it is sorted the other way round
+func sortKey(sort string, defaultSort string) (string, bool) {
+	if sort == "" {
+		return defaultSort, false
+	}
+	if strings.HasPrefix(sort, "-") {
+		return sort[1:], true
+	}
+	return sort, false
+}
+
+// The page of the list, the next cursor is handed out as an opaque string
+func toPageDto(total int64, next *repository.Cursor) dto.PageDto {
+	page := dto.PageDto{Total: total}
+	if next != nil {
+		page.Next = next.Encode()
+	}
+	return page
+}
+
+//This function will call the guest repository to retrieve a page of the guest list, then it maps the guest entity to the response data transfer object
+func (service *guestService) FindAll(eventId int, req dto.GuestListReqDto) ([]dto.GuestResDto, dto.PageDto, error) {
+
+	resArr := []dto.GuestResDto{}
+
+	q, err := guestQuery(req, repository.GuestSortId)
+	if err != nil {
+		log.Println("Get Guests Service - Could not read the cursor")
+		return resArr, dto.PageDto{}, err
+	}
+
+	//This query runs -> SELECT * FROM `guest` WHERE event_id = 1 ORDER BY guest.id LIMIT 101
+	page, err := service.guestRepository.Query(eventId, q)
+	if err != nil {
+		log.Println("Get Guests Service - Could not find guests")
+		return resArr, dto.PageDto{}, err
 	}
 
 	//Looping through the guests arr and mapping it to the response dto (data transfer object)
-	for _, v := range guests {
-		res.Id = v.Id
-		res.Name = v.Name
-		res.Table_ID = v.Table_ID
-		res.Acompanying_Guests = v.Acompanying_Guests
+	loc := eventLocation(service.eventRepository, eventId)
+	for _, v := range page.Guests {
+		resArr = append(resArr, toGuestResDto(v, loc))
+	}
+
+	return resArr, toPageDto(page.Total, page.Next), nil
+}
+
+// Every guest of the event with all of their details, in the order they were added, for exports
+func (service *guestService) ListAll(eventId int) ([]dto.GuestResDto, error) {
+	resArr := []dto.GuestResDto{}
+
+	//This query runs -> SELECT * FROM `guest` WHERE event_id = 1
+	guests, err := service.guestRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("List Guests Service - Could not find guests")
+		return nil, err
+	}
 
-		resArr = append(resArr, res)
+	loc := eventLocation(service.eventRepository, eventId)
+	for _, v := range guests {
+		resArr = append(resArr, toGuestResDto(v, loc))
 	}
 
 	return resArr, nil
 }
 
-func (service *guestService) Save(req dto.GuestReqDto) (dto.GuestResDto, error) {
+// Adds a guest to the guest list. A party that does not fit on its table can ask to wait for seats on the waitlist instead,
+// it is then added to the guest list when a party on the table leaves or the table grows.
+func (service *guestService) Save(eventId int, req dto.GuestReqDto, actor Actor) (dto.GuestResDto, error) {
 
-	var guest model.Guest
 	var res dto.GuestResDto
+	var notices []notice
+
+	// The table row is locked while the reservations are added up, so two parties can not both take the last seats
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		//* The event decides how far its tables can be overbooked
+		//* Its row is locked so that two guests with the same name can not be added at the same time
+		event, err := repos.Events.FindByIdForUpdate(eventId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Create Guest Service - Could not find event")
+			return ErrEventNotFound
+		}
+		if err != nil {
+			log.Println("Create Guest Service - Could not find event")
+			return err
+		}
+
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			newGuest, err := addGuest(repos, event, req, service.options.NamePolicy, actor)
+			if errors.Is(err, ErrOverbooked) && req.Waitlist {
+				// This query runs -> INSERT INTO `waitlist` (`event_id`,`kind`,`name`,`table_id`,...) VALUES (1,'booking','sara',5,...)
+				entry, err := joinWaitlist(repos, model.WaitlistEntry{
+					Event_ID:           eventId,
+					Kind:               model.WaitlistBooking,
+					Name:               req.Name,
+					Aliases:            req.Aliases,
+					Table_ID:           req.Table_ID,
+					Acompanying_Guests: req.Acompanying_Guests,
+					Priority:           req.Priority,
+				})
+				if err != nil {
+					log.Println("Create Guest Service - Could not join the waitlist")
+					return err
+				}
+				res.Name = entry.Name
+				res.Aliases = entry.Aliases
+				res.Table_ID = entry.Table_ID
+				res.Acompanying_Guests = entry.Acompanying_Guests
+				res.Waitlist, err = describeEntry(repos, eventId, entry)
+				return err
+			}
+			if err != nil {
+				return err
+			}
+
+			res.Id = newGuest.PublicId
+			res.Name = newGuest.Name
+			res.Aliases = newGuest.Aliases
+			res.Acompanying_Guests = newGuest.Acompanying_Guests
+
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return dto.GuestResDto{}, err
+	}
+
+	publish(service.options.Publisher, append(changes, notices...))
+
+	return res, nil
+}
+
+// Adds the guest to the event's guest list when the name policy allows the name and the party fits on the table.
+// It runs inside the caller's unit of work, which has locked the event's row, and records the guest in the audit log for the actor.
+func addGuest(repos repository.Repositories, event model.Event, req dto.GuestReqDto, namePolicy string, actor Actor) (model.Guest, error) {
+	var guest model.Guest
+	eventId := event.Id
 
-	id := req.Table_ID
+	//* Names are checked against the whole guest list of the event, the name policy decides which names clash
+	if err := checkName(repos.Guests, eventId, req.Name, 0, namePolicy); err != nil {
+		log.Println("Create Guest Service - The name is already taken")
+		return model.Guest{}, err
+	}
 
-	//* Retrieves the specified table by Id
-	table, err := service.tableRepository.FindById(id)
+	//* Retrieves the specified table of the event by Id along with the seats already reserved on it
+	table, err := repos.Tables.FindByIdForUpdate(eventId, req.Table_ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Create Guest Service - Could not find specified table")
+		return model.Guest{}, fmt.Errorf("%w: %d", ErrTableNotFound, req.Table_ID)
+	}
 	if err != nil {
 		log.Println("Create Guest Service - Could not find specified table")
-		return res, err
+		return model.Guest{}, err
 	}
 
 	//* Added 1 to accompnaying guests because it will then include the main guest
-	//* If the capacity of the table is smaller than the amount of people coming, then throw an error
-	if table.Capacity < (req.Acompanying_Guests + 1) {
+	//* If the party does not fit in the seats that are left once every other reservation is counted, then throw an error
+	bookable := table.Bookable(event.OverbookAllowance)
+	if table.Reserved+(req.Acompanying_Guests+1) > bookable {
 		log.Println("Create Guest Service - There are too many guests")
-		return res, err
+		return model.Guest{}, fmt.Errorf("%w: table %d has %d of %d bookable seats reserved, %d requested",
+			ErrOverbooked, table.Id, table.Reserved, bookable, req.Acompanying_Guests+1)
 	}
 
+	guest.Event_ID = eventId
 	guest.Name = req.Name
+	guest.Aliases = req.Aliases
 	guest.Table_ID = req.Table_ID
 	guest.Acompanying_Guests = req.Acompanying_Guests
 
 	//* Create the guest
 	//* This query runs -> INSERT INTO `guest` (`name`,`table_id`,`acompanying_guests`) VALUES ('sara',5,9)
-	newGuest, err := service.guestRepository.Save(guest)
+	newGuest, err := repos.Guests.Save(guest)
 	if err != nil {
 		log.Println("Create Guest Service - Could not create guest")
-		return res, err
+		return model.Guest{}, err
+	}
+
+	if err := recordGuest(repos, actor, model.AuditGuestCreated, nil, &newGuest); err != nil {
+		return model.Guest{}, err
 	}
 
-	res.Name = newGuest.Name
-	res.Acompanying_Guests = newGuest.Acompanying_Guests
+	return newGuest, nil
+}
 
-	return res, nil
+func (service *guestService) FindOne(eventId int, ref string) (dto.GuestResDto, error) {
+	// This query runs -> SELECT * FROM `guest` WHERE `guest`.`event_id` = 1 AND `guest`.`public_id` = 'g_...', then by name if there is no such id
+	loc := eventLocation(service.eventRepository, eventId)
+	guest, err := findGuest(service.guestRepository, eventId, ref, false, loc)
+	if err != nil {
+		log.Println("Get Guest Service - Could not find guest")
+		return dto.GuestResDto{}, err
+	}
+
+	return toGuestResDto(guest, loc), nil
 }
 
-func (service *guestService) Checkin(req dto.GuestReqDto) (dto.GuestResDto, error) {
+// Renames a guest, moves them to another table or changes the size of their party.
+// The new table has to have room for the party, counting the guest's own seats only once when they stay on the same table.
+func (service *guestService) Update(eventId int, ref string, req dto.GuestPatchReqDto, actor Actor) (dto.GuestResDto, error) {
 	var res dto.GuestResDto
+	var notices []notice
+
+	// The event row is locked for the name check and the table row while its seats are added up, like when a guest is added
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		event, err := repos.Events.FindByIdForUpdate(eventId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Update Guest Service - Could not find event")
+			return ErrEventNotFound
+		}
+		if err != nil {
+			log.Println("Update Guest Service - Could not find event")
+			return err
+		}
 
-	var newTable model.Table
+		loc := eventLocation(repos.Events, eventId)
+		guest, err := findGuest(repos.Guests, eventId, ref, true, loc)
+		if err != nil {
+			log.Println("Update Guest Service - Could not find guest")
+			return err
+		}
+		before := guest
 
-	// Find specified guest
-	guest, err := service.guestRepository.FindByName(req.Name)
+		if req.Name != "" && req.Name != guest.Name {
+			if err := checkName(repos.Guests, eventId, req.Name, guest.Id, service.options.NamePolicy); err != nil {
+				log.Println("Update Guest Service - The name is already taken")
+				return err
+			}
+			guest.Name = req.Name
+		}
+
+		// An empty list takes every alias away, a missing one leaves them as they are
+		if req.Aliases != nil {
+			guest.Aliases = req.Aliases
+			if len(guest.Aliases) == 0 {
+				guest.Aliases = nil
+			}
+		}
+
+		tableId := guest.Table_ID
+		if req.Table_ID != 0 {
+			tableId = req.Table_ID
+		}
+		partySize := guest.Acompanying_Guests
+		if req.Acompanying_Guests != nil {
+			partySize = *req.Acompanying_Guests
+		}
+
+		// Guests who have left hold no seats, so only moving a guest who has not can break a seating rule
+		if tableId != guest.Table_ID && guest.LeftAt == nil {
+			if err := checkMoves(repos, eventId, map[int]int{guest.Id: tableId}); err != nil {
+				log.Println("Update Guest Service - The move breaks a seating constraint")
+				return err
+			}
+		}
+
+		if tableId != guest.Table_ID || partySize != guest.Acompanying_Guests {
+			table, err := repos.Tables.FindByIdForUpdate(eventId, tableId)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Println("Update Guest Service - Could not find specified table")
+				return fmt.Errorf("%w: %d", ErrTableNotFound, tableId)
+			}
+			if err != nil {
+				log.Println("Update Guest Service - Could not find specified table")
+				return err
+			}
+
+			// Guests who have left hold no seats, so only the guest list entry changes
+			if guest.LeftAt == nil {
+				reserved, occupied := table.Reserved, table.Occupied
+				if table.Id == guest.Table_ID {
+					reserved -= guest.Acompanying_Guests + 1
+					if guest.Present() {
+						occupied -= guest.Acompanying_Guests + 1
+					}
+				}
+
+				bookable := table.Bookable(event.OverbookAllowance)
+				if reserved+partySize+1 > bookable {
+					log.Println("Update Guest Service - There are too many guests")
+					return fmt.Errorf("%w: table %d has %d of %d bookable seats reserved, %d requested",
+						ErrOverbooked, table.Id, reserved, bookable, partySize+1)
+				}
+				if guest.Present() && occupied+partySize+1 > table.Capacity {
+					log.Println("Update Guest Service - There are too many guests at the table")
+					return fmt.Errorf("%w: table %d has %d free seats, %d at the party",
+						ErrOverCapacity, table.Id, table.Capacity-occupied, partySize+1)
+				}
+			}
+
+		}
+
+		// The seats the guest gives up on their old table go to the parties waiting for it
+		freed := guest.LeftAt == nil && (tableId != guest.Table_ID || partySize < guest.Acompanying_Guests)
+		oldTableId := guest.Table_ID
+		guest.Table_ID = tableId
+		guest.Acompanying_Guests = partySize
+
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			// This query runs -> UPDATE `guest` SET `name`='sara',`table_id`=6,`acompanying_guests`=2 WHERE `id` = 2
+			if err := repos.Guests.Update(guest); err != nil {
+				log.Println("Update Guest Service - Could not update guest")
+				return err
+			}
+			if err := recordGuest(repos, actor, model.AuditGuestUpdated, &before, &guest); err != nil {
+				return err
+			}
+
+			// A guest waiting at the door waits for their new table
+			entry, waiting, err := arrivalEntry(repos, eventId, guest.Id)
+			if err != nil {
+				return err
+			}
+			if waiting && entry.Table_ID != guest.Table_ID {
+				entry.Table_ID = guest.Table_ID
+				if err := repos.Waitlist.Update(entry); err != nil {
+					log.Println("Update Guest Service - Could not move the guest on the waitlist")
+					return err
+				}
+			}
+
+			if freed {
+				if err := promoteWaitlist(repos, eventId, oldTableId, service.options.NamePolicy, actor); err != nil {
+					log.Println("Update Guest Service - Could not promote the waitlist")
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		res = toGuestResDto(guest, loc)
+
+		return nil
+	})
 	if err != nil {
-		log.Println("Checkin Service - Could not find guest")
-		return res, err
+		return dto.GuestResDto{}, err
 	}
 
-	// Find the guest's table
-	table, err := service.tableRepository.FindById(guest.Table_ID)
+	publish(service.options.Publisher, append(changes, notices...))
+
+	return res, nil
+}
+
+// Takes a guest off the guest list together with their visits, a guest who is at the party has to check out first.
+// The seats they had reserved go to the parties waiting for their table.
+func (service *guestService) Delete(eventId int, ref string, actor Actor) error {
+	var notices []notice
+
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		guest, err := findGuest(repos.Guests, eventId, ref, true, eventLocation(repos.Events, eventId))
+		if err != nil {
+			log.Println("Delete Guest Service - Could not find guest")
+			return err
+		}
+
+		if guest.Present() {
+			log.Println("Delete Guest Service - The guest is at the party")
+			return fmt.Errorf("%w: %s has to check out before they are removed", ErrAlreadyCheckedIn, guest.PublicId)
+		}
+
+		// This query runs -> DELETE FROM `visit` WHERE guest_id = 2
+		if err := repos.Visits.DeleteByGuest(guest.Id); err != nil {
+			log.Println("Delete Guest Service - Could not delete visits")
+			return err
+		}
+
+		if err := forgetGuest(repos, eventId, guest.Id); err != nil {
+			log.Println("Delete Guest Service - Could not update seating constraints")
+			return err
+		}
+
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			if err := leaveWaitlist(repos, eventId, guest.Id); err != nil {
+				log.Println("Delete Guest Service - Could not take the guest off the waitlist")
+				return err
+			}
+
+			// This query runs -> DELETE FROM `guest` WHERE `guest`.`id` = 2
+			if err := repos.Guests.Delete(guest); err != nil {
+				log.Println("Delete Guest Service - Could not delete guest")
+				return err
+			}
+			if err := recordGuest(repos, actor, model.AuditGuestDeleted, &guest, nil); err != nil {
+				return err
+			}
+
+			if guest.LeftAt == nil {
+				if err := promoteWaitlist(repos, eventId, guest.Table_ID, service.options.NamePolicy, actor); err != nil {
+					log.Println("Delete Guest Service - Could not promote the waitlist")
+					return err
+				}
+			}
+			return nil
+		})
+		return err
+	})
 	if err != nil {
-		log.Println("Checkin Service - Could not find specified table")
-		return res, err
+		return err
 	}
 
-	// Added 1 to accompnaying guests because it will then include the main guest
-	// If the capacity of the table is smaller than the actual amount of people coming, then throw an error
-	if table.Capacity < (req.Acompanying_Guests + 1) {
-		log.Println("Checkin Service - There are too many guests")
-		return res, err
-	}
+	publish(service.options.Publisher, append(changes, notices...))
+	return nil
+}
+
+// Checks a guest in at their table. A party that does not fit can ask to wait at the door on the waitlist instead,
+// it is then checked in when a party at the table leaves or the table grows.
+func (service *guestService) Checkin(eventId int, ref string, req dto.CheckinReqDto, actor Actor) (dto.GuestResDto, error) {
+	var res dto.GuestResDto
+	var notices []notice
+
+	// Everything below runs in one transaction, the guest and table rows are locked so that
+	// concurrent check-ins for the same table are applied one after the other
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		// Find specified guest, by public id or name
+		// This query runs -> SELECT * FROM `guest` WHERE `guest`.`event_id` = 1 AND `guest`.`name` = 'sara' ORDER BY id FOR UPDATE
+		guest, err := findGuest(repos.Guests, eventId, ref, true, eventLocation(repos.Events, eventId))
+		if err != nil {
+			log.Println("Checkin Service - Could not find guest")
+			return err
+		}
+
+		if guest.Present() {
+			log.Println("Checkin Service - The guest is already at the party")
+			return fmt.Errorf("%w: %s", ErrAlreadyCheckedIn, guest.PublicId)
+		}
+
+		// Find the guest's table along with the seats that are already occupied
+		table, err := repos.Tables.FindByIdForUpdate(eventId, guest.Table_ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Checkin Service - Could not find specified table")
+			return fmt.Errorf("%w: %d", ErrTableNotFound, guest.Table_ID)
+		}
+		if err != nil {
+			log.Println("Checkin Service - Could not find specified table")
+			return err
+		}
+
+		// Added 1 to accompnaying guests because it will then include the main guest
+		// If the free seats at the table are fewer than the actual amount of people coming, then nothing is written
+		if table.Free() < (req.Acompanying_Guests+1) && req.Waitlist {
+			notices, err = trackWaitlist(repos, eventId, func() error {
+				// A guest already waiting keeps their place, with the party they have now
+				entry, waiting, err := arrivalEntry(repos, eventId, guest.Id)
+				if err != nil {
+					return err
+				}
+				entry.Acompanying_Guests = req.Acompanying_Guests
+				entry.Priority = req.Priority
+				if waiting {
+					err = repos.Waitlist.Update(entry)
+				} else {
+					entry, err = joinWaitlist(repos, model.WaitlistEntry{
+						Event_ID:           eventId,
+						Kind:               model.WaitlistArrival,
+						Guest_ID:           guest.Id,
+						Name:               guest.Name,
+						Aliases:            guest.Aliases,
+						Table_ID:           guest.Table_ID,
+						Acompanying_Guests: req.Acompanying_Guests,
+						Priority:           req.Priority,
+					})
+				}
+				if err != nil {
+					log.Println("Checkin Service - Could not join the waitlist")
+					return err
+				}
+				res.Waitlist, err = describeEntry(repos, eventId, entry)
+				return err
+			})
+			res.Id = guest.PublicId
+			res.Name = guest.Name
+			return err
+		}
+		if table.Free() < (req.Acompanying_Guests + 1) {
+			log.Println("Checkin Service - There are too many guests")
+			return fmt.Errorf("%w: table %d has %d free seats, %d arriving",
+				ErrOverCapacity, table.Id, table.Free(), req.Acompanying_Guests+1)
+		}
 
-	// Reduce the listed capcity once guest actually arrives
-	newTable.Id = table.Id
-	newTable.Capacity = table.Capacity - (req.Acompanying_Guests + 1)
+		// Every check-in starts a new visit, which is how a guest who checked out comes back in
+		before := guest
+		arrived := now()
+		guest.ArrivedAt = &arrived
+		guest.LeftAt = nil
 
-	// Save the new table capacity
-	// This query runs ->  UPDATE `table` SET `capacity`=1 WHERE `id` = 5
-	err = service.tableRepository.Update(newTable)
+		// This query runs -> INSERT INTO `visit` (`event_id`,`guest_id`,`acompanying_guests`,`arrived_at`,`left_at`) VALUES (1,2,1,'2026-12-31 19:30:00',NULL)
+		_, err = repos.Visits.Save(model.Visit{Event_ID: eventId, Guest_ID: guest.Id, Acompanying_Guests: req.Acompanying_Guests, ArrivedAt: arrived})
+		if err != nil {
+			log.Println("Checkin Service - Could not record visit")
+			return err
+		}
+
+		// Update the old accompanying guest number, the table's occupied seats are worked out from this
+		guest.Acompanying_Guests = req.Acompanying_Guests
+
+		// Save the guest details
+		// This query runs -> UPDATE `guest` SET `name`='john',`table_id`=2,`acompanying_guests`=1 WHERE `id` = 1
+		err = repos.Guests.Update(guest)
+		if err != nil {
+			log.Println("Checkin Service - Could not create guest")
+			return err
+		}
+		if err := recordGuest(repos, actor, model.AuditGuestCheckedIn, &before, &guest); err != nil {
+			return err
+		}
+
+		// A guest who was waiting at the door got in after all
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			return leaveWaitlist(repos, eventId, guest.Id)
+		})
+		if err != nil {
+			log.Println("Checkin Service - Could not take the guest off the waitlist")
+			return err
+		}
+
+		// Map the new guest object to the response dto
+		res.Id = guest.PublicId
+		res.Name = guest.Name
+
+		return nil
+	})
 	if err != nil {
-		log.Println("Checkin Service - Could not update table")
-		return res, err
+		return dto.GuestResDto{}, err
 	}
 
-	// Update the old accompanying guest number and log time of arrival
-	guest.Acompanying_Guests = req.Acompanying_Guests
-	guest.TimeArrived = time.Now().Format("15:04")
+	publish(service.options.Publisher, append(changes, notices...))
+
+	return res, nil
+}
+
+// Adds a guest who is not on the guest list and checks them in, at the table that best fits their party.
+// Tables are picked by the people at them rather than the reservations, since the walk-in is already here, but a table whose
+// free seats are not promised to guests still on their way is picked first so they keep their seats.
+func (service *guestService) WalkIn(eventId int, req dto.WalkInReqDto, actor Actor) (dto.GuestResDto, error) {
+	var res dto.GuestResDto
+
+	if service.options.WalkIns == WalkInsDeny {
+		log.Println("Walk In Service - Walk-ins are not allowed")
+		return res, ErrWalkInsNotAllowed
+	}
+
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		// The event row is locked for the name check and so that two walk-ins can not both take the last place under the limit
+		_, err := repos.Events.FindByIdForUpdate(eventId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Walk In Service - Could not find event")
+			return ErrEventNotFound
+		}
+		if err != nil {
+			log.Println("Walk In Service - Could not find event")
+			return err
+		}
+
+		if service.options.MaxWalkIns > 0 {
+			// This query runs -> SELECT * FROM `guest` WHERE event_id = 1
+			guests, err := repos.Guests.FindAll(eventId)
+			if err != nil {
+				log.Println("Walk In Service - Could not retrieve guests")
+				return err
+			}
+			walkIns := 0
+			for _, v := range guests {
+				if v.WalkIn {
+					walkIns++
+				}
+			}
+			if walkIns >= service.options.MaxWalkIns {
+				log.Println("Walk In Service - The event takes no more walk-ins")
+				return fmt.Errorf("%w: event %d has taken %d of %d", ErrWalkInLimitReached, eventId, walkIns, service.options.MaxWalkIns)
+			}
+		}
+
+		if err := checkName(repos.Guests, eventId, req.Name, 0, service.options.NamePolicy); err != nil {
+			log.Println("Walk In Service - The name is already taken")
+			return err
+		}
+
+		table, err := walkInTable(repos, eventId, req.Acompanying_Guests+1)
+		if err != nil {
+			log.Println("Walk In Service - Could not find a table")
+			return err
+		}
+
+		arrived := now()
+		guest := model.Guest{
+			Event_ID:           eventId,
+			Name:               req.Name,
+			Aliases:            req.Aliases,
+			Table_ID:           table.Id,
+			Acompanying_Guests: req.Acompanying_Guests,
+			ArrivedAt:          &arrived,
+			WalkIn:             true,
+		}
+		// This query runs -> INSERT INTO `guest` (`event_id`,`public_id`,`name`,...,`arrived_at`,`walk_in`) VALUES (1,'g_...','sara',...,'2026-12-31 21:05:00',true)
+		guest, err = repos.Guests.Save(guest)
+		if err != nil {
+			log.Println("Walk In Service - Could not create guest")
+			return err
+		}
+
+		_, err = repos.Visits.Save(model.Visit{Event_ID: eventId, Guest_ID: guest.Id, Acompanying_Guests: guest.Acompanying_Guests, ArrivedAt: arrived})
+		if err != nil {
+			log.Println("Walk In Service - Could not record visit")
+			return err
+		}
+
+		// A walk-in is added and checked in at once, it is recorded as both so the arrival is counted like any other
+		booked := guest
+		booked.ArrivedAt = nil
+		if err := recordGuest(repos, actor, model.AuditGuestCreated, nil, &booked); err != nil {
+			return err
+		}
+		if err := recordGuest(repos, actor, model.AuditGuestCheckedIn, &booked, &guest); err != nil {
+			return err
+		}
+
+		res = toGuestResDto(guest, eventLocation(repos.Events, eventId))
 
-	// Save the guest details
-	// This query runs -> UPDATE `guest` SET `name`='john',`table_id`=2,`acompanying_guests`=1 WHERE `id` = 1
-	err = service.guestRepository.Update(guest)
+		return nil
+	})
 	if err != nil {
-		log.Println("Checkin Service - Could not create guest")
-		return res, err
+		return dto.GuestResDto{}, err
 	}
 
-	// Map the new guest object to the response dto
-	res.Name = guest.Name
+	publish(service.options.Publisher, changes)
 
 	return res, nil
 }
 
-func (service *guestService) Checkout(name string) error {
-	var newTable model.Table
-
-	// Find guest by name
-	// This query runs -> SELECT * FROM `guest` WHERE `guest`.`name` = 'sara' ORDER BY `guest`.`id` LIMIT 1
-	guest, err := service.guestRepository.FindByName(name)
+// Finds and locks the table for a walk-in party. Tables with enough seats that are neither taken nor reserved come first,
+// then those with enough seats that nobody is sitting in, the one that leaves the fewest such seats first.
+// The seats of the guests waiting at the door for their table count as taken, they are the next to sit in them.
+func walkInTable(repos repository.Repositories, eventId int, size int) (model.Table, error) {
+	// This query runs -> SELECT `table`.*, ... FROM `table` ... WHERE event_id = 1
+	all, err := repos.Tables.FindAll(eventId)
 	if err != nil {
-		log.Println("Checkout Service - Could not find guest")
-		return err
+		return model.Table{}, err
 	}
 
-	// Find the guest's table
-	table, err := service.tableRepository.FindById(guest.Table_ID)
+	// This query runs -> SELECT * FROM `waitlist` WHERE event_id = 1
+	entries, err := repos.Waitlist.FindAll(eventId)
 	if err != nil {
-		log.Println("Checkout Service - Could not find specified table")
-		return err
+		return model.Table{}, err
+	}
+	waiting := make(map[int]int)
+	for _, v := range entries {
+		if v.Kind == model.WaitlistArrival && v.Waiting() {
+			waiting[v.Table_ID] += v.Acompanying_Guests + 1
+		}
 	}
+	free := func(t model.Table) int { return t.Free() - waiting[t.Id] }
 
-	// Adding the available space back to the table
-	newTable.Id = table.Id
-	newTable.Capacity = table.Capacity + (guest.Acompanying_Guests + 1)
+	var candidates []model.Table
+	for _, v := range all {
+		if free(v) >= size {
+			candidates = append(candidates, v)
+		}
+	}
+	unreserved := func(t model.Table) bool { return t.Capacity-t.Reserved >= size }
+	// The seats the party would pick from, the fewer the better the fit
+	seats := func(t model.Table) int {
+		if unreserved(t) {
+			return t.Capacity - t.Reserved
+		}
+		return free(t)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if unreserved(candidates[i]) != unreserved(candidates[j]) {
+			return unreserved(candidates[i])
+		}
+		if seats(candidates[i]) != seats(candidates[j]) {
+			return seats(candidates[i]) < seats(candidates[j])
+		}
+		return candidates[i].Id < candidates[j].Id
+	})
 
-	// Save the new table capacity
-	// This query runs -> UPDATE `table` SET `capacity`=1 WHERE `id` = 5
-	err = service.tableRepository.Update(newTable)
-	if err != nil {
-		log.Println("Checkout Service - Could not update table")
-		return err
+	// The seats are counted again once the table is locked, a guest may have sat down since they were read
+	for _, v := range candidates {
+		table, err := findTableForUpdate(repos.Tables, eventId, v.Id)
+		if err != nil {
+			return model.Table{}, err
+		}
+		if free(table) >= size {
+			return table, nil
+		}
 	}
 
-	// Soft Delete - There would be a boolean property in the struct for the guest to indicate if they have left the party.
-	// Would be updated with an UPDATE query
-	// Then the GET methods would be changed so that they only retrieve guests that "have arrived"
+	return model.Table{}, fmt.Errorf("%w: a party of %d is arriving", ErrNoFreeTable, size)
+}
+
+// Checks a guest out, the seats they free go to the parties waiting for their table
+func (service *guestService) Checkout(eventId int, ref string, actor Actor) error {
+	var notices []notice
+
+	// The guest row is locked so that a check-in for the same table waits until the seats are given back
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		// Find guest by public id or name
+		// This query runs -> SELECT * FROM `guest` WHERE `guest`.`event_id` = 1 AND `guest`.`public_id` = 'g_...' ORDER BY `guest`.`id` LIMIT 1 FOR UPDATE
+		guest, err := findGuest(repos.Guests, eventId, ref, true, eventLocation(repos.Events, eventId))
+		if err != nil {
+			log.Println("Checkout Service - Could not find guest")
+			return err
+		}
+
+		if !guest.Present() {
+			log.Println("Checkout Service - The guest is not at the party")
+			return fmt.Errorf("%w: %s", ErrGuestNotPresent, guest.PublicId)
+		}
+
+		// Soft Delete - The guest stays on the guest list with the time they left, which frees their seats at the table
+		// The GET methods only retrieve guests that are still at the party
+		// This query runs -> UPDATE `guest` SET ...,`left_at`='2026-12-31 23:10:00' WHERE `id` = 2
+		before := guest
+		left := now()
+		guest.LeftAt = &left
+		err = repos.Guests.Update(guest)
+		if err != nil {
+			log.Println("Checkout Service - Could not update guest")
+			return err
+		}
+		if err := recordGuest(repos, actor, model.AuditGuestCheckedOut, &before, &guest); err != nil {
+			return err
+		}
+
+		// Close the visit the guest was on, guests who arrived before visits were recorded may not have one
+		visit, err := repos.Visits.FindOpen(guest.Id)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Checkout Service - Could not find visit")
+			return err
+		}
+		if err == nil {
+			visit.LeftAt = guest.LeftAt
+			if err := repos.Visits.Update(visit); err != nil {
+				log.Println("Checkout Service - Could not update visit")
+				return err
+			}
+		}
 
-	// Hard Delete - Removes guest from database
-	// This query runs -> DELETE FROM `guest` WHERE `guest`.`id` = 2
-	err = service.guestRepository.Delete(guest)
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			return promoteWaitlist(repos, eventId, guest.Table_ID, service.options.NamePolicy, actor)
+		})
+		if err != nil {
+			log.Println("Checkout Service - Could not promote the waitlist")
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		log.Println("Checkout Service - Could not delete guest")
 		return err
 	}
 
+	publish(service.options.Publisher, append(changes, notices...))
 	return nil
 }
 
-func (service *guestService) GetArrivedGuests() ([]dto.GuestResDto, error) {
-	var res dto.GuestResDto
-	var resArr []dto.GuestResDto
+func (service *guestService) GetArrivedGuests(eventId int, req dto.GuestListReqDto) ([]dto.GuestResDto, dto.PageDto, error) {
+	resArr := []dto.GuestResDto{}
 
-	//* This query runs -> SELECT * FROM `guest` WHERE NOT time_arrived = ''
-	guests, err := service.guestRepository.GetArrivedGuests()
+	// The guests are listed in the order they arrived unless the request asks for another
+	q, err := guestQuery(req, repository.GuestSortArrival)
+	if err != nil {
+		log.Println("Get Arrived Guests Service - Could not read the cursor")
+		return nil, dto.PageDto{}, err
+	}
+	q.Present = true
+
+	//* This query runs -> SELECT * FROM `guest` WHERE event_id = 1 AND arrived_at IS NOT NULL AND left_at IS NULL ORDER BY arrived_at IS NULL, arrived_at, id LIMIT 101
+	page, err := service.guestRepository.Query(eventId, q)
 	if err != nil {
 		log.Println("Get Arrived Guests Service - Could not retrieve guests")
+		return nil, dto.PageDto{}, err
+	}
+
+	loc := eventLocation(service.eventRepository, eventId)
+
+	for _, v := range page.Guests {
+		resArr = append(resArr, toGuestResDto(v, loc))
+	}
+
+	return resArr, toPageDto(page.Total, page.Next), nil
+}
+
+func (service *guestService) GetDepartedGuests(eventId int) ([]dto.GuestResDto, error) {
+	resArr := []dto.GuestResDto{}
+
+	//* This query runs -> SELECT * FROM `guest` WHERE event_id = 1 AND left_at IS NOT NULL ORDER BY left_at, id
+	guests, err := service.guestRepository.GetDepartedGuests(eventId)
+	if err != nil {
+		log.Println("Get Departed Guests Service - Could not retrieve guests")
+		return nil, err
+	}
+
+	loc := eventLocation(service.eventRepository, eventId)
+	for _, v := range guests {
+		resArr = append(resArr, toGuestResDto(v, loc))
+	}
+
+	return resArr, nil
+}
+
+// Every time the guest arrived at and left the party, the first visit first
+func (service *guestService) FindVisits(eventId int, ref string) ([]dto.VisitResDto, error) {
+	resArr := []dto.VisitResDto{}
+
+	loc := eventLocation(service.eventRepository, eventId)
+	guest, err := findGuest(service.guestRepository, eventId, ref, false, loc)
+	if err != nil {
+		log.Println("Get Visits Service - Could not find guest")
+		return nil, err
+	}
+
+	//* This query runs -> SELECT * FROM `visit` WHERE guest_id = 2 ORDER BY id
+	visits, err := service.visitRepository.FindByGuest(guest.Id)
+	if err != nil {
+		log.Println("Get Visits Service - Could not retrieve visits")
+		return nil, err
+	}
+
+	for _, v := range visits {
+		resArr = append(resArr, dto.VisitResDto{
+			Acompanying_Guests: v.Acompanying_Guests,
+			TimeArrived:        formatTime(&v.ArrivedAt, loc),
+			TimeLeft:           formatTime(v.LeftAt, loc),
+		})
+	}
+
+	return resArr, nil
+}
+
+// The guests whose name or one of whose aliases is close to the query, the best match first.
+// The whole guest list is scored in memory, it is small enough and the ranking can not be done in SQL on both MySQL and SQLite.
+func (service *guestService) Search(eventId int, req dto.GuestSearchReqDto) ([]dto.GuestMatchResDto, error) {
+	resArr := []dto.GuestMatchResDto{}
+
+	limit := req.Limit
+	if limit < 1 {
+		limit = 10
+	}
+
+	//* This query runs -> SELECT * FROM `guest` WHERE event_id = 1
+	guests, err := service.guestRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Search Guests Service - Could not retrieve guests")
 		return nil, err
 	}
 
+	loc := eventLocation(service.eventRepository, eventId)
 	for _, v := range guests {
-		res.Name = v.Name
-		res.Acompanying_Guests = v.Acompanying_Guests
-		res.TimeArrived = v.TimeArrived
+		match := dto.GuestMatchResDto{GuestResDto: toGuestResDto(v, loc)}
+		for _, name := range append([]string{v.Name}, v.Aliases...) {
+			if score := search.Score(req.Q, name); score > match.Score {
+				match.Score = score
+				match.Matched = name
+			}
+		}
+		if match.Score >= search.MinScore {
+			resArr = append(resArr, match)
+		}
+	}
 
-		resArr = append(resArr, res)
+	// Equally good matches are listed by name, then in the order they were added
+	sort.SliceStable(resArr, func(i, j int) bool {
+		if resArr[i].Score != resArr[j].Score {
+			return resArr[i].Score > resArr[j].Score
+		}
+		return resArr[i].Name < resArr[j].Name
+	})
+	if len(resArr) > limit {
+		resArr = resArr[:limit]
 	}
 
 	return resArr, nil
 }
+
+// Returned by the unit of work of a dry run so that nothing it added is kept
+var errDryRun = errors.New("dry run")
+
+// Adds every row of an imported guest list, or none of them.
+// The rows are checked one after the other by the same rules as adding a single guest, so the seats taken
+// and the names used by the rows above count against each row. A dry run checks every row and keeps nothing.
+func (service *guestService) Import(eventId int, rows []dto.GuestImportRowDto, dryRun bool, actor Actor) (dto.GuestImportResDto, error) {
+	res := dto.GuestImportResDto{DryRun: dryRun, Rows: len(rows)}
+
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		event, err := repos.Events.FindByIdForUpdate(eventId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Import Guests Service - Could not find event")
+			return ErrEventNotFound
+		}
+		if err != nil {
+			log.Println("Import Guests Service - Could not find event")
+			return err
+		}
+
+		for _, row := range rows {
+			fields := row.Errors
+			if len(fields) == 0 {
+				_, err := addGuest(repos, event, row.Guest, service.options.NamePolicy, actor)
+				if err != nil {
+					field, ok := importField(err)
+					if !ok {
+						log.Println("Import Guests Service - Could not add guest")
+						return err
+					}
+					fields = []dto.FieldErrorResDto{{Field: field, Message: err.Error()}}
+				}
+			}
+
+			if len(fields) > 0 {
+				res.Errors = append(res.Errors, dto.ImportRowErrorResDto{Row: row.Row, Name: row.Guest.Name, Errors: fields})
+			} else {
+				res.Imported++
+			}
+		}
+
+		if len(res.Errors) > 0 && !dryRun {
+			log.Println("Import Guests Service - Some rows can not be added")
+			res.Imported = 0
+			return &ImportError{Report: res}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return dto.GuestImportResDto{}, err
+	}
+
+	// A dry run keeps nothing, so there is nothing to tell
+	publish(service.options.Publisher, changes)
+
+	return res, nil
+}
+
+// The field of a row that an error from adding a guest is about, errors that are not about the row are not reported on it
+func importField(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrDuplicateName):
+		return "name", true
+	case errors.Is(err, ErrTableNotFound), errors.Is(err, ErrOverbooked):
+		return "table_id", true
+	}
+	return "", false
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/ledger"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// Checks the guests and tables of an event against its ledger, and rebuilds them from it
+type LedgerService interface {
+	Check(eventId int) (dto.LedgerCheckResDto, error)
+	Rebuild(eventId int) (dto.LedgerCheckResDto, error)
+}
+
+type ledgerService struct {
+	unitOfWork repository.UnitOfWork
+}
+
+func NewLedgerService(uow repository.UnitOfWork) LedgerService {
+	return &ledgerService{
+		unitOfWork: uow,
+	}
+}
+
+// The kind of ledger entry for a change recorded in the audit log
+func guestLedgerType(action string) string {
+	switch action {
+	case model.AuditGuestCreated:
+		return model.LedgerGuestReserved
+	case model.AuditGuestCheckedIn:
+		return model.LedgerGuestArrived
+	case model.AuditGuestCheckedOut:
+		return model.LedgerGuestDeparted
+	case model.AuditGuestDeleted:
+		return model.LedgerGuestCancelled
+	}
+	return model.LedgerGuestChanged
+}
+
+var tableLedgerTypes = map[string]string{
+	model.AuditTableCreated: model.LedgerTableAdded,
+	model.AuditTableUpdated: model.LedgerTableChanged,
+	model.AuditTableDeleted: model.LedgerTableRemoved,
+}
+
+// Appends the guest as it is after the change to the ledger, after is nil when the guest was deleted
+func ledgerGuest(repos repository.Repositories, action string, before *model.Guest, after *model.Guest) error {
+	entry := model.LedgerEntry{Type: guestLedgerType(action), Stream: model.LedgerStreamGuest}
+	if before != nil {
+		entry.Event_ID, entry.Stream_ID = before.Event_ID, before.Id
+	}
+	if after != nil {
+		entry.Event_ID, entry.Stream_ID = after.Event_ID, after.Id
+		data, err := snapshot(after)
+		if err != nil {
+			return err
+		}
+		entry.Data = data
+	}
+	return appendLedger(repos, entry)
+}
+
+// Appends the table as it is after the change to the ledger, like ledgerGuest
+func ledgerTable(repos repository.Repositories, action string, before *model.Table, after *model.Table) error {
+	entry := model.LedgerEntry{Type: tableLedgerTypes[action], Stream: model.LedgerStreamTable}
+	if before != nil {
+		entry.Event_ID, entry.Stream_ID = before.Event_ID, before.Id
+	}
+	if after != nil {
+		entry.Event_ID, entry.Stream_ID = after.Event_ID, after.Id
+		data, err := snapshot(after)
+		if err != nil {
+			return err
+		}
+		entry.Data = data
+	}
+	return appendLedger(repos, entry)
+}
+
+func appendLedger(repos repository.Repositories, entry model.LedgerEntry) error {
+	entry.CreatedAt = now()
+
+	// This query runs -> INSERT INTO `ledger` (`event_id`,`type`,`stream`,`stream_id`,`data`,`created_at`) VALUES (1,'guest.arrived','guest',2,'{...}',...)
+	if _, err := repos.Ledger.Append(entry); err != nil {
+		log.Println("Ledger Service - Could not append to the ledger")
+		return err
+	}
+	return nil
+}
+
+// Replays the ledger of the event and compares the guests and tables it projects with the stored ones
+func project(repos repository.Repositories, eventId int) (ledger.State, []model.Guest, []model.Table, dto.LedgerCheckResDto, error) {
+	var res dto.LedgerCheckResDto
+
+	// This query runs -> SELECT * FROM `ledger` WHERE event_id = 1 ORDER BY id
+	entries, err := repos.Ledger.FindAll(eventId)
+	if err != nil {
+		return ledger.State{}, nil, nil, res, err
+	}
+	state, err := ledger.Project(entries)
+	if err != nil {
+		return state, nil, nil, res, err
+	}
+
+	guests, err := repos.Guests.FindAll(eventId)
+	if err != nil {
+		return state, nil, nil, res, err
+	}
+	tables, err := repos.Tables.FindAll(eventId)
+	if err != nil {
+		return state, nil, nil, res, err
+	}
+
+	res.Entries = len(entries)
+	res.Guests = len(state.Guests)
+	res.Tables = len(state.Tables)
+	res.Differences = []dto.LedgerDifferenceResDto{}
+	for _, v := range ledger.Compare(state, guests, tables) {
+		res.Differences = append(res.Differences, dto.LedgerDifferenceResDto{Entity: v.Entity, Entity_ID: v.Entity_ID, Field: v.Field, Ledger: v.Ledger, Stored: v.Stored})
+	}
+	res.Consistent = len(res.Differences) == 0
+
+	return state, guests, tables, res, nil
+}
+
+func (service *ledgerService) Check(eventId int) (dto.LedgerCheckResDto, error) {
+	var res dto.LedgerCheckResDto
+
+	// The ledger and the rows are read in one unit of work, so a change made in between does not show up as a difference
+	err := service.unitOfWork.Do(func(repos repository.Repositories) error {
+		var err error
+		_, _, _, res, err = project(repos, eventId)
+		if err != nil {
+			log.Println("Check Ledger Service - Could not project the ledger")
+		}
+		return err
+	})
+	if err != nil {
+		return dto.LedgerCheckResDto{}, err
+	}
+
+	return res, nil
+}
+
+// Makes the guests and tables of the event the ones the ledger projects: rows that differ are overwritten, missing ones are added
+// with their ids and ones the ledger does not have are removed. The visits are left as they are. The differences it found are returned.
+func (service *ledgerService) Rebuild(eventId int) (dto.LedgerCheckResDto, error) {
+	var res dto.LedgerCheckResDto
+
+	err := service.unitOfWork.Do(func(repos repository.Repositories) error {
+		// The event row is locked so that no guest is added or changed while the rows are rebuilt
+		_, err := repos.Events.FindByIdForUpdate(eventId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Rebuild Ledger Service - Could not find event")
+			return ErrEventNotFound
+		}
+		if err != nil {
+			log.Println("Rebuild Ledger Service - Could not find event")
+			return err
+		}
+
+		state, guests, tables, check, err := project(repos, eventId)
+		if err != nil {
+			log.Println("Rebuild Ledger Service - Could not project the ledger")
+			return err
+		}
+		res = check
+		if check.Entries == 0 && len(guests)+len(tables) > 0 {
+			log.Println("Rebuild Ledger Service - The ledger is empty")
+			return fmt.Errorf("%w: event %d has %d guests and %d tables that would be removed", ErrLedgerEmpty, eventId, len(guests), len(tables))
+		}
+
+		storedGuests := make(map[int]bool, len(guests))
+		for _, v := range guests {
+			storedGuests[v.Id] = true
+		}
+		storedTables := make(map[int]bool, len(tables))
+		for _, v := range tables {
+			storedTables[v.Id] = true
+		}
+
+		// The tables come first, so every guest's table exists by the time the guest is written
+		for _, table := range state.TableList() {
+			if storedTables[table.Id] {
+				err = repos.Tables.Update(table)
+			} else {
+				_, err = repos.Tables.Save(table)
+			}
+			if err != nil {
+				log.Println("Rebuild Ledger Service - Could not write table")
+				return err
+			}
+		}
+
+		for _, guest := range state.GuestList() {
+			if storedGuests[guest.Id] {
+				err = repos.Guests.Update(guest)
+			} else {
+				_, err = repos.Guests.Save(guest)
+			}
+			if err != nil {
+				log.Println("Rebuild Ledger Service - Could not write guest")
+				return err
+			}
+		}
+
+		for _, guest := range guests {
+			if _, ok := state.Guests[guest.Id]; ok {
+				continue
+			}
+			if err := repos.Visits.DeleteByGuest(guest.Id); err != nil {
+				return err
+			}
+			if err := forgetGuest(repos, eventId, guest.Id); err != nil {
+				return err
+			}
+			if err := leaveWaitlist(repos, eventId, guest.Id); err != nil {
+				return err
+			}
+			if err := repos.Guests.Delete(guest); err != nil {
+				log.Println("Rebuild Ledger Service - Could not remove guest")
+				return err
+			}
+		}
+
+		for _, table := range tables {
+			if _, ok := state.Tables[table.Id]; ok {
+				continue
+			}
+			if err := unpinTable(repos, eventId, table.Id); err != nil {
+				return err
+			}
+			if err := repos.Tables.Delete(table); err != nil {
+				log.Println("Rebuild Ledger Service - Could not remove table")
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return dto.LedgerCheckResDto{}, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/seating"
+	"gorm.io/gorm"
+)
+
+// The modes of a seating plan
+const (
+	SeatingPreview = "preview"
+	SeatingApply   = "apply"
+)
+
+// Works out the tables of parties that are not on the guest list yet, and new tables for guests on it
+type SeatingService interface {
+	Plan(eventId int, req dto.SeatingPlanReqDto, actor Actor) (dto.SeatingPlanResDto, error)
+}
+
+type seatingService struct {
+	unitOfWork repository.UnitOfWork
+	options    GuestOptions
+}
+
+// The guest options are the guest service's, the parties are added to the guest list by the same rules
+func NewSeatingService(uow repository.UnitOfWork, opts GuestOptions) SeatingService {
+	if opts.NamePolicy == "" {
+		opts.NamePolicy = NamePolicyUnique
+	}
+
+	return &seatingService{
+		unitOfWork: uow,
+		options:    opts,
+	}
+}
+
+// Plans the tables of the parties and the re-seated guests around the reservations already made, keeping the re-seated guests
+// to their seating constraints, then moves the guests and adds the parties to the guest list.
+// A preview does that too, so the names and seats are checked the same way, but nothing it changes is kept.
+func (service *seatingService) Plan(eventId int, req dto.SeatingPlanReqDto, actor Actor) (dto.SeatingPlanResDto, error) {
+	res := dto.SeatingPlanResDto{Mode: req.Mode, Assignments: []dto.SeatAssignmentResDto{}, Unseated: []dto.SeatAssignmentResDto{}, Tables: []dto.TableResDto{}}
+	if res.Mode == "" {
+		res.Mode = SeatingPreview
+	}
+
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		// The event row is locked so that no guest is added while the plan is made
+		event, err := repos.Events.FindByIdForUpdate(eventId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Plan Seating Service - Could not find event")
+			return ErrEventNotFound
+		}
+		if err != nil {
+			log.Println("Plan Seating Service - Could not find event")
+			return err
+		}
+
+		//* This query runs -> SELECT `table`.*, ... FROM `table` ... WHERE event_id = 1
+		tables, err := repos.Tables.FindAll(eventId)
+		if err != nil {
+			log.Println("Plan Seating Service - Could not find tables")
+			return err
+		}
+
+		//* This query runs -> SELECT * FROM `guest` WHERE event_id = 1
+		guests, err := repos.Guests.FindAll(eventId)
+		if err != nil {
+			log.Println("Plan Seating Service - Could not find guests")
+			return err
+		}
+		constraints, err := repos.Constraints.FindAll(eventId)
+		if err != nil {
+			log.Println("Plan Seating Service - Could not find constraints")
+			return err
+		}
+
+		// Only guests who have not arrived are re-seated, a guest given twice is only seated once
+		var moving []model.Guest
+		seen := make(map[int]bool)
+		loc := eventLocation(repos.Events, eventId)
+		for _, ref := range req.Guests {
+			guest, err := findGuest(repos.Guests, eventId, ref, true, loc)
+			if err != nil {
+				log.Println("Plan Seating Service - Could not find guest")
+				return err
+			}
+			if guest.ArrivedAt != nil {
+				log.Println("Plan Seating Service - The guest has arrived")
+				return fmt.Errorf("%w: %s has arrived, only guests who have not are re-seated", ErrAlreadyCheckedIn, guest.PublicId)
+			}
+			if !seen[guest.Id] {
+				moving = append(moving, guest)
+			}
+			seen[guest.Id] = true
+		}
+
+		// The seats of the re-seated guests are given back to their tables
+		released := make(map[int]int)
+		for _, guest := range moving {
+			released[guest.Table_ID] += guest.Acompanying_Guests + 1
+		}
+
+		// The seats a table has left are the ones that can still be booked on it, like when a guest is added
+		var inventory []seating.Table
+		for _, t := range tables {
+			inventory = append(inventory, seating.Table{
+				Id:        t.Id,
+				Room:      t.Bookable(event.OverbookAllowance) - t.Reserved + released[t.Id],
+				InUse:     t.Reserved > released[t.Id],
+				NearStage: t.NearStage,
+			})
+		}
+		units, parties := seatingUnits(moving, constraints, guestsById(guests))
+		for _, p := range req.Parties {
+			parties = append(parties, seating.Party{Size: p.Acompanying_Guests + 1})
+		}
+
+		plan := seating.Solve(inventory, parties)
+		res.Solver, res.Seated, res.NearStage, res.WastedSeats = plan.Solver, plan.Seated, plan.Stage, plan.Wasted
+
+		for u, unit := range units {
+			for _, guest := range unit {
+				assignment := dto.SeatAssignmentResDto{Id: guest.PublicId, Name: guest.Name, Acompanying_Guests: guest.Acompanying_Guests, Table_ID: plan.Tables[u]}
+				if assignment.Table_ID == 0 {
+					res.Unseated = append(res.Unseated, assignment)
+					continue
+				}
+
+				// The plan has made room for the guest, so they are moved without checking the seats again
+				before := guest
+				guest.Table_ID = assignment.Table_ID
+				// This query runs -> UPDATE `guest` SET ...,`table_id`=6 WHERE `id` = 2
+				if err := repos.Guests.Update(guest); err != nil {
+					log.Println("Plan Seating Service - Could not move guest")
+					return err
+				}
+				if guest.Table_ID != before.Table_ID {
+					if err := recordGuest(repos, actor, model.AuditGuestUpdated, &before, &guest); err != nil {
+						return err
+					}
+				}
+				res.Assignments = append(res.Assignments, assignment)
+			}
+		}
+
+		for i, p := range req.Parties {
+			assignment := dto.SeatAssignmentResDto{Name: p.Name, Acompanying_Guests: p.Acompanying_Guests, Table_ID: plan.Tables[len(units)+i]}
+			if assignment.Table_ID == 0 {
+				res.Unseated = append(res.Unseated, assignment)
+				continue
+			}
+
+			guest, err := addGuest(repos, event, dto.GuestReqDto{Name: p.Name, Table_ID: assignment.Table_ID, Acompanying_Guests: p.Acompanying_Guests}, service.options.NamePolicy, actor)
+			if err != nil {
+				log.Println("Plan Seating Service - Could not add party")
+				return err
+			}
+			if res.Mode == SeatingApply {
+				assignment.Id = guest.PublicId
+			}
+			res.Assignments = append(res.Assignments, assignment)
+		}
+
+		if res.Mode == SeatingApply && len(res.Unseated) > 0 {
+			log.Println("Plan Seating Service - Some parties do not fit")
+			return fmt.Errorf("%w: %d of %d parties have no table", ErrPartiesUnseated, len(res.Unseated), len(units)+len(req.Parties))
+		}
+
+		tables, err = repos.Tables.FindAll(eventId)
+		if err != nil {
+			log.Println("Plan Seating Service - Could not find tables")
+			return err
+		}
+		for _, t := range tables {
+			res.Tables = append(res.Tables, toTableResDto(t))
+		}
+
+		if res.Mode == SeatingPreview {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return dto.SeatingPlanResDto{}, err
+	}
+
+	publish(service.options.Publisher, changes)
+
+	return res, nil
+}
+
+// Groups the re-seated guests that have to sit together into parties, and limits the tables of each party by the seating
+// constraints of its guests. Guests who are not re-seated stay where they are, so a party kept together with one of them
+// can only sit at their table and a party kept apart from one of them can not.
+func seatingUnits(moving []model.Guest, constraints []model.Constraint, guests map[int]model.Guest) ([][]model.Guest, []seating.Party) {
+	// The guests kept together are joined into one party
+	pos := make(map[int]int)
+	parent := make([]int, len(moving))
+	for i, guest := range moving {
+		pos[guest.Id] = i
+		parent[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		for parent[i] != i {
+			i = parent[i]
+		}
+		return i
+	}
+	for _, c := range constraints {
+		if c.Type != model.ConstraintTogether {
+			continue
+		}
+		first := -1
+		for _, id := range c.Guest_IDs {
+			if i, ok := pos[id]; ok {
+				if first == -1 {
+					first = i
+				} else {
+					parent[root(i)] = root(first)
+				}
+			}
+		}
+	}
+
+	var units [][]model.Guest
+	var parties []seating.Party
+	unitOf := make(map[int]int)
+	byRoot := make(map[int]int)
+	for i, guest := range moving {
+		u, ok := byRoot[root(i)]
+		if !ok {
+			u = len(units)
+			byRoot[root(i)] = u
+			units = append(units, nil)
+			parties = append(parties, seating.Party{})
+		}
+		units[u] = append(units[u], guest)
+		parties[u].Size += guest.Acompanying_Guests + 1
+		unitOf[guest.Id] = u
+	}
+
+	for _, c := range constraints {
+		// The parties the rule is about, with how many of its guests each has, and the tables of its guests who stay
+		count := make(map[int]int)
+		var involved []int
+		var staying []int
+		for _, id := range c.Guest_IDs {
+			if u, ok := unitOf[id]; ok {
+				if count[u] == 0 {
+					involved = append(involved, u)
+				}
+				count[u]++
+			} else if guest, ok := guests[id]; ok && guest.LeftAt == nil {
+				staying = append(staying, guest.Table_ID)
+			}
+		}
+
+		for _, u := range involved {
+			switch c.Type {
+			case model.ConstraintTogether:
+				if len(staying) > 0 {
+					keepTo(&parties[u], staying)
+				}
+			case model.ConstraintApart:
+				// Guests kept apart that also have to sit together can not be seated
+				if count[u] > 1 {
+					keepTo(&parties[u], nil)
+				}
+				parties[u].NotTables = append(parties[u].NotTables, staying...)
+				for _, other := range involved {
+					if other != u {
+						parties[u].Apart = append(parties[u].Apart, other)
+					}
+				}
+			case model.ConstraintPinned:
+				keepTo(&parties[u], []int{c.Table_ID})
+			case model.ConstraintNearStage:
+				parties[u].Priority += c.Priority
+			}
+		}
+	}
+
+	return units, parties
+}
+
+// Keeps the party to the tables, on top of the ones it is already kept to
+func keepTo(party *seating.Party, tables []int) {
+	if party.Tables == nil {
+		party.Tables = append([]int{}, tables...)
+		return
+	}
+	kept := []int{}
+	for _, id := range party.Tables {
+		for _, t := range tables {
+			if id == t {
+				kept = append(kept, id)
+				break
+			}
+		}
+	}
+	party.Tables = kept
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/hub"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// The kinds of notice published about the guests and tables, they are named like the actions of the audit log.
+// The guest ones carry the guest as a dto.GuestResDto and the table ones the table as a dto.TableResDto.
+const (
+	// A reservation, or a walk-in who was seated
+	NoticeGuestCreated    = model.AuditGuestCreated
+	NoticeGuestUpdated    = model.AuditGuestUpdated
+	NoticeGuestCheckedIn  = model.AuditGuestCheckedIn
+	NoticeGuestCheckedOut = model.AuditGuestCheckedOut
+	NoticeGuestDeleted    = model.AuditGuestDeleted
+	NoticeTableCreated    = model.AuditTableCreated
+	NoticeTableUpdated    = model.AuditTableUpdated
+	NoticeTableDeleted    = model.AuditTableDeleted
+	// The seats of a table once a change to it or its guests is committed, told once for every table a change touched
+	NoticeTableSeats = "table.seats"
+	// A table whose last free seat was taken by the change, told after its seats
+	NoticeTableFull = "table.full"
+	// The first message of a stream that was resumed from a message that is no longer kept, the client has missed
+	// changes and reads the guests and tables again
+	NoticeStreamReset = "stream.reset"
+)
+
+// How many messages a stream holds that were not sent yet, a client that falls further behind misses messages
+const streamBuffer = 512
+
+// Follows the changes to the guests and tables as they are made
+type StreamService interface {
+	// lastId is the id of the last message the client got, the messages published after it are sent first. Nil starts with the next message.
+	Subscribe(req dto.StreamReqDto, lastId *int64) (Stream, error)
+}
+
+// The messages of a subscription, Cancel ends it and has to be called once the client is gone
+type Stream struct {
+	// The messages the client missed, sent before the ones on Messages
+	Replay   []hub.Message
+	Messages <-chan hub.Message
+	Cancel   func()
+}
+
+type streamService struct {
+	eventRepository repository.EventRepository
+	hub             *hub.Hub
+}
+
+// The hub is the one the services publish to
+func NewStreamService(eventRepo repository.EventRepository, h *hub.Hub) StreamService {
+	return &streamService{
+		eventRepository: eventRepo,
+		hub:             h,
+	}
+}
+
+func (service *streamService) Subscribe(req dto.StreamReqDto, lastId *int64) (Stream, error) {
+	if req.Event_ID != 0 {
+		// This query runs -> SELECT * FROM `event` WHERE `event`.`id` = 1 ORDER BY `event`.`id` LIMIT 1
+		_, err := service.eventRepository.FindById(req.Event_ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Stream Service - Could not find event")
+			return Stream{}, ErrEventNotFound
+		}
+		if err != nil {
+			log.Println("Stream Service - Could not find event")
+			return Stream{}, err
+		}
+	}
+
+	tables := make(map[int]bool, len(req.Table_ID))
+	for _, v := range req.Table_ID {
+		tables[v] = true
+	}
+	filter := func(msg hub.Message) bool {
+		if req.Event_ID != 0 && msg.Event_ID != req.Event_ID {
+			return false
+		}
+		return len(tables) == 0 || tables[msg.Table_ID]
+	}
+
+	if lastId == nil {
+		ch, cancel := service.hub.Subscribe(filter, streamBuffer)
+		return Stream{Messages: ch, Cancel: cancel}, nil
+	}
+
+	replay, ch, cancel := service.hub.Resume(*lastId, filter, streamBuffer)
+	stream := Stream{Replay: replay.Messages, Messages: ch, Cancel: cancel}
+	if !replay.Complete {
+		log.Println("Stream Service - The messages after the last one the client got are no longer kept")
+		stream.Replay = []hub.Message{{Id: replay.LastId, Type: NoticeStreamReset, Event_ID: req.Event_ID, Time: now().UTC()}}
+	}
+	return stream, nil
+}
+
+// Passes the audit entries on to the audit repository and keeps them, they tell what the unit of work changed
+type auditTap struct {
+	repository.AuditRepository
+	entries []model.AuditEntry
+}
+
+func (t *auditTap) Save(entry model.AuditEntry) (model.AuditEntry, error) {
+	entry, err := t.AuditRepository.Save(entry)
+	if err == nil {
+		t.entries = append(t.entries, entry)
+	}
+	return entry, err
+}
+
+// Runs fn in a unit of work and works out what it changed on the guests and tables from the changes it recorded in the audit log.
+// The notices are handed back once the unit of work is committed, the seats of the tables are read and the webhook deliveries
+// queued before it is.
+func trackChanges(uow repository.UnitOfWork, fn func(repos repository.Repositories) error) ([]notice, error) {
+	var notices []notice
+
+	err := uow.Do(func(repos repository.Repositories) error {
+		tap := &auditTap{AuditRepository: repos.Audit}
+		repos.Audit = tap
+
+		if err := fn(repos); err != nil {
+			return err
+		}
+
+		var err error
+		notices, err = changeNotices(repos, tap.entries)
+		if err != nil {
+			return err
+		}
+		return queueDeliveries(repos, notices)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notices, nil
+}
+
+// A notice for every change, then the seats of every table the changes touched, by table id
+func changeNotices(repos repository.Repositories, entries []model.AuditEntry) ([]notice, error) {
+	var notices []notice
+	type tableKey struct{ eventId, tableId int }
+	touched := make(map[tableKey]bool)
+	// How many more seats the changes took at each table, and how many more the table has, to tell whether it was full before
+	occupied := make(map[tableKey]int)
+	capacity := make(map[tableKey]int)
+	locations := make(map[int]*time.Location)
+
+	for _, entry := range entries {
+		// The row as it is after the change, or as it was before it was deleted
+		data := entry.After
+		if data == "" {
+			data = entry.Before
+		}
+
+		switch entry.Entity {
+		case model.AuditEntityGuest:
+			var guest, before model.Guest
+			if err := json.Unmarshal([]byte(data), &guest); err != nil {
+				return nil, err
+			}
+			// A guest who moved frees seats at the table they left
+			if entry.Before != "" {
+				if err := json.Unmarshal([]byte(entry.Before), &before); err != nil {
+					return nil, err
+				}
+				touched[tableKey{entry.Event_ID, before.Table_ID}] = true
+				if before.Present() {
+					occupied[tableKey{entry.Event_ID, before.Table_ID}] -= before.Acompanying_Guests + 1
+				}
+			}
+			touched[tableKey{entry.Event_ID, guest.Table_ID}] = true
+			if entry.After != "" && guest.Present() {
+				occupied[tableKey{entry.Event_ID, guest.Table_ID}] += guest.Acompanying_Guests + 1
+			}
+			loc, ok := locations[entry.Event_ID]
+			if !ok {
+				loc = eventLocation(repos.Events, entry.Event_ID)
+				locations[entry.Event_ID] = loc
+			}
+			res := toGuestResDto(guest, loc)
+			notices = append(notices, notice{entry.Action, entry.Event_ID, guest.Table_ID, res})
+
+		case model.AuditEntityTable:
+			var table, before model.Table
+			if err := json.Unmarshal([]byte(data), &table); err != nil {
+				return nil, err
+			}
+			if entry.Before != "" {
+				if err := json.Unmarshal([]byte(entry.Before), &before); err != nil {
+					return nil, err
+				}
+			}
+			if entry.Action != model.AuditTableDeleted {
+				touched[tableKey{entry.Event_ID, table.Id}] = true
+				capacity[tableKey{entry.Event_ID, table.Id}] += table.Capacity - before.Capacity
+			}
+			notices = append(notices, notice{entry.Action, entry.Event_ID, table.Id, toTableResDto(table)})
+		}
+	}
+
+	keys := make([]tableKey, 0, len(touched))
+	for k := range touched {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].eventId != keys[j].eventId {
+			return keys[i].eventId < keys[j].eventId
+		}
+		return keys[i].tableId < keys[j].tableId
+	})
+	for _, k := range keys {
+		// This query runs -> SELECT `table`.*, ... FROM `table` ... WHERE event_id = 1 AND `table`.`id` = 5
+		table, err := repos.Tables.FindById(k.eventId, k.tableId)
+		if err != nil {
+			return nil, err
+		}
+		// A missing table is returned empty, it was deleted by the changes
+		if table.Id == 0 {
+			continue
+		}
+		notices = append(notices, notice{NoticeTableSeats, k.eventId, k.tableId, toTableResDto(table)})
+
+		// A table that was just created had no seats to take before, it is only full once guests sit down at it
+		freeBefore := table.Free() - capacity[k] + occupied[k]
+		if table.Free() <= 0 && freeBefore > 0 {
+			notices = append(notices, notice{NoticeTableFull, k.eventId, k.tableId, toTableResDto(table)})
+		}
+	}
+
+	return notices, nil
+}
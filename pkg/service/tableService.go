@@ -1,96 +1,368 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 
 	"github.com/getground/tech-tasks/backend/pkg/dto"
 	"github.com/getground/tech-tasks/backend/pkg/model"
 	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm"
 )
 
 type TableService interface {
-	FindAll() ([]dto.TableResDto, error)
-	FindById(id int) (dto.TableResDto, error)
-	Save(req dto.TableReqDto) (dto.TableResDto, error)
-	CheckSpace() (int, bool)
+	FindAll(eventId int, req dto.TableListReqDto) ([]dto.TableResDto, dto.PageDto, error)
+	ListAll(eventId int) ([]dto.TableResDto, error)
+	FindById(eventId int, id int) (dto.TableResDto, error)
+	Save(eventId int, req dto.TableReqDto, actor Actor) (dto.TableResDto, error)
+	Update(eventId int, id int, req dto.TablePatchReqDto, actor Actor) (dto.TableResDto, error)
+	Delete(eventId int, id int, reassignTo int, actor Actor) error
+	CheckSpace(eventId int) (dto.SeatsResDto, bool)
 }
 
 type tableService struct {
+	eventRepository repository.EventRepository
 	tableRepository repository.TableRepository
+	unitOfWork      repository.UnitOfWork
+	options         GuestOptions
 }
 
-func NewTableService(tableRepo repository.TableRepository) TableService {
+// The guest options are the guest service's, the parties waiting for a table that grows are added to the guest list by the same rules
+func NewTableService(eventRepo repository.EventRepository, tableRepo repository.TableRepository, uow repository.UnitOfWork, opts GuestOptions) TableService {
+	if opts.NamePolicy == "" {
+		opts.NamePolicy = NamePolicyUnique
+	}
+
 	return &tableService{
+		eventRepository: eventRepo,
 		tableRepository: tableRepo,
+		unitOfWork:      uow,
+		options:         opts,
 	}
 }
 
-func (service *tableService) FindAll() ([]dto.TableResDto, error) {
-	var res dto.TableResDto
-	var resArr []dto.TableResDto
+func (service *tableService) FindAll(eventId int, req dto.TableListReqDto) ([]dto.TableResDto, dto.PageDto, error) {
+	resArr := []dto.TableResDto{}
 
-	tables, err := service.tableRepository.FindAll()
+	q := repository.TableQuery{Limit: req.Limit}
+	q.Sort, q.Desc = sortKey(req.Sort, repository.TableSortId)
+	if req.Cursor != "" {
+		after, err := repository.DecodeCursor(req.Cursor)
+		if err != nil {
+			log.Println("Get Tables Service - Could not read the cursor")
+			return resArr, dto.PageDto{}, err
+		}
+		q.After = &after
+	}
+
+	page, err := service.tableRepository.Query(eventId, q)
 	if err != nil {
 		log.Println("Get Tables Service - Could not find tables")
-		return resArr, err
+		return resArr, dto.PageDto{}, err
 	}
 
-	for _, v := range tables {
-		res.Id = v.Id
-		res.Capacity = v.Capacity
+	for _, v := range page.Tables {
+		resArr = append(resArr, toTableResDto(v))
+	}
 
-		resArr = append(resArr, res)
+	return resArr, toPageDto(page.Total, page.Next), nil
+}
+
+// Every table of the event, for exports
+func (service *tableService) ListAll(eventId int) ([]dto.TableResDto, error) {
+	resArr := []dto.TableResDto{}
+
+	tables, err := service.tableRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("List Tables Service - Could not find tables")
+		return nil, err
+	}
+
+	for _, v := range tables {
+		resArr = append(resArr, toTableResDto(v))
 	}
 
 	return resArr, nil
 }
 
-func (service *tableService) FindById(id int) (dto.TableResDto, error) {
+func (service *tableService) FindById(eventId int, id int) (dto.TableResDto, error) {
 	var res dto.TableResDto
 
-	table, err := service.tableRepository.FindById(id)
+	table, err := service.tableRepository.FindById(eventId, id)
 	if err != nil {
 		log.Println("Get Table By Id Service - Could not find table")
 		return res, err
 	}
+	// The repository returns an empty table when there is none with the id
+	if table.Id == 0 {
+		log.Println("Get Table By Id Service - Could not find table")
+		return res, fmt.Errorf("%w: %d", ErrTableNotFound, id)
+	}
 
-	res.Id = table.Id
-	res.Capacity = table.Capacity
+	res = toTableResDto(table)
 
 	return res, nil
 }
 
-func (service *tableService) Save(req dto.TableReqDto) (dto.TableResDto, error) {
+func (service *tableService) Save(eventId int, req dto.TableReqDto, actor Actor) (dto.TableResDto, error) {
 	var table model.Table
 	var res dto.TableResDto
 
+	table.Event_ID = eventId
 	table.Capacity = req.Capacity
+	table.NearStage = req.NearStage
 
-	err := service.tableRepository.Save(table)
+	// The table is only kept together with its entry in the audit log
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		var err error
+		table, err = repos.Tables.Save(table)
+		if err != nil {
+			log.Println("Create Table Service - Could not create table")
+			return err
+		}
+		return recordTable(repos, actor, model.AuditTableCreated, nil, &table)
+	})
 	if err != nil {
-		log.Println("Create Table Service - Could not create table")
 		return res, err
 	}
 
-	res.Id = table.Id
-	res.Capacity = table.Capacity
+	publish(service.options.Publisher, changes)
+
+	res = toTableResDto(table)
+
+	return res, nil
+}
+
+// Finds the table of the event and locks its row, so the guests on it can not change until the surrounding transaction ends
+func findTableForUpdate(tables repository.TableRepository, eventId int, id int) (model.Table, error) {
+	// This query runs -> SELECT * FROM `table` WHERE event_id = 1 AND `table`.`id` = 5 ORDER BY `table`.`id` LIMIT 1 FOR UPDATE
+	table, err := tables.FindByIdForUpdate(eventId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return table, fmt.Errorf("%w: %d", ErrTableNotFound, id)
+	}
+	return table, err
+}
+
+// Changes the capacity of a table or whether it is near the stage, the capacity can not go below the seats that are reserved or taken on it.
+// The seats a table gains go to the parties waiting for it.
+func (service *tableService) Update(eventId int, id int, req dto.TablePatchReqDto, actor Actor) (dto.TableResDto, error) {
+	var res dto.TableResDto
+	var notices []notice
+
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		event, err := repos.Events.FindById(eventId)
+		if err != nil {
+			log.Println("Update Table Service - Could not find event")
+			return err
+		}
+
+		table, err := findTableForUpdate(repos.Tables, eventId, id)
+		if err != nil {
+			log.Println("Update Table Service - Could not find table")
+			return err
+		}
+		before := table
+
+		if req.Capacity != nil {
+			table.Capacity = *req.Capacity
+		}
+		if req.NearStage != nil {
+			table.NearStage = *req.NearStage
+		}
+
+		// The overbook allowance of the event counts, so a table keeps the reservations it was allowed to take
+		if table.Reserved > table.Bookable(event.OverbookAllowance) {
+			log.Println("Update Table Service - The capacity is below the reservations")
+			return fmt.Errorf("%w: table %d has %d seats reserved, a capacity of %d allows %d",
+				ErrCapacityBelowReservations, table.Id, table.Reserved, table.Capacity, table.Bookable(event.OverbookAllowance))
+		}
+		if table.Free() < 0 {
+			log.Println("Update Table Service - The capacity is below the guests at the table")
+			return fmt.Errorf("%w: table %d has %d guests at it", ErrCapacityBelowReservations, table.Id, table.Occupied)
+		}
+
+		// This query runs -> UPDATE `table` SET `event_id`=1,`capacity`=6 WHERE `id` = 5
+		if err := repos.Tables.Update(table); err != nil {
+			log.Println("Update Table Service - Could not update table")
+			return err
+		}
+		if err := recordTable(repos, actor, model.AuditTableUpdated, &before, &table); err != nil {
+			return err
+		}
+
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			return promoteWaitlist(repos, eventId, id, service.options.NamePolicy, actor)
+		})
+		if err != nil {
+			log.Println("Update Table Service - Could not promote the waitlist")
+			return err
+		}
+
+		// The counts include the parties that were promoted
+		table, err = repos.Tables.FindById(eventId, id)
+		if err != nil {
+			log.Println("Update Table Service - Could not find table")
+			return err
+		}
+		res = toTableResDto(table)
+
+		return nil
+	})
+	if err != nil {
+		return dto.TableResDto{}, err
+	}
+
+	publish(service.options.Publisher, append(changes, notices...))
 
 	return res, nil
 }
 
-func (service *tableService) CheckSpace() (int, bool) {
+// Deletes a table. Guests on the table are moved to the reassignTo table, without one a table with guests is not deleted.
+// The parties waiting for the table wait for the reassignTo table instead, without one they are taken off the waitlist.
+func (service *tableService) Delete(eventId int, id int, reassignTo int, actor Actor) error {
+	var notices []notice
+
+	changes, err := trackChanges(service.unitOfWork, func(repos repository.Repositories) error {
+		event, err := repos.Events.FindById(eventId)
+		if err != nil {
+			log.Println("Delete Table Service - Could not find event")
+			return err
+		}
+
+		// Both tables are locked, the one with the lower id first so that two deletions can not wait on each other
+		ids := []int{id}
+		if reassignTo != 0 {
+			ids = append(ids, reassignTo)
+			sort.Ints(ids)
+		}
+		tables := make(map[int]model.Table)
+		for _, v := range ids {
+			table, err := findTableForUpdate(repos.Tables, eventId, v)
+			if err != nil {
+				log.Println("Delete Table Service - Could not find table")
+				return err
+			}
+			tables[v] = table
+		}
+
+		// Every guest on the list for the table, including those who have left, refers to it
+		// This query runs -> SELECT * FROM `guest` WHERE event_id = 1
+		guests, err := repos.Guests.FindAll(eventId)
+		if err != nil {
+			log.Println("Delete Table Service - Could not retrieve guests")
+			return err
+		}
+		var seated []model.Guest
+		for _, v := range guests {
+			if v.Table_ID == id {
+				seated = append(seated, v)
+			}
+		}
+
+		// Guests pinned to the table could never keep to it again
+		if err := unpinTable(repos, eventId, id); err != nil {
+			log.Println("Delete Table Service - Could not remove pins to the table")
+			return err
+		}
+
+		if len(seated) > 0 {
+			if reassignTo == 0 {
+				log.Println("Delete Table Service - The table still has guests")
+				return fmt.Errorf("%w: table %d has %d guests, give a table to move them to", ErrTableHasGuests, id, len(seated))
+			}
 
-	space := 0
+			// The guests keep their reservations and seats on the new table
+			from, to := tables[id], tables[reassignTo]
+			if to.Reserved+from.Reserved > to.Bookable(event.OverbookAllowance) {
+				log.Println("Delete Table Service - The guests do not fit on the new table")
+				return fmt.Errorf("%w: table %d has %d of %d bookable seats reserved, %d moved",
+					ErrOverbooked, to.Id, to.Reserved, to.Bookable(event.OverbookAllowance), from.Reserved)
+			}
+			if to.Free() < from.Occupied {
+				log.Println("Delete Table Service - The guests do not fit on the new table")
+				return fmt.Errorf("%w: table %d has %d free seats, %d moved", ErrOverCapacity, to.Id, to.Free(), from.Occupied)
+			}
 
-	tables, err := service.tableRepository.FindAll()
+			moves := make(map[int]int)
+			for _, guest := range seated {
+				if guest.LeftAt == nil {
+					moves[guest.Id] = reassignTo
+				}
+			}
+			if err := checkMoves(repos, eventId, moves); err != nil {
+				log.Println("Delete Table Service - Moving the guests breaks a seating constraint")
+				return err
+			}
+
+			for _, guest := range seated {
+				before := guest
+				guest.Table_ID = reassignTo
+				// This query runs -> UPDATE `guest` SET ...,`table_id`=6 WHERE `id` = 2
+				if err := repos.Guests.Update(guest); err != nil {
+					log.Println("Delete Table Service - Could not move guest")
+					return err
+				}
+				if err := recordGuest(repos, actor, model.AuditGuestUpdated, &before, &guest); err != nil {
+					return err
+				}
+			}
+		}
+
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			return moveWaitlist(repos, eventId, id, reassignTo)
+		})
+		if err != nil {
+			log.Println("Delete Table Service - Could not move the waitlist")
+			return err
+		}
+
+		// This query runs -> DELETE FROM `table` WHERE `table`.`id` = 5
+		deleted := tables[id]
+		if err := repos.Tables.Delete(deleted); err != nil {
+			log.Println("Delete Table Service - Could not delete table")
+			return err
+		}
+		if err := recordTable(repos, actor, model.AuditTableDeleted, &deleted, nil); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	publish(service.options.Publisher, append(changes, notices...))
+	return nil
+}
+
+func (service *tableService) CheckSpace(eventId int) (dto.SeatsResDto, bool) {
+	var res dto.SeatsResDto
+
+	tables, err := service.tableRepository.FindAll(eventId)
 	if err != nil {
 		log.Println("Available Space Service - Could not get tables")
-		return 0, false
+		return res, false
 	}
 
 	for _, v := range tables {
-		space += v.Capacity
+		res.SeatsEmpty += v.Free()
+		res.Tables = append(res.Tables, toTableResDto(v))
 	}
 
-	return space, true
+	return res, true
+}
+
+// Maps the table entity to the response dto, the capacity is fixed while the other counts come from the guest list
+func toTableResDto(table model.Table) dto.TableResDto {
+	return dto.TableResDto{
+		Id:        table.Id,
+		Capacity:  table.Capacity,
+		NearStage: table.NearStage,
+		Reserved:  table.Reserved,
+		Arrived:   table.Occupied,
+		Free:      table.Free(),
+	}
 }
@@ -0,0 +1,455 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// The kinds of notice published about the waitlist, their data is the entry as a dto.WaitlistResDto
+const (
+	NoticeWaitlistJoined = "waitlist.joined"
+	// The party's place in the line or the table it waits for changed
+	NoticeWaitlistMoved    = "waitlist.moved"
+	NoticeWaitlistPromoted = "waitlist.promoted"
+	NoticeWaitlistLeft     = "waitlist.left"
+)
+
+// Told about the changes the services make, once they are committed
+type Publisher interface {
+	Publish(kind string, eventId int, tableId int, data interface{})
+}
+
+// A change to publish once the unit of work it was made in is committed
+type notice struct {
+	kind    string
+	eventId int
+	tableId int
+	data    interface{}
+}
+
+func publish(publisher Publisher, notices []notice) {
+	if publisher == nil {
+		return
+	}
+	for _, n := range notices {
+		publisher.Publish(n.kind, n.eventId, n.tableId, n.data)
+	}
+}
+
+// Lists the parties waiting for seats and takes them off the waitlist
+type WaitlistService interface {
+	FindAll(eventId int) ([]dto.WaitlistResDto, error)
+	FindById(eventId int, id int) (dto.WaitlistResDto, error)
+	Delete(eventId int, id int) error
+}
+
+type waitlistService struct {
+	guestRepository    repository.GuestRepository
+	waitlistRepository repository.WaitlistRepository
+	eventRepository    repository.EventRepository
+	unitOfWork         repository.UnitOfWork
+	options            GuestOptions
+}
+
+// The guest options are the guest service's, the publisher is told when a party leaves the waitlist
+func NewWaitlistService(eventRepo repository.EventRepository, guestRepo repository.GuestRepository, waitlistRepo repository.WaitlistRepository, uow repository.UnitOfWork, opts GuestOptions) WaitlistService {
+	return &waitlistService{
+		eventRepository:    eventRepo,
+		guestRepository:    guestRepo,
+		waitlistRepository: waitlistRepo,
+		unitOfWork:         uow,
+		options:            opts,
+	}
+}
+
+// The parties still waiting, by table and then in the order they will be promoted
+func (service *waitlistService) FindAll(eventId int) ([]dto.WaitlistResDto, error) {
+	resArr := []dto.WaitlistResDto{}
+
+	// This query runs -> SELECT * FROM `waitlist` WHERE event_id = 1 ORDER BY id
+	entries, err := service.waitlistRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Waitlist Service - Could not find the waitlist")
+		return nil, err
+	}
+
+	guests, err := service.guestRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Waitlist Service - Could not find guests")
+		return nil, err
+	}
+
+	byId := guestsById(guests)
+	positions := waitlistPositions(entries)
+	loc := eventLocation(service.eventRepository, eventId)
+	for _, v := range waitlistOrder(entries) {
+		resArr = append(resArr, toWaitlistResDto(v, positions[v.Id], byId, loc))
+	}
+
+	return resArr, nil
+}
+
+// An entry of the waitlist, waiting or promoted
+func (service *waitlistService) FindById(eventId int, id int) (dto.WaitlistResDto, error) {
+	entry, err := service.waitlistRepository.FindById(eventId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("Get Waitlist Service - Could not find entry")
+		return dto.WaitlistResDto{}, fmt.Errorf("%w: %d", ErrWaitlistEntryNotFound, id)
+	}
+	if err != nil {
+		log.Println("Get Waitlist Service - Could not find entry")
+		return dto.WaitlistResDto{}, err
+	}
+
+	entries, err := service.waitlistRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Waitlist Service - Could not find the waitlist")
+		return dto.WaitlistResDto{}, err
+	}
+	guests, err := service.guestRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Waitlist Service - Could not find guests")
+		return dto.WaitlistResDto{}, err
+	}
+
+	return toWaitlistResDto(entry, waitlistPositions(entries)[entry.Id], guestsById(guests), eventLocation(service.eventRepository, eventId)), nil
+}
+
+// Takes the party off the waitlist, the parties behind it move up
+func (service *waitlistService) Delete(eventId int, id int) error {
+	var notices []notice
+
+	err := service.unitOfWork.Do(func(repos repository.Repositories) error {
+		var err error
+		notices, err = trackWaitlist(repos, eventId, func() error {
+			entry, err := repos.Waitlist.FindById(eventId, id)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Println("Delete Waitlist Service - Could not find entry")
+				return fmt.Errorf("%w: %d", ErrWaitlistEntryNotFound, id)
+			}
+			if err != nil {
+				log.Println("Delete Waitlist Service - Could not find entry")
+				return err
+			}
+
+			// This query runs -> DELETE FROM `waitlist` WHERE `waitlist`.`id` = 3
+			return repos.Waitlist.Delete(entry)
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	publish(service.options.Publisher, notices)
+	return nil
+}
+
+// The waiting entries in the order they are promoted in: by table, then the highest priority, then the longest wait
+func waitlistOrder(entries []model.WaitlistEntry) []model.WaitlistEntry {
+	var waiting []model.WaitlistEntry
+	for _, v := range entries {
+		if v.Waiting() {
+			waiting = append(waiting, v)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool {
+		a, b := waiting[i], waiting[j]
+		if a.Table_ID != b.Table_ID {
+			return a.Table_ID < b.Table_ID
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.Id < b.Id
+	})
+	return waiting
+}
+
+// The place of every waiting entry in the line for its table, 1 is next
+func waitlistPositions(entries []model.WaitlistEntry) map[int]int {
+	res := make(map[int]int)
+	place, table := 0, 0
+	for _, v := range waitlistOrder(entries) {
+		if v.Table_ID != table {
+			place, table = 0, v.Table_ID
+		}
+		place++
+		res[v.Id] = place
+	}
+	return res
+}
+
+func toWaitlistResDto(entry model.WaitlistEntry, position int, guests map[int]model.Guest, loc *time.Location) dto.WaitlistResDto {
+	res := dto.WaitlistResDto{
+		Id:                 entry.Id,
+		Kind:               entry.Kind,
+		Guest:              guests[entry.Guest_ID].PublicId,
+		Name:               entry.Name,
+		Table_ID:           entry.Table_ID,
+		Acompanying_Guests: entry.Acompanying_Guests,
+		Priority:           entry.Priority,
+		Status:             "waiting",
+		Position:           position,
+		Queued:             formatTime(&entry.CreatedAt, loc),
+		Promoted:           formatTime(entry.PromotedAt, loc),
+	}
+	if !entry.Waiting() {
+		res.Status = "promoted"
+		res.Position = 0
+	}
+	return res
+}
+
+// Runs fn and works out what it changed on the event's waitlist: the parties that joined, moved, were promoted or left
+func trackWaitlist(repos repository.Repositories, eventId int, fn func() error) ([]notice, error) {
+	// This query runs -> SELECT * FROM `waitlist` WHERE event_id = 1 ORDER BY id
+	before, err := repos.Waitlist.FindAll(eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(); err != nil {
+		return nil, err
+	}
+
+	after, err := repos.Waitlist.FindAll(eventId)
+	if err != nil {
+		return nil, err
+	}
+	guests, err := repos.Guests.FindAll(eventId)
+	if err != nil {
+		return nil, err
+	}
+	byId := guestsById(guests)
+	loc := eventLocation(repos.Events, eventId)
+
+	oldPositions, positions := waitlistPositions(before), waitlistPositions(after)
+	old := make(map[int]model.WaitlistEntry, len(before))
+	for _, v := range before {
+		old[v.Id] = v
+	}
+
+	// The parties that were waiting come first, in the order they were in the line, so promotions are told in the order they happened
+	sort.SliceStable(after, func(i, j int) bool {
+		pi, pj := oldPositions[after[i].Id], oldPositions[after[j].Id]
+		if (pi == 0) != (pj == 0) {
+			return pi != 0
+		}
+		if pi != 0 && after[i].Table_ID != after[j].Table_ID {
+			return after[i].Table_ID < after[j].Table_ID
+		}
+		return pi < pj
+	})
+
+	var notices []notice
+	kept := make(map[int]bool, len(after))
+	for _, v := range after {
+		kept[v.Id] = true
+		prev, existed := old[v.Id]
+
+		var kind string
+		switch {
+		case !existed:
+			kind = NoticeWaitlistJoined
+		case prev.Waiting() && !v.Waiting():
+			kind = NoticeWaitlistPromoted
+		case v.Waiting() && (oldPositions[v.Id] != positions[v.Id] || prev.Table_ID != v.Table_ID):
+			kind = NoticeWaitlistMoved
+		default:
+			continue
+		}
+		notices = append(notices, notice{kind, eventId, v.Table_ID, toWaitlistResDto(v, positions[v.Id], byId, loc)})
+	}
+	for _, v := range before {
+		if !kept[v.Id] && v.Waiting() {
+			notices = append(notices, notice{NoticeWaitlistLeft, eventId, v.Table_ID, toWaitlistResDto(v, 0, byId, loc)})
+		}
+	}
+
+	return notices, nil
+}
+
+// The entry with its place in the line, as the party that joined is told it
+func describeEntry(repos repository.Repositories, eventId int, entry model.WaitlistEntry) (*dto.WaitlistResDto, error) {
+	entries, err := repos.Waitlist.FindAll(eventId)
+	if err != nil {
+		return nil, err
+	}
+	guests, err := repos.Guests.FindAll(eventId)
+	if err != nil {
+		return nil, err
+	}
+	res := toWaitlistResDto(entry, waitlistPositions(entries)[entry.Id], guestsById(guests), eventLocation(repos.Events, eventId))
+	return &res, nil
+}
+
+// Puts the party at the back of the line for its priority
+func joinWaitlist(repos repository.Repositories, entry model.WaitlistEntry) (model.WaitlistEntry, error) {
+	entry.CreatedAt = now()
+	// This query runs -> INSERT INTO `waitlist` (`event_id`,`kind`,`guest_id`,`name`,...,`created_at`,`promoted_at`) VALUES (1,'booking',0,'sara',...,NULL)
+	return repos.Waitlist.Save(entry)
+}
+
+// Gives the seats that are free at the table to the parties waiting for it, in the order they are promoted in.
+// A party that does not fit is passed over for a smaller one behind it and keeps its place.
+// It runs inside the caller's unit of work, bookings are added to the guest list by the name policy.
+// The guests added and checked in are recorded in the audit log for the actor whose change freed the seats.
+func promoteWaitlist(repos repository.Repositories, eventId int, tableId int, namePolicy string, actor Actor) error {
+	entries, err := repos.Waitlist.FindAll(eventId)
+	if err != nil {
+		return err
+	}
+	var waiting []model.WaitlistEntry
+	for _, v := range waitlistOrder(entries) {
+		if v.Table_ID == tableId {
+			waiting = append(waiting, v)
+		}
+	}
+	if len(waiting) == 0 {
+		return nil
+	}
+
+	event, err := repos.Events.FindById(eventId)
+	if err != nil {
+		return err
+	}
+	guests, err := repos.Guests.FindAll(eventId)
+	if err != nil {
+		return err
+	}
+	byId := guestsById(guests)
+
+	for _, entry := range waiting {
+		// The seats are counted again for every party, the ones promoted before it have taken some
+		table, err := findTableForUpdate(repos.Tables, eventId, tableId)
+		if err != nil {
+			return err
+		}
+		size := entry.Acompanying_Guests + 1
+
+		switch entry.Kind {
+		case model.WaitlistBooking:
+			if table.Reserved+size > table.Bookable(event.OverbookAllowance) {
+				continue
+			}
+			guest, err := addGuest(repos, event, dto.GuestReqDto{
+				Name:               entry.Name,
+				Aliases:            entry.Aliases,
+				Table_ID:           tableId,
+				Acompanying_Guests: entry.Acompanying_Guests,
+			}, namePolicy, actor)
+			// The name was taken while the party waited, it waits on until it is taken off the waitlist
+			if errors.Is(err, ErrDuplicateName) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			entry.Guest_ID = guest.Id
+
+		case model.WaitlistArrival:
+			if table.Free() < size {
+				continue
+			}
+			guest, err := repos.Guests.FindByPublicIdForUpdate(eventId, byId[entry.Guest_ID].PublicId)
+			if err != nil {
+				return err
+			}
+			// A guest who got in another way or was moved to another table no longer waits for this one
+			if guest.Present() || guest.Table_ID != tableId {
+				continue
+			}
+
+			before := guest
+			arrived := now()
+			guest.ArrivedAt = &arrived
+			guest.LeftAt = nil
+			guest.Acompanying_Guests = entry.Acompanying_Guests
+			_, err = repos.Visits.Save(model.Visit{Event_ID: eventId, Guest_ID: guest.Id, Acompanying_Guests: guest.Acompanying_Guests, ArrivedAt: arrived})
+			if err != nil {
+				return err
+			}
+			if err := repos.Guests.Update(guest); err != nil {
+				return err
+			}
+			if err := recordGuest(repos, actor, model.AuditGuestCheckedIn, &before, &guest); err != nil {
+				return err
+			}
+		}
+
+		promoted := now()
+		entry.PromotedAt = &promoted
+		// This query runs -> UPDATE `waitlist` SET ...,`guest_id`=7,`promoted_at`='2026-12-31 22:15:00' WHERE `id` = 3
+		if err := repos.Waitlist.Update(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Takes the guest's waiting entries off the waitlist, once they got in or are taken off the guest list
+func leaveWaitlist(repos repository.Repositories, eventId int, guestId int) error {
+	entries, err := repos.Waitlist.FindAll(eventId)
+	if err != nil {
+		return err
+	}
+	for _, v := range entries {
+		if v.Kind == model.WaitlistArrival && v.Guest_ID == guestId && v.Waiting() {
+			// This query runs -> DELETE FROM `waitlist` WHERE `waitlist`.`id` = 3
+			if err := repos.Waitlist.Delete(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// The guest's entry waiting at the door, if they have one
+func arrivalEntry(repos repository.Repositories, eventId int, guestId int) (model.WaitlistEntry, bool, error) {
+	entries, err := repos.Waitlist.FindAll(eventId)
+	if err != nil {
+		return model.WaitlistEntry{}, false, err
+	}
+	for _, v := range entries {
+		if v.Kind == model.WaitlistArrival && v.Guest_ID == guestId && v.Waiting() {
+			return v, true, nil
+		}
+	}
+	return model.WaitlistEntry{}, false, nil
+}
+
+// Moves the entries waiting for a table that is deleted to the table its guests go to, without one they are taken off
+func moveWaitlist(repos repository.Repositories, eventId int, from int, to int) error {
+	entries, err := repos.Waitlist.FindAll(eventId)
+	if err != nil {
+		return err
+	}
+	for _, v := range entries {
+		if v.Table_ID != from || !v.Waiting() {
+			continue
+		}
+		if to == 0 {
+			err = repos.Waitlist.Delete(v)
+		} else {
+			v.Table_ID = to
+			err = repos.Waitlist.Update(v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
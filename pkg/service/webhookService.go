@@ -0,0 +1,385 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/webhook"
+	"gorm.io/gorm"
+)
+
+// The kinds of change a webhook can subscribe to
+var WebhookEvents = []string{NoticeGuestCreated, NoticeGuestCheckedIn, NoticeGuestCheckedOut, NoticeTableFull}
+
+// The most deliveries sent at once
+const deliveryBatch = 20
+
+// The longest a delivery waits between two attempts
+const maxRetryDelay = time.Hour
+
+// Manages the webhooks of an event, queues a delivery for every change they subscribe to and sends the queue
+type WebhookService interface {
+	FindAll(eventId int) ([]dto.WebhookResDto, error)
+	FindById(eventId int, id int) (dto.WebhookResDto, error)
+	Save(eventId int, req dto.WebhookReqDto) (dto.WebhookResDto, error)
+	Delete(eventId int, id int) error
+	FindDeliveries(eventId int, id int, req dto.DeliveryListReqDto) ([]dto.DeliveryResDto, dto.PageDto, error)
+	// Sends the deliveries that are due and returns how many were tried
+	DeliverDue() (int, error)
+}
+
+type WebhookOptions struct {
+	// The attempts a delivery gets before it is given up on
+	MaxAttempts int
+	// How long the first retry waits, every retry after it waits twice as long as the one before, up to an hour
+	RetryDelay time.Duration
+	// How long the receiver has to answer
+	Timeout time.Duration
+	// The clock the deliveries are due by, left nil it is the time now
+	Now func() time.Time
+}
+
+type webhookService struct {
+	webhookRepository  repository.WebhookRepository
+	deliveryRepository repository.DeliveryRepository
+	unitOfWork         repository.UnitOfWork
+	sender             *webhook.Sender
+	options            WebhookOptions
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, deliveryRepo repository.DeliveryRepository, uow repository.UnitOfWork, opts WebhookOptions) WebhookService {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	if opts.Now == nil {
+		opts.Now = now
+	}
+
+	return &webhookService{
+		webhookRepository:  webhookRepo,
+		deliveryRepository: deliveryRepo,
+		unitOfWork:         uow,
+		sender:             webhook.NewSender(opts.Timeout),
+		options:            opts,
+	}
+}
+
+func toWebhookResDto(hook model.Webhook) dto.WebhookResDto {
+	return dto.WebhookResDto{
+		Id:      hook.Id,
+		URL:     hook.URL,
+		Events:  hook.Events,
+		Created: formatTime(&hook.CreatedAt, time.UTC),
+	}
+}
+
+func toDeliveryResDto(delivery model.WebhookDelivery) dto.DeliveryResDto {
+	res := dto.DeliveryResDto{
+		Id:             delivery.Id,
+		Webhook_ID:     delivery.Webhook_ID,
+		Type:           delivery.Type,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastAttempt:    formatTime(delivery.LastAttemptAt, time.UTC),
+		ResponseStatus: delivery.ResponseStatus,
+		Error:          delivery.Error,
+		Delivered:      formatTime(delivery.DeliveredAt, time.UTC),
+		Created:        formatTime(&delivery.CreatedAt, time.UTC),
+		Payload:        json.RawMessage(delivery.Payload),
+	}
+	if delivery.Status == model.DeliveryPending {
+		res.NextAttempt = formatTime(&delivery.NextAttemptAt, time.UTC)
+	}
+	return res
+}
+
+func (service *webhookService) FindAll(eventId int) ([]dto.WebhookResDto, error) {
+	resArr := []dto.WebhookResDto{}
+
+	// This query runs -> SELECT * FROM `webhook` WHERE event_id = 1 ORDER BY id
+	hooks, err := service.webhookRepository.FindAll(eventId)
+	if err != nil {
+		log.Println("Get Webhooks Service - Could not retrieve webhooks")
+		return resArr, err
+	}
+
+	for _, v := range hooks {
+		resArr = append(resArr, toWebhookResDto(v))
+	}
+	return resArr, nil
+}
+
+func (service *webhookService) FindById(eventId int, id int) (dto.WebhookResDto, error) {
+	hook, err := findWebhook(service.webhookRepository, eventId, id)
+	if err != nil {
+		log.Println("Get Webhook Service - Could not find webhook")
+		return dto.WebhookResDto{}, err
+	}
+	return toWebhookResDto(hook), nil
+}
+
+func findWebhook(webhooks repository.WebhookRepository, eventId int, id int) (model.Webhook, error) {
+	// This query runs -> SELECT * FROM `webhook` WHERE event_id = 1 AND `webhook`.`id` = 2 ORDER BY `webhook`.`id` LIMIT 1
+	hook, err := webhooks.FindById(eventId, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return hook, fmt.Errorf("%w: %d", ErrWebhookNotFound, id)
+	}
+	return hook, err
+}
+
+func (service *webhookService) Save(eventId int, req dto.WebhookReqDto) (dto.WebhookResDto, error) {
+	// An event given twice is sent once
+	var events []string
+	seen := make(map[string]bool)
+	for _, v := range req.Events {
+		if !seen[v] {
+			events = append(events, v)
+		}
+		seen[v] = true
+	}
+
+	// This query runs -> INSERT INTO `webhook` (`event_id`,`url`,`secret`,`events`,`created_at`) VALUES (1,'https://...','...','["guest.checked_in"]',...)
+	hook, err := service.webhookRepository.Save(model.Webhook{Event_ID: eventId, URL: req.URL, Secret: req.Secret, Events: events, CreatedAt: now().UTC()})
+	if err != nil {
+		log.Println("Create Webhook Service - Could not create webhook")
+		return dto.WebhookResDto{}, err
+	}
+
+	return toWebhookResDto(hook), nil
+}
+
+// Removes the webhook together with its deliveries, the ones still pending are not sent
+func (service *webhookService) Delete(eventId int, id int) error {
+	return service.unitOfWork.Do(func(repos repository.Repositories) error {
+		hook, err := findWebhook(repos.Webhooks, eventId, id)
+		if err != nil {
+			log.Println("Delete Webhook Service - Could not find webhook")
+			return err
+		}
+
+		// This query runs -> DELETE FROM `webhook_delivery` WHERE webhook_id = 2
+		if err := repos.Deliveries.DeleteByWebhook(hook.Id); err != nil {
+			log.Println("Delete Webhook Service - Could not delete the deliveries")
+			return err
+		}
+		// This query runs -> DELETE FROM `webhook` WHERE `webhook`.`id` = 2
+		if err := repos.Webhooks.Delete(hook); err != nil {
+			log.Println("Delete Webhook Service - Could not delete webhook")
+			return err
+		}
+		return nil
+	})
+}
+
+// Lists the deliveries of the webhook, the latest first unless the request asks otherwise
+func (service *webhookService) FindDeliveries(eventId int, id int, req dto.DeliveryListReqDto) ([]dto.DeliveryResDto, dto.PageDto, error) {
+	resArr := []dto.DeliveryResDto{}
+
+	if _, err := findWebhook(service.webhookRepository, eventId, id); err != nil {
+		log.Println("Get Deliveries Service - Could not find webhook")
+		return resArr, dto.PageDto{}, err
+	}
+
+	q := repository.DeliveryQuery{Webhook_ID: id, Status: req.Status, Limit: req.Limit}
+	sort := req.Sort
+	if sort == "" {
+		sort = "-" + repository.DeliverySortId
+	}
+	_, q.Desc = sortKey(sort, repository.DeliverySortId)
+	if req.Cursor != "" {
+		after, err := repository.DecodeCursor(req.Cursor)
+		if err != nil {
+			log.Println("Get Deliveries Service - Could not read the cursor")
+			return resArr, dto.PageDto{}, err
+		}
+		q.After = &after
+	}
+
+	// This query runs -> SELECT * FROM `webhook_delivery` WHERE webhook_delivery.event_id = 1 AND webhook_delivery.webhook_id = 2 ORDER BY webhook_delivery.id DESC LIMIT 101
+	page, err := service.deliveryRepository.Query(eventId, q)
+	if err != nil {
+		log.Println("Get Deliveries Service - Could not retrieve the deliveries")
+		return resArr, dto.PageDto{}, err
+	}
+
+	for _, v := range page.Deliveries {
+		resArr = append(resArr, toDeliveryResDto(v))
+	}
+	return resArr, toPageDto(page.Total, page.Next), nil
+}
+
+// Queues a delivery for every webhook that subscribes to one of the notices, in the unit of work that made the changes,
+// so a change is only ever sent once it is committed and is sent even when the server stops before sending it
+func queueDeliveries(repos repository.Repositories, notices []notice) error {
+	webhooks := make(map[int][]model.Webhook)
+
+	for _, n := range notices {
+		if !isWebhookEvent(n.kind) {
+			continue
+		}
+		hooks, ok := webhooks[n.eventId]
+		if !ok {
+			// This query runs -> SELECT * FROM `webhook` WHERE event_id = 1 ORDER BY id
+			var err error
+			hooks, err = repos.Webhooks.FindAll(n.eventId)
+			if err != nil {
+				log.Println("Webhook Service - Could not find the webhooks")
+				return err
+			}
+			webhooks[n.eventId] = hooks
+		}
+
+		for _, hook := range hooks {
+			if !hook.Wants(n.kind) {
+				continue
+			}
+			at := now().UTC()
+			payload, err := json.Marshal(webhook.Payload{Type: n.kind, Event_ID: n.eventId, Table_ID: n.tableId, Time: at, Data: n.data})
+			if err != nil {
+				return err
+			}
+			// This query runs -> INSERT INTO `webhook_delivery` (`event_id`,`webhook_id`,`type`,`payload`,`status`,...) VALUES (1,2,'guest.checked_in','{...}','pending',...)
+			_, err = repos.Deliveries.Save(model.WebhookDelivery{
+				Event_ID:      n.eventId,
+				Webhook_ID:    hook.Id,
+				Type:          n.kind,
+				Payload:       string(payload),
+				Status:        model.DeliveryPending,
+				NextAttemptAt: at,
+				CreatedAt:     at,
+			})
+			if err != nil {
+				log.Println("Webhook Service - Could not queue the delivery")
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// How long a delivery waits after the attempt-th attempt failed
+func (service *webhookService) retryDelay(attempt int) time.Duration {
+	delay := service.options.RetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+func (service *webhookService) DeliverDue() (int, error) {
+	at := service.options.Now().UTC()
+
+	type attempt struct {
+		delivery model.WebhookDelivery
+		hook     model.Webhook
+	}
+	var attempts []attempt
+
+	// The deliveries are claimed before they are sent: they are held back until the attempt can have timed out,
+	// so a server that looks for due deliveries meanwhile does not send them too
+	err := service.unitOfWork.Do(func(repos repository.Repositories) error {
+		due, err := repos.Deliveries.FindDueForUpdate(at, deliveryBatch)
+		if err != nil {
+			log.Println("Deliver Webhooks Service - Could not find the due deliveries")
+			return err
+		}
+
+		for _, delivery := range due {
+			delivery.Attempts++
+			delivery.LastAttemptAt = &at
+			delivery.NextAttemptAt = at.Add(service.options.Timeout + time.Minute)
+
+			hook, err := repos.Webhooks.FindById(delivery.Event_ID, delivery.Webhook_ID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				delivery.Status = model.DeliveryFailed
+				delivery.Error = "the webhook was deleted"
+			} else if err != nil {
+				return err
+			}
+
+			if err := repos.Deliveries.Update(delivery); err != nil {
+				log.Println("Deliver Webhooks Service - Could not claim the delivery")
+				return err
+			}
+			if delivery.Status == model.DeliveryPending {
+				attempts = append(attempts, attempt{delivery, hook})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The receivers are called at the same time, so a slow one does not hold up the others
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Add(1)
+		go func(a *attempt) {
+			defer wg.Done()
+			d := &a.delivery
+			var err error
+			d.ResponseStatus, err = service.sender.Send(a.hook.URL, a.hook.Secret, d.Id, d.Type, []byte(d.Payload), at)
+			switch {
+			case err == nil:
+				d.Status = model.DeliveryDelivered
+				d.DeliveredAt = &at
+				d.Error = ""
+			case d.Attempts >= service.options.MaxAttempts:
+				d.Status = model.DeliveryFailed
+				d.Error = truncate(err.Error(), 512)
+			default:
+				d.NextAttemptAt = at.Add(service.retryDelay(d.Attempts))
+				d.Error = truncate(err.Error(), 512)
+			}
+		}(&attempts[i])
+	}
+	wg.Wait()
+
+	for _, a := range attempts {
+		// A webhook deleted while it was sent takes its deliveries with it, they are not written back
+		err := service.unitOfWork.Do(func(repos repository.Repositories) error {
+			if _, err := repos.Webhooks.FindById(a.delivery.Event_ID, a.delivery.Webhook_ID); err != nil {
+				return err
+			}
+			return repos.Deliveries.Update(a.delivery)
+		})
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("Deliver Webhooks Service - Could not record the attempt")
+			return len(attempts), err
+		}
+		if a.delivery.Status != model.DeliveryDelivered {
+			log.Println("Deliver Webhooks Service - Could not deliver " + a.delivery.Type + " to webhook " + fmt.Sprint(a.delivery.Webhook_ID) + ": " + a.delivery.Error)
+		}
+	}
+
+	return len(attempts), nil
+}
+
+func isWebhookEvent(kind string) bool {
+	for _, v := range WebhookEvents {
+		if v == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Cuts s down to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
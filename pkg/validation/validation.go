@@ -0,0 +1,241 @@
+// The validation package checks request DTOs before they reach the services.
+//
+// The rules are declared in the binding tags of the DTOs and checked with gin's validator,
+// every field that breaks a rule is reported so the client can point at the offending input.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Every validation error wraps this
+var ErrInvalid = errors.New("the request is not valid")
+
+// The fields of a request that broke a validation rule
+type Error struct {
+	Fields []dto.FieldErrorResDto
+}
+
+func (e *Error) Error() string {
+	var fields []string
+	for _, f := range e.Fields {
+		fields = append(fields, f.Field+" "+f.Message)
+	}
+	return ErrInvalid.Error() + ": " + strings.Join(fields, "; ")
+}
+
+func (e *Error) Unwrap() error {
+	return ErrInvalid
+}
+
+var setup sync.Once
+
+// The validator gin binds requests with, set up with the rules and field names of this package
+func engine() *validator.Validate {
+	v := binding.Validator.Engine().(*validator.Validate)
+	setup.Do(func() {
+		// Fields are reported by the names the client sends them as, in the query string or the body
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"form", "json"} {
+				name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+				if name != "" && name != "-" {
+					return name
+				}
+			}
+			return field.Name
+		})
+		v.RegisterValidation("personname", personName)
+		v.RegisterValidation("webhookurl", webhookURL)
+	})
+	return v
+}
+
+// A name has at least one letter and is made of letters, digits, spaces, apostrophes, hyphens, full stops and ampersands,
+// with no space at either end
+func personName(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+	if strings.TrimSpace(name) != name {
+		return false
+	}
+
+	letters := 0
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.IsMark(r), unicode.IsDigit(r), r == ' ', r == '\'', r == '’', r == '-', r == '.', r == '&':
+		default:
+			return false
+		}
+	}
+	return letters > 0
+}
+
+// A webhook is sent to an absolute http or https URL
+func webhookURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Checks the request against the rules in its binding tags
+func Struct(req interface{}) error {
+	err := engine().Struct(req)
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	res := &Error{}
+	for _, fe := range fieldErrors {
+		res.Fields = append(res.Fields, dto.FieldErrorResDto{Field: fieldPath(fe), Message: message(fe)})
+	}
+	return res
+}
+
+// The field as the client sent it, the fields of a list are given with their place in it, e.g. parties[2].name
+func fieldPath(fe validator.FieldError) string {
+	// The namespace starts with the name of the request struct
+	path := fe.Namespace()
+	if i := strings.Index(path, "."); i >= 0 {
+		return path[i+1:]
+	}
+	return fe.Field()
+}
+
+// The message shown to the client for a broken rule
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_without":
+		return "is required"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gtefield":
+		if fe.Kind() == reflect.Struct {
+			return "must not be before " + snakeCase(fe.Param())
+		}
+		return "must not be less than " + snakeCase(fe.Param())
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "personname":
+		return "must contain a letter and only letters, digits, spaces, apostrophes, hyphens, full stops and ampersands"
+	case "webhookurl":
+		return "must be an http or https URL"
+	}
+	return "is not valid"
+}
+
+// StartTime -> start_time, the JSON name of the fields compared against
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// Checks guest-list entries, including that their table is one of the event's
+type GuestValidator interface {
+	Validate(eventId int, req dto.GuestReqDto) error
+	ValidatePatch(eventId int, req dto.GuestPatchReqDto) error
+	ValidateRows(eventId int, rows []dto.GuestImportRowDto) error
+}
+
+type guestValidator struct {
+	tableRepository repository.TableRepository
+}
+
+func NewGuestValidator(tableRepo repository.TableRepository) GuestValidator {
+	return &guestValidator{
+		tableRepository: tableRepo,
+	}
+}
+
+func (v *guestValidator) Validate(eventId int, req dto.GuestReqDto) error {
+	return v.withTable(Struct(req), eventId, req.Table_ID)
+}
+
+// A table_id of 0 leaves the guest on their table
+func (v *guestValidator) ValidatePatch(eventId int, req dto.GuestPatchReqDto) error {
+	return v.withTable(Struct(req), eventId, req.Table_ID)
+}
+
+// Adds the rules each row of an import breaks to its errors, the returned error is only set when the rows could not be checked
+func (v *guestValidator) ValidateRows(eventId int, rows []dto.GuestImportRowDto) error {
+	for i := range rows {
+		err := v.Validate(eventId, rows[i].Guest)
+
+		var invalid *Error
+		if err != nil && !errors.As(err, &invalid) {
+			return err
+		}
+		if invalid == nil {
+			continue
+		}
+
+		// A cell that could not be read is already reported, its field is left empty and would break the rules again
+		reported := make(map[string]bool)
+		for _, fe := range rows[i].Errors {
+			reported[fe.Field] = true
+		}
+		for _, fe := range invalid.Fields {
+			if !reported[fe.Field] {
+				rows[i].Errors = append(rows[i].Errors, fe)
+			}
+		}
+	}
+	return nil
+}
+
+// Adds a field error to err when the table is not one of the event's
+func (v *guestValidator) withTable(err error, eventId int, tableId int) error {
+	var invalid *Error
+	if err != nil && !errors.As(err, &invalid) {
+		return err
+	}
+	if invalid == nil {
+		invalid = &Error{}
+	}
+
+	// The table is only looked up when the id itself is valid
+	if tableId > 0 {
+		// This query runs -> SELECT `table`.*, ... FROM `table` ... WHERE `table`.id = 5 AND `table`.event_id = 1
+		table, err := v.tableRepository.FindById(eventId, tableId)
+		if err != nil {
+			return err
+		}
+		if table.Id == 0 {
+			invalid.Fields = append(invalid.Fields, dto.FieldErrorResDto{Field: "table_id", Message: fmt.Sprintf("table %d does not exist", tableId)})
+		}
+	}
+
+	if len(invalid.Fields) > 0 {
+		return invalid
+	}
+	return nil
+}
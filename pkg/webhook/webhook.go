@@ -0,0 +1,98 @@
+// The webhook package signs the payloads sent to the organisers' own systems and sends them.
+//
+// Every payload is POSTed as JSON with the headers below. The signature is "sha256=" and the hex HMAC-SHA256 of the timestamp,
+// a full stop and the body, keyed with the webhook's secret. A receiver works it out again to know the payload came from the
+// server, and turns away old timestamps so a payload that was seen once can not be sent to it again.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The headers of every delivery
+const (
+	// The id of the delivery, the same on every attempt so a receiver can tell a retry from a new payload
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// The body of a delivery
+type Payload struct {
+	Type     string `json:"type"`
+	Event_ID int    `json:"event_id"`
+	// The table it happened at
+	Table_ID int         `json:"table_id,omitempty"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data"`
+}
+
+// The signature of the body sent at the timestamp, in unix seconds
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Reports whether the signature is the one of the body and timestamp, compared in constant time
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Sends deliveries over HTTP
+type Sender struct {
+	client *http.Client
+}
+
+// An attempt that takes longer than the timeout fails
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			// A redirect is answered like any other status, the payload is only sent to the URL of the webhook
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Sends the body to the URL, signed with the secret. It returns the status the receiver answered with, 0 when it could not be
+// reached, and an error unless the status is a 2xx one.
+func (s *Sender) Send(url string, secret string, deliveryId int, kind string, body []byte, at time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := at.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "party-server-webhooks")
+	req.Header.Set(HeaderDelivery, strconv.Itoa(deliveryId))
+	req.Header.Set(HeaderEvent, kind)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// The body is read so the connection can be used again, only so much of it is
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("the receiver answered %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return resp.StatusCode, nil
+}
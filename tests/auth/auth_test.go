@@ -0,0 +1,87 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticate(t *testing.T) {
+	clock := time.Date(2026, 12, 31, 19, 0, 0, 0, time.UTC)
+	a := auth.New(auth.Options{
+		Keys:       []auth.APIKey{{Name: "front-door", Role: auth.RoleDoorStaff, Key: "door-key-0123456789"}},
+		Secret:     "0123456789abcdef0123456789abcdef",
+		SessionTTL: time.Hour,
+		Now:        func() time.Time { return clock },
+	})
+
+	request := func(header string, value string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/guests", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		return r
+	}
+
+	p, err := a.Authenticate(request("X-API-Key", "door-key-0123456789"))
+	assert.Nil(t, err)
+	assert.Equal(t, auth.Principal{Subject: "front-door", Role: auth.RoleDoorStaff, Method: auth.MethodAPIKey}, p)
+	assert.True(t, p.Allowed(auth.RoleOrganiser, auth.RoleDoorStaff))
+	assert.False(t, p.Allowed(auth.RoleOrganiser))
+
+	token, expires, err := a.Issue(p)
+	assert.Nil(t, err)
+	assert.Equal(t, clock.Add(time.Hour), expires)
+
+	p, err = a.Authenticate(request("Authorization", "Bearer "+token))
+	assert.Nil(t, err)
+	assert.Equal(t, auth.Principal{Subject: "front-door", Role: auth.RoleDoorStaff, Method: auth.MethodSession}, p)
+
+	// A token signed with another key, or whose role was changed, is turned away
+	other := auth.New(auth.Options{Secret: "another-key-another-key-another-k", SessionTTL: time.Hour})
+	forged, _, _ := other.Issue(auth.Principal{Subject: "front-door", Role: auth.RoleOrganiser})
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+
+	for name, r := range map[string]*http.Request{
+		"No credentials":  request("", ""),
+		"Unknown key":     request("X-API-Key", "door-key-01234567"),
+		"Other signature": request("Authorization", "Bearer "+forged),
+		"Changed claims":  request("Authorization", "Bearer "+tampered),
+		"Not a token":     request("Authorization", "Bearer door-key-0123456789"),
+	} {
+		_, err := a.Authenticate(r)
+		assert.True(t, errors.Is(err, auth.ErrUnauthenticated), name)
+	}
+
+	// A session takes on the current role of its key, and ends when the key is removed
+	downgraded := auth.New(auth.Options{
+		Keys:       []auth.APIKey{{Name: "front-door", Role: auth.RoleCaterer, Key: "door-key-0123456789"}},
+		Secret:     "0123456789abcdef0123456789abcdef",
+		SessionTTL: time.Hour,
+		Now:        func() time.Time { return clock },
+	})
+	p, err = downgraded.Authenticate(request("Authorization", "Bearer "+token))
+	assert.Nil(t, err)
+	assert.Equal(t, auth.RoleCaterer, p.Role)
+	removed := auth.New(auth.Options{
+		Keys:       []auth.APIKey{{Name: "back-door", Role: auth.RoleDoorStaff, Key: "back-key-0123456789"}},
+		Secret:     "0123456789abcdef0123456789abcdef",
+		SessionTTL: time.Hour,
+		Now:        func() time.Time { return clock },
+	})
+	_, err = removed.Authenticate(request("Authorization", "Bearer "+token))
+	assert.True(t, errors.Is(err, auth.ErrUnauthenticated))
+	assert.Contains(t, err.Error(), "no longer exists")
+
+	clock = clock.Add(time.Hour)
+	_, err = a.Authenticate(request("Authorization", "Bearer "+token))
+	assert.True(t, errors.Is(err, auth.ErrUnauthenticated))
+	assert.Contains(t, err.Error(), "expired")
+}
@@ -0,0 +1,158 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a getenv function that reads from the map instead of the real environment
+func env(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := config.Load(nil, env(nil))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4000, cfg.Port)
+	assert.Equal(t, "mysql", cfg.Database.Driver)
+	assert.Contains(t, cfg.Database.DSN, "host.docker.internal")
+	assert.True(t, cfg.Features.RequestLogging)
+	assert.Equal(t, "unique", cfg.Guests.NamePolicy)
+	assert.Equal(t, "allow", cfg.Guests.WalkIns)
+	assert.Equal(t, 0, cfg.Guests.MaxWalkIns)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "party.yaml", "port: 5000\nlog_level: warn\ndatabase:\n  driver: sqlite\n  dsn: file.db\nguests:\n  overbook_allowance: 10\n")
+
+	t.Run("File over defaults", func(t *testing.T) {
+		cfg, err := config.Load([]string{"-config", yamlFile}, env(nil))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 5000, cfg.Port)
+		assert.Equal(t, "warn", cfg.LogLevel)
+		assert.Equal(t, "file.db", cfg.Database.DSN)
+		assert.Equal(t, 10, cfg.Guests.OverbookAllowance)
+		assert.Equal(t, "unique", cfg.Guests.NamePolicy)
+	})
+
+	t.Run("Environment over file", func(t *testing.T) {
+		cfg, err := config.Load(nil, env(map[string]string{"CONFIG_FILE": yamlFile, "PORT": "6000", "REQUEST_LOGGING": "false"}))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 6000, cfg.Port)
+		assert.Equal(t, "sqlite", cfg.Database.Driver)
+		assert.False(t, cfg.Features.RequestLogging)
+	})
+
+	t.Run("Flags over environment", func(t *testing.T) {
+		cfg, err := config.Load([]string{"-config", yamlFile, "-port", "7000", "-db-driver", "memory"}, env(map[string]string{"PORT": "6000"}))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 7000, cfg.Port)
+		assert.Equal(t, "memory", cfg.Database.Driver)
+	})
+
+	t.Run("TOML file", func(t *testing.T) {
+		tomlFile := writeFile(t, "party.toml", "port = 8000\n[database]\ndriver = \"memory\"\n")
+
+		cfg, err := config.Load([]string{"-config", tomlFile}, env(nil))
+
+		assert.Nil(t, err)
+		assert.Equal(t, 8000, cfg.Port)
+		assert.Equal(t, "memory", cfg.Database.Driver)
+		assert.Equal(t, "", cfg.Database.DSN)
+	})
+}
+
+func TestLoadValidation(t *testing.T) {
+	_, err := config.Load([]string{"-port", "0", "-db-driver", "oracle", "-log-level", "loud", "-overbook-allowance", "-5", "-guest-name-policy", "any", "-walk-ins", "maybe", "-max-walk-ins", "-1"}, env(nil))
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "port must be between 1 and 65535")
+	assert.Contains(t, err.Error(), "database.driver must be one of")
+	assert.Contains(t, err.Error(), "log_level must be")
+	assert.Contains(t, err.Error(), "guests.overbook_allowance must be between 0 and 100")
+	assert.Contains(t, err.Error(), "guests.name_policy must be one of")
+	assert.Contains(t, err.Error(), "guests.walk_ins must be one of")
+	assert.Contains(t, err.Error(), "guests.max_walk_ins must be at least 0")
+
+	_, err = config.Load(nil, env(map[string]string{"PORT": "abc"}))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "PORT")
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.DSN = "root:secret@tcp(db:3306)/party"
+
+	redacted := cfg.Redacted()
+
+	assert.Equal(t, "root:*****@tcp(db:3306)/party", redacted.Database.DSN)
+	assert.NotContains(t, redacted.String(), "secret")
+	assert.Equal(t, "root:secret@tcp(db:3306)/party", cfg.Database.DSN)
+}
+
+func TestLoadAuth(t *testing.T) {
+	cfg, err := config.Load(nil, env(nil))
+	assert.Nil(t, err)
+	assert.False(t, cfg.Auth.Enabled)
+	assert.Equal(t, 720, cfg.Auth.SessionMinutes)
+
+	// The keys only have to be right once auth is on
+	_, err = config.Load([]string{"-auth", "true", "-api-keys", "door:bouncer:short,door:door_staff:short"}, env(nil))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "auth.signing_key must be at least 32 characters long")
+	assert.Contains(t, err.Error(), "auth.api_keys[0].role must be one of organiser, door_staff, caterer")
+	assert.Contains(t, err.Error(), "auth.api_keys[0].key must be unique and at least 16 characters long")
+	assert.Contains(t, err.Error(), "auth.api_keys[1].name must be set and unique")
+	assert.NotContains(t, err.Error(), "short")
+
+	cfg, err = config.Load(nil, env(map[string]string{
+		"AUTH_ENABLED":        "true",
+		"SESSION_SIGNING_KEY": "0123456789abcdef0123456789abcdef",
+		"API_KEYS":            "front-door:door_staff:door-key-0123456789, office:organiser:office:key:0123456789",
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(cfg.Auth.Keys()))
+	assert.Equal(t, "office:key:0123456789", cfg.Auth.Keys()[1].Key)
+
+	redacted := cfg.Redacted().String()
+	assert.NotContains(t, redacted, "door-key")
+	assert.NotContains(t, redacted, "0123456789abcdef")
+	assert.Equal(t, "door-key-0123456789", cfg.Auth.APIKeys[0].Key)
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	cfg, err := config.Load(nil, env(nil))
+	assert.Nil(t, err)
+	assert.True(t, cfg.Webhooks.Enabled)
+	assert.Equal(t, 8, cfg.Webhooks.Options().MaxAttempts)
+	assert.Equal(t, 30*time.Second, cfg.Webhooks.Options().RetryDelay)
+
+	cfg, err = config.Load([]string{"-webhooks", "false", "-webhook-timeout-seconds", "5"}, env(map[string]string{"WEBHOOK_MAX_ATTEMPTS": "3"}))
+	assert.Nil(t, err)
+	assert.False(t, cfg.Webhooks.Enabled)
+	assert.Equal(t, 3, cfg.Webhooks.MaxAttempts)
+	assert.Equal(t, 5*time.Second, cfg.Webhooks.Options().Timeout)
+
+	_, err = config.Load([]string{"-webhook-max-attempts", "0", "-webhook-retry-seconds", "0", "-webhook-timeout-seconds", "61"}, env(nil))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "webhooks.max_attempts must be between 1 and 20, got 0")
+	assert.Contains(t, err.Error(), "webhooks.retry_seconds must be at least 1, got 0")
+	assert.Contains(t, err.Error(), "webhooks.timeout_seconds must be between 1 and 60, got 61")
+}
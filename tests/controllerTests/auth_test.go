@@ -0,0 +1,181 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/config"
+	"github.com/getground/tech-tasks/backend/pkg/controller"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/server"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorization(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	store := repository.NewMemoryStore()
+	store.Events.Save(model.Event{Id: 1, Name: "Gala"})
+	store.Tables.Save(model.Table{Id: 1, Event_ID: 1, Capacity: 10})
+
+	cfg := config.Default()
+	cfg.Features.RequestLogging = false
+	cfg.Auth.Enabled = true
+	cfg.Auth.SigningKey = "0123456789abcdef0123456789abcdef"
+	cfg.Auth.APIKeys = []config.APIKeyConfig{
+		{Name: "office", Role: "organiser", Key: "organiser-key-0123"},
+		{Name: "front-door", Role: "door_staff", Key: "door-staff-key-0123"},
+		{Name: "kitchen", Role: "caterer", Key: "caterer-key-012345"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	router, err := server.New(ctx, store, cfg)
+	assert.Nil(t, err)
+
+	send := func(method string, path string, key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The order matters, the organiser adds the guests the door staff check in
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"Nobody may read without a key", "", http.MethodGet, "/guest_list", "", http.StatusUnauthorized},
+		{"A wrong key is turned away", "guessed-key-012345", http.MethodGet, "/guest_list", "", http.StatusUnauthorized},
+		{"Unknown events are not given away", "", http.MethodGet, "/events/42/tables", "", http.StatusUnauthorized},
+		{"Caterers read the guest list", "caterer-key-012345", http.MethodGet, "/guest_list", "", http.StatusOK},
+		{"Caterers read the exports", "caterer-key-012345", http.MethodGet, "/export/guests", "", http.StatusOK},
+		{"Caterers may not add guests", "caterer-key-012345", http.MethodPost, "/guest_list/John", `{"table_id": 1}`, http.StatusForbidden},
+		{"Door staff may not add guests", "door-staff-key-0123", http.MethodPost, "/guest_list/John", `{"table_id": 1}`, http.StatusForbidden},
+		{"Organisers add guests", "organiser-key-0123", http.MethodPost, "/guest_list/John", `{"table_id": 1}`, http.StatusCreated},
+		{"Organisers add guests to other events", "organiser-key-0123", http.MethodPost, "/events/1/guest_list/Sara", `{"table_id": 1}`, http.StatusCreated},
+		{"Caterers may not check guests in", "caterer-key-012345", http.MethodPut, "/guests/John", `{}`, http.StatusForbidden},
+		{"Door staff check guests in", "door-staff-key-0123", http.MethodPut, "/guests/John", `{}`, http.StatusCreated},
+		{"Door staff check guests out", "door-staff-key-0123", http.MethodDelete, "/guests/John", "", http.StatusNoContent},
+		{"Door staff may not edit the guest list", "door-staff-key-0123", http.MethodPatch, "/guest_list/John", `{"name": "Jon"}`, http.StatusForbidden},
+		{"Door staff may not remove guests", "door-staff-key-0123", http.MethodDelete, "/guest_list/John", "", http.StatusForbidden},
+		{"Door staff may not edit tables", "door-staff-key-0123", http.MethodPatch, "/tables/1", `{"capacity": 12}`, http.StatusForbidden},
+		{"Door staff may not add tables", "door-staff-key-0123", http.MethodPost, "/tables", `{"capacity": 4}`, http.StatusForbidden},
+		{"Caterers may not delete tables", "caterer-key-012345", http.MethodDelete, "/tables/1", "", http.StatusForbidden},
+		{"Caterers may not create events", "caterer-key-012345", http.MethodPost, "/events", `{"name": "Party"}`, http.StatusForbidden},
+		{"Organisers edit tables", "organiser-key-0123", http.MethodPatch, "/tables/1", `{"capacity": 12}`, http.StatusOK},
+		{"Organisers remove guests", "organiser-key-0123", http.MethodDelete, "/guest_list/John", "", http.StatusNoContent},
+		{"Door staff may not read the audit log", "door-staff-key-0123", http.MethodGet, "/audit", "", http.StatusForbidden},
+		{"Organisers read the audit log", "organiser-key-0123", http.MethodGet, "/audit", "", http.StatusOK},
+		{"Door staff may not manage webhooks", "door-staff-key-0123", http.MethodGet, "/webhooks", "", http.StatusForbidden},
+		{"Organisers manage webhooks", "organiser-key-0123", http.MethodGet, "/webhooks", "", http.StatusOK},
+		{"Nobody may follow the live stream without a key", "", http.MethodGet, "/events/stream", "", http.StatusUnauthorized},
+		{"API keys are not read from the query string", "", http.MethodGet, "/events/socket?access_token=caterer-key-012345", "", http.StatusUnauthorized},
+		{"The live stream only follows events that exist", "caterer-key-012345", http.MethodGet, "/events/stream?event_id=42", "", http.StatusNotFound},
+		{"The health check stays open", "", http.MethodGet, "/ping", "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := send(test.method, test.path, test.key, test.body)
+			assert.Equal(t, test.status, rr.Code, rr.Body.String())
+
+			var problem dto.ProblemResDto
+			switch test.status {
+			case http.StatusUnauthorized:
+				json.Unmarshal(rr.Body.Bytes(), &problem)
+				assert.Equal(t, "/problems/unauthenticated", problem.Type)
+				assert.NotEqual(t, "", rr.Header().Get("WWW-Authenticate"))
+			case http.StatusForbidden:
+				json.Unmarshal(rr.Body.Bytes(), &problem)
+				assert.Equal(t, "/problems/forbidden", problem.Type)
+			}
+		})
+	}
+
+	t.Run("Sessions", func(t *testing.T) {
+		rr := send(http.MethodPost, "/auth/sessions", "door-staff-key-0123", "")
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var session dto.SessionResDto
+		json.Unmarshal(rr.Body.Bytes(), &session)
+		assert.Equal(t, "door_staff", session.Role)
+		assert.NotEqual(t, "", session.Expires)
+
+		bearer := func(method string, path string, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+session.Token)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr
+		}
+
+		rr = bearer(http.MethodGet, "/auth/session", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var current dto.SessionResDto
+		json.Unmarshal(rr.Body.Bytes(), &current)
+		assert.Equal(t, dto.SessionResDto{Subject: "front-door", Role: "door_staff", Method: "session"}, current)
+
+		// The session has the role of the key it was opened with
+		assert.Equal(t, http.StatusCreated, bearer(http.MethodPut, "/guests/Sara", `{}`).Code)
+		assert.Equal(t, http.StatusForbidden, bearer(http.MethodDelete, "/guest_list/Sara", "").Code)
+		// Browsers send the session in the query string of the live stream, the unknown event shows it got past auth
+		assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/events/stream?event_id=42&access_token="+session.Token, "", "").Code)
+		// A session can not be renewed by itself
+		assert.Equal(t, http.StatusForbidden, bearer(http.MethodPost, "/auth/sessions", "").Code)
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		// The audit log names the key that made each change
+		rr := send(http.MethodGet, "/audit?entity=guest&sort=id", "organiser-key-0123", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var entries []dto.AuditResDto
+		json.Unmarshal(rr.Body.Bytes(), &entries)
+		var actors []string
+		for _, v := range entries {
+			actors = append(actors, v.Actor+" "+v.Role+" "+v.Action)
+		}
+		assert.Equal(t, []string{
+			"office organiser guest.created",
+			"office organiser guest.created",
+			"front-door door_staff guest.checked_in",
+			"front-door door_staff guest.checked_out",
+			"office organiser guest.deleted",
+			// A session is recorded under the key it was opened with
+			"front-door door_staff guest.checked_in",
+		}, actors)
+	})
+}
+
+func TestRequestLoggerHidesTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logged bytes.Buffer
+	defer func(w io.Writer) { gin.DefaultWriter = w }(gin.DefaultWriter)
+	gin.DefaultWriter = &logged
+
+	router := gin.New()
+	router.Use(controller.RequestLogger())
+	router.GET("/events/stream", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/events/stream?event_id=1&access_token=header.claims.signature&table_id=2", nil))
+
+	assert.Contains(t, logged.String(), "/events/stream?event_id=1&access_token=*****&table_id=2")
+	assert.NotContains(t, logged.String(), "header.claims.signature")
+}
@@ -0,0 +1,91 @@
+package controller_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/controller"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/getground/tech-tasks/backend/tests/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const tableCapacity = 50
+
+// Sends a check-in for every guest of a table at once, only the guests the table has seats for get in
+func checkinConcurrently(t *testing.T, store repository.Store, guestCount int) {
+	gin.SetMode(gin.TestMode)
+
+	event, err := store.Events.Save(model.Event{Name: "Gala"})
+	assert.Nil(t, err)
+	table, err := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: tableCapacity})
+	assert.Nil(t, err)
+	for i := 1; i <= guestCount; i++ {
+		_, err := store.Guests.Save(model.Guest{Event_ID: event.Id, Name: fmt.Sprintf("guest%d", i), Table_ID: table.Id})
+		assert.Nil(t, err)
+	}
+
+	eventController := controller.NewEventController(service.NewEventService(store.Events, service.EventOptions{}), event.Id)
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, service.GuestOptions{})
+	guestController := controller.NewGuestController(guestService, validation.NewGuestValidator(store.Tables))
+
+	router := gin.New()
+	router.Use(controller.ErrorHandler)
+	router.PUT("/guests/:guest", eventController.Scope, guestController.Checkin)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	statuses := make(map[int]int)
+
+	for i := 1; i <= guestCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/guests/guest%d", i), strings.NewReader(`{"accompanying_guests": 0}`))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			mu.Lock()
+			statuses[rr.Code]++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	table, _ = store.Tables.FindById(event.Id, table.Id)
+	arrived, _ := store.Guests.GetArrivedGuests(event.Id)
+
+	assert.Equal(t, tableCapacity, statuses[http.StatusCreated])
+	assert.Equal(t, guestCount-tableCapacity, statuses[http.StatusConflict])
+	assert.Equal(t, tableCapacity, table.Capacity)
+	assert.Equal(t, tableCapacity, table.Occupied)
+	assert.Equal(t, 0, table.Free())
+	assert.Equal(t, tableCapacity, len(arrived))
+}
+
+func TestConcurrentCheckinNeverOversellsTable(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			checkinConcurrently(t, backend.Store, 300)
+		})
+	}
+}
+
+// SQLite runs one transaction at a time, MySQL runs the check-ins side by side,
+// so only the row locks taken on the table and its guests keep it from being oversold.
+// Run with TEST_MYSQL_DSN set, for example to the database started by make docker-up.
+func TestConcurrentCheckinNeverOversellsTableMySQL(t *testing.T) {
+	backend := testutil.MySQL(t)
+
+	// Kept under MySQL's default limit of 151 connections, every check-in holds one
+	checkinConcurrently(t, backend.Store, 120)
+}
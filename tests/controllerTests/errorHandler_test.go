@@ -0,0 +1,99 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/controller"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorHandler(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	// Answers every request with the error, wrapped the way the services wrap them
+	respond := func(err error, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(controller.ErrorHandler)
+		router.GET("/fail", func(ctx *gin.Context) {
+			ctx.Error(err)
+			if handler != nil {
+				handler(ctx)
+			}
+		})
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/fail", nil))
+		return rr
+	}
+
+	cases := []struct {
+		err    error
+		status int
+		typ    string
+	}{
+		{controller.ErrMalformedRequest, http.StatusBadRequest, "/problems/malformed-request"},
+		{service.ErrEventNotFound, http.StatusNotFound, "/problems/event-not-found"},
+		{service.ErrTableNotFound, http.StatusNotFound, "/problems/table-not-found"},
+		{service.ErrGuestNotFound, http.StatusNotFound, "/problems/guest-not-found"},
+		{service.ErrOverbooked, http.StatusConflict, "/problems/overbooked"},
+		{service.ErrOverCapacity, http.StatusConflict, "/problems/over-capacity"},
+		{service.ErrAlreadyCheckedIn, http.StatusConflict, "/problems/already-checked-in"},
+		{service.ErrGuestNotPresent, http.StatusConflict, "/problems/guest-not-present"},
+		{service.ErrDuplicateName, http.StatusConflict, "/problems/duplicate-name"},
+		{service.ErrAmbiguousGuest, http.StatusConflict, "/problems/ambiguous-guest"},
+		{service.ErrInvalidEvent, http.StatusUnprocessableEntity, "/problems/invalid-event"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.typ, func(t *testing.T) {
+			err := fmt.Errorf("%w: details", c.err)
+			rr := respond(err, nil)
+
+			var res dto.ProblemResDto
+			assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			assert.Equal(t, c.status, rr.Code)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, c.typ, res.Type)
+			assert.Equal(t, c.status, res.Status)
+			assert.Equal(t, err.Error(), res.Detail)
+			assert.Equal(t, "/fail", res.Instance)
+		})
+	}
+
+	t.Run("Ambiguous names list the candidates", func(t *testing.T) {
+		rr := respond(&service.AmbiguousGuestError{Name: "John", Candidates: []dto.GuestResDto{{Id: "g_1"}, {Id: "g_2"}}}, nil)
+
+		var res dto.ProblemResDto
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, 2, len(res.Candidates))
+	})
+
+	t.Run("Unexpected errors are hidden", func(t *testing.T) {
+		rr := respond(errors.New("connection refused"), nil)
+
+		var res dto.ProblemResDto
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Equal(t, "about:blank", res.Type)
+		assert.NotContains(t, rr.Body.String(), "connection refused")
+	})
+
+	t.Run("A written response is left alone", func(t *testing.T) {
+		rr := respond(service.ErrGuestNotFound, func(ctx *gin.Context) {
+			ctx.String(http.StatusTeapot, "written")
+		})
+
+		assert.Equal(t, http.StatusTeapot, rr.Code)
+		assert.Equal(t, "written", rr.Body.String())
+	})
+}
@@ -1 +1,206 @@
 package controller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/controller"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateGuest(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	// Adds ten parties of four to a table of four, then returns the response codes
+	bookParties := func(overbookAllowance int) []int {
+		store := repository.NewMemoryStore()
+		store.Events.Save(model.Event{Id: 1, Name: "Gala", OverbookAllowance: overbookAllowance})
+		store.Tables.Save(model.Table{Id: 1, Event_ID: 1, Capacity: 4})
+
+		eventController := controller.NewEventController(service.NewEventService(store.Events, service.EventOptions{}), 1)
+		guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, service.GuestOptions{})
+		guestController := controller.NewGuestController(guestService, validation.NewGuestValidator(store.Tables))
+
+		router := gin.New()
+		router.Use(controller.ErrorHandler)
+		router.POST("/guest_list/:name", eventController.Scope, guestController.CreateGuest)
+
+		var codes []int
+		for i := 1; i <= 10; i++ {
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/guest_list/party%d", i), strings.NewReader(`{"table_id": 1, "accompanying_guests": 3}`))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			codes = append(codes, rr.Code)
+		}
+		return codes
+	}
+
+	t.Run("Rejects overbooking", func(t *testing.T) {
+		codes := bookParties(0)
+
+		assert.Equal(t, http.StatusCreated, codes[0])
+		for _, code := range codes[1:] {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	})
+
+	t.Run("Allows the overbook allowance", func(t *testing.T) {
+		// 100% on a table of four allows eight seats to be booked
+		codes := bookParties(100)
+
+		assert.Equal(t, http.StatusCreated, codes[0])
+		assert.Equal(t, http.StatusCreated, codes[1])
+		for _, code := range codes[2:] {
+			assert.Equal(t, http.StatusConflict, code)
+		}
+	})
+}
+
+func TestGuestNames(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(namePolicy string) *gin.Engine {
+		store := repository.NewMemoryStore()
+		store.Events.Save(model.Event{Id: 1, Name: "Gala"})
+		store.Tables.Save(model.Table{Id: 1, Event_ID: 1, Capacity: 10})
+
+		eventController := controller.NewEventController(service.NewEventService(store.Events, service.EventOptions{}), 1)
+		guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, service.GuestOptions{NamePolicy: namePolicy})
+		guestController := controller.NewGuestController(guestService, validation.NewGuestValidator(store.Tables))
+
+		router := gin.New()
+		router.Use(controller.ErrorHandler)
+		router.POST("/guest_list/:name", eventController.Scope, guestController.CreateGuest)
+		router.GET("/guests/:guest", eventController.Scope, guestController.GetAGuest)
+		router.PUT("/guests/:guest", eventController.Scope, guestController.Checkin)
+		router.DELETE("/guests/:guest", eventController.Scope, guestController.Checkout)
+		return router
+	}
+
+	send := func(router *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Unique names", func(t *testing.T) {
+		router := newRouter(service.NamePolicyUnique)
+
+		assert.Equal(t, http.StatusCreated, send(router, http.MethodPost, "/guest_list/John", `{"table_id": 1}`).Code)
+		assert.Equal(t, http.StatusConflict, send(router, http.MethodPost, "/guest_list/John", `{"table_id": 1}`).Code)
+		assert.Equal(t, http.StatusCreated, send(router, http.MethodPost, "/guest_list/john", `{"table_id": 1}`).Code)
+	})
+
+	t.Run("Unique names ignoring case", func(t *testing.T) {
+		router := newRouter(service.NamePolicyUniqueIgnoreCase)
+
+		assert.Equal(t, http.StatusCreated, send(router, http.MethodPost, "/guest_list/John", `{"table_id": 1}`).Code)
+		assert.Equal(t, http.StatusConflict, send(router, http.MethodPost, "/guest_list/john", `{"table_id": 1}`).Code)
+	})
+
+	t.Run("Duplicate names are told apart by id", func(t *testing.T) {
+		router := newRouter(service.NamePolicyAllowDuplicates)
+
+		var first, second dto.GuestResDto
+		rr := send(router, http.MethodPost, "/guest_list/John", `{"table_id": 1}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), &first)
+		rr = send(router, http.MethodPost, "/guest_list/John", `{"table_id": 1, "accompanying_guests": 1}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), &second)
+		assert.True(t, model.IsGuestPublicId(first.Id))
+		assert.NotEqual(t, first.Id, second.Id)
+
+		// The name alone is ambiguous, the candidates are listed
+		rr = send(router, http.MethodPut, "/guests/John", `{"accompanying_guests": 0}`)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		var conflict struct {
+			Candidates []dto.GuestResDto `json:"candidates"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &conflict)
+		assert.Equal(t, 2, len(conflict.Candidates))
+		assert.Equal(t, first.Id, conflict.Candidates[0].Id)
+		assert.Equal(t, second.Id, conflict.Candidates[1].Id)
+
+		assert.Equal(t, http.StatusConflict, send(router, http.MethodDelete, "/guests/John", "").Code)
+
+		// The id always finds the one guest
+		assert.Equal(t, http.StatusCreated, send(router, http.MethodPut, "/guests/"+second.Id, `{"accompanying_guests": 1}`).Code)
+		var found dto.GuestResDto
+		rr = send(router, http.MethodGet, "/guests/"+second.Id, "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), &found)
+		assert.Equal(t, 1, found.Acompanying_Guests)
+		assert.NotEqual(t, "", found.TimeArrived)
+
+		// Guests who have left stay on the guest list, so the name is still ambiguous
+		assert.Equal(t, http.StatusNoContent, send(router, http.MethodDelete, "/guests/"+second.Id, "").Code)
+		assert.Equal(t, http.StatusConflict, send(router, http.MethodGet, "/guests/John", "").Code)
+
+		assert.Equal(t, http.StatusNotFound, send(router, http.MethodGet, "/guests/Nobody", "").Code)
+		assert.Equal(t, http.StatusNotFound, send(router, http.MethodGet, "/guests/"+model.NewGuestPublicId(), "").Code)
+	})
+}
+
+func TestGetArrivedGuests(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	store := repository.NewMemoryStore()
+	store.Events.Save(model.Event{Id: 1, Name: "Gala"})
+	store.Tables.Save(model.Table{Id: 1, Event_ID: 1, Capacity: 10})
+
+	eventController := controller.NewEventController(service.NewEventService(store.Events, service.EventOptions{}), 1)
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, service.GuestOptions{})
+	guestController := controller.NewGuestController(guestService, validation.NewGuestValidator(store.Tables))
+
+	router := gin.New()
+	router.Use(controller.ErrorHandler)
+	router.POST("/guest_list/:name", eventController.Scope, guestController.CreateGuest)
+	router.PUT("/guests/:guest", eventController.Scope, guestController.Checkin)
+	router.GET("/guests", eventController.Scope, guestController.GetArrivedGuests)
+
+	send := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Nobody has arrived yet, the page is empty rather than null
+	rr := send(http.MethodGet, "/guests", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", strings.TrimSpace(rr.Body.String()))
+
+	assert.Equal(t, http.StatusCreated, send(http.MethodPost, "/guest_list/John", `{"table_id": 1, "aliases": ["Johnny"], "accompanying_guests": 2}`).Code)
+	assert.Equal(t, http.StatusCreated, send(http.MethodPut, "/guests/John", `{"accompanying_guests": 2}`).Code)
+
+	var guests []map[string]interface{}
+	rr = send(http.MethodGet, "/guests", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &guests))
+	assert.Equal(t, 1, len(guests))
+	assert.Equal(t, "John", guests[0]["name"])
+	assert.Equal(t, float64(1), guests[0]["table_id"])
+	assert.Equal(t, []interface{}{"Johnny"}, guests[0]["aliases"])
+	assert.NotEqual(t, nil, guests[0]["time_arrived"])
+
+	// Filtering by another table leaves an empty page
+	rr = send(http.MethodGet, "/guests?table_id=2", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "[]", strings.TrimSpace(rr.Body.String()))
+}
@@ -12,7 +12,7 @@ type MockTableService struct {
 	tableMock mock.Mock
 }
 
-func (s *MockTableService) FindAll() ([]dto.TableResDto, error) {
+func (s *MockTableService) FindAll(eventId int) ([]dto.TableResDto, error) {
 	args := s.tableMock.Called()
 	if args.Error(1) != nil {
 		return []dto.TableResDto{}, args.Error(1)
@@ -20,7 +20,7 @@ func (s *MockTableService) FindAll() ([]dto.TableResDto, error) {
 	return args.Get(0).([]dto.TableResDto), nil
 }
 
-func (s *MockTableService) FindById(id int) (dto.TableResDto, error) {
+func (s *MockTableService) FindById(eventId int, id int) (dto.TableResDto, error) {
 	args := s.tableMock.Called(id)
 	if args.Error(1) != nil {
 		return dto.TableResDto{}, args.Error(1)
@@ -28,7 +28,7 @@ func (s *MockTableService) FindById(id int) (dto.TableResDto, error) {
 	return args.Get(0).(dto.TableResDto), nil
 }
 
-func (s *MockTableService) Save(req dto.TableReqDto) (dto.TableResDto, error) {
+func (s *MockTableService) Save(eventId int, req dto.TableReqDto) (dto.TableResDto, error) {
 	args := s.tableMock.Called(req)
 	if args.Error(1) != nil {
 		return dto.TableResDto{}, args.Error(1)
@@ -36,12 +36,12 @@ func (s *MockTableService) Save(req dto.TableReqDto) (dto.TableResDto, error) {
 	return args.Get(0).(dto.TableResDto), nil
 }
 
-func (s *MockTableService) CheckSpace() (int, bool) {
+func (s *MockTableService) CheckSpace(eventId int) (dto.SeatsResDto, bool) {
 	args := s.tableMock.Called()
 	if args.Bool(1) == false {
-		return 0, args.Bool(1)
+		return dto.SeatsResDto{}, args.Bool(1)
 	}
-	return args.Int(0), true
+	return args.Get(0).(dto.SeatsResDto), true
 }
 
 // var (
@@ -0,0 +1,105 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/controller"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidation(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	store := repository.NewMemoryStore()
+	store.Events.Save(model.Event{Id: 1, Name: "Gala"})
+	store.Tables.Save(model.Table{Id: 1, Event_ID: 1, Capacity: 4})
+
+	eventService := service.NewEventService(store.Events, service.EventOptions{})
+	eventController := controller.NewEventController(eventService, 1)
+	tableController := controller.NewTableController(service.NewTableService(store.Events, store.Tables, store.UnitOfWork, service.GuestOptions{}))
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, service.GuestOptions{})
+	guestController := controller.NewGuestController(guestService, validation.NewGuestValidator(store.Tables))
+
+	router := gin.New()
+	router.Use(controller.ErrorHandler)
+	router.POST("/events", eventController.CreateEvent)
+	router.POST("/tables", eventController.Scope, tableController.CreateTable)
+	router.POST("/guest_list/:name", eventController.Scope, guestController.CreateGuest)
+	router.PUT("/guests/:guest", eventController.Scope, guestController.Checkin)
+
+	tests := []struct {
+		name   string
+		path   string
+		method string
+		body   string
+		errors []dto.FieldErrorResDto
+	}{
+		{"Negative accompanying guests", "/guest_list/John", http.MethodPost, `{"table_id": 1, "accompanying_guests": -1}`,
+			[]dto.FieldErrorResDto{{Field: "accompanying_guests", Message: "must be at least 0"}}},
+		{"Missing table", "/guest_list/John", http.MethodPost, `{}`,
+			[]dto.FieldErrorResDto{{Field: "table_id", Message: "is required"}}},
+		{"Unknown table", "/guest_list/John", http.MethodPost, `{"table_id": 7}`,
+			[]dto.FieldErrorResDto{{Field: "table_id", Message: "table 7 does not exist"}}},
+		{"Every broken field is listed", "/guest_list/%3Cb%3E", http.MethodPost, `{"table_id": 7, "accompanying_guests": 500}`,
+			[]dto.FieldErrorResDto{
+				{Field: "name", Message: "must contain a letter and only letters, digits, spaces, apostrophes, hyphens, full stops and ampersands"},
+				{Field: "accompanying_guests", Message: "must be at most 100"},
+				{Field: "table_id", Message: "table 7 does not exist"},
+			}},
+		{"Name without letters", "/guest_list/123", http.MethodPost, `{"table_id": 1}`,
+			[]dto.FieldErrorResDto{{Field: "name", Message: "must contain a letter and only letters, digits, spaces, apostrophes, hyphens, full stops and ampersands"}}},
+		{"Long name", "/guest_list/" + strings.Repeat("a", 101), http.MethodPost, `{"table_id": 1}`,
+			[]dto.FieldErrorResDto{{Field: "name", Message: "must be at most 100 characters long"}}},
+		{"Negative accompanying guests at check-in", "/guests/John", http.MethodPut, `{"accompanying_guests": -2}`,
+			[]dto.FieldErrorResDto{{Field: "accompanying_guests", Message: "must be at least 0"}}},
+		{"Table without seats", "/tables", http.MethodPost, `{"capacity": 0}`,
+			[]dto.FieldErrorResDto{{Field: "capacity", Message: "must be at least 1"}}},
+		{"Event without a name that ends before it starts", "/events", http.MethodPost,
+			`{"start_time": "2026-12-31T20:00:00Z", "end_time": "2026-12-31T19:00:00Z", "overbook_allowance": -5}`,
+			[]dto.FieldErrorResDto{
+				{Field: "name", Message: "is required"},
+				{Field: "end_time", Message: "must not be before start_time"},
+				{Field: "overbook_allowance", Message: "must be at least 0"},
+			}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			var problem dto.ProblemResDto
+			json.Unmarshal(rr.Body.Bytes(), &problem)
+			assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			assert.Equal(t, "/problems/validation-failed", problem.Type)
+			assert.Equal(t, test.errors, problem.Errors)
+		})
+	}
+
+	// Nothing that failed validation reached the services
+	guests, _ := store.Guests.FindAll(1)
+	assert.Equal(t, 0, len(guests))
+	tables, _ := store.Tables.FindAll(1)
+	assert.Equal(t, 1, len(tables))
+
+	t.Run("Valid names", func(t *testing.T) {
+		for _, name := range []string{"Zoë", "Mary-Jane O'Neil", "Dr. Who", "Mr %26 Mrs Smith", "Guest 2"} {
+			req := httptest.NewRequest(http.MethodPost, "/guest_list/"+strings.ReplaceAll(name, " ", "%20"), strings.NewReader(`{"table_id": 1}`))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.NotEqual(t, http.StatusUnprocessableEntity, rr.Code, name)
+		}
+	})
+}
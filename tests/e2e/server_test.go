@@ -0,0 +1,1249 @@
+// These tests boot the whole server on every storage backend and drive it over HTTP
+package e2e_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/config"
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/hub"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/server"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/webhook"
+	"github.com/getground/tech-tasks/backend/tests/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// Sends the request to the server and decodes the JSON response body into res, when res is not nil
+func call(t *testing.T, srv *httptest.Server, method string, path string, body interface{}, res interface{}) int {
+	t.Helper()
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reqBody).Encode(body)
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, &reqBody)
+	assert.Nil(t, err)
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if res != nil {
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(res))
+	}
+
+	return resp.StatusCode
+}
+
+// Starts the whole server on the backend with the default configuration
+func newServer(t *testing.T, backend testutil.Backend) *httptest.Server {
+	t.Helper()
+
+	// The background work of the server stops with the test
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	router, err := server.New(ctx, backend.Store, config.Default())
+	if err != nil {
+		t.Fatalf("could not build the server: %v", err)
+	}
+	return httptest.NewServer(router)
+}
+
+func TestPartyFlow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/ping", nil, nil))
+
+			// Before the party
+			var table dto.TableResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &table))
+			assert.NotEqual(t, 0, table.Id)
+			assert.Equal(t, 4, table.Capacity)
+
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/guest_list/Echez",
+				dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, nil))
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPost, "/guest_list/John",
+				dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 3}, nil))
+
+			var guestList []dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guest_list", nil, &guestList)
+			assert.Equal(t, 1, len(guestList))
+			assert.Equal(t, "Echez", guestList[0].Name)
+
+			// During the party
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/Echez",
+				dto.GuestReqDto{Acompanying_Guests: 1}, nil))
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPut, "/guests/Echez",
+				dto.GuestReqDto{Acompanying_Guests: 1}, nil))
+
+			// Failures are answered with problem details
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodPut, "/guests/Nobody", dto.GuestReqDto{}, &problem))
+			assert.Equal(t, "/problems/guest-not-found", problem.Type)
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, "/tables/1000", nil, &problem))
+			assert.Equal(t, "/problems/table-not-found", problem.Type)
+			assert.Equal(t, http.StatusBadRequest, call(t, srv, http.MethodPost, "/tables", "four", &problem))
+			assert.Equal(t, "/problems/malformed-request", problem.Type)
+
+			var seats dto.SeatsResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/seats_empty", nil, &seats))
+			assert.Equal(t, 2, seats.SeatsEmpty)
+			assert.Equal(t, []dto.TableResDto{{Id: table.Id, Capacity: 4, Reserved: 2, Arrived: 2, Free: 2}}, seats.Tables)
+
+			var arrived []dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guests", nil, &arrived)
+			assert.Equal(t, 1, len(arrived))
+			_, err := time.Parse(time.RFC3339, arrived[0].TimeArrived)
+			assert.Nil(t, err)
+
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guests/Echez", nil, nil))
+
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/seats_empty", nil, &seats))
+			assert.Equal(t, 4, seats.SeatsEmpty)
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodDelete, "/guests/Echez", nil, nil))
+
+			// Guests who left stay on the guest list and can come back
+			call(t, srv, http.MethodGet, "/guests", nil, &arrived)
+			assert.Equal(t, 0, len(arrived))
+			call(t, srv, http.MethodGet, "/guest_list", nil, &guestList)
+			assert.Equal(t, 1, len(guestList))
+
+			var departed []dto.GuestResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/guests/departed", nil, &departed))
+			assert.Equal(t, 1, len(departed))
+			assert.NotEqual(t, "", departed[0].TimeLeft)
+
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/Echez",
+				dto.GuestReqDto{Acompanying_Guests: 0}, nil))
+			call(t, srv, http.MethodGet, "/guests/departed", nil, &departed)
+			assert.Equal(t, 0, len(departed))
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/seats_empty", nil, &seats))
+			assert.Equal(t, 3, seats.SeatsEmpty)
+
+			var visits []dto.VisitResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/guests/Echez/visits", nil, &visits))
+			assert.Equal(t, 2, len(visits))
+			assert.Equal(t, 1, visits[0].Acompanying_Guests)
+			assert.NotEqual(t, "", visits[0].TimeLeft)
+			assert.Equal(t, 0, visits[1].Acompanying_Guests)
+			assert.Equal(t, "", visits[1].TimeLeft)
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			// The default event is created when the server starts
+			var events []dto.EventResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/events", nil, &events))
+			assert.Equal(t, 1, len(events))
+			assert.Equal(t, 1, events[0].Id)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/events", dto.EventReqDto{Name: ""}, nil))
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/events", dto.EventReqDto{Name: "Gala", Timezone: "Nowhere/Nowhere"}, nil))
+
+			var gala dto.EventResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/events", dto.EventReqDto{Name: "Gala", Venue: "Town hall", Timezone: "Europe/London"}, &gala))
+			assert.NotEqual(t, 1, gala.Id)
+			assert.Equal(t, "Europe/London", gala.Timezone)
+
+			galaPath := fmt.Sprintf("/events/%d", gala.Id)
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, galaPath, nil, &gala))
+			assert.Equal(t, "Gala", gala.Name)
+
+			// Tables and guests of one event are not seen by another
+			var table dto.TableResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, galaPath+"/tables", dto.TableReqDto{Capacity: 4}, &table))
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, galaPath+"/guest_list/Echez",
+				dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, nil))
+
+			var tables []dto.TableResDto
+			call(t, srv, http.MethodGet, galaPath+"/tables", nil, &tables)
+			assert.Equal(t, 1, len(tables))
+			call(t, srv, http.MethodGet, "/tables", nil, &tables)
+			assert.Equal(t, 0, len(tables))
+
+			var guestList []dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guest_list", nil, &guestList)
+			assert.Equal(t, 0, len(guestList))
+			// The other event's table is reported against the table_id field
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/guest_list/John",
+				dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, &problem))
+			assert.Equal(t, "/problems/validation-failed", problem.Type)
+			assert.Equal(t, []dto.FieldErrorResDto{{Field: "table_id", Message: fmt.Sprintf("table %d does not exist", table.Id)}}, problem.Errors)
+
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, "/events/1000", nil, nil))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, "/events/1000/tables", nil, nil))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, "/events/party/tables", nil, nil))
+		})
+	}
+}
+
+func TestEditing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var small, large dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &small)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 10}, &large)
+
+			var guest dto.GuestResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/guest_list/Echez",
+				dto.GuestReqDto{Table_ID: small.Id, Acompanying_Guests: 2}, &guest))
+
+			// Tables can not shrink below their reservations
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPatch, fmt.Sprintf("/tables/%d", small.Id), map[string]int{"capacity": 2}, &problem))
+			assert.Equal(t, "/problems/capacity-below-reservations", problem.Type)
+			var table dto.TableResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPut, fmt.Sprintf("/tables/%d", small.Id), dto.TableReqDto{Capacity: 3}, &table))
+			assert.Equal(t, 3, table.Capacity)
+			assert.Equal(t, 3, table.Reserved)
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodPatch, "/tables/1000", map[string]int{"capacity": 2}, nil))
+
+			// Renaming, growing the party and moving it to a table with room
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPatch, "/guest_list/"+guest.Id, map[string]int{"accompanying_guests": 3}, &problem))
+			assert.Equal(t, "/problems/overbooked", problem.Type)
+			var edited dto.GuestResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPatch, "/guest_list/Echez",
+				map[string]interface{}{"name": "Sara Echez", "table_id": large.Id, "accompanying_guests": 5}, &edited))
+			assert.Equal(t, guest.Id, edited.Id)
+			assert.Equal(t, "Sara Echez", edited.Name)
+			assert.Equal(t, large.Id, edited.Table_ID)
+			assert.Equal(t, 5, edited.Acompanying_Guests)
+			call(t, srv, http.MethodGet, fmt.Sprintf("/tables/%d", small.Id), nil, &table)
+			assert.Equal(t, 0, table.Reserved)
+			call(t, srv, http.MethodGet, fmt.Sprintf("/tables/%d", large.Id), nil, &table)
+			assert.Equal(t, 6, table.Reserved)
+
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPut, "/guest_list/"+guest.Id,
+				dto.GuestReqDto{Name: "Sara Echez", Table_ID: large.Id, Acompanying_Guests: 1}, &edited))
+			assert.Equal(t, 1, edited.Acompanying_Guests)
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPut, "/guest_list/"+guest.Id, dto.GuestReqDto{Table_ID: large.Id}, nil))
+
+			// A table with guests is only deleted when they are moved to another table
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodDelete, fmt.Sprintf("/tables/%d", large.Id), nil, &problem))
+			assert.Equal(t, "/problems/table-has-guests", problem.Type)
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodDelete, fmt.Sprintf("/tables/%d?reassign_to=%d", large.Id, large.Id), nil, nil))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodDelete, fmt.Sprintf("/tables/%d?reassign_to=1000", large.Id), nil, nil))
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, fmt.Sprintf("/tables/%d?reassign_to=%d", large.Id, small.Id), nil, nil))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, fmt.Sprintf("/tables/%d", large.Id), nil, nil))
+			var found dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guests/"+guest.Id, nil, &found)
+			assert.Equal(t, small.Id, found.Table_ID)
+
+			// Guests at the party check out before they are taken off the list
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/"+guest.Id, dto.CheckinReqDto{Acompanying_Guests: 1}, nil))
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodDelete, "/guest_list/"+guest.Id, nil, nil))
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guests/"+guest.Id, nil, nil))
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guest_list/"+guest.Id, nil, nil))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, "/guests/"+guest.Id, nil, nil))
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, fmt.Sprintf("/tables/%d", small.Id), nil, nil))
+		})
+	}
+}
+
+func TestListPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 20}, &table)
+			for _, name := range []string{"Sara", "Echez", "Ade", "Sam", "Bob"} {
+				call(t, srv, http.MethodPost, "/guest_list/"+name, dto.GuestReqDto{Table_ID: table.Id}, nil)
+			}
+
+			// Follows the next links from the first page and collects the names
+			var names []string
+			path := "/guest_list?sort=-name&limit=2&name_prefix=&table_id=" + fmt.Sprint(table.Id)
+			for path != "" {
+				resp, err := srv.Client().Get(srv.URL + path)
+				assert.Nil(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "5", resp.Header.Get("X-Total-Count"))
+
+				var guests []dto.GuestResDto
+				json.NewDecoder(resp.Body).Decode(&guests)
+				resp.Body.Close()
+				for _, v := range guests {
+					names = append(names, v.Name)
+				}
+
+				path = ""
+				for _, link := range strings.Split(resp.Header.Get("Link"), ", ") {
+					if strings.HasSuffix(link, `; rel="next"`) {
+						path = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+						assert.Contains(t, path, "sort=-name")
+					}
+				}
+			}
+			assert.Equal(t, []string{"Sara", "Sam", "Echez", "Bob", "Ade"}, names)
+
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusBadRequest, call(t, srv, http.MethodGet, "/guest_list?cursor=nonsense", nil, &problem))
+			assert.Equal(t, "/problems/invalid-cursor", problem.Type)
+			assert.Equal(t, http.StatusBadRequest, call(t, srv, http.MethodGet, "/guests?limit=ten", nil, &problem))
+			assert.Equal(t, "/problems/malformed-request", problem.Type)
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodGet, "/tables?sort=seats&limit=5000", nil, &problem))
+			assert.Equal(t, []dto.FieldErrorResDto{
+				{Field: "sort", Message: "must be one of id, -id, capacity, -capacity"},
+				{Field: "limit", Message: "must be at most 1000"},
+			}, problem.Errors)
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 20}, &table)
+			for _, guest := range []dto.GuestReqDto{
+				{Name: "Hannah Smith", Table_ID: table.Id, Acompanying_Guests: 2},
+				{Name: "Zoë Martin", Table_ID: table.Id},
+				{Name: "Elizabeth Jones", Aliases: []string{"Betty"}, Table_ID: table.Id, Acompanying_Guests: 1},
+				{Name: "Boris", Table_ID: table.Id},
+			} {
+				assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/guest_list/"+strings.ReplaceAll(guest.Name, " ", "%20"), guest, nil))
+			}
+
+			// Misspelt, without accents and by alias
+			var matches []dto.GuestMatchResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/guests/search?q=hanna", nil, &matches))
+			assert.Equal(t, 1, len(matches))
+			assert.Equal(t, "Hannah Smith", matches[0].Name)
+			assert.Equal(t, table.Id, matches[0].Table_ID)
+			assert.Equal(t, 2, matches[0].Acompanying_Guests)
+
+			call(t, srv, http.MethodGet, "/guests/search?q=zoe%20martin", nil, &matches)
+			assert.Equal(t, 1, len(matches))
+			assert.Equal(t, 1.0, matches[0].Score)
+
+			call(t, srv, http.MethodGet, "/guests/search?q=Bety", nil, &matches)
+			assert.Equal(t, 1, len(matches))
+			assert.Equal(t, "Elizabeth Jones", matches[0].Name)
+			assert.Equal(t, "Betty", matches[0].Matched)
+			assert.Equal(t, []string{"Betty"}, matches[0].Aliases)
+
+			call(t, srv, http.MethodGet, "/guests/search?q=nobody", nil, &matches)
+			assert.Equal(t, 0, len(matches))
+
+			// Aliases can be changed and taken away
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPatch, "/guest_list/Boris", map[string]interface{}{"aliases": []string{"Bobby"}}, nil))
+			call(t, srv, http.MethodGet, "/guests/search?q=bobby", nil, &matches)
+			assert.Equal(t, 1, len(matches))
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPatch, "/guest_list/Boris", map[string]interface{}{"aliases": []string{}}, nil))
+			call(t, srv, http.MethodGet, "/guests/search?q=bobby", nil, &matches)
+			assert.Equal(t, 0, len(matches))
+
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodGet, "/guests/search", nil, &problem))
+			assert.Equal(t, []dto.FieldErrorResDto{{Field: "q", Message: "is required"}}, problem.Errors)
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Posts the CSV file to the import route and decodes the response into res
+	upload := func(t *testing.T, srv *httptest.Server, query string, file string, res interface{}) int {
+		resp, err := srv.Client().Post(srv.URL+"/guest_list"+query, "text/csv", strings.NewReader(file))
+		if err != nil {
+			t.Fatalf("import failed: %v", err)
+		}
+		defer resp.Body.Close()
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(res))
+		return resp.StatusCode
+	}
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 6}, &table)
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/guest_list/Echez", dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, nil))
+
+			// The third party only fits if the rows above it are not counted, Echez is already on the list and table 99 does not exist
+			file := fmt.Sprintf("Full name,table_id,accompanying_guests\nSara,%[1]d,1\nJohn,%[1]d,0\nMary,%[1]d,1\nEchez,%[1]d,0\nBoris,99,0\n", table.Id)
+
+			var report dto.GuestImportResDto
+			assert.Equal(t, http.StatusOK, upload(t, srv, "?dry_run=true&column[name]=Full%20name", file, &report))
+			assert.True(t, report.DryRun)
+			assert.Equal(t, 5, report.Rows)
+			assert.Equal(t, 2, report.Imported)
+			assert.Equal(t, []dto.ImportRowErrorResDto{
+				{Row: 4, Name: "Mary", Errors: []dto.FieldErrorResDto{{Field: "table_id", Message: fmt.Sprintf("table is fully booked: table %d has 5 of 6 bookable seats reserved, 2 requested", table.Id)}}},
+				{Row: 5, Name: "Echez", Errors: []dto.FieldErrorResDto{{Field: "name", Message: `a guest with this name is already on the guest list: "Echez"`}}},
+				{Row: 6, Name: "Boris", Errors: []dto.FieldErrorResDto{{Field: "table_id", Message: "table 99 does not exist"}}},
+			}, report.Errors)
+
+			// Nothing is added while any row has an error
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusUnprocessableEntity, upload(t, srv, "?column[name]=Full%20name", file, &problem))
+			assert.Equal(t, "/problems/import-failed", problem.Type)
+			assert.Equal(t, 3, len(problem.Rows))
+			var guestList []dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guest_list", nil, &guestList)
+			assert.Equal(t, 1, len(guestList))
+
+			file = fmt.Sprintf("Full name,table_id,accompanying_guests\nSara,%[1]d,1\nJohn,%[1]d,0\n", table.Id)
+			assert.Equal(t, http.StatusCreated, upload(t, srv, "?column[name]=Full%20name", file, &report))
+			assert.False(t, report.DryRun)
+			assert.Equal(t, 2, report.Imported)
+			call(t, srv, http.MethodGet, "/guest_list", nil, &guestList)
+			assert.Equal(t, 3, len(guestList))
+
+			assert.Equal(t, http.StatusUnprocessableEntity, upload(t, srv, "", file, &problem))
+			assert.Equal(t, []dto.FieldErrorResDto{{Field: "column[name]", Message: `the header has no column "name"`}}, problem.Errors)
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Fetches the path and returns the status, the Content-Type and the body
+	download := func(t *testing.T, srv *httptest.Server, path string) (int, string, string) {
+		resp, err := srv.Client().Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), body.String()
+	}
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 6}, &table)
+			var guest dto.GuestResDto
+			call(t, srv, http.MethodPost, "/guest_list/Echez", dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, &guest)
+			call(t, srv, http.MethodPut, "/guests/Echez", dto.CheckinReqDto{Acompanying_Guests: 1}, nil)
+
+			var guests []dto.GuestExportResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/export/guests", nil, &guests))
+			assert.Equal(t, 1, len(guests))
+			assert.Equal(t, guest.Id, guests[0].Id)
+			assert.Equal(t, table.Id, guests[0].Table_ID)
+			assert.Equal(t, 2, guests[0].PartySize)
+			assert.NotEqual(t, "", guests[0].TimeArrived)
+
+			code, contentType, body := download(t, srv, "/export/guests?format=csv")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "text/csv; charset=utf-8", contentType)
+			lines := strings.Split(strings.TrimSpace(body), "\n")
+			assert.Equal(t, 2, len(lines))
+			assert.True(t, strings.HasPrefix(lines[1], fmt.Sprintf("%s,Echez,,%d,1,2,", guest.Id, table.Id)))
+
+			code, contentType, body = download(t, srv, "/export/seating_chart")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "application/pdf", contentType)
+			assert.True(t, strings.HasPrefix(body, "%PDF-"))
+			assert.Contains(t, body, "(Echez, party of 2, arrived ")
+
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodGet, "/export/guests?format=xml", nil, nil))
+		})
+	}
+}
+
+func TestSeatingPlan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var small, large dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &small)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 6}, &large)
+			call(t, srv, http.MethodPost, "/guest_list/Echez", dto.GuestReqDto{Table_ID: large.Id, Acompanying_Guests: 1}, nil)
+
+			// Four seats are left on each table, the parties of three and one share one and the party of four takes the other
+			req := dto.SeatingPlanReqDto{Parties: []dto.SeatingPartyReqDto{
+				{Name: "Sara", Acompanying_Guests: 2},
+				{Name: "John", Acompanying_Guests: 3},
+				{Name: "Mary"},
+			}}
+			var plan dto.SeatingPlanResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPost, "/seating/plan", req, &plan))
+			assert.Equal(t, "preview", plan.Mode)
+			assert.Equal(t, "exact", plan.Solver)
+			assert.Equal(t, 8, plan.Seated)
+			assert.Equal(t, 0, plan.WastedSeats)
+			assert.Equal(t, 3, len(plan.Assignments))
+			assert.Equal(t, plan.Assignments[0].Table_ID, plan.Assignments[2].Table_ID)
+			assert.NotEqual(t, plan.Assignments[0].Table_ID, plan.Assignments[1].Table_ID)
+
+			// A preview adds nothing
+			var guestList []dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guest_list", nil, &guestList)
+			assert.Equal(t, 1, len(guestList))
+
+			req.Mode = "apply"
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/seating/plan", req, &plan))
+			assert.True(t, strings.HasPrefix(plan.Assignments[0].Id, "g_"))
+			var found dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guests/John", nil, &found)
+			assert.Equal(t, plan.Assignments[1].Table_ID, found.Table_ID)
+			for _, table := range plan.Tables {
+				assert.Equal(t, table.Capacity, table.Reserved)
+			}
+
+			// Every table is full now
+			var problem dto.ProblemResDto
+			full := dto.SeatingPlanReqDto{Mode: "apply", Parties: []dto.SeatingPartyReqDto{{Name: "Boris"}}}
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPost, "/seating/plan", full, &problem))
+			assert.Equal(t, "/problems/parties-unseated", problem.Type)
+			full.Mode = ""
+			call(t, srv, http.MethodPost, "/seating/plan", full, &plan)
+			assert.Equal(t, "Boris", plan.Unseated[0].Name)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/seating/plan",
+				dto.SeatingPlanReqDto{Parties: []dto.SeatingPartyReqDto{{Name: "Ann"}, {Name: "<b>"}}}, &problem))
+			assert.Equal(t, "parties[1].name", problem.Errors[0].Field)
+		})
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var a, b, stage dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &a)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &b)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4, NearStage: true}, &stage)
+			assert.True(t, stage.NearStage)
+			guests := make(map[string]dto.GuestResDto)
+			for name, table := range map[string]int{"Ann": a.Id, "Bob": a.Id, "Cat": b.Id, "Dan": b.Id} {
+				var guest dto.GuestResDto
+				call(t, srv, http.MethodPost, "/guest_list/"+name, dto.GuestReqDto{Table_ID: table}, &guest)
+				guests[name] = guest
+			}
+
+			var constraint dto.ConstraintResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/seating/constraints",
+				dto.ConstraintReqDto{Type: "together", Guests: []string{"Ann", guests["Bob"].Id}}, &constraint))
+			assert.Equal(t, []string{guests["Ann"].Id, guests["Bob"].Id}, constraint.Guests)
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/seating/constraints",
+				dto.ConstraintReqDto{Type: "apart", Guests: []string{"Ann", "Cat"}}, nil))
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/seating/constraints",
+				dto.ConstraintReqDto{Type: "pinned", Guests: []string{"Dan"}, Table_ID: b.Id}, nil))
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/seating/constraints",
+				dto.ConstraintReqDto{Type: "near_stage", Guests: []string{"Cat"}}, &constraint))
+			assert.Equal(t, 1, constraint.Priority)
+
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/seating/constraints",
+				dto.ConstraintReqDto{Type: "together", Guests: []string{"Ann", "Ann"}}, &problem))
+			assert.Equal(t, "/problems/invalid-constraint", problem.Type)
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/seating/constraints",
+				dto.ConstraintReqDto{Type: "pinned", Guests: []string{"Ann"}}, &problem))
+			assert.Equal(t, []dto.FieldErrorResDto{{Field: "table_id", Message: "is required"}}, problem.Errors)
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodPost, "/seating/constraints",
+				dto.ConstraintReqDto{Type: "apart", Guests: []string{"Ann", "Nobody"}}, nil))
+
+			// Only the near stage wish is not met
+			var violations []dto.ViolationResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/seating/violations", nil, &violations))
+			assert.Equal(t, 1, len(violations))
+			assert.Equal(t, "near_stage", violations[0].Type)
+			assert.False(t, violations[0].Hard)
+			assert.Equal(t, "Cat is not near the stage", violations[0].Message)
+
+			// Moves that break a rule the guests keep are refused
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPatch, "/guest_list/Bob", dto.GuestPatchReqDto{Table_ID: b.Id}, &problem))
+			assert.Equal(t, "/problems/constraint-violated", problem.Type)
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPatch, "/guest_list/Dan", dto.GuestPatchReqDto{Table_ID: a.Id}, nil))
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPatch, "/guest_list/Cat", dto.GuestPatchReqDto{Table_ID: a.Id}, nil))
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPatch, "/guest_list/Cat", dto.GuestPatchReqDto{Table_ID: stage.Id}, nil))
+			call(t, srv, http.MethodPatch, "/guest_list/Cat", dto.GuestPatchReqDto{Table_ID: b.Id}, nil)
+
+			// The planner seats Cat near the stage
+			var plan dto.SeatingPlanResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/seating/plan",
+				dto.SeatingPlanReqDto{Mode: "apply", Guests: []string{"Cat"}}, &plan))
+			assert.Equal(t, []dto.SeatAssignmentResDto{{Id: guests["Cat"].Id, Name: "Cat", Table_ID: stage.Id}}, plan.Assignments)
+			assert.Equal(t, 1, plan.NearStage)
+			call(t, srv, http.MethodGet, "/seating/violations", nil, &violations)
+			assert.Equal(t, 0, len(violations))
+
+			// Ann and Bob are re-seated together and away from Cat
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPost, "/seating/plan",
+				dto.SeatingPlanReqDto{Guests: []string{"Ann", "Bob"}, Parties: []dto.SeatingPartyReqDto{{Name: "Eve", Acompanying_Guests: 1}}}, &plan))
+			assert.Equal(t, 3, len(plan.Assignments))
+			assert.Equal(t, plan.Assignments[0].Table_ID, plan.Assignments[1].Table_ID)
+			assert.NotEqual(t, stage.Id, plan.Assignments[0].Table_ID)
+
+			// The pin to a deleted table goes with it, so Dan can be moved
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, fmt.Sprintf("/tables/%d?reassign_to=%d", b.Id, a.Id), nil, nil))
+			var constraints []dto.ConstraintResDto
+			call(t, srv, http.MethodGet, "/seating/constraints", nil, &constraints)
+			assert.Equal(t, 3, len(constraints))
+
+			// A rule left with one guest goes with the other
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guest_list/Bob", nil, nil))
+			call(t, srv, http.MethodGet, "/seating/constraints", nil, &constraints)
+			assert.Equal(t, 2, len(constraints))
+			assert.Equal(t, "apart", constraints[0].Type)
+
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, fmt.Sprintf("/seating/constraints/%d", constraints[0].Id), nil, nil))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodDelete, fmt.Sprintf("/seating/constraints/%d", constraints[0].Id), nil, &problem))
+			assert.Equal(t, "/problems/constraint-not-found", problem.Type)
+		})
+	}
+}
+
+func TestWalkIns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var small, large dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 2}, &small)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 6}, &large)
+			call(t, srv, http.MethodPost, "/guest_list/Echez", dto.GuestReqDto{Table_ID: large.Id, Acompanying_Guests: 4}, nil)
+
+			stream, closeStream := openStream(t, srv, fmt.Sprintf("/events/stream?table_id=%d", small.Id), "")
+			defer closeStream()
+
+			// Only the small table has two seats nobody has reserved
+			var walkIn dto.GuestResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Walker", Acompanying_Guests: 1}, &walkIn))
+			assert.Equal(t, small.Id, walkIn.Table_ID)
+			assert.True(t, walkIn.WalkIn)
+			assert.NotEqual(t, "", walkIn.TimeArrived)
+
+			// The walk-in is added to the guest list and checked in, so the subscribers hear of both
+			assert.Equal(t, service.NoticeGuestCreated, readEvent(t, stream).Type)
+			arrived := readEvent(t, stream)
+			assert.Equal(t, service.NoticeGuestCheckedIn, arrived.Type)
+			var guest dto.GuestResDto
+			json.Unmarshal(arrived.Data.Data, &guest)
+			assert.Equal(t, walkIn.Id, guest.Id)
+			assert.NotEqual(t, "", guest.TimeArrived)
+			seats := readEvent(t, stream)
+			assert.Equal(t, service.NoticeTableSeats, seats.Type)
+			var res dto.TableResDto
+			json.Unmarshal(seats.Data.Data, &res)
+			assert.Equal(t, 2, res.Arrived)
+
+			// The large table has the free seats, though Echez has reserved them
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Sara", Acompanying_Guests: 1}, &walkIn))
+			assert.Equal(t, large.Id, walkIn.Table_ID)
+
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "John", Acompanying_Guests: 4}, &problem))
+			assert.Equal(t, "/problems/no-free-table", problem.Type)
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Echez"}, &problem))
+			assert.Equal(t, "/problems/duplicate-name", problem.Type)
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{}, nil))
+
+			var present []dto.GuestResDto
+			call(t, srv, http.MethodGet, "/guests", nil, &present)
+			assert.Equal(t, 2, len(present))
+			var visits []dto.VisitResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/guests/Walker/visits", nil, &visits))
+			assert.Equal(t, 1, len(visits))
+
+			var exported []dto.GuestExportResDto
+			call(t, srv, http.MethodGet, "/export/guests", nil, &exported)
+			walkIns := 0
+			for _, guest := range exported {
+				if guest.WalkIn {
+					walkIns++
+				}
+			}
+			assert.Equal(t, 2, walkIns)
+		})
+	}
+
+	t.Run("Policy", func(t *testing.T) {
+		newPolicyServer := func(walkIns string, maxWalkIns int) *httptest.Server {
+			cfg := config.Default()
+			cfg.Guests.WalkIns, cfg.Guests.MaxWalkIns = walkIns, maxWalkIns
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			router, err := server.New(ctx, repository.NewMemoryStore(), cfg)
+			assert.Nil(t, err)
+			srv := httptest.NewServer(router)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 10}, nil)
+			return srv
+		}
+
+		var problem dto.ProblemResDto
+		denied := newPolicyServer("deny", 0)
+		defer denied.Close()
+		assert.Equal(t, http.StatusForbidden, call(t, denied, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Walker"}, &problem))
+		assert.Equal(t, "/problems/walk-ins-not-allowed", problem.Type)
+
+		capped := newPolicyServer("allow", 1)
+		defer capped.Close()
+		assert.Equal(t, http.StatusCreated, call(t, capped, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Walker"}, nil))
+		assert.Equal(t, http.StatusConflict, call(t, capped, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Sara"}, &problem))
+		assert.Equal(t, "/problems/walk-in-limit-reached", problem.Type)
+		// Guests on the guest list still check in
+		assert.Equal(t, http.StatusCreated, call(t, capped, http.MethodPost, "/guest_list/John", dto.GuestReqDto{Table_ID: 1}, nil))
+		assert.Equal(t, http.StatusCreated, call(t, capped, http.MethodPut, "/guests/John", dto.CheckinReqDto{}, nil))
+	})
+
+	t.Run("Guests waiting at the door", func(t *testing.T) {
+		for _, backend := range testutil.Backends(t) {
+			t.Run(backend.Name, func(t *testing.T) {
+				srv := newServer(t, backend)
+				defer srv.Close()
+
+				var door dto.TableResDto
+				call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 3}, &door)
+				call(t, srv, http.MethodPost, "/guest_list/Ben", dto.GuestReqDto{Table_ID: door.Id}, nil)
+				call(t, srv, http.MethodPost, "/guest_list/Cy", dto.GuestReqDto{Table_ID: door.Id, Acompanying_Guests: 1}, nil)
+				assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/Ben", dto.CheckinReqDto{Acompanying_Guests: 1}, nil))
+				assert.Equal(t, http.StatusAccepted, call(t, srv, http.MethodPut, "/guests/Cy", dto.CheckinReqDto{Acompanying_Guests: 1, Waitlist: true}, nil))
+
+				// The last free seat is Cy's once Ben leaves, the walk-in can not take it
+				var problem dto.ProblemResDto
+				assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Walker"}, &problem))
+				assert.Equal(t, "/problems/no-free-table", problem.Type)
+
+				assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guests/Ben", nil, nil))
+				var cy dto.GuestResDto
+				call(t, srv, http.MethodGet, "/guests/Cy", nil, &cy)
+				assert.NotEqual(t, "", cy.TimeArrived)
+				var walkIn dto.GuestResDto
+				assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Walker"}, &walkIn))
+				assert.Equal(t, door.Id, walkIn.Table_ID)
+			})
+		}
+	})
+}
+
+func TestWaitlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table, door dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 2}, &table)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 2}, &door)
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/guest_list/John", dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, nil))
+
+			// Parties that do not fit wait when they ask to, the higher priority goes first
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPost, "/guest_list/Tom", dto.GuestReqDto{Table_ID: table.Id}, &problem))
+			assert.Equal(t, "/problems/overbooked", problem.Type)
+
+			var sara, mia dto.GuestResDto
+			assert.Equal(t, http.StatusAccepted, call(t, srv, http.MethodPost, "/guest_list/Sara", dto.GuestReqDto{Table_ID: table.Id, Waitlist: true}, &sara))
+			assert.Equal(t, "", sara.Id)
+			assert.Equal(t, 1, sara.Waitlist.Position)
+			assert.Equal(t, "waiting", sara.Waitlist.Status)
+			assert.Equal(t, http.StatusAccepted, call(t, srv, http.MethodPost, "/guest_list/Mia", dto.GuestReqDto{Table_ID: table.Id, Waitlist: true, Priority: 5}, &mia))
+			assert.Equal(t, 1, mia.Waitlist.Position)
+
+			var entry dto.WaitlistResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, fmt.Sprintf("/waitlist/%d", sara.Waitlist.Id), nil, &entry))
+			assert.Equal(t, 2, entry.Position)
+
+			// A table that grows gives its new seat to the next party
+			var grown dto.TableResDto
+			three := 3
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPatch, fmt.Sprintf("/tables/%d", table.Id), dto.TablePatchReqDto{Capacity: &three}, &grown))
+			assert.Equal(t, 3, grown.Reserved)
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/guests/Mia", nil, nil))
+
+			var waiting []dto.WaitlistResDto
+			call(t, srv, http.MethodGet, "/waitlist", nil, &waiting)
+			assert.Equal(t, 1, len(waiting))
+			assert.Equal(t, "Sara", waiting[0].Name)
+			assert.Equal(t, 1, waiting[0].Position)
+
+			// John leaving frees his seats for Sara
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/John", dto.CheckinReqDto{Acompanying_Guests: 1}, nil))
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guests/John", nil, nil))
+			call(t, srv, http.MethodGet, fmt.Sprintf("/waitlist/%d", sara.Waitlist.Id), nil, &entry)
+			assert.Equal(t, "promoted", entry.Status)
+			assert.Equal(t, 0, entry.Position)
+			assert.NotEqual(t, "", entry.Guest)
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/guests/"+entry.Guest, nil, nil))
+
+			// A guest on the guest list waits at the door until their table has room
+			call(t, srv, http.MethodPost, "/guest_list/Ben", dto.GuestReqDto{Table_ID: door.Id}, nil)
+			call(t, srv, http.MethodPost, "/guest_list/Cy", dto.GuestReqDto{Table_ID: door.Id}, nil)
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/Ben", dto.CheckinReqDto{Acompanying_Guests: 1}, nil))
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodPut, "/guests/Cy", dto.CheckinReqDto{}, &problem))
+			assert.Equal(t, "/problems/over-capacity", problem.Type)
+			var cy dto.GuestResDto
+			assert.Equal(t, http.StatusAccepted, call(t, srv, http.MethodPut, "/guests/Cy", dto.CheckinReqDto{Waitlist: true}, &cy))
+			assert.Equal(t, "arrival", cy.Waitlist.Kind)
+			assert.Equal(t, cy.Id, cy.Waitlist.Guest)
+
+			call(t, srv, http.MethodDelete, "/guests/Ben", nil, nil)
+			call(t, srv, http.MethodGet, "/guests/Cy", nil, &cy)
+			assert.NotEqual(t, "", cy.TimeArrived)
+
+			// A party can leave the waitlist
+			var ann dto.GuestResDto
+			assert.Equal(t, http.StatusAccepted, call(t, srv, http.MethodPost, "/guest_list/Ann", dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 2, Waitlist: true}, &ann))
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, fmt.Sprintf("/waitlist/%d", ann.Waitlist.Id), nil, nil))
+			call(t, srv, http.MethodGet, "/waitlist", nil, &waiting)
+			assert.Equal(t, 0, len(waiting))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodDelete, fmt.Sprintf("/waitlist/%d", ann.Waitlist.Id), nil, &problem))
+			assert.Equal(t, "/problems/waitlist-entry-not-found", problem.Type)
+		})
+	}
+}
+
+func TestAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &table)
+			var guest dto.GuestResDto
+			call(t, srv, http.MethodPost, "/guest_list/Echez", dto.GuestReqDto{Table_ID: table.Id}, &guest)
+
+			// The request id the client sends is recorded with the change and sent back
+			req, _ := http.NewRequest(http.MethodPut, srv.URL+"/guests/Echez", strings.NewReader(`{"accompanying_guests": 1}`))
+			req.Header.Set("X-Request-ID", "door-42")
+			resp, err := srv.Client().Do(req)
+			assert.Nil(t, err)
+			resp.Body.Close()
+			assert.Equal(t, "door-42", resp.Header.Get("X-Request-ID"))
+
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guests/Echez", nil, nil))
+			call(t, srv, http.MethodPatch, "/tables/"+fmt.Sprint(table.Id), map[string]int{"capacity": 6}, nil)
+			// A change that is refused is not recorded
+			assert.Equal(t, http.StatusConflict, call(t, srv, http.MethodDelete, "/guests/Echez", nil, nil))
+
+			var entries []dto.AuditResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/audit", nil, &entries))
+			var actions []string
+			for _, v := range entries {
+				actions = append(actions, v.Action)
+				assert.Equal(t, "anonymous", v.Actor)
+				assert.NotEqual(t, "", v.Request_ID)
+			}
+			// The latest change comes first
+			assert.Equal(t, []string{"table.updated", "guest.checked_out", "guest.checked_in", "guest.created", "table.created"}, actions)
+
+			var checkin []dto.AuditResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/audit?request_id=door-42", nil, &checkin))
+			assert.Equal(t, 1, len(checkin))
+			assert.Equal(t, "guest", checkin[0].Entity)
+			assert.Equal(t, guest.Id, checkin[0].Entity_ID)
+			var before, after map[string]interface{}
+			json.Unmarshal(checkin[0].Before, &before)
+			json.Unmarshal(checkin[0].After, &after)
+			assert.Equal(t, nil, before["arrived_at"])
+			assert.NotEqual(t, nil, after["arrived_at"])
+			assert.Equal(t, float64(1), after["accompanying_guests"])
+
+			var created []dto.AuditResDto
+			call(t, srv, http.MethodGet, "/audit?action=table.created", nil, &created)
+			assert.Equal(t, 1, len(created))
+			assert.Nil(t, created[0].Before)
+			assert.Equal(t, fmt.Sprint(table.Id), created[0].Entity_ID)
+
+			var guestEntries []dto.AuditResDto
+			call(t, srv, http.MethodGet, "/audit?entity=guest&sort=id&limit=2", nil, &guestEntries)
+			assert.Equal(t, 2, len(guestEntries))
+			assert.Equal(t, "guest.created", guestEntries[0].Action)
+
+			var none []dto.AuditResDto
+			call(t, srv, http.MethodGet, "/audit?since="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), nil, &none)
+			assert.Equal(t, 0, len(none))
+
+			// The export lists every entry, oldest first, one to a line
+			resp, err = srv.Client().Get(srv.URL + "/audit/export")
+			assert.Nil(t, err)
+			var body bytes.Buffer
+			body.ReadFrom(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+			lines := strings.Split(strings.TrimSpace(body.String()), "\n")
+			assert.Equal(t, 5, len(lines))
+			var first dto.AuditResDto
+			assert.Nil(t, json.Unmarshal([]byte(lines[0]), &first))
+			assert.Equal(t, "table.created", first.Action)
+
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodGet, "/audit?action=guest.renamed", nil, &problem))
+			assert.Equal(t, "/problems/validation-failed", problem.Type)
+		})
+	}
+}
+
+func TestLedger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table, other dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 6}, &table)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &other)
+			call(t, srv, http.MethodPost, "/guest_list/Echez", dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 2}, nil)
+			call(t, srv, http.MethodPost, "/guest_list/John", dto.GuestReqDto{Table_ID: table.Id}, nil)
+			call(t, srv, http.MethodPut, "/guests/Echez", dto.CheckinReqDto{Acompanying_Guests: 1}, nil)
+			call(t, srv, http.MethodPost, "/walk_ins", dto.WalkInReqDto{Name: "Sara"}, nil)
+			call(t, srv, http.MethodDelete, "/guest_list/John", nil, nil)
+			call(t, srv, http.MethodPatch, "/tables/"+fmt.Sprint(other.Id), map[string]int{"capacity": 8}, nil)
+
+			var check dto.LedgerCheckResDto
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/ledger/check", nil, &check))
+			assert.True(t, check.Consistent)
+			// The walk-in is reserved and arrives in two entries
+			assert.Equal(t, 9, check.Entries)
+			assert.Equal(t, 2, check.Guests)
+			assert.Equal(t, 2, check.Tables)
+
+			// A bug writes to the rows without going through the services
+			guests, _ := backend.Store.Guests.FindAllByName(1, "Echez")
+			guests[0].Acompanying_Guests = 5
+			backend.Store.Guests.Update(guests[0])
+			stray, _ := backend.Store.Tables.Save(model.Table{Event_ID: 1, Capacity: 2})
+
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/ledger/check", nil, &check))
+			assert.False(t, check.Consistent)
+			assert.Equal(t, []dto.LedgerDifferenceResDto{
+				{Entity: "guest", Entity_ID: guests[0].Id, Field: "accompanying_guests", Ledger: "1", Stored: "5"},
+				{Entity: "table", Entity_ID: table.Id, Field: "occupied", Ledger: "3", Stored: "7"},
+				{Entity: "table", Entity_ID: table.Id, Field: "reserved", Ledger: "3", Stored: "7"},
+				{Entity: "table", Entity_ID: stray.Id, Ledger: "missing", Stored: "capacity 2"},
+			}, check.Differences)
+
+			// The rebuild reports what it put right
+			ledgerService := service.NewLedgerService(backend.Store.UnitOfWork)
+			rebuilt, err := ledgerService.Rebuild(1)
+			assert.Nil(t, err)
+			assert.Equal(t, check.Differences, rebuilt.Differences)
+
+			call(t, srv, http.MethodGet, "/ledger/check", nil, &check)
+			assert.True(t, check.Consistent)
+			var res dto.TableResDto
+			call(t, srv, http.MethodGet, "/tables/"+fmt.Sprint(table.Id), nil, &res)
+			// Echez and the walk-in
+			assert.Equal(t, 3, res.Reserved)
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, "/tables/"+fmt.Sprint(stray.Id), nil, nil))
+		})
+	}
+}
+
+// An event read off a Server-Sent Events stream
+type streamEvent struct {
+	Id   string
+	Type string
+	Data struct {
+		Id       int64           `json:"id"`
+		Type     string          `json:"type"`
+		Event_ID int             `json:"event_id"`
+		Table_ID int             `json:"table_id"`
+		Data     json.RawMessage `json:"data"`
+	}
+}
+
+// Reads the next event off the stream, heartbeats are skipped
+func readEvent(t *testing.T, r *bufio.Reader) streamEvent {
+	t.Helper()
+
+	var ev streamEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("the stream ended: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			ev.Id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.Type = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.Data))
+		case line == "" && ev.Type != "":
+			return ev
+		}
+	}
+}
+
+// Opens the Server-Sent Events stream, lastId is sent as the Last-Event-ID header when it is not empty
+func openStream(t *testing.T, srv *httptest.Server, path string, lastId string) (*bufio.Reader, func()) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	assert.Nil(t, err)
+	if lastId != "" {
+		req.Header.Set("Last-Event-ID", lastId)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("could not open the stream: %v", err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+}
+
+func TestStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table, other dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &table)
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &other)
+
+			// The dashboard follows one table of the default event
+			stream, closeStream := openStream(t, srv, fmt.Sprintf("/events/stream?event_id=1&table_id=%d", table.Id), "")
+			defer closeStream()
+
+			call(t, srv, http.MethodPost, "/guest_list/Ben", dto.GuestReqDto{Table_ID: other.Id}, nil)
+			call(t, srv, http.MethodPost, "/guest_list/John", dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, nil)
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/John", dto.CheckinReqDto{Acompanying_Guests: 1}, nil))
+
+			// Ben sits at the other table, so nothing is heard of him
+			reserved := readEvent(t, stream)
+			assert.Equal(t, service.NoticeGuestCreated, reserved.Type)
+			assert.Equal(t, reserved.Id, fmt.Sprint(reserved.Data.Id))
+			assert.Equal(t, table.Id, reserved.Data.Table_ID)
+			var guest dto.GuestResDto
+			json.Unmarshal(reserved.Data.Data, &guest)
+			assert.Equal(t, "John", guest.Name)
+
+			seats := readEvent(t, stream)
+			assert.Equal(t, service.NoticeTableSeats, seats.Type)
+			var res dto.TableResDto
+			json.Unmarshal(seats.Data.Data, &res)
+			assert.Equal(t, 2, res.Reserved)
+			assert.Equal(t, 0, res.Arrived)
+
+			arrived := readEvent(t, stream)
+			assert.Equal(t, service.NoticeGuestCheckedIn, arrived.Type)
+			seats = readEvent(t, stream)
+			json.Unmarshal(seats.Data.Data, &res)
+			assert.Equal(t, 2, res.Arrived)
+			assert.Equal(t, 2, res.Free)
+
+			// A client that lost its connection picks up after the last event it got
+			resumed, closeResumed := openStream(t, srv, fmt.Sprintf("/events/stream?table_id=%d", table.Id), reserved.Id)
+			defer closeResumed()
+			assert.Equal(t, reserved.Data.Id+1, readEvent(t, resumed).Data.Id)
+			assert.Equal(t, service.NoticeGuestCheckedIn, readEvent(t, resumed).Type)
+			assert.Equal(t, seats.Id, readEvent(t, resumed).Id)
+
+			// One that resumes from before the server started is told to read everything again
+			reset, closeReset := openStream(t, srv, "/events/stream", "100000")
+			defer closeReset()
+			assert.Equal(t, service.NoticeStreamReset, readEvent(t, reset).Type)
+
+			// The WebSocket sends the same messages, one per frame
+			ws, err := websocket.Dial(strings.Replace(srv.URL, "http", "ws", 1)+fmt.Sprintf("/events/socket?table_id=%d&last_event_id=%s", table.Id, arrived.Id), "", srv.URL)
+			if err != nil {
+				t.Fatalf("could not open the WebSocket: %v", err)
+			}
+			defer ws.Close()
+
+			var msg hub.Message
+			assert.Nil(t, websocket.JSON.Receive(ws, &msg))
+			assert.Equal(t, service.NoticeTableSeats, msg.Type)
+			assert.Equal(t, seats.Id, fmt.Sprint(msg.Id))
+
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, "/guests/John", nil, nil))
+			assert.Nil(t, websocket.JSON.Receive(ws, &msg))
+			assert.Equal(t, service.NoticeGuestCheckedOut, msg.Type)
+			assert.Equal(t, service.NoticeGuestCheckedOut, readEvent(t, stream).Type)
+
+			// A table deleted with its guests moved away is not followed by its seats, only the table they moved to is
+			both, closeBoth := openStream(t, srv, fmt.Sprintf("/events/stream?table_id=%d&table_id=%d", table.Id, other.Id), "")
+			defer closeBoth()
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, fmt.Sprintf("/tables/%d?reassign_to=%d", table.Id, other.Id), nil, nil))
+			// The next change marks the end of the deletion's messages
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodPatch, fmt.Sprintf("/tables/%d", other.Id), map[string]int{"capacity": 6}, nil))
+			var kinds []string
+			for ev := readEvent(t, both); ev.Type != service.NoticeTableUpdated; ev = readEvent(t, both) {
+				kinds = append(kinds, ev.Type)
+				if ev.Type == service.NoticeTableSeats {
+					assert.Equal(t, other.Id, ev.Data.Table_ID)
+					json.Unmarshal(ev.Data.Data, &res)
+					assert.Equal(t, other.Id, res.Id)
+				}
+			}
+			assert.Equal(t, []string{service.NoticeGuestUpdated, service.NoticeTableDeleted, service.NoticeTableSeats}, kinds)
+
+			// Unknown events can not be followed
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, "/events/stream?event_id=42", nil, &problem))
+			assert.Equal(t, "/problems/event-not-found", problem.Type)
+		})
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const secret = "a-webhook-secret-0123"
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			received := make(chan webhook.Payload, 10)
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+				if !webhook.Verify(secret, timestamp, body, r.Header.Get(webhook.HeaderSignature)) {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				var payload webhook.Payload
+				json.Unmarshal(body, &payload)
+				received <- payload
+			}))
+			defer receiver.Close()
+
+			srv := newServer(t, backend)
+			defer srv.Close()
+
+			var table dto.TableResDto
+			call(t, srv, http.MethodPost, "/tables", dto.TableReqDto{Capacity: 4}, &table)
+
+			var problem dto.ProblemResDto
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/webhooks", dto.WebhookReqDto{URL: "ftp://example.com", Secret: secret, Events: []string{"guest.checked_in"}}, &problem))
+			assert.Equal(t, "url", problem.Errors[0].Field)
+			assert.Equal(t, "must be an http or https URL", problem.Errors[0].Message)
+			assert.Equal(t, http.StatusUnprocessableEntity, call(t, srv, http.MethodPost, "/webhooks", dto.WebhookReqDto{URL: receiver.URL, Secret: secret, Events: []string{"guest.updated"}}, nil))
+
+			var hook dto.WebhookResDto
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPost, "/webhooks", dto.WebhookReqDto{URL: receiver.URL, Secret: secret, Events: []string{"guest.checked_in"}}, &hook))
+			var raw []map[string]interface{}
+			assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, "/webhooks", nil, &raw))
+			assert.Equal(t, 1, len(raw))
+			assert.NotContains(t, raw[0], "secret")
+
+			call(t, srv, http.MethodPost, "/guest_list/John", dto.GuestReqDto{Table_ID: table.Id, Acompanying_Guests: 1}, nil)
+			assert.Equal(t, http.StatusCreated, call(t, srv, http.MethodPut, "/guests/John", dto.CheckinReqDto{Acompanying_Guests: 1}, nil))
+
+			select {
+			case payload := <-received:
+				assert.Equal(t, service.NoticeGuestCheckedIn, payload.Type)
+				assert.Equal(t, 1, payload.Event_ID)
+				assert.Equal(t, table.Id, payload.Table_ID)
+			case <-time.After(5 * time.Second):
+				t.Fatal("the webhook was not sent")
+			}
+
+			// The result is recorded once the receiver has answered
+			var deliveries []dto.DeliveryResDto
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				assert.Equal(t, http.StatusOK, call(t, srv, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", hook.Id), nil, &deliveries))
+				if len(deliveries) == 1 && deliveries[0].Status == model.DeliveryDelivered || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			assert.Equal(t, 1, len(deliveries))
+			assert.Equal(t, model.DeliveryDelivered, deliveries[0].Status)
+			assert.Equal(t, 1, deliveries[0].Attempts)
+			assert.Equal(t, http.StatusOK, deliveries[0].ResponseStatus)
+			assert.Equal(t, 0, len(received))
+
+			assert.Equal(t, http.StatusNoContent, call(t, srv, http.MethodDelete, fmt.Sprintf("/webhooks/%d", hook.Id), nil, nil))
+			assert.Equal(t, http.StatusNotFound, call(t, srv, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", hook.Id), nil, &problem))
+			assert.Equal(t, "/problems/webhook-not-found", problem.Type)
+		})
+	}
+}
+
+// Reports whether the webhook delivery loop is running, by looking for it in the stacks of the goroutines
+func delivering() bool {
+	buf := make([]byte, 1<<20)
+	return strings.Contains(string(buf[:runtime.Stack(buf, true)]), "server.deliverWebhooks")
+}
+
+func TestServerStops(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := server.New(ctx, repository.NewMemoryStore(), config.Default())
+	assert.Nil(t, err)
+	assert.True(t, delivering())
+
+	// The webhook deliveries stop being sent with the context
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for delivering() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(t, delivering())
+}
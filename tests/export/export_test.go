@@ -0,0 +1,95 @@
+package export_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/export"
+	"github.com/stretchr/testify/assert"
+)
+
+var guests = []dto.GuestResDto{
+	{Id: "g_2", Name: "Zoë Martin", Table_ID: 2, TimeArrived: "2026-12-31T20:15:00Z", WalkIn: true},
+	{Id: "g_1", Name: "Hannah Smith", Aliases: []string{"Han", "Hanna"}, Table_ID: 1, Acompanying_Guests: 2},
+	{Id: "g_3", Name: "-Boris", Table_ID: 1, TimeArrived: "2026-12-31T20:00:00Z", TimeLeft: "2026-12-31T23:10:00Z"},
+}
+
+func TestGuests(t *testing.T) {
+	res := export.Guests(guests)
+
+	assert.Equal(t, []string{"g_3", "g_1", "g_2"}, []string{res[0].Id, res[1].Id, res[2].Id})
+	assert.Equal(t, 3, res[1].PartySize)
+	assert.Equal(t, []string{}, res[0].Aliases)
+}
+
+func TestWriteGuestsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, export.WriteGuestsCSV(&buf, export.Guests(guests)))
+
+	assert.Equal(t, "id,name,aliases,table_id,accompanying_guests,party_size,time_arrived,time_left,walk_in\n"+
+		"g_3,'-Boris,,1,0,1,2026-12-31T20:00:00Z,2026-12-31T23:10:00Z,false\n"+
+		"g_1,Hannah Smith,Han;Hanna,1,2,3,,,false\n"+
+		"g_2,Zoë Martin,,2,0,1,2026-12-31T20:15:00Z,,true\n", buf.String())
+}
+
+// Checks that every entry of the cross-reference table points at the start of its object
+func assertValidPDF(t *testing.T, data []byte) {
+	t.Helper()
+
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	assert.NotNil(t, start)
+	xref, _ := strconv.Atoi(string(start[1]))
+	assert.True(t, bytes.HasPrefix(data[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
+
+func TestWriteSeatingChart(t *testing.T) {
+	event := dto.EventResDto{Name: "Gala (New Year)", Venue: "The Hall", StartTime: time.Date(2026, 12, 31, 20, 0, 0, 0, time.UTC), Timezone: "Europe/London"}
+	tables := []dto.TableResDto{{Id: 2, Capacity: 4, Reserved: 1}, {Id: 1, Capacity: 6, Reserved: 4}, {Id: 3, Capacity: 2}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, export.WriteSeatingChart(&buf, event, tables, export.Guests(guests)))
+	data := buf.Bytes()
+	assertValidPDF(t, data)
+
+	// The text is in Windows-1252 with the brackets of the PDF string escaped
+	assert.Contains(t, string(data), `(Seating chart: Gala \(New Year\)) Tj`)
+	assert.Contains(t, string(data), "(The Hall \xb7 Thursday 31 December 2026, 20:00) Tj")
+	assert.Contains(t, string(data), "(Table 1: 6 seats, 4 reserved) Tj")
+	assert.Contains(t, string(data), "(Hannah Smith, party of 3) Tj")
+	assert.Contains(t, string(data), "(-Boris, left 23:10) Tj")
+	assert.Contains(t, string(data), "(Zo\xeb Martin, walk-in, arrived 20:15) Tj")
+	assert.Contains(t, string(data), "(No guests) Tj")
+	assert.Less(t, strings.Index(string(data), "Table 1:"), strings.Index(string(data), "Table 2:"))
+	assert.Contains(t, string(data), "/Count 1 >>")
+
+	t.Run("Long lists run over several pages", func(t *testing.T) {
+		var many []dto.GuestResDto
+		for i := 0; i < 200; i++ {
+			many = append(many, dto.GuestResDto{Id: fmt.Sprintf("g_%d", i), Name: fmt.Sprintf("Guest %d", i), Table_ID: 1 + i%10})
+		}
+		var tables []dto.TableResDto
+		for i := 1; i <= 10; i++ {
+			tables = append(tables, dto.TableResDto{Id: i, Capacity: 20, Reserved: 20})
+		}
+
+		var buf bytes.Buffer
+		assert.Nil(t, export.WriteSeatingChart(&buf, dto.EventResDto{}, tables, export.Guests(many)))
+		assertValidPDF(t, buf.Bytes())
+		assert.Regexp(t, `/Count [4-9] >>`, buf.String())
+	})
+}
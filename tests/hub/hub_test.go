@@ -0,0 +1,186 @@
+package hub_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/hub"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub(t *testing.T) {
+	h := hub.New()
+
+	all, cancelAll := h.Subscribe(nil, 10)
+	defer cancelAll()
+	tableTwo, cancelTable := h.Subscribe(func(msg hub.Message) bool { return msg.Table_ID == 2 }, 1)
+
+	h.Publish("waitlist.joined", 1, 1, nil)
+	h.Publish("waitlist.joined", 1, 2, nil)
+	// The table subscriber has room for one message, the others are dropped rather than waited for
+	h.Publish("waitlist.promoted", 1, 2, nil)
+
+	assert.Equal(t, 3, len(all))
+	first := <-all
+	assert.Equal(t, int64(1), first.Id)
+	assert.Equal(t, "waitlist.joined", first.Type)
+
+	assert.Equal(t, 1, len(tableTwo))
+	msg := <-tableTwo
+	assert.Equal(t, int64(2), msg.Id)
+
+	cancelTable()
+	_, open := <-tableTwo
+	assert.False(t, open)
+	// Cancelling twice does nothing
+	cancelTable()
+	h.Publish("waitlist.left", 1, 2, nil)
+	assert.Equal(t, 3, len(all))
+}
+
+func TestWaitlistNotices(t *testing.T) {
+	store := repository.NewMemoryStore()
+	store.Events.Save(model.Event{Id: 1, Name: "Gala"})
+	store.Tables.Save(model.Table{Id: 1, Event_ID: 1, Capacity: 2})
+
+	h := hub.New()
+	// The guest and table notices are left to TestChangeNotices
+	messages, cancel := h.Subscribe(func(msg hub.Message) bool { return msg.Event_ID == 1 && strings.HasPrefix(msg.Type, "waitlist.") }, 10)
+	defer cancel()
+
+	opts := service.GuestOptions{Publisher: h}
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, opts)
+
+	_, err := guestService.Save(1, dto.GuestReqDto{Name: "John", Table_ID: 1, Acompanying_Guests: 1}, service.Actor{})
+	assert.Nil(t, err)
+	// Nothing about the waitlist is published for a guest who is not on it
+	assert.Equal(t, 0, len(messages))
+
+	_, err = guestService.Save(1, dto.GuestReqDto{Name: "Sara", Table_ID: 1, Waitlist: true}, service.Actor{})
+	assert.Nil(t, err)
+	_, err = guestService.Save(1, dto.GuestReqDto{Name: "Mia", Table_ID: 1, Waitlist: true, Priority: 1}, service.Actor{})
+	assert.Nil(t, err)
+
+	// Mia goes ahead of Sara
+	var kinds []string
+	for len(messages) > 0 {
+		msg := <-messages
+		kinds = append(kinds, msg.Type)
+		assert.Equal(t, 1, msg.Table_ID)
+	}
+	assert.Equal(t, []string{service.NoticeWaitlistJoined, service.NoticeWaitlistMoved, service.NoticeWaitlistJoined}, kinds)
+
+	_, err = guestService.Checkin(1, "John", dto.CheckinReqDto{Acompanying_Guests: 1}, service.Actor{})
+	assert.Nil(t, err)
+	assert.Nil(t, guestService.Checkout(1, "John", service.Actor{}))
+
+	// John's two seats go to both parties, the one with the higher priority first
+	promoted := <-messages
+	assert.Equal(t, service.NoticeWaitlistPromoted, promoted.Type)
+	assert.Equal(t, "Mia", promoted.Data.(dto.WaitlistResDto).Name)
+	promoted = <-messages
+	assert.Equal(t, service.NoticeWaitlistPromoted, promoted.Type)
+	assert.Equal(t, "Sara", promoted.Data.(dto.WaitlistResDto).Name)
+	assert.Equal(t, 0, len(messages))
+}
+
+func TestResume(t *testing.T) {
+	h := hub.New()
+	for i := 1; i <= 3; i++ {
+		h.Publish("waitlist.joined", 1, i, nil)
+	}
+
+	// The messages after the second one, for the tables the filter lets through
+	replay, messages, cancel := h.Resume(1, func(msg hub.Message) bool { return msg.Table_ID != 2 }, 10)
+	assert.True(t, replay.Complete)
+	assert.Equal(t, int64(3), replay.LastId)
+	assert.Equal(t, 1, len(replay.Messages))
+	assert.Equal(t, int64(3), replay.Messages[0].Id)
+
+	// The messages published afterwards come on the channel
+	h.Publish("waitlist.left", 1, 1, nil)
+	msg := <-messages
+	assert.Equal(t, int64(4), msg.Id)
+	cancel()
+
+	// A client that is already up to date gets nothing to replay
+	replay, _, cancel = h.Resume(4, nil, 10)
+	assert.True(t, replay.Complete)
+	assert.Equal(t, 0, len(replay.Messages))
+	cancel()
+
+	// An id the hub never published, from before the server was restarted, can not be resumed from
+	replay, _, cancel = h.Resume(9, nil, 10)
+	assert.False(t, replay.Complete)
+	cancel()
+
+	// Only the last messages are kept
+	for i := 0; i < hub.History; i++ {
+		h.Publish("waitlist.joined", 1, 1, nil)
+	}
+	replay, _, cancel = h.Resume(4, nil, 10)
+	assert.True(t, replay.Complete)
+	assert.Equal(t, hub.History, len(replay.Messages))
+	cancel()
+	replay, _, cancel = h.Resume(3, nil, 10)
+	assert.False(t, replay.Complete)
+	assert.Equal(t, 0, len(replay.Messages))
+	cancel()
+}
+
+func TestChangeNotices(t *testing.T) {
+	store := repository.NewMemoryStore()
+	store.Events.Save(model.Event{Id: 1, Name: "Gala"})
+
+	h := hub.New()
+	messages, cancel := h.Subscribe(nil, 20)
+	defer cancel()
+
+	opts := service.GuestOptions{Publisher: h}
+	tableService := service.NewTableService(store.Events, store.Tables, store.UnitOfWork, opts)
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, opts)
+
+	table, err := tableService.Save(1, dto.TableReqDto{Capacity: 4}, service.Actor{})
+	assert.Nil(t, err)
+	_, err = guestService.Save(1, dto.GuestReqDto{Name: "John", Table_ID: table.Id, Acompanying_Guests: 1}, service.Actor{})
+	assert.Nil(t, err)
+	_, err = guestService.Checkin(1, "John", dto.CheckinReqDto{Acompanying_Guests: 1}, service.Actor{})
+	assert.Nil(t, err)
+	assert.Nil(t, guestService.Checkout(1, "John", service.Actor{}))
+
+	var kinds []string
+	var seats []dto.TableResDto
+	for len(messages) > 0 {
+		msg := <-messages
+		kinds = append(kinds, msg.Type)
+		assert.Equal(t, table.Id, msg.Table_ID)
+		if msg.Type == service.NoticeTableSeats {
+			seats = append(seats, msg.Data.(dto.TableResDto))
+		}
+	}
+	assert.Equal(t, []string{
+		service.NoticeTableCreated, service.NoticeTableSeats,
+		service.NoticeGuestCreated, service.NoticeTableSeats,
+		service.NoticeGuestCheckedIn, service.NoticeTableSeats,
+		service.NoticeGuestCheckedOut, service.NoticeTableSeats,
+	}, kinds)
+
+	// The seats are the table's once each change was made
+	assert.Equal(t, 4, len(seats))
+	assert.Equal(t, dto.TableResDto{Id: table.Id, Capacity: 4, Free: 4}, seats[0])
+	assert.Equal(t, 2, seats[1].Reserved)
+	assert.Equal(t, 0, seats[1].Arrived)
+	assert.Equal(t, 2, seats[2].Arrived)
+	assert.Equal(t, 2, seats[2].Free)
+	assert.Equal(t, 0, seats[3].Reserved)
+	assert.Equal(t, 4, seats[3].Free)
+
+	// Nothing is published for a change that is not made
+	_, err = guestService.Checkin(1, "Nobody", dto.CheckinReqDto{}, service.Actor{})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(messages))
+}
@@ -0,0 +1,126 @@
+package importer_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/importer"
+	"github.com/getground/tech-tasks/backend/pkg/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+// Builds the smallest XLSX file a spreadsheet program would open, the cells are written as shared and inline strings
+func xlsxFile(t *testing.T) []byte {
+	t.Helper()
+
+	return xlsxSheet(t, `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="C2"><v>3</v></c></row>
+			<row r="3"><c r="A3" t="inlineStr"><is><t>Boris</t></is></c><c r="C3"><v>4.0</v></c></row>`)
+}
+
+// Builds an XLSX file whose sheet has the rows
+func xlsxSheet(t *testing.T, rows string) []byte {
+	t.Helper()
+
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<sheets><sheet name="Guests" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+			<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/guests.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+			<si><t>Full name</t></si><si><t>table_id</t></si><si><r><t>Zoë </t></r><r><t>Martin</t></r></si></sst>`,
+		"xl/worksheets/guests.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`,
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := w.Create(name)
+		assert.Nil(t, err)
+		f.Write([]byte(content))
+	}
+	assert.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	t.Run("CSV", func(t *testing.T) {
+		rows, err := importer.Read(importer.FormatCSV, strings.NewReader("\xef\xbb\xbfname,table_id\nSara,1\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, [][]string{{"name", "table_id"}, {"Sara", "1"}}, rows)
+	})
+
+	t.Run("XLSX", func(t *testing.T) {
+		data := xlsxFile(t)
+		assert.Equal(t, importer.FormatXLSX, importer.DetectFormat("", data))
+
+		rows, err := importer.Read(importer.FormatXLSX, bytes.NewReader(data))
+		assert.Nil(t, err)
+		assert.Equal(t, [][]string{{"Full name", "", "table_id"}, {"Zoë Martin", "", "3"}, {"Boris", "", "4.0"}}, rows)
+	})
+
+	t.Run("Cells beyond the last column", func(t *testing.T) {
+		// XFD is the last column a spreadsheet program has
+		rows, err := importer.Read(importer.FormatXLSX, bytes.NewReader(xlsxSheet(t, `<row r="1"><c r="XFD1"><v>1</v></c></row>`)))
+		assert.Nil(t, err)
+		assert.Equal(t, 16384, len(rows[0]))
+		assert.Equal(t, "1", rows[0][16383])
+
+		for _, ref := range []string{"XFE1", "ZZZZZZ1", strings.Repeat("Z", 30) + "1"} {
+			data := xlsxSheet(t, `<row r="1"><c r="`+ref+`"><v>1</v></c></row>`)
+			_, err := importer.Read(importer.FormatXLSX, bytes.NewReader(data))
+			assert.ErrorIs(t, err, importer.ErrUnreadable, ref)
+		}
+
+		// Cells far to the right on many rows are too many once the empty ones are filled in
+		far := strings.Repeat(`<row><c r="XFD1"><v>1</v></c></row>`, 100)
+		_, err = importer.Read(importer.FormatXLSX, bytes.NewReader(xlsxSheet(t, far)))
+		assert.ErrorIs(t, err, importer.ErrUnreadable)
+	})
+
+	t.Run("Sheet too large once decompressed", func(t *testing.T) {
+		// The spaces compress to a file a fraction of the size of the upload limit
+		data := xlsxSheet(t, strings.Repeat(" ", 40<<20))
+		assert.Less(t, len(data), 1<<20)
+
+		_, err := importer.Read(importer.FormatXLSX, bytes.NewReader(data))
+		assert.ErrorIs(t, err, importer.ErrUnreadable)
+		assert.Contains(t, err.Error(), "larger than")
+	})
+
+	t.Run("Not a spreadsheet", func(t *testing.T) {
+		_, err := importer.Read(importer.FormatXLSX, strings.NewReader("name,table_id"))
+		assert.ErrorIs(t, err, importer.ErrUnreadable)
+	})
+}
+
+func TestGuests(t *testing.T) {
+	rows := [][]string{
+		{"Guest", " TABLE_ID ", "accompanying_guests", "aliases"},
+		{"Hannah Smith", "1", "2", "Han; Hanna"},
+		{"", "", "", ""},
+		{"Boris", "one", "", ""},
+		{"Sara", "2"},
+	}
+
+	guests, err := importer.Guests(rows, map[string]string{"name": "guest"})
+	assert.Nil(t, err)
+	assert.Equal(t, []dto.GuestImportRowDto{
+		{Row: 2, Guest: dto.GuestReqDto{Name: "Hannah Smith", Table_ID: 1, Acompanying_Guests: 2, Aliases: []string{"Han", "Hanna"}}},
+		{Row: 4, Guest: dto.GuestReqDto{Name: "Boris"}, Errors: []dto.FieldErrorResDto{{Field: "table_id", Message: "must be a whole number"}}},
+		{Row: 5, Guest: dto.GuestReqDto{Name: "Sara", Table_ID: 2}},
+	}, guests)
+
+	// The columns that are needed have to be in the header
+	_, err = importer.Guests(rows, map[string]string{"table_id": "Table", "size": "Party"})
+	var invalid *validation.Error
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []dto.FieldErrorResDto{
+		{Field: "column[size]", Message: "must be one of name, table_id, accompanying_guests, aliases"},
+		{Field: "column[name]", Message: `the header has no column "name"`},
+		{Field: "column[table_id]", Message: `the header has no column "Table"`},
+	}, invalid.Fields)
+}
@@ -0,0 +1,85 @@
+package ledger_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/ledger"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// A ledger entry with the row as it is after the change, a nil row removes it
+func entry(id int, kind string, stream string, streamId int, row interface{}) model.LedgerEntry {
+	e := model.LedgerEntry{Id: id, Event_ID: 1, Type: kind, Stream: stream, Stream_ID: streamId}
+	if row != nil {
+		data, _ := json.Marshal(row)
+		e.Data = string(data)
+	}
+	return e
+}
+
+func TestProject(t *testing.T) {
+	arrived := time.Date(2026, 12, 31, 20, 0, 0, 0, time.UTC)
+	left := arrived.Add(time.Hour)
+
+	entries := []model.LedgerEntry{
+		entry(1, model.LedgerTableAdded, model.LedgerStreamTable, 1, model.Table{Id: 1, Event_ID: 1, Capacity: 10}),
+		entry(2, model.LedgerTableAdded, model.LedgerStreamTable, 2, model.Table{Id: 2, Event_ID: 1, Capacity: 4}),
+		entry(3, model.LedgerGuestReserved, model.LedgerStreamGuest, 1, model.Guest{Id: 1, Event_ID: 1, Name: "Echez", Table_ID: 1, Acompanying_Guests: 2}),
+		entry(4, model.LedgerGuestReserved, model.LedgerStreamGuest, 2, model.Guest{Id: 2, Event_ID: 1, Name: "John", Table_ID: 1}),
+		entry(5, model.LedgerGuestArrived, model.LedgerStreamGuest, 1, model.Guest{Id: 1, Event_ID: 1, Name: "Echez", Table_ID: 1, Acompanying_Guests: 1, ArrivedAt: &arrived}),
+		entry(6, model.LedgerGuestReserved, model.LedgerStreamGuest, 3, model.Guest{Id: 3, Event_ID: 1, Name: "Sara", Table_ID: 2}),
+		entry(7, model.LedgerGuestCancelled, model.LedgerStreamGuest, 3, nil),
+		entry(8, model.LedgerGuestArrived, model.LedgerStreamGuest, 2, model.Guest{Id: 2, Event_ID: 1, Name: "John", Table_ID: 1, ArrivedAt: &arrived}),
+		entry(9, model.LedgerGuestDeparted, model.LedgerStreamGuest, 2, model.Guest{Id: 2, Event_ID: 1, Name: "John", Table_ID: 1, ArrivedAt: &arrived, LeftAt: &left}),
+		// The seats in an entry are never trusted, they are added up from the guests
+		entry(10, model.LedgerTableChanged, model.LedgerStreamTable, 1, model.Table{Id: 1, Event_ID: 1, Capacity: 8, Reserved: 99, Occupied: 99}),
+	}
+
+	state, err := ledger.Project(entries)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(state.Guests))
+	assert.Equal(t, 1, state.Guests[1].Acompanying_Guests)
+	assert.Equal(t, []int{1, 2}, []int{state.GuestList()[0].Id, state.GuestList()[1].Id})
+
+	assert.Equal(t, 8, state.Tables[1].Capacity)
+	assert.Equal(t, 2, state.Tables[1].Reserved)
+	assert.Equal(t, 2, state.Tables[1].Occupied)
+	assert.Equal(t, 0, state.Tables[2].Reserved)
+
+	_, err = ledger.Project([]model.LedgerEntry{{Id: 1, Stream: "visit", Data: "{}"}})
+	assert.NotNil(t, err)
+	_, err = ledger.Project([]model.LedgerEntry{{Id: 1, Stream: model.LedgerStreamGuest, Data: "{"}})
+	assert.NotNil(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	arrived := time.Date(2026, 12, 31, 20, 0, 0, 0, time.UTC)
+	entries := []model.LedgerEntry{
+		entry(1, model.LedgerTableAdded, model.LedgerStreamTable, 1, model.Table{Id: 1, Event_ID: 1, Capacity: 10}),
+		entry(2, model.LedgerGuestArrived, model.LedgerStreamGuest, 1, model.Guest{Id: 1, Event_ID: 1, PublicId: "g_a", Name: "Echez", Table_ID: 1, Acompanying_Guests: 1, ArrivedAt: &arrived}),
+		entry(3, model.LedgerGuestReserved, model.LedgerStreamGuest, 2, model.Guest{Id: 2, Event_ID: 1, PublicId: "g_b", Name: "John", Table_ID: 1}),
+	}
+	state, err := ledger.Project(entries)
+	assert.Nil(t, err)
+
+	// The same instant in another zone is not a difference
+	local := arrived.In(time.FixedZone("CET", 3600))
+	guests := []model.Guest{{Id: 1, Event_ID: 1, PublicId: "g_a", Name: "Echez", Table_ID: 1, Acompanying_Guests: 1, ArrivedAt: &local}, {Id: 2, Event_ID: 1, PublicId: "g_b", Name: "John", Table_ID: 1}}
+	tables := []model.Table{{Id: 1, Event_ID: 1, Capacity: 10, Reserved: 3, Occupied: 2}}
+	assert.Equal(t, 0, len(ledger.Compare(state, guests, tables)))
+
+	// A bug that checked John in without the ledger hearing of it, and a guest the ledger never had
+	guests[1].ArrivedAt = &arrived
+	guests = append(guests, model.Guest{Id: 3, Event_ID: 1, Name: "Sara", Table_ID: 1})
+	tables[0].Occupied = 3
+	tables[0].Capacity = 8
+	assert.Equal(t, []ledger.Difference{
+		{Entity: "guest", Entity_ID: 2, Field: "arrived_at", Ledger: "", Stored: "2026-12-31T20:00:00Z"},
+		{Entity: "guest", Entity_ID: 3, Ledger: "missing", Stored: "Sara"},
+		{Entity: "table", Entity_ID: 1, Field: "capacity", Ledger: "10", Stored: "8"},
+		{Entity: "table", Entity_ID: 1, Field: "occupied", Ledger: "2", Stored: "3"},
+	}, ledger.Compare(state, guests, tables))
+}
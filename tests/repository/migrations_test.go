@@ -0,0 +1,79 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/ledger"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/logger"
+)
+
+// A database from before visits were recorded, when arrivals were "15:04" strings
+var legacySchema = []string{
+	"CREATE TABLE `event` (`id` integer PRIMARY KEY AUTOINCREMENT, `name` text, `venue` text, `start_time` datetime, `end_time` datetime, `timezone` text, `overbook_allowance` integer)",
+	"CREATE TABLE `table` (`id` integer PRIMARY KEY AUTOINCREMENT, `event_id` integer, `capacity` integer)",
+	"CREATE TABLE `guest` (`id` integer PRIMARY KEY AUTOINCREMENT, `event_id` integer, `public_id` text, `name` text, `table_id` integer, `acompanying_guests` integer, `time_arrived` text)",
+	"CREATE TABLE `schema_migration` (`name` text PRIMARY KEY, `applied_at` datetime)",
+	"INSERT INTO `schema_migration` VALUES ('0001_restore_table_capacity', '2026-01-01 00:00:00'), ('0002_default_event', '2026-01-01 00:00:00'), ('0003_guest_public_ids', '2026-01-01 00:00:00')",
+	"INSERT INTO `event` VALUES (1, 'Gala', '', '2026-07-31 19:00:00+00:00', '2026-08-01 01:00:00+00:00', 'Europe/London', 0)",
+	"INSERT INTO `table` VALUES (1, 1, 10)",
+	"INSERT INTO `guest` VALUES (1, 1, 'g_aaaaaaaaaaaaaaaa', 'Echez', 1, 2, '21:15'), (2, 1, 'g_bbbbbbbbbbbbbbbb', 'John', 1, 0, '00:30'), (3, 1, 'g_cccccccccccccccc', 'Hannah', 1, 1, '')",
+}
+
+func TestMigrateClockTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "party.db")
+
+	db, err := repository.NewDatabase(repository.DriverSQLite, path, logger.Silent)
+	assert.Nil(t, err)
+	for _, statement := range legacySchema {
+		assert.Nil(t, db.Exec(statement).Error)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+
+	store, err := repository.NewStore(repository.DriverSQLite, path, logger.Silent)
+	assert.Nil(t, err)
+
+	// The event starts at 20:00 in London, John arrived after midnight
+	arrived, err := store.Guests.GetArrivedGuests(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(arrived))
+	assert.Equal(t, "Echez", arrived[0].Name)
+	assert.True(t, time.Date(2026, 7, 31, 20, 15, 0, 0, time.UTC).Equal(*arrived[0].ArrivedAt))
+	assert.Equal(t, "John", arrived[1].Name)
+	assert.True(t, time.Date(2026, 7, 31, 23, 30, 0, 0, time.UTC).Equal(*arrived[1].ArrivedAt))
+
+	// The guests at the party are given the visit they are on
+	visits, err := store.Visits.FindByGuest(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(visits))
+	assert.True(t, arrived[1].ArrivedAt.Equal(visits[0].ArrivedAt))
+	assert.Nil(t, visits[0].LeftAt)
+
+	table, err := store.Tables.FindById(1, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, table.Reserved)
+	assert.Equal(t, 4, table.Occupied)
+
+	// The ledger starts with the guests and tables as they were migrated, so it projects them as they are
+	entries, err := store.Ledger.FindAll(1)
+	assert.Nil(t, err)
+	var kinds []string
+	for _, v := range entries {
+		kinds = append(kinds, v.Type)
+	}
+	assert.Equal(t, []string{model.LedgerTableAdded, model.LedgerGuestArrived, model.LedgerGuestArrived, model.LedgerGuestReserved}, kinds)
+	state, err := ledger.Project(entries)
+	assert.Nil(t, err)
+	guests, _ := store.Guests.FindAll(1)
+	tables, _ := store.Tables.FindAll(1)
+	assert.Equal(t, 0, len(ledger.Compare(state, guests, tables)))
+
+	db, err = repository.NewDatabase(repository.DriverSQLite, path, logger.Silent)
+	assert.Nil(t, err)
+	assert.False(t, db.Migrator().HasColumn(&model.Guest{}, "time_arrived"))
+}
@@ -0,0 +1,470 @@
+// These tests are run against every storage backend, so they all behave the same way
+package repository_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/tests/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// A time on the day of the party
+func at(hour int, minute int) *time.Time {
+	t := time.Date(2026, 12, 31, hour, minute, 0, 0, time.UTC)
+	return &t
+}
+
+func TestGuestRepository(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			event, _ := store.Events.Save(model.Event{Name: "Gala"})
+			other, _ := store.Events.Save(model.Event{Name: "Other"})
+
+			table, err := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: 10})
+			assert.Nil(t, err)
+
+			echez, err := store.Guests.Save(model.Guest{Event_ID: event.Id, Name: "Echez", Table_ID: table.Id, Acompanying_Guests: 2})
+			assert.Nil(t, err)
+			assert.NotEqual(t, 0, echez.Id)
+
+			_, err = store.Guests.Save(model.Guest{Event_ID: event.Id, Name: "John", Table_ID: table.Id, Acompanying_Guests: 1})
+			assert.Nil(t, err)
+
+			guests, err := store.Guests.FindAll(event.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(guests))
+			assert.Equal(t, "Echez", guests[0].Name)
+
+			guests, err = store.Guests.FindAll(other.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, len(guests))
+
+			assert.True(t, model.IsGuestPublicId(echez.PublicId))
+
+			guest, err := store.Guests.FindByPublicId(event.Id, echez.PublicId)
+			assert.Nil(t, err)
+			assert.Equal(t, echez.Id, guest.Id)
+
+			_, err = store.Guests.FindByPublicId(other.Id, echez.PublicId)
+			assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+			// A second guest with the same name is told apart by the public id
+			twin, err := store.Guests.Save(model.Guest{Event_ID: event.Id, Name: "Echez", Table_ID: table.Id})
+			assert.Nil(t, err)
+			assert.NotEqual(t, echez.PublicId, twin.PublicId)
+
+			named, err := store.Guests.FindAllByName(event.Id, "Echez")
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(named))
+			assert.Equal(t, echez.Id, named[0].Id)
+			assert.Equal(t, twin.Id, named[1].Id)
+			assert.Nil(t, store.Guests.Delete(twin))
+
+			named, err = store.Guests.FindAllByName(event.Id, "Nobody")
+			assert.Nil(t, err)
+			assert.Equal(t, 0, len(named))
+			named, err = store.Guests.FindAllByName(other.Id, "Echez")
+			assert.Nil(t, err)
+			assert.Equal(t, 0, len(named))
+
+			guest.ArrivedAt = at(19, 30)
+			assert.Nil(t, store.Guests.Update(guest))
+
+			arrived, err := store.Guests.GetArrivedGuests(event.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(arrived))
+			assert.True(t, at(19, 30).Equal(*arrived[0].ArrivedAt))
+
+			assert.Nil(t, store.Guests.Delete(guest))
+
+			guests, err = store.Guests.FindAll(event.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(guests))
+			assert.Equal(t, "John", guests[0].Name)
+		})
+	}
+}
+
+func TestTableRepository(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			event, _ := store.Events.Save(model.Event{Name: "Gala"})
+			other, _ := store.Events.Save(model.Event{Name: "Other"})
+
+			table, err := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: 10})
+			assert.Nil(t, err)
+			empty, err := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: 4})
+			assert.Nil(t, err)
+			_, err = store.Tables.Save(model.Table{Event_ID: other.Id, Capacity: 6})
+			assert.Nil(t, err)
+
+			store.Guests.Save(model.Guest{Event_ID: event.Id, Name: "Echez", Table_ID: table.Id, Acompanying_Guests: 2, ArrivedAt: at(19, 30)})
+			store.Guests.Save(model.Guest{Event_ID: event.Id, Name: "John", Table_ID: table.Id, Acompanying_Guests: 1})
+			// Guests who have left hold no seats
+			store.Guests.Save(model.Guest{Event_ID: event.Id, Name: "Hannah", Table_ID: table.Id, Acompanying_Guests: 4, ArrivedAt: at(19, 0), LeftAt: at(20, 0)})
+
+			tables, err := store.Tables.FindAll(event.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(tables))
+			assert.Equal(t, model.Table{Id: table.Id, Event_ID: event.Id, Capacity: 10, Reserved: 5, Occupied: 3}, tables[0])
+			assert.Equal(t, model.Table{Id: empty.Id, Event_ID: event.Id, Capacity: 4}, tables[1])
+
+			found, err := store.Tables.FindById(event.Id, table.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 7, found.Free())
+
+			found, err = store.Tables.FindById(other.Id, table.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, found.Id)
+
+			err = store.UnitOfWork.Do(func(repos repository.Repositories) error {
+				locked, err := repos.Tables.FindByIdForUpdate(event.Id, table.Id)
+				assert.Nil(t, err)
+				assert.Equal(t, 5, locked.Reserved)
+				assert.Equal(t, 3, locked.Occupied)
+				return nil
+			})
+			assert.Nil(t, err)
+
+			err = store.UnitOfWork.Do(func(repos repository.Repositories) error {
+				_, err := repos.Tables.FindByIdForUpdate(other.Id, table.Id)
+				return err
+			})
+			assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+			found, _ = store.Tables.FindById(event.Id, table.Id)
+			found.Capacity = 12
+			assert.Nil(t, store.Tables.Update(found))
+			found, _ = store.Tables.FindById(event.Id, table.Id)
+			assert.Equal(t, 12, found.Capacity)
+
+			assert.Nil(t, store.Tables.Delete(empty))
+			tables, _ = store.Tables.FindAll(event.Id)
+			assert.Equal(t, 1, len(tables))
+		})
+	}
+}
+
+func TestEventRepository(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			start := time.Date(2026, 12, 31, 20, 0, 0, 0, time.UTC)
+			event, err := store.Events.Save(model.Event{Name: "Gala", Venue: "Town hall", StartTime: start, EndTime: start.Add(5 * time.Hour), Timezone: "Europe/London"})
+			assert.Nil(t, err)
+			assert.NotEqual(t, 0, event.Id)
+
+			found, err := store.Events.FindById(event.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, "Town hall", found.Venue)
+			assert.True(t, start.Equal(found.StartTime))
+
+			_, err = store.Events.FindById(1000)
+			assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+			found.OverbookAllowance = 10
+			assert.Nil(t, store.Events.Update(found))
+
+			events, err := store.Events.FindAll()
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(events))
+			assert.Equal(t, 10, events[0].OverbookAllowance)
+		})
+	}
+}
+
+func TestVisitRepository(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			first, err := store.Visits.Save(model.Visit{Event_ID: 1, Guest_ID: 1, ArrivedAt: *at(19, 0), LeftAt: at(20, 0)})
+			assert.Nil(t, err)
+			second, err := store.Visits.Save(model.Visit{Event_ID: 1, Guest_ID: 1, Acompanying_Guests: 2, ArrivedAt: *at(21, 0)})
+			assert.Nil(t, err)
+			store.Visits.Save(model.Visit{Event_ID: 1, Guest_ID: 2, ArrivedAt: *at(19, 0)})
+
+			visits, err := store.Visits.FindByGuest(1)
+			assert.Nil(t, err)
+			assert.Equal(t, []model.Visit{first, second}, visits)
+
+			open, err := store.Visits.FindOpen(1)
+			assert.Nil(t, err)
+			assert.Equal(t, second.Id, open.Id)
+
+			open.LeftAt = at(23, 0)
+			assert.Nil(t, store.Visits.Update(open))
+			_, err = store.Visits.FindOpen(1)
+			assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+			assert.Nil(t, store.Visits.DeleteByGuest(1))
+			visits, _ = store.Visits.FindByGuest(1)
+			assert.Equal(t, 0, len(visits))
+			visits, _ = store.Visits.FindByGuest(2)
+			assert.Equal(t, 1, len(visits))
+		})
+	}
+}
+
+// Reads every page of the query, limit rows at a time, and returns the names in order
+func pageGuests(t *testing.T, guests repository.GuestRepository, eventId int, q repository.GuestQuery, limit int) []string {
+	t.Helper()
+
+	var names []string
+	q.Limit = limit
+	for {
+		page, err := guests.Query(eventId, q)
+		assert.Nil(t, err)
+		for _, v := range page.Guests {
+			names = append(names, v.Name)
+		}
+		if page.Next == nil {
+			return names
+		}
+		// The cursor is passed on as a string, like the routes do
+		after, err := repository.DecodeCursor(page.Next.Encode())
+		assert.Nil(t, err)
+		q.After = &after
+	}
+}
+
+func TestGuestQuery(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			event, _ := store.Events.Save(model.Event{Name: "Gala"})
+			first, _ := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: 10})
+			second, _ := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: 10})
+
+			for _, guest := range []model.Guest{
+				{Name: "Sara", Table_ID: second.Id, Acompanying_Guests: 1, ArrivedAt: at(20, 0)},
+				{Name: "Echez", Table_ID: first.Id, Acompanying_Guests: 3},
+				{Name: "Ade", Table_ID: second.Id, ArrivedAt: at(19, 30), LeftAt: at(21, 0)},
+				{Name: "Sam", Table_ID: first.Id, Acompanying_Guests: 2, ArrivedAt: at(20, 0)},
+				{Name: "Bo_b", Table_ID: first.Id},
+			} {
+				guest.Event_ID = event.Id
+				_, err := store.Guests.Save(guest)
+				assert.Nil(t, err)
+			}
+
+			// Every page size gives the same order as one page
+			orders := []struct {
+				sort  string
+				desc  bool
+				names []string
+			}{
+				{repository.GuestSortId, false, []string{"Sara", "Echez", "Ade", "Sam", "Bo_b"}},
+				{repository.GuestSortId, true, []string{"Bo_b", "Sam", "Ade", "Echez", "Sara"}},
+				{repository.GuestSortName, false, []string{"Ade", "Bo_b", "Echez", "Sam", "Sara"}},
+				{repository.GuestSortName, true, []string{"Sara", "Sam", "Echez", "Bo_b", "Ade"}},
+				{repository.GuestSortTable, false, []string{"Echez", "Sam", "Bo_b", "Sara", "Ade"}},
+				// Guests who have not arrived come last both ways round
+				{repository.GuestSortArrival, false, []string{"Ade", "Sara", "Sam", "Echez", "Bo_b"}},
+				{repository.GuestSortArrival, true, []string{"Sam", "Sara", "Ade", "Bo_b", "Echez"}},
+			}
+			for _, order := range orders {
+				for _, limit := range []int{1, 2, 5} {
+					q := repository.GuestQuery{Sort: order.sort, Desc: order.desc}
+					assert.Equal(t, order.names, pageGuests(t, store.Guests, event.Id, q, limit), "%s desc=%v limit=%d", order.sort, order.desc, limit)
+				}
+			}
+
+			arrived, notArrived := true, false
+			filters := []struct {
+				q     repository.GuestQuery
+				names []string
+			}{
+				{repository.GuestQuery{Table_ID: first.Id}, []string{"Echez", "Sam", "Bo_b"}},
+				{repository.GuestQuery{Arrived: &arrived}, []string{"Sara", "Ade", "Sam"}},
+				{repository.GuestQuery{Arrived: &notArrived}, []string{"Echez", "Bo_b"}},
+				{repository.GuestQuery{Present: true}, []string{"Sara", "Sam"}},
+				{repository.GuestQuery{MinPartySize: 2, MaxPartySize: 3}, []string{"Sara", "Sam"}},
+				{repository.GuestQuery{NamePrefix: "sa"}, []string{"Sara", "Sam"}},
+				// The wildcards of LIKE are matched as they are written
+				{repository.GuestQuery{NamePrefix: "Bo_"}, []string{"Bo_b"}},
+				{repository.GuestQuery{NamePrefix: "B_"}, nil},
+			}
+			for _, filter := range filters {
+				page, err := store.Guests.Query(event.Id, filter.q)
+				assert.Nil(t, err)
+				var names []string
+				for _, v := range page.Guests {
+					names = append(names, v.Name)
+				}
+				assert.Equal(t, filter.names, names)
+				assert.Equal(t, int64(len(filter.names)), page.Total)
+			}
+
+			// The total counts every page
+			page, _ := store.Guests.Query(event.Id, repository.GuestQuery{Limit: 2})
+			assert.Equal(t, 2, len(page.Guests))
+			assert.Equal(t, int64(5), page.Total)
+
+			// A cursor only works for the order it was made for
+			_, err := store.Guests.Query(event.Id, repository.GuestQuery{Sort: repository.GuestSortName, After: page.Next})
+			assert.True(t, errors.Is(err, repository.ErrInvalidCursor))
+			_, err = repository.DecodeCursor("not a cursor")
+			assert.True(t, errors.Is(err, repository.ErrInvalidCursor))
+		})
+	}
+}
+
+func TestTableQuery(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			event, _ := store.Events.Save(model.Event{Name: "Gala"})
+			var ids []int
+			for _, capacity := range []int{6, 2, 6, 4} {
+				table, _ := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: capacity})
+				ids = append(ids, table.Id)
+			}
+			store.Guests.Save(model.Guest{Event_ID: event.Id, Name: "Echez", Table_ID: ids[3], Acompanying_Guests: 1})
+
+			var capacities []int
+			var reserved []int
+			q := repository.TableQuery{Sort: repository.TableSortCapacity, Desc: true, Limit: 1}
+			for {
+				page, err := store.Tables.Query(event.Id, q)
+				assert.Nil(t, err)
+				assert.Equal(t, int64(4), page.Total)
+				for _, v := range page.Tables {
+					capacities = append(capacities, v.Capacity)
+					reserved = append(reserved, v.Reserved)
+				}
+				if page.Next == nil {
+					break
+				}
+				q.After = page.Next
+			}
+			assert.Equal(t, []int{6, 6, 4, 2}, capacities)
+			assert.Equal(t, []int{0, 0, 2, 0}, reserved)
+		})
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			event, _ := store.Events.Save(model.Event{Name: "Gala"})
+			other, _ := store.Events.Save(model.Event{Name: "Picnic"})
+			start := time.Date(2026, 12, 31, 19, 0, 0, 0, time.UTC)
+			for i, action := range []string{model.AuditTableCreated, model.AuditGuestCreated, model.AuditGuestCheckedIn, model.AuditGuestCreated} {
+				_, err := store.Audit.Save(model.AuditEntry{Event_ID: event.Id, Actor: "office", Action: action, CreatedAt: start.Add(time.Duration(i) * time.Hour)})
+				assert.Nil(t, err)
+			}
+			store.Audit.Save(model.AuditEntry{Event_ID: other.Id, Actor: "office", Action: model.AuditGuestCreated, CreatedAt: start})
+
+			var actions []string
+			q := repository.AuditQuery{Desc: true, Limit: 3}
+			for {
+				page, err := store.Audit.Query(event.Id, q)
+				assert.Nil(t, err)
+				assert.Equal(t, int64(4), page.Total)
+				for _, v := range page.Entries {
+					actions = append(actions, v.Action)
+				}
+				if page.Next == nil {
+					break
+				}
+				q.After = page.Next
+			}
+			assert.Equal(t, []string{model.AuditGuestCreated, model.AuditGuestCheckedIn, model.AuditGuestCreated, model.AuditTableCreated}, actions)
+
+			page, err := store.Audit.Query(event.Id, repository.AuditQuery{Action: model.AuditGuestCreated, Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)})
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(page.Entries))
+			assert.Equal(t, start.Add(time.Hour), page.Entries[0].CreatedAt.UTC())
+		})
+	}
+}
+
+func TestUnitOfWorkRollsBack(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			event, _ := store.Events.Save(model.Event{Name: "Gala"})
+			table, _ := store.Tables.Save(model.Table{Event_ID: event.Id, Capacity: 10})
+			failure := errors.New("failure")
+
+			err := store.UnitOfWork.Do(func(repos repository.Repositories) error {
+				_, err := repos.Guests.Save(model.Guest{Event_ID: event.Id, Name: "Echez", Table_ID: table.Id})
+				assert.Nil(t, err)
+
+				table.Capacity = 2
+				assert.Nil(t, repos.Tables.Update(table))
+
+				return failure
+			})
+			assert.Equal(t, failure, err)
+
+			guests, _ := store.Guests.FindAll(event.Id)
+			found, _ := store.Tables.FindById(event.Id, table.Id)
+			assert.Equal(t, 0, len(guests))
+			assert.Equal(t, 10, found.Capacity)
+		})
+	}
+}
+
+func TestUnitOfWorkRollsBackHistory(t *testing.T) {
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			store := backend.Store
+
+			event, _ := store.Events.Save(model.Event{Name: "Gala"})
+			webhook, _ := store.Webhooks.Save(model.Webhook{Event_ID: event.Id, URL: "https://example.com/hook"})
+			store.Audit.Save(model.AuditEntry{Event_ID: event.Id, Actor: "office", Action: model.AuditTableCreated})
+			store.Ledger.Append(model.LedgerEntry{Event_ID: event.Id, Type: "table_added"})
+			delivery, _ := store.Deliveries.Save(model.WebhookDelivery{Event_ID: event.Id, Webhook_ID: webhook.Id, Status: model.DeliveryPending})
+			failure := errors.New("failure")
+
+			err := store.UnitOfWork.Do(func(repos repository.Repositories) error {
+				repos.Audit.Save(model.AuditEntry{Event_ID: event.Id, Actor: "office", Action: model.AuditGuestCreated})
+				repos.Ledger.Append(model.LedgerEntry{Event_ID: event.Id, Type: "guest_added"})
+				repos.Deliveries.Save(model.WebhookDelivery{Event_ID: event.Id, Webhook_ID: webhook.Id, Status: model.DeliveryPending})
+
+				sent := delivery
+				sent.Status = model.DeliveryDelivered
+				assert.Nil(t, repos.Deliveries.Update(sent))
+
+				return failure
+			})
+			assert.Equal(t, failure, err)
+
+			audit, _ := store.Audit.Query(event.Id, repository.AuditQuery{})
+			ledger, _ := store.Ledger.FindAll(event.Id)
+			deliveries, _ := store.Deliveries.Query(event.Id, repository.DeliveryQuery{})
+			assert.Equal(t, 1, len(audit.Entries))
+			assert.Equal(t, 1, len(ledger))
+			assert.Equal(t, 1, len(deliveries.Deliveries))
+			assert.Equal(t, delivery.Id, deliveries.Deliveries[0].Id)
+			assert.Equal(t, model.DeliveryPending, deliveries.Deliveries[0].Status)
+
+			// What is written after the failed unit of work is kept
+			err = store.UnitOfWork.Do(func(repos repository.Repositories) error {
+				_, err := repos.Ledger.Append(model.LedgerEntry{Event_ID: event.Id, Type: "guest_added"})
+				return err
+			})
+			assert.Nil(t, err)
+			ledger, _ = store.Ledger.FindAll(event.Id)
+			assert.Equal(t, 2, len(ledger))
+			assert.Equal(t, "guest_added", ledger[1].Type)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package search_test
+
+import (
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "zoe", search.Normalize("Zoë"))
+	assert.Equal(t, "mary jane o neil", search.Normalize("  Mary-Jane O'Neil "))
+	assert.Equal(t, "francois", search.Normalize("FRANÇOIS"))
+	assert.Equal(t, "", search.Normalize("--"))
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, search.Levenshtein("hannah", "hannah"))
+	assert.Equal(t, 1, search.Levenshtein("hanna", "hannah"))
+	assert.Equal(t, 3, search.Levenshtein("kitten", "sitting"))
+	assert.Equal(t, 4, search.Levenshtein("", "abcd"))
+}
+
+func TestScore(t *testing.T) {
+	assert.Equal(t, 1.0, search.Score("zoe", "Zoë"))
+	assert.Equal(t, 1.0, search.Score("HANNAH", "hannah"))
+
+	// A missing letter, a swapped letter and a single word of the name all still match
+	assert.GreaterOrEqual(t, search.Score("Hanna", "Hannah"), search.MinScore)
+	assert.GreaterOrEqual(t, search.Score("Jhon", "John"), search.MinScore)
+	assert.GreaterOrEqual(t, search.Score("hanna", "Hannah Smith"), search.MinScore)
+	assert.GreaterOrEqual(t, search.Score("Smyth", "Hannah Smith"), search.MinScore)
+
+	// The closer spelling ranks higher
+	assert.Greater(t, search.Score("hanna", "Hannah"), search.Score("hanna", "Anna"))
+
+	assert.Less(t, search.Score("Hannah", "Boris"), search.MinScore)
+	assert.Equal(t, 0.0, search.Score("", "Hannah"))
+}
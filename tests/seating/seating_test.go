@@ -0,0 +1,144 @@
+package seating_test
+
+import (
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/seating"
+	"github.com/stretchr/testify/assert"
+)
+
+func parties(sizes ...int) []seating.Party {
+	var res []seating.Party
+	for _, size := range sizes {
+		res = append(res, seating.Party{Size: size})
+	}
+	return res
+}
+
+// The people at each table in the plan
+func seated(plan seating.Plan, parties []seating.Party) map[int]int {
+	res := make(map[int]int)
+	for i, table := range plan.Tables {
+		if table != 0 {
+			res[table] += parties[i].Size
+		}
+	}
+	return res
+}
+
+func TestSolve(t *testing.T) {
+	t.Run("Finds the plan that best fit decreasing misses", func(t *testing.T) {
+		// Biggest first puts 5 and 4 together and needs a third table, 5+3+2 and 4+3+3 fill two exactly
+		tables := []seating.Table{{Id: 1, Room: 10}, {Id: 2, Room: 10}, {Id: 3, Room: 10}}
+		ps := parties(5, 4, 3, 3, 3, 2)
+
+		plan := seating.Solve(tables, ps)
+		assert.Equal(t, seating.SolverExact, plan.Solver)
+		assert.Equal(t, 20, plan.Seated)
+		assert.Equal(t, 0, plan.Wasted)
+		assert.Equal(t, 2, len(seated(plan, ps)))
+	})
+
+	t.Run("Keeps parties together and seats the most people", func(t *testing.T) {
+		ps := parties(3, 7, 4, 3)
+		plan := seating.Solve([]seating.Table{{Id: 1, Room: 6}}, ps)
+
+		// The party of 7 fits nowhere, two parties of 3 fill the table
+		assert.Equal(t, []int{1, 0, 0, 1}, plan.Tables)
+		assert.Equal(t, 6, plan.Seated)
+		assert.Equal(t, 0, plan.Wasted)
+	})
+
+	t.Run("Fills tables in use before starting another", func(t *testing.T) {
+		tables := []seating.Table{{Id: 1, Room: 2}, {Id: 2, Room: 4, InUse: true}}
+		plan := seating.Solve(tables, parties(2))
+
+		assert.Equal(t, []int{2}, plan.Tables)
+		assert.Equal(t, 2, plan.Wasted)
+	})
+
+	t.Run("Large events use the heuristic", func(t *testing.T) {
+		var tables []seating.Table
+		for i := 1; i <= 30; i++ {
+			tables = append(tables, seating.Table{Id: i, Room: 8})
+		}
+		var sizes []int
+		for i := 0; i < 60; i++ {
+			sizes = append(sizes, 1+i%5)
+		}
+		ps := parties(sizes...)
+
+		plan := seating.Solve(tables, ps)
+		assert.Equal(t, seating.SolverHeuristic, plan.Solver)
+		assert.Equal(t, 180, plan.Seated)
+		for _, people := range seated(plan, ps) {
+			assert.LessOrEqual(t, people, 8)
+		}
+	})
+
+	t.Run("No tables", func(t *testing.T) {
+		plan := seating.Solve(nil, parties(2, 3))
+		assert.Equal(t, []int{0, 0}, plan.Tables)
+		assert.Equal(t, 0, plan.Seated)
+	})
+}
+
+func TestSolveConstraints(t *testing.T) {
+	t.Run("Keeps parties to their tables", func(t *testing.T) {
+		tables := []seating.Table{{Id: 1, Room: 4}, {Id: 2, Room: 4}}
+		ps := []seating.Party{{Size: 4, Tables: []int{2}}, {Size: 4, NotTables: []int{1}}}
+
+		// Only one of them can sit at table 2
+		plan := seating.Solve(tables, ps)
+		assert.Equal(t, []int{2, 0}, plan.Tables)
+		assert.Equal(t, 4, plan.Seated)
+	})
+
+	t.Run("An empty list of tables seats nowhere", func(t *testing.T) {
+		plan := seating.Solve([]seating.Table{{Id: 1, Room: 4}}, []seating.Party{{Size: 1, Tables: []int{}}})
+		assert.Equal(t, []int{0}, plan.Tables)
+	})
+
+	t.Run("Keeps parties apart", func(t *testing.T) {
+		tables := []seating.Table{{Id: 1, Room: 6}, {Id: 2, Room: 6}}
+		ps := []seating.Party{{Size: 2, Apart: []int{1}}, {Size: 2}, {Size: 2}}
+
+		plan := seating.Solve(tables, ps)
+		assert.Equal(t, 6, plan.Seated)
+		assert.NotEqual(t, plan.Tables[0], plan.Tables[1])
+	})
+
+	t.Run("Seats the wishes with the highest priority near the stage", func(t *testing.T) {
+		tables := []seating.Table{{Id: 1, Room: 4}, {Id: 2, Room: 4, NearStage: true}}
+		ps := []seating.Party{{Size: 4, Priority: 1}, {Size: 4, Priority: 3}}
+
+		plan := seating.Solve(tables, ps)
+		assert.Equal(t, []int{1, 2}, plan.Tables)
+		assert.Equal(t, 3, plan.Stage)
+	})
+
+	t.Run("The heuristic keeps the same rules", func(t *testing.T) {
+		var tables []seating.Table
+		for i := 1; i <= 20; i++ {
+			tables = append(tables, seating.Table{Id: i, Room: 6, NearStage: i <= 2})
+		}
+		var ps []seating.Party
+		for i := 0; i < 30; i++ {
+			ps = append(ps, seating.Party{Size: 1 + i%3})
+		}
+		ps[0].Priority = 1
+		ps[1].Apart = []int{2, 3}
+		ps[4].Tables = []int{7}
+
+		plan := seating.Solve(tables, ps)
+		assert.Equal(t, seating.SolverHeuristic, plan.Solver)
+		assert.Equal(t, 60, plan.Seated)
+		assert.LessOrEqual(t, plan.Tables[0], 2)
+		assert.NotEqual(t, plan.Tables[1], plan.Tables[2])
+		assert.NotEqual(t, plan.Tables[1], plan.Tables[3])
+		assert.Equal(t, 7, plan.Tables[4])
+		for _, people := range seated(plan, ps) {
+			assert.LessOrEqual(t, people, 6)
+		}
+	})
+}
@@ -1,510 +1,240 @@
-//This package will hold all the tests for the service layer
+//This package will hold all the tests for the service layer, they run on every storage backend
 package service_test
 
 import (
 	"errors"
 	"testing"
-	"time"
 
 	"github.com/getground/tech-tasks/backend/pkg/dto"
 	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/tests/testutil"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 )
 
-// Initialising a mock object to act as the repo
-type MockGuestRepo struct {
-	guestMock mock.Mock
-}
-
-// These are mock functions, they simulate the behaviour of the real function, so any arguments that will be passed in and what will be returned
-
-func (m *MockGuestRepo) FindAll() ([]model.Guest, error) {
-	args := m.guestMock.Called()
-	if args.Error(1) != nil {
-		return []model.Guest{}, args.Error(1)
-	}
-
-	return args.Get(0).([]model.Guest), nil
-}
-
-func (m *MockGuestRepo) FindByName(name string) (model.Guest, error) {
-	args := m.guestMock.Called(name)
-	if args.Error(1) != nil {
-		return model.Guest{}, args.Error(1)
-	}
-
-	return args.Get(0).(model.Guest), nil
-}
-
-func (m *MockTableRepo) FindTableById(id int) (model.Table, error) {
-	args := m.tableMock.Called(id)
-	if args.Error(1) != nil {
-		return model.Table{}, args.Error(1)
-	}
-
-	return args.Get(0).(model.Table), nil
-}
+// The event the tests add their tables and guests to
+const eventId = 1
 
-func (m *MockGuestRepo) Save(guest model.Guest) (model.Guest, error) {
-	args := m.guestMock.Called(guest)
-	if args.Error(1) != nil {
-		return model.Guest{}, args.Error(1)
-	}
+// Builds the table and guest services on top of the store, with the event they work against
+func newServices(store repository.Store) (service.TableService, service.GuestService) {
+	store.Events.Save(model.Event{Id: eventId, Name: "Gala"})
 
-	return args.Get(0).(model.Guest), nil
-}
-
-func (m *MockGuestRepo) UpdateGuest(guest model.Guest) (model.Guest, error) {
-	args := m.guestMock.Called(guest)
-	if args.Error(1) != nil {
-		return model.Guest{}, args.Error(1)
-	}
-
-	return args.Get(0).(model.Guest), nil
-}
-
-func (m *MockTableRepo) UpdateTable(table model.Table) (model.Table, error) {
-	args := m.tableMock.Called(table)
-	if args.Error(1) != nil {
-		return model.Table{}, args.Error(1)
-	}
-
-	return args.Get(0).(model.Table), nil
-}
-
-func (m *MockGuestRepo) GetArrivedGuests() ([]model.Guest, error) {
-	args := m.guestMock.Called()
-	if args.Error(1) != nil {
-		return []model.Guest{}, args.Error(1)
-	}
-
-	return args.Get(0).([]model.Guest), nil
-}
-
-func (m *MockGuestRepo) Delete(guest model.Guest) error {
-	args := m.guestMock.Called(guest)
-	if args.Error(0) != nil {
-		return args.Error(0)
-	}
-
-	return nil
+	opts := service.GuestOptions{}
+	tableService := service.NewTableService(store.Events, store.Tables, store.UnitOfWork, opts)
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, opts)
+	return tableService, guestService
 }
 
 // This will test a success use case when trying to retrieve all guests
 func TestGuestFindAllSuccess(t *testing.T) {
-	testObj := new(MockGuestRepo)
-
-	var res dto.GuestResDto
-	var resArr []dto.GuestResDto
-
-	expectedRes := []model.Guest{
-		{
-			Id:                 1,
-			Name:               "Echez",
-			Table_ID:           3,
-			Acompanying_Guests: 2,
-			TimeArrived:        "",
-		},
-		{
-			Id:                 2,
-			Name:               "John",
-			Table_ID:           1,
-			Acompanying_Guests: 6,
-			TimeArrived:        "19:30",
-		},
-		{
-			Id:                 3,
-			Name:               "Hannah",
-			Table_ID:           6,
-			Acompanying_Guests: 10,
-			TimeArrived:        "22:16",
-		},
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10, 10, 12)
+
+			for _, req := range []dto.GuestReqDto{
+				{Name: "Echez", Table_ID: tables[2].Id, Acompanying_Guests: 2},
+				{Name: "John", Table_ID: tables[0].Id, Acompanying_Guests: 6},
+				{Name: "Hannah", Table_ID: tables[1].Id, Acompanying_Guests: 9},
+			} {
+				_, err := guestService.Save(eventId, req, service.Actor{})
+				assert.Nil(t, err)
+			}
+			_, err := guestService.Checkin(eventId, "John", dto.CheckinReqDto{Acompanying_Guests: 6}, service.Actor{})
+			assert.Nil(t, err)
+
+			resArr, page, err := guestService.FindAll(eventId, dto.GuestListReqDto{})
+
+			assert.Nil(t, err)
+			assert.Equal(t, int64(3), page.Total)
+			assert.Equal(t, 3, len(resArr))
+			assert.NotEqual(t, "", resArr[0].Id)
+			assert.Equal(t, "Echez", resArr[0].Name)
+			assert.Equal(t, tables[2].Id, resArr[0].Table_ID)
+			assert.Equal(t, 2, resArr[0].Acompanying_Guests)
+			assert.Equal(t, "", resArr[0].TimeArrived)
+			assert.Equal(t, "John", resArr[1].Name)
+			assert.NotEqual(t, "", resArr[1].TimeArrived)
+			assert.False(t, resArr[1].WalkIn)
+
+			// A page with no guests is empty rather than missing
+			none, _, err := guestService.FindAll(eventId, dto.GuestListReqDto{Table_ID: tables[0].Id + 100})
+			assert.Nil(t, err)
+			assert.NotNil(t, none)
+			assert.Equal(t, 0, len(none))
+		})
 	}
-
-	testObj.guestMock.On("FindAll").Return(expectedRes, nil)
-
-	testRes, err := testObj.FindAll()
-
-	for _, v := range testRes {
-		res.Id = v.Id
-		res.Name = v.Name
-		res.Table_ID = v.Table_ID
-		res.Acompanying_Guests = v.Acompanying_Guests
-
-		resArr = append(resArr, res)
-	}
-
-	testObj.guestMock.AssertExpectations(t)
-
-	assert.Equal(t, 1, resArr[0].Id)
-	assert.Equal(t, "Echez", resArr[0].Name)
-	assert.Equal(t, 3, resArr[0].Table_ID)
-	assert.Equal(t, 2, resArr[0].Acompanying_Guests)
-	assert.Equal(t, 3, len(testRes))
-	assert.Nil(t, err)
-
 }
 
 func TestGuestFindAllError(t *testing.T) {
-	testObj := new(MockGuestRepo)
-
-	testObj.guestMock.On("FindAll").Return([]model.Guest{}, errors.New("Could not retrieve guests"))
-
-	testRes, err := testObj.FindAll()
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			_, guestService := newServices(backend.Store)
 
-	testObj.guestMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "Could not retrieve guests", err.Error())
-	assert.Equal(t, []model.Guest{}, testRes)
+			resArr, _, err := guestService.FindAll(eventId, dto.GuestListReqDto{Cursor: "not-a-cursor"})
 
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrInvalidCursor))
+			assert.Equal(t, 0, len(resArr))
+		})
+	}
 }
 
 func TestGuestSaveSuccess(t *testing.T) {
-	tableTestObj := new(MockTableRepo)
-	guestTestObj := new(MockGuestRepo)
-
-	var guestResDto dto.GuestResDto
-
-	tableTestId := 1
-
-	expectedTableRes := model.Table{Id: tableTestId, Capacity: 10}
-
-	tableTestObj.tableMock.On("FindTableById", tableTestId).Return(expectedTableRes, nil)
-
-	tableRes, err := tableTestObj.FindTableById(tableTestId)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, expectedTableRes, tableRes)
-	assert.Equal(t, 10, tableRes.Capacity)
-
-	guest := model.Guest{Id: 3, Name: "John", Table_ID: tableRes.Id, Acompanying_Guests: 8}
-
-	guestTestObj.guestMock.On("Save", guest).Return(guest, nil)
-
-	guestRes, err := guestTestObj.Save(guest)
-
-	guestResDto.Name = guestRes.Name
-	guestResDto.Acompanying_Guests = guestRes.Acompanying_Guests
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, "John", guestResDto.Name)
-	assert.Equal(t, 8, guestResDto.Acompanying_Guests)
-
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
+
+			guestResDto, err := guestService.Save(eventId, dto.GuestReqDto{Name: "John", Table_ID: tables[0].Id, Acompanying_Guests: 8}, service.Actor{})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "John", guestResDto.Name)
+			assert.Equal(t, 8, guestResDto.Acompanying_Guests)
+
+			// The party reserves its seats without taking them
+			table, err := tableService.FindById(eventId, tables[0].Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 9, table.Reserved)
+			assert.Equal(t, 10, table.Free)
+		})
+	}
 }
 
 func TestGuestSaveTooManyGuestsError(t *testing.T) {
-	guestTestObj := new(MockGuestRepo)
-	var guestResDto dto.GuestResDto
-
-	request := dto.GuestReqDto{Name: "John", Table_ID: 1, Acompanying_Guests: 12}
-
-	//Find table by Id
-	table := model.Table{Id: 1, Capacity: 10}
-
-	guest := model.Guest{Id: 3, Name: "John", Table_ID: 1, Acompanying_Guests: 8}
-
-	// Check if table capacity is less than the amount of guests attending, throw error
-	if table.Capacity < (request.Acompanying_Guests + 1) {
-		guestTestObj.guestMock.On("Save", guest).Return(model.Guest{}, errors.New("There are too many guests"))
-
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
+
+			guestResDto, err := guestService.Save(eventId, dto.GuestReqDto{Name: "John", Table_ID: tables[0].Id, Acompanying_Guests: 12}, service.Actor{})
+
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrOverbooked))
+			assert.Equal(t, dto.GuestResDto{}, guestResDto)
+			guests, _, _ := guestService.FindAll(eventId, dto.GuestListReqDto{})
+			assert.Equal(t, 0, len(guests))
+		})
 	}
-
-	guestRes, err := guestTestObj.Save(guest)
-
-	guestResDto.Name = guestRes.Name
-	guestResDto.Acompanying_Guests = guestRes.Acompanying_Guests
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "There are too many guests", err.Error())
-	assert.Equal(t, dto.GuestResDto{}, guestResDto)
 }
 
 func TestGuestSaveError(t *testing.T) {
-	tableTestObj := new(MockTableRepo)
-	guestTestObj := new(MockGuestRepo)
-
-	var guestResDto dto.GuestResDto
-
-	//Find table by id
-	tableTestId := 1
-
-	expectedTableRes := model.Table{Id: tableTestId, Capacity: 10}
-
-	tableTestObj.tableMock.On("FindTableById", tableTestId).Return(expectedTableRes, nil)
-
-	tableRes, err := tableTestObj.FindTableById(tableTestId)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, expectedTableRes, tableRes)
-	assert.Equal(t, 10, tableRes.Capacity)
-
-	// Attempting to create the guest, throw error
-	guest := model.Guest{Id: 3, Name: "John", Table_ID: tableRes.Id, Acompanying_Guests: 8}
-
-	guestTestObj.guestMock.On("Save", guest).Return(model.Guest{}, errors.New("Could not create guest"))
-
-	guestRes, err := guestTestObj.Save(guest)
-
-	guestResDto.Name = guestRes.Name
-	guestResDto.Acompanying_Guests = guestRes.Acompanying_Guests
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "Could not create guest", err.Error())
-	assert.Equal(t, dto.GuestResDto{}, guestResDto)
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
+			_, err := guestService.Save(eventId, dto.GuestReqDto{Name: "John", Table_ID: tables[0].Id, Acompanying_Guests: 1}, service.Actor{})
+			assert.Nil(t, err)
+
+			// Names are unique on the guest list
+			guestResDto, err := guestService.Save(eventId, dto.GuestReqDto{Name: "John", Table_ID: tables[0].Id, Acompanying_Guests: 1}, service.Actor{})
+
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrDuplicateName))
+			assert.Equal(t, dto.GuestResDto{}, guestResDto)
+		})
+	}
 }
 
 func TestGuestCheckinSuccess(t *testing.T) {
-
-	tableTestObj := new(MockTableRepo)
-	guestTestObj := new(MockGuestRepo)
-
-	guestRequest := dto.GuestReqDto{Name: "Hannah", Table_ID: 3, Acompanying_Guests: 7}
-
-	//Find the guest
-	expectedGuestRes := model.Guest{Id: 1, Name: "Hannah", Table_ID: 3, Acompanying_Guests: 5}
-
-	guestTestObj.guestMock.On("FindByName", guestRequest.Name).Return(expectedGuestRes, nil)
-
-	guest, err := guestTestObj.FindByName(guestRequest.Name)
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 1, guest.Id)
-	assert.Equal(t, "Hannah", guest.Name)
-	assert.Equal(t, 3, guest.Table_ID)
-	assert.Equal(t, 5, guest.Acompanying_Guests)
-
-	// Find the guest's table
-	expectedTableRes := model.Table{Id: 3, Capacity: 10}
-
-	tableTestObj.tableMock.On("FindTableById", guest.Table_ID).Return(expectedTableRes, nil)
-
-	tableRes, err := tableTestObj.FindTableById(guest.Table_ID)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, expectedTableRes, tableRes)
-	assert.Equal(t, 10, tableRes.Capacity)
-
-	// Update table capacity
-	newExpectedTableRes := model.Table{Id: 3, Capacity: 3}
-
-	tableRes.Capacity -= (guestRequest.Acompanying_Guests + 1)
-
-	tableTestObj.tableMock.On("UpdateTable", tableRes).Return(newExpectedTableRes, nil)
-
-	newTableRes, err := tableTestObj.UpdateTable(tableRes)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 3, newTableRes.Id)
-	assert.Equal(t, 3, newTableRes.Capacity)
-
-	// Update the old accompanying guest number and log time of arrival
-
-	newExpectedGuestRes := model.Guest{Id: 1, Name: "Hannah", Table_ID: 3, Acompanying_Guests: 7, TimeArrived: time.Now().Format("15:04")}
-
-	guest.Acompanying_Guests = guestRequest.Acompanying_Guests
-	guest.TimeArrived = time.Now().Format("15:04")
-
-	guestTestObj.guestMock.On("UpdateGuest", guest).Return(newExpectedGuestRes, nil)
-
-	newGuest, err := guestTestObj.UpdateGuest(guest)
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 1, newGuest.Id)
-	assert.Equal(t, "Hannah", newGuest.Name)
-	assert.Equal(t, 3, newGuest.Table_ID)
-	assert.Equal(t, 7, newGuest.Acompanying_Guests)
-	assert.Equal(t, time.Now().Format("15:04"), newGuest.TimeArrived)
-
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
+			_, err := guestService.Save(eventId, dto.GuestReqDto{Name: "Hannah", Table_ID: tables[0].Id, Acompanying_Guests: 5}, service.Actor{})
+			assert.Nil(t, err)
+
+			// Hannah brings more guests than she booked for, there is room for them
+			res, err := guestService.Checkin(eventId, "Hannah", dto.CheckinReqDto{Acompanying_Guests: 7}, service.Actor{})
+
+			assert.Nil(t, err)
+			assert.Equal(t, "Hannah", res.Name)
+			newGuest, err := guestService.FindOne(eventId, res.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, tables[0].Id, newGuest.Table_ID)
+			assert.Equal(t, 7, newGuest.Acompanying_Guests)
+			assert.NotEqual(t, "", newGuest.TimeArrived)
+
+			table, err := tableService.FindById(eventId, tables[0].Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 8, table.Arrived)
+			assert.Equal(t, 2, table.Free)
+		})
+	}
 }
 
 func TestGuestCheckinTooManyGuestsError(t *testing.T) {
-	tableTestObj := new(MockTableRepo)
-	guestTestObj := new(MockGuestRepo)
-
-	var resDto dto.GuestResDto
-
-	guestRequest := dto.GuestReqDto{Name: "Hannah", Table_ID: 3, Acompanying_Guests: 12}
-
-	//Find the guest
-	expectedGuestRes := model.Guest{Id: 1, Name: "Hannah", Table_ID: 3, Acompanying_Guests: 5}
-
-	guestTestObj.guestMock.On("FindByName", guestRequest.Name).Return(expectedGuestRes, nil)
-
-	guest, err := guestTestObj.FindByName(guestRequest.Name)
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 1, guest.Id)
-	assert.Equal(t, "Hannah", guest.Name)
-	assert.Equal(t, 3, guest.Table_ID)
-	assert.Equal(t, 5, guest.Acompanying_Guests)
-
-	// Find the guest's table
-	expectedTableRes := model.Table{Id: 3, Capacity: 10}
-
-	tableTestObj.tableMock.On("FindTableById", guest.Table_ID).Return(expectedTableRes, nil)
-
-	tableRes, err := tableTestObj.FindTableById(guest.Table_ID)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, expectedTableRes, tableRes)
-	assert.Equal(t, 10, tableRes.Capacity)
-
-	if tableRes.Capacity < (guestRequest.Acompanying_Guests + 1) {
-		guestTestObj.guestMock.On("UpdateGuest", guest).Return(model.Guest{}, errors.New("There are too many guests"))
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
+			_, err := guestService.Save(eventId, dto.GuestReqDto{Name: "Hannah", Table_ID: tables[0].Id, Acompanying_Guests: 5}, service.Actor{})
+			assert.Nil(t, err)
+
+			resDto, err := guestService.Checkin(eventId, "Hannah", dto.CheckinReqDto{Acompanying_Guests: 12}, service.Actor{})
+
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrOverCapacity))
+			assert.Equal(t, dto.GuestResDto{}, resDto)
+
+			// Nothing was changed
+			guest, err := guestService.FindOne(eventId, "Hannah")
+			assert.Nil(t, err)
+			assert.Equal(t, 5, guest.Acompanying_Guests)
+			assert.Equal(t, "", guest.TimeArrived)
+		})
 	}
-
-	newGuest, err := guestTestObj.UpdateGuest(guest)
-
-	resDto.Name = newGuest.Name
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "There are too many guests", err.Error())
-	assert.Equal(t, dto.GuestResDto{}, resDto)
-
 }
 
 func TestGuestCheckoutSuccess(t *testing.T) {
-	tableTestObj := new(MockTableRepo)
-	guestTestObj := new(MockGuestRepo)
-
-	name := "Hannah"
-
-	//Find the guest
-	expectedGuestRes := model.Guest{Id: 1, Name: "Hannah", Table_ID: 3, Acompanying_Guests: 7}
-
-	guestTestObj.guestMock.On("FindByName", name).Return(expectedGuestRes, nil)
-
-	guest, err := guestTestObj.FindByName(name)
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 1, guest.Id)
-	assert.Equal(t, "Hannah", guest.Name)
-	assert.Equal(t, 3, guest.Table_ID)
-	assert.Equal(t, 7, guest.Acompanying_Guests)
-
-	// Find table by Id
-	tableTestId := 3
-
-	expectedTableRes := model.Table{Id: tableTestId, Capacity: 2}
-
-	tableTestObj.tableMock.On("FindTableById", tableTestId).Return(expectedTableRes, nil)
-
-	tableRes, err := tableTestObj.FindTableById(tableTestId)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, expectedTableRes, tableRes)
-	assert.Equal(t, 2, tableRes.Capacity)
-
-	// Update table capacity
-	tableRes.Capacity += (guest.Acompanying_Guests + 1)
-
-	tableTestObj.tableMock.On("UpdateTable", tableRes).Return(tableRes, nil)
-
-	newTableRes, err := tableTestObj.UpdateTable(tableRes)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 3, newTableRes.Id)
-	assert.Equal(t, 10, newTableRes.Capacity)
-
-	// Delete guest from db
-	guestTestObj.guestMock.On("Delete", guest).Return(nil)
-
-	err = guestTestObj.Delete(guest)
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
+			_, err := guestService.Save(eventId, dto.GuestReqDto{Name: "Hannah", Table_ID: tables[0].Id, Acompanying_Guests: 7}, service.Actor{})
+			assert.Nil(t, err)
+			_, err = guestService.Checkin(eventId, "Hannah", dto.CheckinReqDto{Acompanying_Guests: 7}, service.Actor{})
+			assert.Nil(t, err)
+
+			err = guestService.Checkout(eventId, "Hannah", service.Actor{})
+
+			assert.Nil(t, err)
+			// The seats are free again and Hannah is kept on the guest list as departed
+			table, err := tableService.FindById(eventId, tables[0].Id)
+			assert.Nil(t, err)
+			assert.Equal(t, 0, table.Arrived)
+			assert.Equal(t, 10, table.Free)
+			departed, err := guestService.GetDepartedGuests(eventId)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(departed))
+			assert.Equal(t, "Hannah", departed[0].Name)
+		})
+	}
 }
 
 func TestGuestCheckoutError(t *testing.T) {
-	tableTestObj := new(MockTableRepo)
-	guestTestObj := new(MockGuestRepo)
-
-	name := "Hannah"
-
-	//Find the guest
-	expectedGuestRes := model.Guest{Id: 1, Name: "Hannah", Table_ID: 3, Acompanying_Guests: 7}
-
-	guestTestObj.guestMock.On("FindByName", name).Return(expectedGuestRes, nil)
-
-	guest, err := guestTestObj.FindByName(name)
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 1, guest.Id)
-	assert.Equal(t, "Hannah", guest.Name)
-	assert.Equal(t, 3, guest.Table_ID)
-	assert.Equal(t, 7, guest.Acompanying_Guests)
-
-	// Find table by Id
-	tableTestId := 3
-
-	expectedTableRes := model.Table{Id: tableTestId, Capacity: 2}
-
-	tableTestObj.tableMock.On("FindTableById", tableTestId).Return(expectedTableRes, nil)
-
-	tableRes, err := tableTestObj.FindTableById(tableTestId)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, expectedTableRes, tableRes)
-	assert.Equal(t, 2, tableRes.Capacity)
-
-	// Update table capacity
-	tableRes.Capacity += (guest.Acompanying_Guests + 1)
-
-	tableTestObj.tableMock.On("UpdateTable", tableRes).Return(tableRes, nil)
-
-	newTableRes, err := tableTestObj.UpdateTable(tableRes)
-
-	tableTestObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 3, newTableRes.Id)
-	assert.Equal(t, 10, newTableRes.Capacity)
-
-	// Delete guest from db
-	guestTestObj.guestMock.On("Delete", guest).Return(errors.New("Could not delete guest"))
-
-	err = guestTestObj.Delete(guest)
-
-	guestTestObj.guestMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "Could not delete guest", err.Error())
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
+			_, err := guestService.Save(eventId, dto.GuestReqDto{Name: "Hannah", Table_ID: tables[0].Id, Acompanying_Guests: 7}, service.Actor{})
+			assert.Nil(t, err)
+
+			// Hannah has not arrived, so she can not leave
+			err = guestService.Checkout(eventId, "Hannah", service.Actor{})
+
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrGuestNotPresent))
+
+			err = guestService.Checkout(eventId, "Nobody", service.Actor{})
+			assert.True(t, errors.Is(err, service.ErrGuestNotFound))
+
+			// Nobody has left, the list is empty rather than missing
+			departed, err := guestService.GetDepartedGuests(eventId)
+			assert.Nil(t, err)
+			assert.NotNil(t, departed)
+			assert.Equal(t, 0, len(departed))
+		})
+	}
 }
@@ -5,258 +5,171 @@ import (
 	"testing"
 
 	"github.com/getground/tech-tasks/backend/pkg/dto"
-	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/tests/testutil"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 )
 
-// Initialising a mock object to act as the repo
-type MockTableRepo struct {
-	tableMock mock.Mock
-}
-
-// This is a mock function, it simulates the behaviour of the real function, so any arguments that will be passed in and what will be returned
-func (m *MockTableRepo) FindAll() ([]model.Table, error) {
-	args := m.tableMock.Called()
-	if args.Error(1) != nil {
-		return []model.Table{}, args.Error(1)
+// Adds a table of each capacity to the event, in order
+func saveTables(t *testing.T, tableService service.TableService, capacities ...int) []dto.TableResDto {
+	t.Helper()
+
+	var tables []dto.TableResDto
+	for _, v := range capacities {
+		table, err := tableService.Save(eventId, dto.TableReqDto{Capacity: v}, service.Actor{})
+		if err != nil {
+			t.Fatalf("could not add table: %v", err)
+		}
+		tables = append(tables, table)
 	}
-
-	return args.Get(0).([]model.Table), nil
-}
-
-func (m *MockTableRepo) FindById(id int) (model.Table, error) {
-	args := m.tableMock.Called(id)
-	if args.Error(1) != nil {
-		return model.Table{}, args.Error(1)
-	}
-
-	return args.Get(0).(model.Table), nil
-}
-
-func (m *MockTableRepo) Save(table model.Table) (model.Table, error) {
-	args := m.tableMock.Called(table)
-	if args.Error(1) != nil {
-		return model.Table{}, args.Error(1)
-	}
-	return table, nil
-}
-
-func (m *MockTableRepo) Update(table model.Table) (model.Table, error) {
-	args := m.tableMock.Called(table)
-	if args.Error(1) != nil {
-		return model.Table{}, args.Error(1)
-	}
-	return table, nil
+	return tables
 }
 
 func TestTableFindAllSuccess(t *testing.T) {
-
-	testObj := new(MockTableRepo)
-
-	var resDto dto.TableResDto
-	var resDtoArr []dto.TableResDto
-
-	expectedRes := []model.Table{
-		{
-			Id:       1,
-			Capacity: 10,
-		},
-		{
-			Id:       2,
-			Capacity: 2,
-		},
-		{
-			Id:       3,
-			Capacity: 2,
-		},
-		{
-			Id:       4,
-			Capacity: 5,
-		},
-		{
-			Id:       5,
-			Capacity: 15,
-		},
-	}
-
-	testObj.tableMock.On("FindAll").Return(expectedRes, nil)
-
-	testRes, err := testObj.FindAll()
-
-	for _, v := range testRes {
-		resDto.Id = v.Id
-		resDto.Capacity = v.Capacity
-
-		resDtoArr = append(resDtoArr, resDto)
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, _ := newServices(backend.Store)
+			saveTables(t, tableService, 10, 2, 2, 5, 15)
+
+			resDtoArr, page, err := tableService.FindAll(eventId, dto.TableListReqDto{})
+
+			assert.Nil(t, err)
+			assert.Equal(t, 5, len(resDtoArr))
+			assert.Equal(t, int64(5), page.Total)
+			assert.Equal(t, 15, resDtoArr[4].Capacity)
+			assert.Equal(t, 15, resDtoArr[4].Free)
+
+			// An event with no tables has an empty page rather than a missing one
+			none, _, err := tableService.FindAll(eventId+1, dto.TableListReqDto{})
+			assert.Nil(t, err)
+			assert.NotNil(t, none)
+			assert.Equal(t, 0, len(none))
+		})
 	}
-
-	testObj.tableMock.AssertExpectations(t)
-
-	assert.Equal(t, 5, len(resDtoArr))
-	assert.Equal(t, 15, resDtoArr[4].Capacity)
-	assert.Nil(t, err)
 }
 
 func TestTableFindAllError(t *testing.T) {
-	testObj := new(MockTableRepo)
-
-	var resDto dto.TableResDto
-	var resDtoArr []dto.TableResDto
-
-	testObj.tableMock.On("FindAll").Return([]model.Table{}, errors.New("Could not find tables"))
-
-	testRes, err := testObj.FindAll()
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, _ := newServices(backend.Store)
+			saveTables(t, tableService, 10)
 
-	for _, v := range testRes {
-		resDto.Id = v.Id
-		resDto.Capacity = v.Capacity
+			resDtoArr, _, err := tableService.FindAll(eventId, dto.TableListReqDto{Cursor: "not-a-cursor"})
 
-		resDtoArr = append(resDtoArr, resDto)
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrInvalidCursor))
+			assert.Equal(t, 0, len(resDtoArr))
+		})
 	}
-
-	testObj.tableMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "Could not find tables", err.Error())
-	assert.Equal(t, 0, len(resDtoArr))
 }
 
 func TestTableFindByIdSuccess(t *testing.T) {
-	testObj := new(MockTableRepo)
-
-	var resDto dto.TableResDto
-
-	testId := 1
-
-	expectedRes := model.Table{Id: testId, Capacity: 10}
-
-	testObj.tableMock.On("FindById", testId).Return(expectedRes, nil)
-
-	testRes, err := testObj.FindById(testId)
-
-	resDto.Id = testRes.Id
-	resDto.Capacity = testRes.Capacity
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, _ := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10)
 
-	testObj.tableMock.AssertExpectations(t)
+			resDto, err := tableService.FindById(eventId, tables[0].Id)
 
-	assert.Nil(t, err)
-	assert.Equal(t, 1, resDto.Id)
-	assert.Equal(t, 10, resDto.Capacity)
+			assert.Nil(t, err)
+			assert.Equal(t, tables[0].Id, resDto.Id)
+			assert.Equal(t, 10, resDto.Capacity)
+		})
+	}
 }
 
 func TestTableFindByIdError(t *testing.T) {
-	testObj := new(MockTableRepo)
-
-	var resDto dto.TableResDto
-	testId := 1
-
-	testObj.tableMock.On("FindById", testId).Return(model.Table{}, errors.New("Could not find table"))
-
-	testRes, err := testObj.FindById(testId)
-
-	resDto.Id = testRes.Id
-	resDto.Capacity = testRes.Capacity
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, _ := newServices(backend.Store)
 
-	testObj.tableMock.AssertExpectations(t)
+			resDto, err := tableService.FindById(eventId, 1)
 
-	assert.NotNil(t, err)
-	assert.Equal(t, "Could not find table", err.Error())
-	assert.Equal(t, dto.TableResDto{}, resDto)
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrTableNotFound))
+			assert.Equal(t, dto.TableResDto{}, resDto)
+		})
+	}
 }
 
 func TestTableSaveSuccess(t *testing.T) {
-	testObj := new(MockTableRepo)
-
-	var res dto.TableResDto
-	table := model.Table{Id: 1, Capacity: 15}
-
-	testObj.tableMock.On("Save", table).Return(table, nil)
-
-	testRes, err := testObj.Save(table)
-
-	res.Id = testRes.Id
-	res.Capacity = testRes.Capacity
-
-	testObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 1, res.Id)
-	assert.Equal(t, 15, res.Capacity)
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, _ := newServices(backend.Store)
+
+			res, err := tableService.Save(eventId, dto.TableReqDto{Capacity: 15}, service.Actor{})
+
+			assert.Nil(t, err)
+			assert.NotEqual(t, 0, res.Id)
+			assert.Equal(t, 15, res.Capacity)
+			assert.Equal(t, 15, res.Free)
+
+			// The table is stored, not only answered with
+			stored, err := tableService.FindById(eventId, res.Id)
+			assert.Nil(t, err)
+			assert.Equal(t, res, stored)
+		})
+	}
 }
 
 func TestTableSaveError(t *testing.T) {
-	testObj := new(MockTableRepo)
-
-	var res dto.TableResDto
-	table := model.Table{Id: 1, Capacity: 15}
-
-	testObj.tableMock.On("Save", table).Return(model.Table{}, errors.New("Could not create table"))
-
-	testRes, err := testObj.Save(table)
-
-	res.Id = testRes.Id
-	res.Capacity = testRes.Capacity
-
-	testObj.tableMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "Could not create table", err.Error())
-	assert.Equal(t, dto.TableResDto{}, res)
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 15)
+			_, err := guestService.Save(eventId, dto.GuestReqDto{Name: "John", Table_ID: tables[0].Id, Acompanying_Guests: 9}, service.Actor{})
+			assert.Nil(t, err)
+
+			// The table can not shrink below the seats John's party reserved
+			capacity := 5
+			res, err := tableService.Update(eventId, tables[0].Id, dto.TablePatchReqDto{Capacity: &capacity}, service.Actor{})
+
+			assert.NotNil(t, err)
+			assert.True(t, errors.Is(err, service.ErrCapacityBelowReservations))
+			assert.Equal(t, dto.TableResDto{}, res)
+			stored, _ := tableService.FindById(eventId, tables[0].Id)
+			assert.Equal(t, 15, stored.Capacity)
+		})
+	}
 }
 
 func TestTableCheckSpaceSuccess(t *testing.T) {
-	testObj := new(MockTableRepo)
-
-	space := 0
-
-	expectedRes := []model.Table{
-		{
-			Id:       1,
-			Capacity: 10,
-		},
-		{
-			Id:       2,
-			Capacity: 2,
-		},
-		{
-			Id:       3,
-			Capacity: 2,
-		},
-		{
-			Id:       4,
-			Capacity: 5,
-		},
-		{
-			Id:       5,
-			Capacity: 15,
-		},
-	}
-
-	testObj.tableMock.On("FindAll").Return(expectedRes, nil)
-
-	testRes, err := testObj.FindAll()
-
-	for _, v := range testRes {
-		space += v.Capacity
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, guestService := newServices(backend.Store)
+			tables := saveTables(t, tableService, 10, 2, 2, 5, 15)
+
+			space, ok := tableService.CheckSpace(eventId)
+
+			assert.True(t, ok)
+			assert.Equal(t, 34, space.SeatsEmpty)
+			assert.Equal(t, 5, len(space.Tables))
+
+			// Only the guests who have arrived take seats
+			_, err := guestService.Save(eventId, dto.GuestReqDto{Name: "John", Table_ID: tables[0].Id, Acompanying_Guests: 3}, service.Actor{})
+			assert.Nil(t, err)
+			space, _ = tableService.CheckSpace(eventId)
+			assert.Equal(t, 34, space.SeatsEmpty)
+			_, err = guestService.Checkin(eventId, "John", dto.CheckinReqDto{Acompanying_Guests: 3}, service.Actor{})
+			assert.Nil(t, err)
+			space, _ = tableService.CheckSpace(eventId)
+			assert.Equal(t, 30, space.SeatsEmpty)
+		})
 	}
-
-	testObj.tableMock.AssertExpectations(t)
-
-	assert.Nil(t, err)
-	assert.Equal(t, 34, space)
 }
 
 func TestTableCheckSpaceError(t *testing.T) {
-	testObj := new(MockTableRepo)
-
-	testObj.tableMock.On("FindAll").Return([]model.Table{}, errors.New("Could not find tables"))
-
-	testRes, err := testObj.FindAll()
-
-	testObj.tableMock.AssertExpectations(t)
-
-	assert.NotNil(t, err)
-	assert.Equal(t, "Could not find tables", err.Error())
-	assert.Equal(t, []model.Table{}, testRes)
+	for _, backend := range testutil.Backends(t) {
+		t.Run(backend.Name, func(t *testing.T) {
+			tableService, _ := newServices(backend.Store)
+			saveTables(t, tableService, 10)
+
+			// Another event's tables are not counted
+			space, ok := tableService.CheckSpace(eventId + 1)
+
+			assert.True(t, ok)
+			assert.Equal(t, 0, space.SeatsEmpty)
+			assert.Equal(t, 0, len(space.Tables))
+		})
+	}
 }
@@ -0,0 +1,55 @@
+package testutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm/logger"
+)
+
+// The database the MySQL tests are run against, they are skipped when it is not set.
+// Every table in it is dropped, so it must not be one whose data is wanted.
+const MySQLEnv = "TEST_MYSQL_DSN"
+
+// Returns an empty store on the MySQL database named by TEST_MYSQL_DSN, or skips the test
+func MySQL(t *testing.T) Backend {
+	t.Helper()
+
+	dsn := os.Getenv(MySQLEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", MySQLEnv)
+	}
+
+	db, err := repository.NewDatabase(repository.DriverMySQL, dsn, logger.Silent)
+	if err != nil {
+		t.Fatalf("could not connect to mysql: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("could not connect to mysql: %v", err)
+	}
+	// Turning off the foreign key checks only lasts for the connection
+	sqlDB.SetMaxOpenConns(1)
+	defer sqlDB.Close()
+
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		t.Fatalf("could not list the mysql tables: %v", err)
+	}
+	if err := db.Exec("SET FOREIGN_KEY_CHECKS = 0").Error; err != nil {
+		t.Fatalf("could not empty the mysql database: %v", err)
+	}
+	for _, v := range tables {
+		if err := db.Migrator().DropTable(v); err != nil {
+			t.Fatalf("could not empty the mysql database: %v", err)
+		}
+	}
+
+	store, err := repository.NewStore(repository.DriverMySQL, dsn, logger.Silent)
+	if err != nil {
+		t.Fatalf("could not open mysql store: %v", err)
+	}
+
+	return Backend{Name: repository.DriverMySQL, Store: store}
+}
@@ -0,0 +1,31 @@
+// Helpers shared by the tests
+package testutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"gorm.io/gorm/logger"
+)
+
+// A storage backend that the tests are run against
+type Backend struct {
+	Name  string
+	Store repository.Store
+}
+
+// Returns a fresh, empty store for every backend that can run without a database server
+func Backends(t *testing.T) []Backend {
+	t.Helper()
+
+	sqliteStore, err := repository.NewStore(repository.DriverSQLite, filepath.Join(t.TempDir(), "party.db"), logger.Silent)
+	if err != nil {
+		t.Fatalf("could not open sqlite store: %v", err)
+	}
+
+	return []Backend{
+		{Name: repository.DriverMemory, Store: repository.NewMemoryStore()},
+		{Name: repository.DriverSQLite, Store: sqliteStore},
+	}
+}
@@ -0,0 +1,186 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getground/tech-tasks/backend/pkg/dto"
+	"github.com/getground/tech-tasks/backend/pkg/model"
+	"github.com/getground/tech-tasks/backend/pkg/repository"
+	"github.com/getground/tech-tasks/backend/pkg/service"
+	"github.com/getground/tech-tasks/backend/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+const secret = "0123456789abcdef"
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"guest.checked_in"}`)
+	signature := webhook.Sign(secret, 1700000000, body)
+
+	assert.Equal(t, 71, len(signature))
+	assert.True(t, webhook.Verify(secret, 1700000000, body, signature))
+	assert.False(t, webhook.Verify(secret, 1700000001, body, signature))
+	assert.False(t, webhook.Verify("another-secret-0123", 1700000000, body, signature))
+	assert.False(t, webhook.Verify(secret, 1700000000, []byte(`{"type":"guest.checked_out"}`), signature))
+}
+
+// A webhook receiver that keeps what it was sent and answers with the status it is set to
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	payloads []webhook.Payload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	timestamp, _ := strconv.ParseInt(req.Header.Get(webhook.HeaderTimestamp), 10, 64)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !webhook.Verify(secret, timestamp, body, req.Header.Get(webhook.HeaderSignature)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var payload webhook.Payload
+	json.Unmarshal(body, &payload)
+	if payload.Type != req.Header.Get(webhook.HeaderEvent) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.payloads = append(r.payloads, payload)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) types() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []string
+	for _, v := range r.payloads {
+		types = append(types, v.Type)
+	}
+	return types
+}
+
+func TestDeliverDue(t *testing.T) {
+	store := repository.NewMemoryStore()
+	store.Events.Save(model.Event{Id: 1, Name: "Gala"})
+	store.Tables.Save(model.Table{Id: 1, Event_ID: 1, Capacity: 2})
+
+	arrivals := &receiver{status: http.StatusInternalServerError}
+	arrivalsSrv := httptest.NewServer(arrivals)
+	defer arrivalsSrv.Close()
+	departures := &receiver{status: http.StatusServiceUnavailable}
+	departuresSrv := httptest.NewServer(departures)
+	defer departuresSrv.Close()
+
+	// The deliveries are queued at the time now, the clock starts after that
+	clock := time.Now().Add(time.Minute)
+	webhookService := service.NewWebhookService(store.Webhooks, store.Deliveries, store.UnitOfWork, service.WebhookOptions{
+		MaxAttempts: 2,
+		RetryDelay:  10 * time.Second,
+		Timeout:     time.Second,
+		Now:         func() time.Time { return clock },
+	})
+	guestService := service.NewGuestService(store.Events, store.Guests, store.Tables, store.Visits, store.UnitOfWork, service.GuestOptions{})
+
+	hook, err := webhookService.Save(1, dto.WebhookReqDto{URL: arrivalsSrv.URL, Secret: secret, Events: []string{service.NoticeGuestCheckedIn, service.NoticeTableFull, service.NoticeTableFull}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{service.NoticeGuestCheckedIn, service.NoticeTableFull}, hook.Events)
+	other, err := webhookService.Save(1, dto.WebhookReqDto{URL: departuresSrv.URL, Secret: secret, Events: []string{service.NoticeGuestCheckedOut}})
+	assert.Nil(t, err)
+
+	_, err = guestService.Save(1, dto.GuestReqDto{Name: "John", Table_ID: 1, Acompanying_Guests: 1}, service.Actor{})
+	assert.Nil(t, err)
+	// Neither webhook wants guest.created
+	n, err := webhookService.DeliverDue()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	// John and his guest take both seats, so the table is full too
+	_, err = guestService.Checkin(1, "John", dto.CheckinReqDto{Acompanying_Guests: 1}, service.Actor{})
+	assert.Nil(t, err)
+
+	n, err = webhookService.DeliverDue()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	deliveries, page, err := webhookService.FindDeliveries(1, hook.Id, dto.DeliveryListReqDto{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), page.Total)
+	for _, v := range deliveries {
+		assert.Equal(t, model.DeliveryPending, v.Status)
+		assert.Equal(t, 1, v.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, v.ResponseStatus)
+		assert.Contains(t, v.Error, "500")
+	}
+
+	// Nothing is due again until the retry delay has passed
+	n, err = webhookService.DeliverDue()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	arrivals.mu.Lock()
+	arrivals.status = http.StatusNoContent
+	arrivals.mu.Unlock()
+	clock = clock.Add(10 * time.Second)
+	n, err = webhookService.DeliverDue()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	deliveries, _, err = webhookService.FindDeliveries(1, hook.Id, dto.DeliveryListReqDto{Sort: "id"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(deliveries))
+	assert.Equal(t, service.NoticeGuestCheckedIn, deliveries[0].Type)
+	assert.Equal(t, service.NoticeTableFull, deliveries[1].Type)
+	for _, v := range deliveries {
+		assert.Equal(t, model.DeliveryDelivered, v.Status)
+		assert.Equal(t, 2, v.Attempts)
+		assert.Equal(t, http.StatusNoContent, v.ResponseStatus)
+		assert.Equal(t, "", v.Error)
+		assert.NotEqual(t, "", v.Delivered)
+	}
+	assert.ElementsMatch(t, []string{"guest.checked_in", "table.full", "guest.checked_in", "table.full"}, arrivals.types())
+
+	var full dto.TableResDto
+	assert.Nil(t, json.Unmarshal(deliveries[1].Payload, &struct {
+		Data *dto.TableResDto `json:"data"`
+	}{&full}))
+	assert.Equal(t, 1, full.Id)
+	assert.Equal(t, 0, full.Free)
+
+	// The other webhook gives up after its two attempts
+	assert.Nil(t, guestService.Checkout(1, "John", service.Actor{}))
+	n, err = webhookService.DeliverDue()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	clock = clock.Add(10 * time.Second)
+	n, err = webhookService.DeliverDue()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	clock = clock.Add(time.Hour)
+	n, err = webhookService.DeliverDue()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	deliveries, _, err = webhookService.FindDeliveries(1, other.Id, dto.DeliveryListReqDto{Status: model.DeliveryFailed})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, service.NoticeGuestCheckedOut, deliveries[0].Type)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].ResponseStatus)
+	assert.Equal(t, 2, len(departures.types()))
+
+	// A webhook that is deleted takes its deliveries with it
+	assert.Nil(t, webhookService.Delete(1, other.Id))
+	_, _, err = webhookService.FindDeliveries(1, other.Id, dto.DeliveryListReqDto{})
+	assert.ErrorIs(t, err, service.ErrWebhookNotFound)
+	hooks, err := webhookService.FindAll(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(hooks))
+}